@@ -4,18 +4,21 @@ package main
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
 	"openlora/adapters/internal/api"
 	"openlora/adapters/internal/store"
+	"openlora/core/logging"
+	"openlora/core/svcauth"
 
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	log.Println("🔌 OpenLoRA Adapter Registry starting...")
+	logging.Setup("adapters")
+	slog.Info("🔌 OpenLoRA Adapter Registry starting...")
 
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
@@ -24,11 +27,16 @@ func main() {
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 	defer db.Close()
 
 	adapterStore := store.NewAdapterStore(db)
+	if tmpl, ok := os.LookupEnv("ADAPTER_PATH_TEMPLATE"); ok {
+		if err := adapterStore.SetPathTemplate(tmpl); err != nil {
+			logging.Fatal("Invalid ADAPTER_PATH_TEMPLATE", "error", err)
+		}
+	}
 	server := api.NewServer(adapterStore)
 
 	port := os.Getenv("PORT")
@@ -36,8 +44,8 @@ func main() {
 		port = "8084"
 	}
 
-	log.Printf("🌐 Listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	slog.Info("🌐 Listening", "port", port)
+	if err := http.ListenAndServe(":"+port, svcauth.Middleware(os.Getenv(svcauth.SecretEnv), server)); err != nil {
+		logging.Fatal("Server failed", "error", err)
 	}
 }
@@ -2,11 +2,18 @@
 package api
 
 import (
+	"crypto/ed25519"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"openlora/adapters/internal/store"
+	"openlora/core/jsonbody"
 
 	"github.com/google/uuid"
 )
@@ -27,9 +34,16 @@ func NewServer(s *store.AdapterStore) *Server {
 func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/health", s.handleHealth)
 	s.mux.HandleFunc("/adapters", s.handleAdapters)
+	s.mux.HandleFunc("/adapters/upload", s.handleUpload)
+	s.mux.HandleFunc("/adapters/search", s.handleSearch)
+	s.mux.HandleFunc("/adapters/import", s.handleImport)
+	s.mux.HandleFunc("/adapters/count", s.handleCount)
 	s.mux.HandleFunc("/adapters/", s.handleAdapterByID)
 	s.mux.HandleFunc("/adapters/name/", s.handleAdapterByName)
 	s.mux.HandleFunc("/compatible", s.handleCompatible)
+	s.mux.HandleFunc("/compatibility-rules", s.handleRules)
+	s.mux.HandleFunc("/compatibility-rules/", s.handleRuleByID)
+	s.mux.HandleFunc("/signing-keys", s.handleSigningKeys)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -40,14 +54,36 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// handleCount reports how many adapters exist, without listing them.
+func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	n, err := s.store.Count()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"count": n})
+}
+
 func (s *Server) handleAdapters(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	switch r.Method {
 	case http.MethodGet:
-		ownerID := r.URL.Query().Get("owner_id")
-		status := store.AdapterStatus(r.URL.Query().Get("status"))
-		adapters, err := s.store.List(ownerID, status, 100)
+		q := r.URL.Query()
+		adapters, err := s.store.List(store.ListFilter{
+			OwnerID:        q.Get("owner_id"),
+			Status:         store.AdapterStatus(q.Get("status")),
+			BaseModel:      q.Get("base_model"),
+			Task:           q.Get("task"),
+			IncludeDeleted: q.Get("include_deleted") == "true",
+			Limit:          100,
+		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -56,7 +92,7 @@ func (s *Server) handleAdapters(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var a store.Adapter
-		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		if err := jsonbody.Decode(w, r, &a); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -65,19 +101,129 @@ func (s *Server) handleAdapters(w http.ResponseWriter, r *http.Request) {
 		a.CreatedAt = time.Now()
 		a.UpdatedAt = time.Now()
 
-		if err := s.store.Register(&a); err != nil {
+		err := s.store.Register(&a)
+		if errors.Is(err, store.ErrVersionExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(a)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleUpload registers an adapter together with its artifact. The body is
+// multipart: a "metadata" part holding the adapter JSON, followed by an
+// "artifact" part whose SHA-256 must match the metadata checksum.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var a *store.Adapter
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch part.FormName() {
+		case "metadata":
+			a = &store.Adapter{}
+			if err := json.NewDecoder(part).Decode(a); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case "artifact":
+			if a == nil {
+				http.Error(w, "metadata part must precede artifact", http.StatusBadRequest)
+				return
+			}
+			a.ID = uuid.New().String()
+			a.Status = store.StatusActive
+			a.CreatedAt = time.Now()
+			a.UpdatedAt = time.Now()
+
+			err := s.store.RegisterVerified(a, part)
+			if errors.Is(err, store.ErrChecksumMismatch) {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			if errors.Is(err, store.ErrVersionExists) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(a)
+			return
+		}
+	}
+
+	http.Error(w, "artifact part required", http.StatusBadRequest)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	q := r.URL.Query()
+	adapters, err := s.store.Search(q.Get("owner_id"), q.Get("q"), q["tag"], 100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adapters)
 }
 
 func (s *Server) handleAdapterByID(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Path[len("/adapters/"):]
+	if base, ok := strings.CutSuffix(id, "/dependencies"); ok {
+		s.handleDependencies(w, r, base)
+		return
+	}
+	if base, ok := strings.CutSuffix(id, "/restore"); ok {
+		s.handleRestore(w, r, base)
+		return
+	}
+	if base, ok := strings.CutSuffix(id, "/download"); ok {
+		s.handleDownload(w, r, base)
+		return
+	}
+	if base, ok := strings.CutSuffix(id, "/signatures"); ok {
+		s.handleSignatures(w, r, base)
+		return
+	}
+	if base, ok := strings.CutSuffix(id, "/verify"); ok {
+		s.handleVerify(w, r, base)
+		return
+	}
+	if base, ok := strings.CutSuffix(id, "/export"); ok {
+		s.handleExport(w, r, base)
+		return
+	}
 	if id == "" {
 		http.Error(w, "ID required", http.StatusBadRequest)
 		return
@@ -97,7 +243,7 @@ func (s *Server) handleAdapterByID(w http.ResponseWriter, r *http.Request) {
 		var update struct {
 			Status store.AdapterStatus `json:"status"`
 		}
-		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		if err := jsonbody.Decode(w, r, &update); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -108,13 +254,278 @@ func (s *Server) handleAdapterByID(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
 
+	case http.MethodDelete:
+		err := s.store.SoftDelete(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	bundle, err := s.store.Export(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-v%d.json"`, bundle.Adapter.Name, bundle.Adapter.Version))
+	json.NewEncoder(w).Encode(bundle)
+}
+
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var bundle store.Bundle
+	if err := jsonbody.Decode(w, r, &bundle); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a, err := s.store.Import(&bundle)
+	switch {
+	case errors.Is(err, store.ErrInvalidBundle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, store.ErrVersionExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+// handleDownload counts a pull of the adapter and returns where to fetch
+// the artifact from.
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+
+	path, downloads, err := s.store.IncrementDownloads(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":           id,
+		"storage_path": path,
+		"downloads":    downloads,
+	})
+}
+
+// handleSigningKeys registers an ed25519 public key (base64 in JSON) that
+// adapter signatures can be verified against.
+func (s *Server) handleSigningKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		PublicKey []byte `json:"public_key"`
+	}
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.PublicKey) != ed25519.PublicKeySize {
+		http.Error(w, "public_key must be a base64 ed25519 public key", http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.store.RegisterPublicKey(req.PublicKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// handleSignatures attaches a client-produced signature to an adapter.
+func (s *Server) handleSignatures(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var sig store.Signature
+	if err := jsonbody.Decode(w, r, &sig); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.Get(id); err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	sig.ID = uuid.New().String()
+	sig.AdapterID = id
+	if sig.Algo == "" {
+		sig.Algo = store.AlgoEd25519
+	}
+	sig.SignedAt = time.Now()
+
+	err := s.store.AddSignature(&sig)
+	if errors.Is(err, store.ErrUnknownKey) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sig)
+}
+
+// handleVerify reports whether the adapter's latest signature still
+// matches its checksum.
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	result := map[string]interface{}{"adapter_id": id, "verified": false}
+	ok, err := s.store.Verify(id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrNotSigned), errors.Is(err, store.ErrUnknownKey):
+		result["error"] = err.Error()
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	default:
+		result["verified"] = ok
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	err := s.store.Restore(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not found or not deleted", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "restored"})
+}
+
+// handleDependencies lists or adds an adapter's dependencies. GET with
+// resolve=true returns the load order instead.
+func (s *Server) handleDependencies(w http.ResponseWriter, r *http.Request, id string) {
+	if id == "" {
+		http.Error(w, "ID required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("resolve") == "true" {
+			order, err := s.store.ResolveLoadOrder(id)
+			if errors.Is(err, store.ErrDependencyCycle) || errors.Is(err, store.ErrDependencyConflict) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string][]string{"load_order": order})
+			return
+		}
+
+		deps, err := s.store.GetDependencies(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deps)
+
+	case http.MethodPost:
+		var d store.Dependency
+		if err := jsonbody.Decode(w, r, &d); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d.AdapterID = id
+
+		err := s.store.AddDependency(&d)
+		if errors.Is(err, store.ErrInvalidDependency) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
 	}
 }
 
 func (s *Server) handleAdapterByName(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Path[len("/adapters/name/"):]
+	if base, ok := strings.CutSuffix(name, "/versions"); ok {
+		s.handleVersions(w, r, base)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
 	adapter, err := s.store.GetByName(name)
 	if err != nil {
 		http.Error(w, "Not found", http.StatusNotFound)
@@ -124,7 +535,56 @@ func (s *Server) handleAdapterByName(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(adapter)
 }
 
+func (s *Server) handleVersions(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		http.Error(w, "name required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		versions, err := s.store.ListVersions(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(versions)
+
+	case http.MethodPost:
+		var a store.Adapter
+		if err := jsonbody.Decode(w, r, &a); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.ID = uuid.New().String()
+		a.Status = store.StatusActive
+		a.CreatedAt = time.Now()
+		a.UpdatedAt = time.Now()
+
+		err := s.store.PublishVersion(name, &a)
+		if errors.Is(err, store.ErrVersionExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
 func (s *Server) handleCompatible(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
 	baseModel := r.URL.Query().Get("base_model")
 	adapters, err := s.store.GetCompatible(baseModel)
 	if err != nil {
@@ -134,3 +594,120 @@ func (s *Server) handleCompatible(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(adapters)
 }
+
+func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := s.store.ListRules()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(rules)
+
+	case http.MethodPost:
+		var rule store.CompatibilityRule
+		if err := jsonbody.Decode(w, r, &rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateRule(&rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule.ID = uuid.New().String()
+		rule.CreatedAt = time.Now()
+		rule.UpdatedAt = time.Now()
+
+		if err := s.store.CreateRule(&rule); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(rule)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (s *Server) handleRuleByID(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[len("/compatibility-rules/"):]
+	if id == "" {
+		http.Error(w, "ID required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := s.store.GetRule(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodPut:
+		var rule store.CompatibilityRule
+		if err := jsonbody.Decode(w, r, &rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateRule(&rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule.ID = id
+		rule.UpdatedAt = time.Now()
+
+		err := s.store.UpdateRule(&rule)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+
+	case http.MethodDelete:
+		err := s.store.DeleteRule(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func validateRule(rule *store.CompatibilityRule) error {
+	if rule.BaseModelFamily == "" {
+		return errors.New("base_model_family required")
+	}
+	if rule.MinRank < 0 || rule.MaxRank < 0 {
+		return errors.New("rank bounds must be non-negative")
+	}
+	if rule.MaxRank > 0 && rule.MinRank > rule.MaxRank {
+		return errors.New("min_rank exceeds max_rank")
+	}
+	return nil
+}
+
+// methodNotAllowed responds with 405 and an Allow header listing the
+// methods the route supports.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
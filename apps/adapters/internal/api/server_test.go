@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"openlora/adapters/internal/store"
+)
+
+func TestMethodNotAllowedListsAllowedMethods(t *testing.T) {
+	// Method checks run before any store access, so no database is needed
+	srv := NewServer(store.NewAdapterStore(nil))
+
+	tests := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{http.MethodPost, "/adapters/count", "GET"},
+		{http.MethodDelete, "/adapters", "GET, POST"},
+		{http.MethodGet, "/adapters/upload", "POST"},
+		{http.MethodPost, "/adapters/search", "GET"},
+		{http.MethodGet, "/adapters/import", "POST"},
+		{http.MethodPut, "/adapters/a1", "GET, PATCH, DELETE"},
+		{http.MethodPost, "/adapters/a1/export", "GET"},
+		{http.MethodDelete, "/adapters/a1/download", "GET, POST"},
+		{http.MethodGet, "/adapters/a1/signatures", "POST"},
+		{http.MethodPost, "/adapters/a1/verify", "GET"},
+		{http.MethodGet, "/adapters/a1/restore", "POST"},
+		{http.MethodPut, "/adapters/a1/dependencies", "GET, POST"},
+		{http.MethodPost, "/adapters/name/llama-chat", "GET"},
+		{http.MethodDelete, "/adapters/name/llama-chat/versions", "GET, POST"},
+		{http.MethodPost, "/compatible", "GET"},
+		{http.MethodPatch, "/compatibility-rules", "GET, POST"},
+		{http.MethodPost, "/compatibility-rules/r1", "GET, PUT, DELETE"},
+		{http.MethodGet, "/signing-keys", "POST"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s = %d with Allow %q, want 405 with %q", tt.method, tt.path, rec.Code, rec.Header().Get("Allow"), tt.allow)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BundleFormat is the version of the export bundle layout.
+const BundleFormat = 1
+
+// maxLineageDepth bounds how far Export follows ParentID links.
+const maxLineageDepth = 100
+
+// ErrInvalidBundle is returned by Import for a malformed bundle.
+var ErrInvalidBundle = errors.New("invalid adapter bundle")
+
+// ArtifactManifest says where an adapter's weights live and how to check
+// them.
+type ArtifactManifest struct {
+	StoragePath string `json:"storage_path"`
+	Checksum    string `json:"checksum"`
+}
+
+// Bundle is a self-contained export of an adapter: its metadata, config
+// and metrics, the chain of versions it was derived from (nearest parent
+// first), and its artifact manifest.
+type Bundle struct {
+	Format     int              `json:"format"`
+	Adapter    *Adapter         `json:"adapter"`
+	Lineage    []*Adapter       `json:"lineage"`
+	Artifact   ArtifactManifest `json:"artifact"`
+	ExportedAt time.Time        `json:"exported_at"`
+}
+
+// Export builds a bundle for adapter id.
+func (s *AdapterStore) Export(id string) (*Bundle, error) {
+	a, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{
+		Format:     BundleFormat,
+		Adapter:    a,
+		Lineage:    []*Adapter{},
+		Artifact:   ArtifactManifest{StoragePath: a.StoragePath, Checksum: a.Checksum},
+		ExportedAt: time.Now(),
+	}
+
+	seen := map[string]bool{a.ID: true}
+	for parentID := a.ParentID; parentID != ""; {
+		if seen[parentID] || len(b.Lineage) >= maxLineageDepth {
+			return nil, fmt.Errorf("lineage of %s does not terminate at %s", id, parentID)
+		}
+		seen[parentID] = true
+
+		parent, err := s.Get(parentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The chain was cut by a hard delete; export what remains
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b.Lineage = append(b.Lineage, parent)
+		parentID = parent.ParentID
+	}
+
+	return b, nil
+}
+
+// validate checks that the bundle is complete and that its lineage is a
+// single chain ending at the adapter.
+func (b *Bundle) validate() error {
+	if b.Format != BundleFormat {
+		return fmt.Errorf("%w: unsupported format %d", ErrInvalidBundle, b.Format)
+	}
+	if b.Adapter == nil || b.Adapter.ID == "" || b.Adapter.Name == "" {
+		return fmt.Errorf("%w: missing adapter", ErrInvalidBundle)
+	}
+
+	child := b.Adapter
+	for i, parent := range b.Lineage {
+		if parent == nil || parent.ID == "" {
+			return fmt.Errorf("%w: lineage entry %d is empty", ErrInvalidBundle, i)
+		}
+		if child.ParentID != parent.ID {
+			return fmt.Errorf("%w: %s's parent is %q, but lineage continues with %s", ErrInvalidBundle, child.ID, child.ParentID, parent.ID)
+		}
+		child = parent
+	}
+	return nil
+}
+
+// Import recreates a bundle's adapter and any of its lineage missing from
+// this registry, keeping the original IDs so parent links survive.
+// Ancestors that already exist are left untouched. The artifact manifest
+// overrides the adapter's storage path and checksum.
+func (s *AdapterStore) Import(b *Bundle) (*Adapter, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	a := *b.Adapter
+	if b.Artifact.StoragePath != "" {
+		a.StoragePath = b.Artifact.StoragePath
+	}
+	if b.Artifact.Checksum != "" {
+		a.Checksum = b.Artifact.Checksum
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Oldest ancestor first so each parent exists before its child; a
+	// chain cut by a hard delete starts without a parent
+	chain := append([]*Adapter{&a}, b.Lineage...)
+	for i := len(chain) - 1; i >= 0; i-- {
+		node := *chain[i]
+		if i == len(chain)-1 && node.ParentID != "" {
+			var exists bool
+			if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM adapters WHERE id = $1)`, node.ParentID).Scan(&exists); err != nil {
+				return nil, err
+			}
+			if !exists {
+				node.ParentID = ""
+			}
+		}
+
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM adapters WHERE id = $1)`, node.ID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			if i == 0 {
+				return nil, fmt.Errorf("%w: %s v%d", ErrVersionExists, node.Name, node.Version)
+			}
+			continue
+		}
+		if err := insertAdapter(tx, &node); err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			a = node
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// lineageChain returns three versions of one adapter, each derived from the
+// one before.
+func lineageChain() (v1, v2, v3 *Adapter) {
+	v1 = &Adapter{ID: "a1", Name: "sql-coder", Version: 1, BaseModel: "llama-3-8b", Status: StatusActive, Task: "CAUSAL_LM",
+		OwnerID: "alice", StoragePath: "alice/sql-coder/v1", Checksum: "sum1",
+		Config: map[string]interface{}{"rank": 8}, Metrics: map[string]float64{"eval_loss": 0.9}, Tags: []string{"sql"}}
+	v2 = &Adapter{ID: "a2", Name: "sql-coder", Version: 2, BaseModel: "llama-3-8b", Status: StatusActive, Task: "CAUSAL_LM",
+		OwnerID: "alice", StoragePath: "alice/sql-coder/v2", Checksum: "sum2", ParentID: "a1",
+		Config: map[string]interface{}{"rank": 16}, Metrics: map[string]float64{"eval_loss": 0.7}, Tags: []string{"sql"}}
+	v3 = &Adapter{ID: "a3", Name: "sql-coder", Version: 3, BaseModel: "llama-3-8b", Status: StatusActive, Task: "CAUSAL_LM",
+		OwnerID: "alice", StoragePath: "alice/sql-coder/v3", Checksum: "sum3", ParentID: "a2",
+		Config: map[string]interface{}{"rank": 16, "alpha": 32}, Metrics: map[string]float64{"eval_loss": 0.5}, Tags: []string{"sql", "prod"}}
+	return v1, v2, v3
+}
+
+// expectExists expects Import's existence check for id.
+func expectExists(mock sqlmock.Sqlmock, id string, exists bool) {
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM adapters WHERE id = \$1\)`).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+}
+
+// expectInsert expects a's row to be inserted with the given parent, which
+// is nil for none.
+func expectInsert(mock sqlmock.Sqlmock, a *Adapter, parent interface{}) {
+	configJSON, _ := json.Marshal(a.Config)
+	metricsJSON, _ := json.Marshal(a.Metrics)
+	tagsJSON, _ := json.Marshal(a.Tags)
+	mock.ExpectExec(`INSERT INTO adapters`).
+		WithArgs(a.ID, a.Name, a.Version, a.BaseModel, string(a.Status), a.Task, a.OwnerID, a.StoragePath, a.Checksum,
+			configJSON, metricsJSON, tagsJSON, parent, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestExportImportRoundTripPreservesLineage(t *testing.T) {
+	v1, v2, v3 := lineageChain()
+
+	src, srcMock := newMockStore(t)
+	for _, a := range []*Adapter{v3, v2, v1} {
+		srcMock.ExpectQuery(`FROM adapters WHERE id = \$1`).WithArgs(a.ID).WillReturnRows(adapterRows(a))
+	}
+	bundle, err := src.Export("a3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Lineage) != 2 || bundle.Lineage[0].ID != "a2" || bundle.Lineage[1].ID != "a1" {
+		t.Fatalf("lineage = %+v, want a2 then a1", bundle.Lineage)
+	}
+	if bundle.Artifact != (ArtifactManifest{StoragePath: "alice/sql-coder/v3", Checksum: "sum3"}) {
+		t.Fatalf("artifact = %+v", bundle.Artifact)
+	}
+
+	// The bundle travels as JSON
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received Bundle
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty registry gets every version, oldest first, with the
+	// original IDs and parent links
+	dst, dstMock := newMockStore(t)
+	dstMock.ExpectBegin()
+	expectExists(dstMock, "a1", false)
+	expectInsert(dstMock, v1, nil)
+	expectExists(dstMock, "a2", false)
+	expectInsert(dstMock, v2, "a1")
+	expectExists(dstMock, "a3", false)
+	expectInsert(dstMock, v3, "a2")
+	dstMock.ExpectCommit()
+
+	got, err := dst.Import(&received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "a3" || got.ParentID != "a2" || got.Metrics["eval_loss"] != 0.5 || got.Config["alpha"] != float64(32) {
+		t.Fatalf("imported adapter = %+v", got)
+	}
+}
+
+func TestImportSkipsExistingAncestors(t *testing.T) {
+	v1, v2, v3 := lineageChain()
+	bundle := &Bundle{Format: BundleFormat, Adapter: v3, Lineage: []*Adapter{v2, v1}}
+
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	expectExists(mock, "a1", true)
+	expectExists(mock, "a2", false)
+	expectInsert(mock, v2, "a1")
+	expectExists(mock, "a3", false)
+	expectInsert(mock, v3, "a2")
+	mock.ExpectCommit()
+
+	if _, err := s.Import(bundle); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportCutChainDropsMissingParent(t *testing.T) {
+	// a1 was hard-deleted before export, so the chain stops at a2
+	_, v2, v3 := lineageChain()
+	bundle := &Bundle{Format: BundleFormat, Adapter: v3, Lineage: []*Adapter{v2},
+		Artifact: ArtifactManifest{StoragePath: "s3://moved/v3", Checksum: "sum3"}}
+
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	expectExists(mock, "a1", false)
+	expectExists(mock, "a2", false)
+	expectInsert(mock, v2, nil)
+	expectExists(mock, "a3", false)
+	moved := *v3
+	moved.StoragePath = "s3://moved/v3"
+	expectInsert(mock, &moved, "a2")
+	mock.ExpectCommit()
+
+	got, err := s.Import(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StoragePath != "s3://moved/v3" {
+		t.Fatalf("storage path = %q, want the manifest's", got.StoragePath)
+	}
+}
+
+func TestImportExistingAdapter(t *testing.T) {
+	v1, _, _ := lineageChain()
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	expectExists(mock, "a1", true)
+	mock.ExpectRollback()
+
+	if _, err := s.Import(&Bundle{Format: BundleFormat, Adapter: v1}); !errors.Is(err, ErrVersionExists) {
+		t.Fatalf("err = %v, want ErrVersionExists", err)
+	}
+}
+
+func TestImportRejectsInvalidBundle(t *testing.T) {
+	v1, v2, v3 := lineageChain()
+	tests := []struct {
+		name   string
+		bundle *Bundle
+	}{
+		{"unknown format", &Bundle{Format: 99, Adapter: v1}},
+		{"no adapter", &Bundle{Format: BundleFormat}},
+		{"unnamed adapter", &Bundle{Format: BundleFormat, Adapter: &Adapter{ID: "x"}}},
+		{"lineage out of order", &Bundle{Format: BundleFormat, Adapter: v3, Lineage: []*Adapter{v1, v2}}},
+		{"empty lineage entry", &Bundle{Format: BundleFormat, Adapter: v3, Lineage: []*Adapter{nil}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Validation happens before any query, so no database is needed
+			s := NewAdapterStore(nil)
+			if _, err := s.Import(tt.bundle); !errors.Is(err, ErrInvalidBundle) {
+				t.Fatalf("err = %v, want ErrInvalidBundle", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when an artifact's SHA-256 differs from
+// the adapter's recorded checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// VerifyChecksum reads the adapter artifact from r and compares its SHA-256
+// against a.Checksum, which may carry a "sha256:" prefix.
+func VerifyChecksum(a *Adapter, r io.Reader) error {
+	want := strings.ToLower(strings.TrimPrefix(a.Checksum, "sha256:"))
+	if want == "" {
+		return fmt.Errorf("%w: adapter has no checksum", ErrChecksumMismatch)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return fmt.Errorf("read artifact: %w", err)
+	}
+
+	got := hex.EncodeToString(h.Sum(nil))
+	if got != want {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, want, got)
+	}
+	return nil
+}
+
+// RegisterVerified registers a only if artifact hashes to a.Checksum.
+func (s *AdapterStore) RegisterVerified(a *Adapter, artifact io.Reader) error {
+	if err := VerifyChecksum(a, artifact); err != nil {
+		return err
+	}
+	return s.Register(a)
+}
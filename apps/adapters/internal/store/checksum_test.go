@@ -0,0 +1,71 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	helloSum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" // sha256 of "hello"
+	emptySum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" // sha256 of ""
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum string
+		content  string
+		wantErr  bool
+	}{
+		{name: "match", checksum: helloSum, content: "hello"},
+		{name: "prefixed uppercase match", checksum: "sha256:" + strings.ToUpper(helloSum), content: "hello"},
+		{name: "corrupted content", checksum: helloSum, content: "hellp", wantErr: true},
+		{name: "truncated content", checksum: helloSum, content: "hell", wantErr: true},
+		{name: "no checksum recorded", checksum: "", content: "hello", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyChecksum(&Adapter{Checksum: tt.checksum}, strings.NewReader(tt.content))
+			if tt.wantErr {
+				if !errors.Is(err, ErrChecksumMismatch) {
+					t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksumReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	err := VerifyChecksum(&Adapter{Checksum: emptySum}, iotest.ErrReader(readErr))
+	if !errors.Is(err, readErr) || errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want the read error rather than a mismatch", err)
+	}
+}
+
+func TestRegisterVerified(t *testing.T) {
+	t.Run("mismatch is not registered", func(t *testing.T) {
+		s, _ := newMockStore(t) // no INSERT expected
+		err := s.RegisterVerified(&Adapter{ID: "a1", Checksum: helloSum}, strings.NewReader("tampered"))
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+		}
+	})
+
+	t.Run("match is registered", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectExec(`INSERT INTO adapters`).WillReturnResult(sqlmock.NewResult(0, 1))
+		if err := s.RegisterVerified(&Adapter{ID: "a1", Checksum: helloSum}, strings.NewReader("hello")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// CompatibilityRule describes which adapters work with a family of base
+// models. A zero MinRank or MaxRank leaves that bound open, and an empty
+// AllowedTasks accepts any task.
+type CompatibilityRule struct {
+	ID              string    `json:"id"`
+	BaseModelFamily string    `json:"base_model_family"` // prefix, e.g. "meta-llama/Llama-2"
+	MinRank         int       `json:"min_rank,omitempty"`
+	MaxRank         int       `json:"max_rank,omitempty"`
+	AllowedTasks    []string  `json:"allowed_tasks,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// AppliesTo reports whether the rule covers the given base model.
+func (r *CompatibilityRule) AppliesTo(baseModel string) bool {
+	return r.BaseModelFamily != "" && strings.HasPrefix(baseModel, r.BaseModelFamily)
+}
+
+// Allows reports whether the adapter's rank and task satisfy the rule.
+// Whether the adapter targets the base model at all is decided by the
+// caller.
+func (r *CompatibilityRule) Allows(a *Adapter) bool {
+	if r.MinRank > 0 || r.MaxRank > 0 {
+		rank := adapterRank(a)
+		if rank == 0 {
+			return false
+		}
+		if r.MinRank > 0 && rank < r.MinRank {
+			return false
+		}
+		if r.MaxRank > 0 && rank > r.MaxRank {
+			return false
+		}
+	}
+
+	if len(r.AllowedTasks) > 0 {
+		for _, t := range r.AllowedTasks {
+			if strings.EqualFold(t, a.Task) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// adapterRank reads the LoRA rank from the adapter config ("rank" or the
+// PEFT-style "r"), returning 0 if it is not recorded.
+func adapterRank(a *Adapter) int {
+	for _, key := range []string{"rank", "r"} {
+		if v, ok := a.Config[key].(float64); ok {
+			return int(v)
+		}
+	}
+	return 0
+}
+
+// CreateRule stores a new compatibility rule.
+func (s *AdapterStore) CreateRule(r *CompatibilityRule) error {
+	tasksJSON, _ := json.Marshal(r.AllowedTasks)
+
+	_, err := s.db.Exec(`
+		INSERT INTO compatibility_rules (id, base_model_family, min_rank, max_rank, allowed_tasks, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, r.ID, r.BaseModelFamily, r.MinRank, r.MaxRank, tasksJSON, r.CreatedAt, r.UpdatedAt)
+
+	return err
+}
+
+// GetRule retrieves a compatibility rule by ID.
+func (s *AdapterStore) GetRule(id string) (*CompatibilityRule, error) {
+	r := &CompatibilityRule{}
+	var tasksJSON []byte
+
+	err := s.db.QueryRow(`
+		SELECT id, base_model_family, min_rank, max_rank, allowed_tasks, created_at, updated_at
+		FROM compatibility_rules WHERE id = $1
+	`, id).Scan(&r.ID, &r.BaseModelFamily, &r.MinRank, &r.MaxRank, &tasksJSON, &r.CreatedAt, &r.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(tasksJSON, &r.AllowedTasks)
+	return r, nil
+}
+
+// ListRules returns every compatibility rule.
+func (s *AdapterStore) ListRules() ([]*CompatibilityRule, error) {
+	rows, err := s.db.Query(`
+		SELECT id, base_model_family, min_rank, max_rank, allowed_tasks, created_at, updated_at
+		FROM compatibility_rules ORDER BY base_model_family, created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*CompatibilityRule
+	for rows.Next() {
+		r := &CompatibilityRule{}
+		var tasksJSON []byte
+		if err := rows.Scan(&r.ID, &r.BaseModelFamily, &r.MinRank, &r.MaxRank, &tasksJSON, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(tasksJSON, &r.AllowedTasks)
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// UpdateRule replaces a rule's constraints.
+func (s *AdapterStore) UpdateRule(r *CompatibilityRule) error {
+	tasksJSON, _ := json.Marshal(r.AllowedTasks)
+
+	res, err := s.db.Exec(`
+		UPDATE compatibility_rules
+		SET base_model_family = $1, min_rank = $2, max_rank = $3, allowed_tasks = $4, updated_at = $5
+		WHERE id = $6
+	`, r.BaseModelFamily, r.MinRank, r.MaxRank, tasksJSON, r.UpdatedAt, r.ID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+// DeleteRule removes a compatibility rule.
+func (s *AdapterStore) DeleteRule(id string) error {
+	res, err := s.db.Exec(`DELETE FROM compatibility_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+// filterCompatible keeps the adapters allowed by at least one rule that
+// applies to baseModel. With no applicable rules the adapters are returned
+// unchanged.
+func filterCompatible(baseModel string, adapters []*Adapter, rules []*CompatibilityRule) []*Adapter {
+	var applicable []*CompatibilityRule
+	for _, r := range rules {
+		if r.AppliesTo(baseModel) {
+			applicable = append(applicable, r)
+		}
+	}
+	if len(applicable) == 0 {
+		return adapters
+	}
+
+	result := make([]*Adapter, 0, len(adapters))
+	for _, a := range adapters {
+		for _, r := range applicable {
+			if r.Allows(a) {
+				result = append(result, a)
+				break
+			}
+		}
+	}
+	return result
+}
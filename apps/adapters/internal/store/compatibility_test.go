@@ -0,0 +1,79 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterCompatible(t *testing.T) {
+	adapters := []*Adapter{
+		{ID: "r8-lm", Task: "CAUSAL_LM", Config: map[string]interface{}{"r": float64(8)}},
+		{ID: "r64-lm", Task: "CAUSAL_LM", Config: map[string]interface{}{"rank": float64(64)}},
+		{ID: "r16-cls", Task: "SEQ_CLS", Config: map[string]interface{}{"r": float64(16)}},
+		{ID: "unranked", Task: "causal_lm"},
+	}
+
+	tests := []struct {
+		name      string
+		baseModel string
+		rules     []*CompatibilityRule
+		want      []string
+	}{
+		{
+			name:      "no rules",
+			baseModel: "meta-llama/Llama-2-7b-hf",
+			want:      []string{"r8-lm", "r64-lm", "r16-cls", "unranked"},
+		},
+		{
+			name:      "rule for another family",
+			baseModel: "meta-llama/Llama-2-7b-hf",
+			rules:     []*CompatibilityRule{{BaseModelFamily: "mistralai/", MaxRank: 8}},
+			want:      []string{"r8-lm", "r64-lm", "r16-cls", "unranked"},
+		},
+		{
+			name:      "rank range excludes out-of-range and unranked",
+			baseModel: "meta-llama/Llama-2-7b-hf",
+			rules:     []*CompatibilityRule{{BaseModelFamily: "meta-llama/Llama-2", MinRank: 8, MaxRank: 32}},
+			want:      []string{"r8-lm", "r16-cls"},
+		},
+		{
+			name:      "allowed tasks match case-insensitively",
+			baseModel: "meta-llama/Llama-2-7b-hf",
+			rules:     []*CompatibilityRule{{BaseModelFamily: "meta-llama/Llama-2", AllowedTasks: []string{"causal_lm"}}},
+			want:      []string{"r8-lm", "r64-lm", "unranked"},
+		},
+		{
+			name:      "rank and task combined",
+			baseModel: "meta-llama/Llama-2-7b-hf",
+			rules:     []*CompatibilityRule{{BaseModelFamily: "meta-llama/Llama-2", MaxRank: 16, AllowedTasks: []string{"CAUSAL_LM"}}},
+			want:      []string{"r8-lm"},
+		},
+		{
+			name:      "any applicable rule admits",
+			baseModel: "meta-llama/Llama-2-7b-hf",
+			rules: []*CompatibilityRule{
+				{BaseModelFamily: "meta-llama/Llama-2", MaxRank: 8},
+				{BaseModelFamily: "meta-llama/", AllowedTasks: []string{"SEQ_CLS"}},
+			},
+			want: []string{"r8-lm", "r16-cls"},
+		},
+		{
+			name:      "rules exclude everything",
+			baseModel: "meta-llama/Llama-2-7b-hf",
+			rules:     []*CompatibilityRule{{BaseModelFamily: "meta-llama/Llama-2", MinRank: 128}},
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, a := range filterCompatible(tt.baseModel, adapters, tt.rules) {
+				got = append(got, a.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterCompatible = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidDependency is returned for an unknown dependency type or a
+	// self-referencing edge.
+	ErrInvalidDependency = errors.New("invalid dependency")
+	// ErrDependencyCycle is returned when requires/extends edges loop.
+	ErrDependencyCycle = errors.New("dependency cycle")
+	// ErrDependencyConflict is returned when two adapters that conflict
+	// would be loaded together.
+	ErrDependencyConflict = errors.New("dependency conflict")
+)
+
+// AddDependency records an edge from d.AdapterID to d.DependsOnID.
+func (s *AdapterStore) AddDependency(d *Dependency) error {
+	switch d.DependencyType {
+	case DependencyRequires, DependencyExtends, DependencyConflicts:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidDependency, d.DependencyType)
+	}
+	if d.AdapterID == d.DependsOnID {
+		return fmt.Errorf("%w: adapter cannot depend on itself", ErrInvalidDependency)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO adapter_dependencies (adapter_id, depends_on_id, dependency_type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (adapter_id, depends_on_id) DO UPDATE SET dependency_type = EXCLUDED.dependency_type
+	`, d.AdapterID, d.DependsOnID, d.DependencyType)
+
+	return err
+}
+
+// GetDependencies returns the direct dependencies of an adapter.
+func (s *AdapterStore) GetDependencies(adapterID string) ([]*Dependency, error) {
+	rows, err := s.db.Query(`
+		SELECT adapter_id, depends_on_id, dependency_type
+		FROM adapter_dependencies WHERE adapter_id = $1
+		ORDER BY depends_on_id
+	`, adapterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deps []*Dependency
+	for rows.Next() {
+		d := &Dependency{}
+		if err := rows.Scan(&d.AdapterID, &d.DependsOnID, &d.DependencyType); err != nil {
+			return nil, err
+		}
+		deps = append(deps, d)
+	}
+
+	return deps, nil
+}
+
+// ResolveLoadOrder returns the adapters to load for adapterID, each after
+// everything it requires or extends, ending with adapterID itself.
+func (s *AdapterStore) ResolveLoadOrder(adapterID string) ([]string, error) {
+	return resolveLoadOrder(adapterID, s.GetDependencies)
+}
+
+func resolveLoadOrder(root string, depsOf func(string) ([]*Dependency, error)) ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	conflicts := make(map[string][]string)
+	var order, path []string
+
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case done:
+			return nil
+		case visiting:
+			// path holds the chain from root; show the loop from id onwards
+			for i, p := range path {
+				if p == id {
+					return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(append(path[i:], id), " -> "))
+				}
+			}
+		}
+
+		state[id] = visiting
+		path = append(path, id)
+
+		deps, err := depsOf(id)
+		if err != nil {
+			return err
+		}
+		for _, d := range deps {
+			if d.DependencyType == DependencyConflicts {
+				conflicts[id] = append(conflicts[id], d.DependsOnID)
+				continue
+			}
+			if err := visit(d.DependsOnID); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[id] = done
+		order = append(order, id)
+		return nil
+	}
+
+	if err := visit(root); err != nil {
+		return nil, err
+	}
+
+	for id, others := range conflicts {
+		for _, other := range others {
+			if state[other] == done {
+				return nil, fmt.Errorf("%w: %s conflicts with %s", ErrDependencyConflict, id, other)
+			}
+		}
+	}
+	return order, nil
+}
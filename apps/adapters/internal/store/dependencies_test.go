@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// depGraph maps an adapter ID to its outgoing edges, "type:target" each.
+type depGraph map[string][]string
+
+func (g depGraph) depsOf(id string) ([]*Dependency, error) {
+	var deps []*Dependency
+	for _, edge := range g[id] {
+		typ, target, _ := strings.Cut(edge, ":")
+		deps = append(deps, &Dependency{AdapterID: id, DependsOnID: target, DependencyType: typ})
+	}
+	return deps, nil
+}
+
+func TestResolveLoadOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   depGraph
+		want    []string
+		wantErr error
+		errText string
+	}{
+		{
+			name:  "no dependencies",
+			graph: depGraph{},
+			want:  []string{"app"},
+		},
+		{
+			name: "chain",
+			graph: depGraph{
+				"app":    {"extends:domain"},
+				"domain": {"requires:base"},
+			},
+			want: []string{"base", "domain", "app"},
+		},
+		{
+			name: "shared dependency loads once",
+			graph: depGraph{
+				"app":   {"requires:left", "requires:right"},
+				"left":  {"requires:base"},
+				"right": {"requires:base"},
+			},
+			want: []string{"base", "left", "right", "app"},
+		},
+		{
+			name: "conflict with an unloaded adapter is fine",
+			graph: depGraph{
+				"app":    {"requires:base", "conflicts:legacy"},
+				"legacy": {},
+			},
+			want: []string{"base", "app"},
+		},
+		{
+			name: "conflicting pair both loaded",
+			graph: depGraph{
+				"app":   {"requires:left", "requires:right"},
+				"left":  {"conflicts:right"},
+				"right": {},
+			},
+			wantErr: ErrDependencyConflict,
+			errText: "left conflicts with right",
+		},
+		{
+			name: "cycle",
+			graph: depGraph{
+				"app": {"requires:a"},
+				"a":   {"extends:b"},
+				"b":   {"requires:a"},
+			},
+			wantErr: ErrDependencyCycle,
+			errText: "a -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveLoadOrder("app", tt.graph.depsOf)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("err = %v, want %v mentioning %q", err, tt.wantErr, tt.errText)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("load order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AlgoEd25519 is the only supported signature algorithm.
+const AlgoEd25519 = "ed25519"
+
+var (
+	// ErrNotSigned is returned by Verify for an adapter with no signature.
+	ErrNotSigned = errors.New("adapter is not signed")
+	// ErrUnknownKey is returned when a signature names an unregistered key.
+	ErrUnknownKey = errors.New("unknown signing key")
+)
+
+// Signature is a detached signature over an adapter's checksum.
+type Signature struct {
+	ID          string    `json:"id"`
+	AdapterID   string    `json:"adapter_id"`
+	PublicKeyID string    `json:"public_key_id"`
+	Signature   []byte    `json:"signature"`
+	Algo        string    `json:"algo"`
+	SignedAt    time.Time `json:"signed_at"`
+}
+
+// KeyID is the fingerprint a public key is registered under.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// signingPayload binds the signature to both the adapter and its content,
+// so a signature can't be replayed onto another adapter with the same
+// artifact.
+func signingPayload(a *Adapter) []byte {
+	return []byte(a.ID + "\n" + a.Checksum)
+}
+
+// RegisterPublicKey trusts pub for verifying signatures and returns its ID.
+func (s *AdapterStore) RegisterPublicKey(pub ed25519.PublicKey) (string, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return "", fmt.Errorf("invalid ed25519 public key length %d", len(pub))
+	}
+
+	id := KeyID(pub)
+	_, err := s.db.Exec(`
+		INSERT INTO signing_keys (id, public_key, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+	`, id, []byte(pub), time.Now())
+	return id, err
+}
+
+func (s *AdapterStore) publicKey(id string) (ed25519.PublicKey, error) {
+	var pub []byte
+	err := s.db.QueryRow(`SELECT public_key FROM signing_keys WHERE id = $1`, id).Scan(&pub)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return ed25519.PublicKey(pub), err
+}
+
+// Sign signs the adapter's checksum with privKey and stores the signature.
+// The matching public key must already be registered.
+func (s *AdapterStore) Sign(adapterID string, privKey ed25519.PrivateKey) (*Signature, error) {
+	a, err := s.Get(adapterID)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &Signature{
+		ID:          uuid.New().String(),
+		AdapterID:   adapterID,
+		PublicKeyID: KeyID(privKey.Public().(ed25519.PublicKey)),
+		Signature:   ed25519.Sign(privKey, signingPayload(a)),
+		Algo:        AlgoEd25519,
+		SignedAt:    time.Now(),
+	}
+	if err := s.AddSignature(sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// AddSignature stores a signature produced elsewhere, e.g. by a client that
+// keeps its private key locally.
+func (s *AdapterStore) AddSignature(sig *Signature) error {
+	if sig.Algo != AlgoEd25519 {
+		return fmt.Errorf("unsupported signature algorithm %q", sig.Algo)
+	}
+	if _, err := s.publicKey(sig.PublicKeyID); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO adapter_signatures (id, adapter_id, public_key_id, signature, algo, signed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, sig.ID, sig.AdapterID, sig.PublicKeyID, sig.Signature, sig.Algo, sig.SignedAt)
+	return err
+}
+
+// LatestSignature returns the most recent signature on an adapter.
+func (s *AdapterStore) LatestSignature(adapterID string) (*Signature, error) {
+	sig := &Signature{}
+	err := s.db.QueryRow(`
+		SELECT id, adapter_id, public_key_id, signature, algo, signed_at
+		FROM adapter_signatures WHERE adapter_id = $1
+		ORDER BY signed_at DESC LIMIT 1
+	`, adapterID).Scan(&sig.ID, &sig.AdapterID, &sig.PublicKeyID, &sig.Signature, &sig.Algo, &sig.SignedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotSigned
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// Verify checks the adapter's latest signature against its current checksum
+// using the registered public key. It returns false without an error when
+// the signature doesn't match, e.g. because the checksum changed.
+func (s *AdapterStore) Verify(adapterID string) (bool, error) {
+	a, err := s.Get(adapterID)
+	if err != nil {
+		return false, err
+	}
+	sig, err := s.LatestSignature(adapterID)
+	if err != nil {
+		return false, err
+	}
+	pub, err := s.publicKey(sig.PublicKeyID)
+	if err != nil {
+		return false, err
+	}
+	return verifySignature(a, sig, pub), nil
+}
+
+func verifySignature(a *Adapter, sig *Signature, pub ed25519.PublicKey) bool {
+	if sig.Algo != AlgoEd25519 || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(pub, signingPayload(a), sig.Signature)
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestVerifySignature(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	pub := priv.Public().(ed25519.PublicKey)
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+
+	signed := &Adapter{ID: "a1", Checksum: "sha256:" + helloSum}
+	sig := &Signature{AdapterID: "a1", PublicKeyID: KeyID(pub), Algo: AlgoEd25519, Signature: ed25519.Sign(priv, signingPayload(signed))}
+
+	flipped := append([]byte(nil), sig.Signature...)
+	flipped[0] ^= 1
+
+	tests := []struct {
+		name    string
+		adapter *Adapter
+		sig     *Signature
+		pub     ed25519.PublicKey
+		want    bool
+	}{
+		{name: "valid", adapter: signed, sig: sig, pub: pub, want: true},
+		{name: "checksum changed", adapter: &Adapter{ID: "a1", Checksum: "sha256:" + emptySum}, sig: sig, pub: pub},
+		{name: "signature bytes tampered", adapter: signed, sig: &Signature{Algo: AlgoEd25519, Signature: flipped}, pub: pub},
+		{name: "replayed onto another adapter", adapter: &Adapter{ID: "a2", Checksum: signed.Checksum}, sig: sig, pub: pub},
+		{name: "different key", adapter: signed, sig: sig, pub: other},
+		{name: "unsupported algorithm", adapter: signed, sig: &Signature{Algo: "rsa", Signature: sig.Signature}, pub: pub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifySignature(tt.adapter, tt.sig, tt.pub); got != tt.want {
+				t.Errorf("verifySignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyUnsignedAdapter(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM adapters WHERE id = \$1`).WithArgs("a1").
+		WillReturnRows(adapterRows(&Adapter{ID: "a1", Name: "sentiment", Checksum: helloSum}))
+	mock.ExpectQuery(`FROM adapter_signatures WHERE adapter_id = \$1`).WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "adapter_id", "public_key_id", "signature", "algo", "signed_at"}))
+
+	ok, err := s.Verify("a1")
+	if ok || !errors.Is(err, ErrNotSigned) {
+		t.Fatalf("Verify = %v, %v; want false, ErrNotSigned", ok, err)
+	}
+}
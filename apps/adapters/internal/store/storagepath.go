@@ -0,0 +1,83 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultPathTemplate lays adapters out by owner, name and version.
+const DefaultPathTemplate = "{owner}/{name}/v{version}"
+
+// ErrInvalidPathTemplate is returned for a template with an unknown or
+// unterminated placeholder.
+var ErrInvalidPathTemplate = errors.New("invalid storage path template")
+
+// pathFields maps template placeholders to adapter fields.
+var pathFields = map[string]func(a *Adapter) string{
+	"id":         func(a *Adapter) string { return a.ID },
+	"owner":      func(a *Adapter) string { return a.OwnerID },
+	"name":       func(a *Adapter) string { return a.Name },
+	"version":    func(a *Adapter) string { return strconv.Itoa(a.Version) },
+	"base_model": func(a *Adapter) string { return a.BaseModel },
+	"task":       func(a *Adapter) string { return a.Task },
+}
+
+// SetPathTemplate sets the template used to fill in StoragePath for
+// adapters registered without one. Placeholders are {id}, {owner}, {name},
+// {version}, {base_model} and {task}. An empty template leaves StoragePath
+// as the client sent it.
+func (s *AdapterStore) SetPathTemplate(tmpl string) error {
+	if _, err := expandPath(tmpl, &Adapter{}); err != nil {
+		return err
+	}
+	s.pathTemplate = tmpl
+	return nil
+}
+
+// applyPathTemplate fills in a.StoragePath from the template if the client
+// didn't supply one.
+func (s *AdapterStore) applyPathTemplate(a *Adapter) {
+	if a.StoragePath != "" || s.pathTemplate == "" {
+		return
+	}
+	// The template was validated by SetPathTemplate
+	a.StoragePath, _ = expandPath(s.pathTemplate, a)
+}
+
+// expandPath substitutes adapter fields into tmpl. Values are sanitized so
+// a name like "../x" can't escape its directory.
+func expandPath(tmpl string, a *Adapter) (string, error) {
+	var b strings.Builder
+	rest := tmpl
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		b.WriteString(rest[:open])
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("%w: unterminated placeholder in %q", ErrInvalidPathTemplate, tmpl)
+		}
+		key := rest[open+1 : open+end]
+		field, ok := pathFields[key]
+		if !ok {
+			return "", fmt.Errorf("%w: unknown placeholder {%s}", ErrInvalidPathTemplate, key)
+		}
+		b.WriteString(pathSegment(field(a)))
+		rest = rest[open+end+1:]
+	}
+}
+
+// pathSegment makes v safe to use as (part of) a single path segment.
+func pathSegment(v string) string {
+	v = strings.NewReplacer("/", "_", "\\", "_").Replace(v)
+	if strings.Trim(v, ".") == "" {
+		return strings.Repeat("_", len(v))
+	}
+	return v
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestExpandPath(t *testing.T) {
+	a := &Adapter{ID: "a-1", OwnerID: "alice", Name: "sql-coder", Version: 3, BaseModel: "llama-3-8b", Task: "text-to-sql"}
+	tests := []struct {
+		name string
+		tmpl string
+		a    *Adapter
+		want string
+	}{
+		{"default", DefaultPathTemplate, a, "alice/sql-coder/v3"},
+		{"every field", "{base_model}/{task}/{id}", a, "llama-3-8b/text-to-sql/a-1"},
+		{"literal text", "adapters/{name}-{version}.safetensors", a, "adapters/sql-coder-3.safetensors"},
+		{"no placeholders", "fixed/path", a, "fixed/path"},
+		{"slashes in values", DefaultPathTemplate, &Adapter{OwnerID: "team/ml", Name: `a\b`, Version: 1}, "team_ml/a_b/v1"},
+		{"dot segments", DefaultPathTemplate, &Adapter{OwnerID: "..", Name: ".", Version: 1}, "__/_/v1"},
+		{"empty values", "{owner}/{name}", &Adapter{}, "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandPath(tt.tmpl, tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("expandPath(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPathTemplateRejectsInvalid(t *testing.T) {
+	s := NewAdapterStore(nil)
+	for _, tmpl := range []string{"{owner}/{nmae}", "{owner}/{name", "{}"} {
+		if err := s.SetPathTemplate(tmpl); !errors.Is(err, ErrInvalidPathTemplate) {
+			t.Errorf("SetPathTemplate(%q) = %v, want ErrInvalidPathTemplate", tmpl, err)
+		}
+	}
+	if s.pathTemplate != DefaultPathTemplate {
+		t.Fatalf("template = %q after rejected updates, want the default kept", s.pathTemplate)
+	}
+}
+
+func TestRegisterAppliesPathTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		tmpl     string
+		explicit string
+		want     string
+	}{
+		{"default template", DefaultPathTemplate, "", "alice/sql-coder/v2"},
+		{"custom template", "{base_model}/{name}/{version}", "", "llama-3-8b/sql-coder/2"},
+		{"explicit path kept", DefaultPathTemplate, "s3://bucket/custom", "s3://bucket/custom"},
+		{"templating disabled", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockStore(t)
+			if err := s.SetPathTemplate(tt.tmpl); err != nil {
+				t.Fatal(err)
+			}
+			a := &Adapter{ID: "a-2", Name: "sql-coder", Version: 2, OwnerID: "alice", BaseModel: "llama-3-8b", StoragePath: tt.explicit}
+			mock.ExpectExec(`INSERT INTO adapters`).
+				WithArgs("a-2", "sql-coder", 2, "llama-3-8b", sqlmock.AnyArg(), sqlmock.AnyArg(), "alice", tt.want,
+					sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			if err := s.Register(a); err != nil {
+				t.Fatal(err)
+			}
+			if a.StoragePath != tt.want {
+				t.Fatalf("StoragePath = %q, want %q", a.StoragePath, tt.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,12 @@ package store
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // AdapterStatus represents adapter lifecycle state.
@@ -34,6 +39,7 @@ type Adapter struct {
 	Tags        []string               `json:"tags,omitempty"`
 	ParentID    string                 `json:"parent_id,omitempty"`
 	SignatureID string                 `json:"signature_id,omitempty"`
+	Downloads   int64                  `json:"downloads"`
 	CreatedAt   time.Time              `json:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at"`
 }
@@ -45,30 +51,99 @@ type Dependency struct {
 	DependencyType string `json:"dependency_type"` // requires, extends, conflicts
 }
 
+// Dependency types.
+const (
+	DependencyRequires  = "requires"
+	DependencyExtends   = "extends"
+	DependencyConflicts = "conflicts"
+)
+
 // AdapterStore handles adapter persistence.
 type AdapterStore struct {
-	db *sql.DB
+	db           *sql.DB
+	pathTemplate string
 }
 
-// NewAdapterStore creates a new store.
+// NewAdapterStore creates a new store that lays out storage paths with
+// DefaultPathTemplate.
 func NewAdapterStore(db *sql.DB) *AdapterStore {
-	return &AdapterStore{db: db}
+	return &AdapterStore{db: db, pathTemplate: DefaultPathTemplate}
+}
+
+// ErrVersionExists is returned when (name, version) is already registered.
+var ErrVersionExists = errors.New("adapter version already exists")
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
 }
 
-// Register creates a new adapter.
+// Register creates a new adapter, filling in StoragePath from the path
+// template if it is empty.
 func (s *AdapterStore) Register(a *Adapter) error {
+	s.applyPathTemplate(a)
+	return insertAdapter(s.db, a)
+}
+
+func insertAdapter(db execer, a *Adapter) error {
 	configJSON, _ := json.Marshal(a.Config)
 	metricsJSON, _ := json.Marshal(a.Metrics)
 	tagsJSON, _ := json.Marshal(a.Tags)
 
-	_, err := s.db.Exec(`
+	_, err := db.Exec(`
 		INSERT INTO adapters (id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
-	`, a.ID, a.Name, a.Version, a.BaseModel, a.Status, a.Task, a.OwnerID, a.StoragePath, a.Checksum, configJSON, metricsJSON, tagsJSON, a.ParentID, a.CreatedAt, a.UpdatedAt)
+	`, a.ID, a.Name, a.Version, a.BaseModel, a.Status, a.Task, a.OwnerID, a.StoragePath, a.Checksum, configJSON, metricsJSON, tagsJSON, sql.NullString{String: a.ParentID, Valid: a.ParentID != ""}, a.CreatedAt, a.UpdatedAt)
 
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return fmt.Errorf("%w: %s v%d", ErrVersionExists, a.Name, a.Version)
+	}
 	return err
 }
 
+// PublishVersion registers a as the next version of name. The version is
+// max+1 of the existing versions and ParentID links to the previous one;
+// concurrent publishes for the same name are serialized.
+func (s *AdapterStore) PublishVersion(name string, a *Adapter) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, "adapter:"+name); err != nil {
+		return err
+	}
+
+	var prevID string
+	var prevVersion int
+	err = tx.QueryRow(`
+		SELECT id, version FROM adapters WHERE name = $1 ORDER BY version DESC LIMIT 1
+	`, name).Scan(&prevID, &prevVersion)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	a.Name = name
+	a.Version = prevVersion + 1
+	a.ParentID = prevID
+	s.applyPathTemplate(a)
+
+	if err := insertAdapter(tx, a); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListVersions returns every version of name, newest first.
+func (s *AdapterStore) ListVersions(name string) ([]*Adapter, error) {
+	return s.queryAdapters(`
+		SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, downloads, created_at, updated_at
+		FROM adapters WHERE name = $1 ORDER BY version DESC
+	`, name)
+}
+
 // Get retrieves an adapter by ID.
 func (s *AdapterStore) Get(id string) (*Adapter, error) {
 	a := &Adapter{}
@@ -76,9 +151,9 @@ func (s *AdapterStore) Get(id string) (*Adapter, error) {
 	var parentID sql.NullString
 
 	err := s.db.QueryRow(`
-		SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, created_at, updated_at
+		SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, downloads, created_at, updated_at
 		FROM adapters WHERE id = $1
-	`, id).Scan(&a.ID, &a.Name, &a.Version, &a.BaseModel, &a.Status, &a.Task, &a.OwnerID, &a.StoragePath, &a.Checksum, &configJSON, &metricsJSON, &tagsJSON, &parentID, &a.CreatedAt, &a.UpdatedAt)
+	`, id).Scan(&a.ID, &a.Name, &a.Version, &a.BaseModel, &a.Status, &a.Task, &a.OwnerID, &a.StoragePath, &a.Checksum, &configJSON, &metricsJSON, &tagsJSON, &parentID, &a.Downloads, &a.CreatedAt, &a.UpdatedAt)
 
 	if err != nil {
 		return nil, err
@@ -101,9 +176,9 @@ func (s *AdapterStore) GetByName(name string) (*Adapter, error) {
 	var parentID sql.NullString
 
 	err := s.db.QueryRow(`
-		SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, created_at, updated_at
+		SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, downloads, created_at, updated_at
 		FROM adapters WHERE name = $1 ORDER BY version DESC LIMIT 1
-	`, name).Scan(&a.ID, &a.Name, &a.Version, &a.BaseModel, &a.Status, &a.Task, &a.OwnerID, &a.StoragePath, &a.Checksum, &configJSON, &metricsJSON, &tagsJSON, &parentID, &a.CreatedAt, &a.UpdatedAt)
+	`, name).Scan(&a.ID, &a.Name, &a.Version, &a.BaseModel, &a.Status, &a.Task, &a.OwnerID, &a.StoragePath, &a.Checksum, &configJSON, &metricsJSON, &tagsJSON, &parentID, &a.Downloads, &a.CreatedAt, &a.UpdatedAt)
 
 	if err != nil {
 		return nil, err
@@ -119,25 +194,63 @@ func (s *AdapterStore) GetByName(name string) (*Adapter, error) {
 	return a, nil
 }
 
-// List retrieves adapters with filters.
-func (s *AdapterStore) List(ownerID string, status AdapterStatus, limit int) ([]*Adapter, error) {
-	query := `SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, created_at, updated_at FROM adapters WHERE 1=1`
-	args := []interface{}{}
-	argIdx := 1
+// ListFilter narrows List results. Empty fields are not filtered on.
+// Soft-deleted adapters are left out unless IncludeDeleted is set.
+type ListFilter struct {
+	OwnerID        string
+	Status         AdapterStatus
+	BaseModel      string
+	Task           string
+	IncludeDeleted bool
+	Limit          int
+}
 
-	if ownerID != "" {
-		query += ` AND owner_id = $` + string(rune('0'+argIdx))
-		args = append(args, ownerID)
-		argIdx++
+// buildListQuery renders the List query and its positional arguments.
+func buildListQuery(f ListFilter) (string, []interface{}) {
+	query := `SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, downloads, created_at, updated_at FROM adapters WHERE 1=1`
+	var args []interface{}
+
+	add := func(clause string, v interface{}) {
+		args = append(args, v)
+		query += fmt.Sprintf(" AND %s = $%d", clause, len(args))
 	}
-	if status != "" {
-		query += ` AND status = $` + string(rune('0'+argIdx))
-		args = append(args, status)
-		argIdx++
+	if f.OwnerID != "" {
+		add("owner_id", f.OwnerID)
 	}
-	query += ` ORDER BY created_at DESC LIMIT $` + string(rune('0'+argIdx))
-	args = append(args, limit)
+	if f.Status != "" {
+		add("status", f.Status)
+	}
+	if f.BaseModel != "" {
+		add("base_model", f.BaseModel)
+	}
+	if f.Task != "" {
+		add("task", f.Task)
+	}
+	if !f.IncludeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
+
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", len(args))
+	return query, args
+}
 
+// List retrieves adapters with filters.
+func (s *AdapterStore) List(f ListFilter) ([]*Adapter, error) {
+	query, args := buildListQuery(f)
+	return s.queryAdapters(query, args...)
+}
+
+// Count returns the number of adapters that have not been deleted.
+func (s *AdapterStore) Count() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM adapters WHERE deleted_at IS NULL`).Scan(&n)
+	return n, err
+}
+
+// queryAdapters runs a query selecting the full adapter column list and
+// scans every row.
+func (s *AdapterStore) queryAdapters(query string, args ...interface{}) ([]*Adapter, error) {
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
@@ -149,7 +262,7 @@ func (s *AdapterStore) List(ownerID string, status AdapterStatus, limit int) ([]
 		a := &Adapter{}
 		var configJSON, metricsJSON, tagsJSON []byte
 		var parentID sql.NullString
-		if err := rows.Scan(&a.ID, &a.Name, &a.Version, &a.BaseModel, &a.Status, &a.Task, &a.OwnerID, &a.StoragePath, &a.Checksum, &configJSON, &metricsJSON, &tagsJSON, &parentID, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.Name, &a.Version, &a.BaseModel, &a.Status, &a.Task, &a.OwnerID, &a.StoragePath, &a.Checksum, &configJSON, &metricsJSON, &tagsJSON, &parentID, &a.Downloads, &a.CreatedAt, &a.UpdatedAt); err != nil {
 			return nil, err
 		}
 		json.Unmarshal(configJSON, &a.Config)
@@ -170,7 +283,113 @@ func (s *AdapterStore) UpdateStatus(id string, status AdapterStatus) error {
 	return err
 }
 
-// GetCompatible finds adapters compatible with a base model.
+// IncrementDownloads atomically bumps an adapter's download count and
+// returns its storage path along with the new count.
+func (s *AdapterStore) IncrementDownloads(id string) (string, int64, error) {
+	var path string
+	var downloads int64
+	err := s.db.QueryRow(`
+		UPDATE adapters SET downloads = downloads + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING storage_path, downloads
+	`, id).Scan(&path, &downloads)
+	return path, downloads, err
+}
+
+// SoftDelete archives an adapter and stamps deleted_at so it drops out of
+// List. It can be undone with Restore.
+func (s *AdapterStore) SoftDelete(id string) error {
+	now := time.Now()
+	res, err := s.db.Exec(`
+		UPDATE adapters SET status = $1, deleted_at = $2, updated_at = $2
+		WHERE id = $3 AND deleted_at IS NULL
+	`, StatusArchived, now, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+// Restore reactivates a soft-deleted adapter.
+func (s *AdapterStore) Restore(id string) error {
+	res, err := s.db.Exec(`
+		UPDATE adapters SET status = $1, deleted_at = NULL, updated_at = $2
+		WHERE id = $3 AND deleted_at IS NOT NULL
+	`, StatusActive, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+// GetCompatible finds active adapters trained on baseModel, or that list it
+// under "compatible_base_models" in their config, then applies the
+// configured compatibility rules. An empty baseModel lists all active
+// adapters.
 func (s *AdapterStore) GetCompatible(baseModel string) ([]*Adapter, error) {
-	return s.List("", StatusActive, 100) // Simplified - filter by base_model in production
+	if baseModel == "" {
+		return s.List(ListFilter{Status: StatusActive, Limit: 100})
+	}
+
+	adapters, err := s.queryAdapters(`
+		SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, downloads, created_at, updated_at
+		FROM adapters
+		WHERE status = $1 AND (base_model = $2 OR config::jsonb -> 'compatible_base_models' ? $2)
+		ORDER BY created_at DESC LIMIT 100
+	`, StatusActive, baseModel)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.ListRules()
+	if err != nil {
+		return nil, err
+	}
+	return filterCompatible(baseModel, adapters, rules), nil
+}
+
+// requireRow returns sql.ErrNoRows if an update or delete matched nothing.
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// buildSearchQuery renders the Search query. query matches name or the
+// config "description" case-insensitively; every tag must be present.
+func buildSearchQuery(ownerID, query string, tags []string, limit int) (string, []interface{}) {
+	sqlQuery := `SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, downloads, created_at, updated_at FROM adapters WHERE deleted_at IS NULL`
+	var args []interface{}
+
+	if ownerID != "" {
+		args = append(args, ownerID)
+		sqlQuery += fmt.Sprintf(" AND owner_id = $%d", len(args))
+	}
+	if query != "" {
+		args = append(args, "%"+likeEscaper.Replace(query)+"%")
+		sqlQuery += fmt.Sprintf(" AND (name ILIKE $%[1]d OR config::jsonb ->> 'description' ILIKE $%[1]d)", len(args))
+	}
+	if len(tags) > 0 {
+		tagsJSON, _ := json.Marshal(tags)
+		args = append(args, string(tagsJSON))
+		sqlQuery += fmt.Sprintf(" AND tags::jsonb @> $%d::jsonb", len(args))
+	}
+
+	args = append(args, limit)
+	sqlQuery += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", len(args))
+	return sqlQuery, args
+}
+
+// likeEscaper escapes LIKE wildcards so user input matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search finds adapters whose name or description contains query and
+// that carry all of tags.
+func (s *AdapterStore) Search(ownerID, query string, tags []string, limit int) ([]*Adapter, error) {
+	sqlQuery, args := buildSearchQuery(ownerID, query, tags, limit)
+	return s.queryAdapters(sqlQuery, args...)
 }
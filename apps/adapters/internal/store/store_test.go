@@ -0,0 +1,551 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+const listSelect = `SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, downloads, created_at, updated_at FROM adapters WHERE 1=1`
+
+func TestBuildListQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ListFilter
+		where  string
+		args   []interface{}
+	}{
+		{
+			name:   "no filters",
+			filter: ListFilter{Limit: 20},
+			where:  " AND deleted_at IS NULL ORDER BY created_at DESC LIMIT $1",
+			args:   []interface{}{20},
+		},
+		{
+			name:   "include deleted",
+			filter: ListFilter{IncludeDeleted: true, Limit: 20},
+			where:  " ORDER BY created_at DESC LIMIT $1",
+			args:   []interface{}{20},
+		},
+		{
+			name:   "task only",
+			filter: ListFilter{Task: "chat", Limit: 5},
+			where:  " AND task = $1 AND deleted_at IS NULL ORDER BY created_at DESC LIMIT $2",
+			args:   []interface{}{"chat", 5},
+		},
+		{
+			name:   "every filter",
+			filter: ListFilter{OwnerID: "u1", Status: StatusActive, BaseModel: "llama-3-8b", Task: "chat", IncludeDeleted: true, Limit: 10},
+			where:  " AND owner_id = $1 AND status = $2 AND base_model = $3 AND task = $4 ORDER BY created_at DESC LIMIT $5",
+			args:   []interface{}{"u1", StatusActive, "llama-3-8b", "chat", 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := buildListQuery(tt.filter)
+			if want := listSelect + tt.where; query != want {
+				t.Errorf("query =\n%s\nwant\n%s", query, want)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+// TestBuildListQueryAllCombinations checks every subset of filters gets
+// consecutive placeholders, in column order, matching its args.
+func TestBuildListQueryAllCombinations(t *testing.T) {
+	columns := []struct {
+		name  string
+		value string
+		set   func(*ListFilter)
+	}{
+		{"owner_id", "u1", func(f *ListFilter) { f.OwnerID = "u1" }},
+		{"status", "active", func(f *ListFilter) { f.Status = StatusActive }},
+		{"base_model", "llama-3-8b", func(f *ListFilter) { f.BaseModel = "llama-3-8b" }},
+		{"task", "chat", func(f *ListFilter) { f.Task = "chat" }},
+	}
+
+	for mask := 0; mask < 1<<(len(columns)+1); mask++ {
+		f := ListFilter{Limit: 50, IncludeDeleted: mask&(1<<len(columns)) != 0}
+		var where []string
+		var args []interface{}
+		for i, col := range columns {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			col.set(&f)
+			args = append(args, col.value)
+			where = append(where, fmt.Sprintf(" AND %s = $%d", col.name, len(args)))
+		}
+		if !f.IncludeDeleted {
+			where = append(where, " AND deleted_at IS NULL")
+		}
+		args = append(args, 50)
+		want := listSelect + strings.Join(where, "") + fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", len(args))
+
+		query, gotArgs := buildListQuery(f)
+		if query != want {
+			t.Errorf("filter %+v: query =\n%s\nwant\n%s", f, query, want)
+		}
+		if fmt.Sprint(gotArgs) != fmt.Sprint(args) {
+			t.Errorf("filter %+v: args = %v, want %v", f, gotArgs, args)
+		}
+	}
+}
+
+// newMockStore returns a store over a mock database whose expectations
+// must all be met by the end of the test.
+func newMockStore(t *testing.T) (*AdapterStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return NewAdapterStore(db), mock
+}
+
+var adapterColumns = []string{"id", "name", "version", "base_model", "status", "task", "owner_id", "storage_path", "checksum", "config", "metrics", "tags", "parent_id", "downloads", "created_at", "updated_at"}
+
+// adapterRows renders adapters as rows of adapterColumns.
+func adapterRows(adapters ...*Adapter) *sqlmock.Rows {
+	rows := sqlmock.NewRows(adapterColumns)
+	for _, a := range adapters {
+		configJSON, _ := json.Marshal(a.Config)
+		metricsJSON, _ := json.Marshal(a.Metrics)
+		tagsJSON, _ := json.Marshal(a.Tags)
+		var parentID interface{}
+		if a.ParentID != "" {
+			parentID = a.ParentID
+		}
+		rows.AddRow(a.ID, a.Name, a.Version, a.BaseModel, a.Status, a.Task, a.OwnerID, a.StoragePath, a.Checksum,
+			configJSON, metricsJSON, tagsJSON, parentID, a.Downloads, time.Now(), time.Now())
+	}
+	return rows
+}
+
+func TestGetCompatibleFiltersByBaseModel(t *testing.T) {
+	adapters := []*Adapter{
+		{ID: "llama-a", BaseModel: "llama-2-7b", Status: StatusActive},
+		{ID: "llama-b", BaseModel: "llama-2-7b", Status: StatusActive},
+		{ID: "mistral", BaseModel: "mistral-7b", Status: StatusActive,
+			Config: map[string]interface{}{"compatible_base_models": []string{"llama-2-7b"}}},
+		{ID: "falcon", BaseModel: "falcon-7b", Status: StatusActive},
+	}
+	// What the WHERE clause selects: trained on the model or declaring it
+	// compatible
+	matching := func(baseModel string) []*Adapter {
+		var result []*Adapter
+		for _, a := range adapters {
+			declared, _ := a.Config["compatible_base_models"].([]string)
+			for _, m := range append(declared, a.BaseModel) {
+				if m == baseModel {
+					result = append(result, a)
+					break
+				}
+			}
+		}
+		return result
+	}
+
+	tests := []struct {
+		baseModel string
+		want      []string
+	}{
+		{"llama-2-7b", []string{"llama-a", "llama-b", "mistral"}},
+		{"mistral-7b", []string{"mistral"}},
+		{"falcon-7b", []string{"falcon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.baseModel, func(t *testing.T) {
+			s, mock := newMockStore(t)
+			mock.ExpectQuery(`WHERE status = \$1 AND \(base_model = \$2 OR config::jsonb -> 'compatible_base_models' \? \$2\)`).
+				WithArgs(StatusActive, tt.baseModel).
+				WillReturnRows(adapterRows(matching(tt.baseModel)...))
+			mock.ExpectQuery(`FROM compatibility_rules`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "base_model_family", "min_rank", "max_rank", "allowed_tasks", "created_at", "updated_at"}))
+
+			got, err := s.GetCompatible(tt.baseModel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, a := range got {
+				ids = append(ids, a.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("GetCompatible(%q) = %v, want %v", tt.baseModel, ids, tt.want)
+			}
+		})
+	}
+}
+
+// fakeDB is an in-memory database/sql connector that understands only
+// the statements PublishVersion and IncrementDownloads issue. Its advisory
+// lock blocks until the holding transaction ends, each statement is atomic
+// and (name, version) is unique, as in Postgres.
+type fakeDB struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+	rows  []fakeRow
+}
+
+type fakeRow struct {
+	id, name, parentID, storagePath string
+	version, downloads              int64
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{locks: make(map[string]*sync.Mutex)}
+}
+
+func (d *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: d}, nil }
+func (d *fakeDB) Driver() driver.Driver                        { return nil }
+
+// versions returns the stored rows for name ordered by version.
+func (d *fakeDB) versions(name string) []fakeRow {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var result []fakeRow
+	for _, r := range d.rows {
+		if r.name == name {
+			result = append(result, r)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].version < result[j].version })
+	return result
+}
+
+type fakeConn struct {
+	db   *fakeDB
+	held []*sync.Mutex
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { c.release(); return nil }
+func (c *fakeConn) Rollback() error           { c.release(); return nil }
+
+func (c *fakeConn) release() {
+	for _, l := range c.held {
+		l.Unlock()
+	}
+	c.held = nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.conn.db
+	switch {
+	case strings.Contains(s.query, "pg_advisory_xact_lock"):
+		d.mu.Lock()
+		l, ok := d.locks[args[0].(string)]
+		if !ok {
+			l = &sync.Mutex{}
+			d.locks[args[0].(string)] = l
+		}
+		d.mu.Unlock()
+		l.Lock()
+		s.conn.held = append(s.conn.held, l)
+		return driver.RowsAffected(0), nil
+	case strings.Contains(s.query, "INSERT INTO adapters"):
+		row := fakeRow{id: args[0].(string), name: args[1].(string), version: args[2].(int64), storagePath: args[7].(string)}
+		if parent, ok := args[12].(string); ok {
+			row.parentID = parent
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for _, r := range d.rows {
+			if r.name == row.name && r.version == row.version {
+				return nil, &pq.Error{Code: "23505"}
+			}
+		}
+		d.rows = append(d.rows, row)
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("fakeDB: unexpected exec %q", s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.conn.db
+	switch {
+	case strings.Contains(s.query, "SELECT id, version FROM adapters WHERE name = $1 ORDER BY version DESC LIMIT 1"):
+		rows := &fakeRows{columns: []string{"id", "version"}}
+		if versions := d.versions(args[0].(string)); len(versions) > 0 {
+			latest := versions[len(versions)-1]
+			rows.values = [][]driver.Value{{latest.id, latest.version}}
+		}
+		// Let other publishers run between the read and the insert, as a
+		// network round trip would
+		runtime.Gosched()
+		return rows, nil
+	case strings.Contains(s.query, "UPDATE adapters SET downloads = downloads + 1"):
+		rows := &fakeRows{columns: []string{"storage_path", "downloads"}}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for i := range d.rows {
+			if r := &d.rows[i]; r.id == args[0].(string) {
+				r.downloads++
+				rows.values = [][]driver.Value{{r.storagePath, r.downloads}}
+			}
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("fakeDB: unexpected query %q", s.query)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestPublishVersionConcurrent(t *testing.T) {
+	const publishes = 20
+	db := newFakeDB()
+	conn := sql.OpenDB(db)
+	defer conn.Close()
+	s := NewAdapterStore(conn)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*publishes)
+	for i := 0; i < publishes; i++ {
+		for _, name := range []string{"sentiment", "summarizer"} {
+			wg.Add(1)
+			go func(name string, i int) {
+				defer wg.Done()
+				errs <- s.PublishVersion(name, &Adapter{ID: fmt.Sprintf("%s-%d", name, i)})
+			}(name, i)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("PublishVersion: %v", err)
+		}
+	}
+
+	for _, name := range []string{"sentiment", "summarizer"} {
+		versions := db.versions(name)
+		if len(versions) != publishes {
+			t.Fatalf("%s has %d versions, want %d", name, len(versions), publishes)
+		}
+		for i, v := range versions {
+			if v.version != int64(i+1) {
+				t.Fatalf("%s versions = %+v, want 1..%d with no duplicates or gaps", name, versions, publishes)
+			}
+			wantParent := ""
+			if i > 0 {
+				wantParent = versions[i-1].id
+			}
+			if v.parentID != wantParent {
+				t.Errorf("%s v%d parent = %q, want %q", name, v.version, v.parentID, wantParent)
+			}
+		}
+	}
+}
+
+func TestInsertAdapterReportsDuplicateVersion(t *testing.T) {
+	conn := sql.OpenDB(newFakeDB())
+	defer conn.Close()
+
+	if err := insertAdapter(conn, &Adapter{ID: "a1", Name: "sentiment", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	err := insertAdapter(conn, &Adapter{ID: "a2", Name: "sentiment", Version: 1})
+	if !errors.Is(err, ErrVersionExists) {
+		t.Fatalf("err = %v, want ErrVersionExists", err)
+	}
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	s, mock := newMockStore(t)
+	kept := &Adapter{ID: "a2", Name: "kept", Status: StatusActive}
+
+	mock.ExpectExec(`UPDATE adapters SET status = \$1, deleted_at = \$2, updated_at = \$2\s+WHERE id = \$3 AND deleted_at IS NULL`).
+		WithArgs(StatusArchived, sqlmock.AnyArg(), "a1").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.SoftDelete("a1"); err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+
+	// Deleting twice finds nothing to delete
+	mock.ExpectExec(`UPDATE adapters SET status = \$1, deleted_at`).
+		WithArgs(StatusArchived, sqlmock.AnyArg(), "a1").WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := s.SoftDelete("a1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("second SoftDelete err = %v, want sql.ErrNoRows", err)
+	}
+
+	// List hides deleted adapters unless asked for them
+	mock.ExpectQuery(`AND deleted_at IS NULL ORDER BY created_at DESC`).WithArgs(10).WillReturnRows(adapterRows(kept))
+	got, err := s.List(ListFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "a2" {
+		t.Fatalf("List = %v, want only a2", got)
+	}
+	deleted := &Adapter{ID: "a1", Name: "gone", Status: StatusArchived}
+	mock.ExpectQuery(`FROM adapters WHERE 1=1 AND status = \$1 ORDER BY`).WithArgs(StatusArchived, 10).WillReturnRows(adapterRows(deleted))
+	got, err = s.List(ListFilter{Status: StatusArchived, IncludeDeleted: true, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "a1" {
+		t.Fatalf("List with deleted = %v, want a1", got)
+	}
+
+	mock.ExpectExec(`UPDATE adapters SET status = \$1, deleted_at = NULL, updated_at = \$2\s+WHERE id = \$3 AND deleted_at IS NOT NULL`).
+		WithArgs(StatusActive, sqlmock.AnyArg(), "a1").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.Restore("a1"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	// Restoring an adapter that isn't deleted is an error
+	mock.ExpectExec(`deleted_at = NULL`).
+		WithArgs(StatusActive, sqlmock.AnyArg(), "a2").WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := s.Restore("a2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Restore of a live adapter err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+const searchSelect = `SELECT id, name, version, base_model, status, task, owner_id, storage_path, checksum, config, metrics, tags, parent_id, downloads, created_at, updated_at FROM adapters WHERE deleted_at IS NULL`
+
+func TestBuildSearchQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		ownerID string
+		query   string
+		tags    []string
+		where   string
+		args    []interface{}
+	}{
+		{
+			name:  "name or description, case-insensitive",
+			query: "MedChat",
+			where: " AND (name ILIKE $1 OR config::jsonb ->> 'description' ILIKE $1) ORDER BY created_at DESC LIMIT $2",
+			args:  []interface{}{"%MedChat%", 20},
+		},
+		{
+			name:  "wildcards match literally",
+			query: `50%_off\`,
+			where: " AND (name ILIKE $1 OR config::jsonb ->> 'description' ILIKE $1) ORDER BY created_at DESC LIMIT $2",
+			args:  []interface{}{`%50\%\_off\\%`, 20},
+		},
+		{
+			name:  "every tag must be present",
+			tags:  []string{"medical", "chat"},
+			where: " AND tags::jsonb @> $1::jsonb ORDER BY created_at DESC LIMIT $2",
+			args:  []interface{}{`["medical","chat"]`, 20},
+		},
+		{
+			name:    "owner, query and tags",
+			ownerID: "u1",
+			query:   "llama",
+			tags:    []string{"chat"},
+			where:   " AND owner_id = $1 AND (name ILIKE $2 OR config::jsonb ->> 'description' ILIKE $2) AND tags::jsonb @> $3::jsonb ORDER BY created_at DESC LIMIT $4",
+			args:    []interface{}{"u1", "%llama%", `["chat"]`, 20},
+		},
+		{
+			name:  "no criteria",
+			where: " ORDER BY created_at DESC LIMIT $1",
+			args:  []interface{}{20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := buildSearchQuery(tt.ownerID, tt.query, tt.tags, 20)
+			if want := searchSelect + tt.where; query != want {
+				t.Errorf("query =\n%s\nwant\n%s", query, want)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestIncrementDownloadsConcurrent(t *testing.T) {
+	const pulls = 50
+	conn := sql.OpenDB(newFakeDB())
+	defer conn.Close()
+	s := NewAdapterStore(conn)
+	if err := s.Register(&Adapter{ID: "a1", Name: "sentiment", Version: 1, StoragePath: "s3://adapters/a1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	counts := make(chan int64, pulls)
+	for i := 0; i < pulls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, n, err := s.IncrementDownloads("a1")
+			if err != nil || path != "s3://adapters/a1" {
+				t.Errorf("IncrementDownloads = %q, %v", path, err)
+			}
+			counts <- n
+		}()
+	}
+	wg.Wait()
+	close(counts)
+
+	// Every pull saw a distinct count, so none was lost
+	seen := make(map[int64]bool)
+	for n := range counts {
+		if n < 1 || n > pulls || seen[n] {
+			t.Fatalf("count %d returned twice or out of range", n)
+		}
+		seen[n] = true
+	}
+	if len(seen) != pulls {
+		t.Fatalf("got %d distinct counts, want %d", len(seen), pulls)
+	}
+
+	if _, _, err := s.IncrementDownloads("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("missing adapter err = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -4,16 +4,20 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
 	"openlora/api/internal/aggregator"
 	"openlora/api/internal/handlers"
+	"openlora/core/logging"
+	"openlora/core/svcauth"
 )
 
 func main() {
-	log.Println("🌐 OpenLoRA Core API starting...")
+	logging.Setup("api")
+	slog.Info("🌐 OpenLoRA Core API starting...")
 
 	// Initialize aggregator with service endpoints
 	agg := aggregator.New(aggregator.Config{
@@ -27,12 +31,24 @@ func main() {
 		UniversityURL:   getEnv("UNIVERSITY_URL", "http://localhost:8088"),
 	})
 
+	if v := os.Getenv("STATUS_CACHE_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl < 0 {
+			logging.Fatal("Invalid STATUS_CACHE_TTL", "value", v)
+		}
+		agg.SetStatusTTL(ttl)
+	}
+
+	if secret := os.Getenv(svcauth.SecretEnv); secret != "" {
+		agg.SetTransport(svcauth.NewSigner(secret).Transport(nil))
+	}
+
 	server := handlers.NewServer(agg)
 
 	port := getEnv("PORT", "8090")
-	log.Printf("🚀 Core API listening on :%s", port)
+	slog.Info("🚀 Core API listening", "port", port)
 	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
+		logging.Fatal("Server failed", "error", err)
 	}
 }
 
@@ -2,10 +2,12 @@
 package aggregator
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -21,20 +23,56 @@ type Config struct {
 	UniversityURL   string
 }
 
+// DefaultCallTimeout bounds each backend call made while aggregating.
+const DefaultCallTimeout = 3 * time.Second
+
+// DefaultStatusTTL is how long a system status is reused before the
+// backends are probed again.
+const DefaultStatusTTL = 5 * time.Second
+
 // Aggregator fetches and combines data from backend services.
 type Aggregator struct {
-	config Config
-	client *http.Client
+	config      Config
+	client      *http.Client
+	callTimeout time.Duration
+
+	statusMu  sync.Mutex
+	statusTTL time.Duration
+	status    *SystemStatus
+	statusAt  time.Time
 }
 
 // New creates a new Aggregator.
 func New(cfg Config) *Aggregator {
 	return &Aggregator{
-		config: cfg,
-		client: &http.Client{Timeout: 5 * time.Second},
+		config:      cfg,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		callTimeout: DefaultCallTimeout,
+		statusTTL:   DefaultStatusTTL,
 	}
 }
 
+// SetStatusTTL sets how long GetSystemStatus reuses a result. Zero
+// disables caching.
+func (a *Aggregator) SetStatusTTL(d time.Duration) {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.statusTTL = d
+}
+
+// SetCallTimeout sets how long each backend call may take before it is
+// abandoned. Calls run concurrently, so this also bounds a whole
+// aggregate request.
+func (a *Aggregator) SetCallTimeout(d time.Duration) {
+	a.callTimeout = d
+}
+
+// SetTransport replaces the transport used for backend calls, e.g. with
+// one that signs requests.
+func (a *Aggregator) SetTransport(rt http.RoundTripper) {
+	a.client.Transport = rt
+}
+
 // SystemStatus represents the health of all services.
 type SystemStatus struct {
 	Orchestrator string `json:"orchestrator"`
@@ -47,22 +85,76 @@ type SystemStatus struct {
 	University   string `json:"university"`
 }
 
-// GetSystemStatus checks health of all services.
-func (a *Aggregator) GetSystemStatus() SystemStatus {
-	return SystemStatus{
-		Orchestrator: a.checkHealth(a.config.OrchestratorURL),
-		Experiments:  a.checkHealth(a.config.ExperimentsURL),
-		Datasets:     a.checkHealth(a.config.DatasetsURL),
-		Adapters:     a.checkHealth(a.config.AdaptersURL),
-		Metrics:      a.checkHealth(a.config.MetricsURL),
-		Deploy:       a.checkHealth(a.config.DeployURL),
-		Marketplace:  a.checkHealth(a.config.MarketplaceURL),
-		University:   a.checkHealth(a.config.UniversityURL),
+// GetSystemStatus returns the health of all services, reusing the last
+// result if it is younger than the status TTL.
+func (a *Aggregator) GetSystemStatus(ctx context.Context) SystemStatus {
+	return a.systemStatus(ctx, false)
+}
+
+// RefreshSystemStatus probes every service now, bypassing and updating
+// the cache.
+func (a *Aggregator) RefreshSystemStatus(ctx context.Context) SystemStatus {
+	return a.systemStatus(ctx, true)
+}
+
+// systemStatus holds statusMu while probing, so concurrent callers with a
+// stale cache wait for one round of probes instead of each starting one.
+// The probes are detached from ctx, since their result is shared: a caller
+// that gives up must not leave every service marked offline. Each probe is
+// still bounded by the call timeout.
+func (a *Aggregator) systemStatus(ctx context.Context, refresh bool) SystemStatus {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+
+	if !refresh && a.status != nil && time.Since(a.statusAt) < a.statusTTL {
+		return *a.status
 	}
+	status := a.probeAll(context.WithoutCancel(ctx))
+	if ctx.Err() == nil {
+		a.status = &status
+		a.statusAt = time.Now()
+	}
+	return status
 }
 
-func (a *Aggregator) checkHealth(baseURL string) string {
-	resp, err := a.client.Get(baseURL + "/health")
+// probeAll checks health of all services concurrently.
+func (a *Aggregator) probeAll(ctx context.Context) SystemStatus {
+	var status SystemStatus
+	checks := []struct {
+		result  *string
+		baseURL string
+	}{
+		{&status.Orchestrator, a.config.OrchestratorURL},
+		{&status.Experiments, a.config.ExperimentsURL},
+		{&status.Datasets, a.config.DatasetsURL},
+		{&status.Adapters, a.config.AdaptersURL},
+		{&status.Metrics, a.config.MetricsURL},
+		{&status.Deploy, a.config.DeployURL},
+		{&status.Marketplace, a.config.MarketplaceURL},
+		{&status.University, a.config.UniversityURL},
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(result *string, baseURL string) {
+			defer wg.Done()
+			*result = a.checkHealth(ctx, baseURL)
+		}(c.result, c.baseURL)
+	}
+	wg.Wait()
+	return status
+}
+
+func (a *Aggregator) checkHealth(ctx context.Context, baseURL string) string {
+	ctx, cancel := context.WithTimeout(ctx, a.callTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/health", nil)
+	if err != nil {
+		return "offline"
+	}
+	resp, err := a.client.Do(req)
 	if err != nil {
 		return "offline"
 	}
@@ -73,49 +165,113 @@ func (a *Aggregator) checkHealth(baseURL string) string {
 	return "unhealthy"
 }
 
-// DashboardData represents aggregated data for the dashboard.
+// DashboardData represents aggregated data for the dashboard. Errors names
+// each source that could not be fetched; its fields are left empty.
 type DashboardData struct {
 	TotalAdapters    int                      `json:"total_adapters"`
 	TotalExperiments int                      `json:"total_experiments"`
 	TotalDatasets    int                      `json:"total_datasets"`
 	TrendingAdapters []map[string]interface{} `json:"trending_adapters"`
 	RecentMetrics    []map[string]interface{} `json:"recent_metrics"`
+	Errors           []string                 `json:"errors,omitempty"`
 }
 
-// GetDashboard aggregates data for a dashboard view.
-func (a *Aggregator) GetDashboard() (*DashboardData, error) {
+// GetDashboard aggregates data for a dashboard view. Sources are fetched
+// concurrently, and one that fails or times out is reported in Errors
+// rather than failing the whole dashboard.
+func (a *Aggregator) GetDashboard(ctx context.Context) (*DashboardData, error) {
 	data := &DashboardData{}
 
-	// Fetch trending adapters from marketplace
-	trending, err := a.fetchJSON(a.config.MarketplaceURL + "/trending?limit=5")
-	if err == nil {
-		if arr, ok := trending.([]interface{}); ok {
-			for _, item := range arr {
-				if m, ok := item.(map[string]interface{}); ok {
-					data.TrendingAdapters = append(data.TrendingAdapters, m)
-				}
-			}
-			data.TotalAdapters = len(arr)
-		}
+	sources := []struct {
+		name  string
+		fetch func() error
+	}{
+		{"adapters", func() (err error) {
+			data.TotalAdapters, err = a.fetchCount(ctx, a.config.AdaptersURL+"/adapters/count")
+			return err
+		}},
+		{"experiments", func() (err error) {
+			data.TotalExperiments, err = a.fetchCount(ctx, a.config.ExperimentsURL+"/experiments/count")
+			return err
+		}},
+		{"datasets", func() (err error) {
+			data.TotalDatasets, err = a.fetchCount(ctx, a.config.DatasetsURL+"/datasets/count")
+			return err
+		}},
+		{"marketplace", func() (err error) {
+			data.TrendingAdapters, err = a.fetchObjects(ctx, a.config.MarketplaceURL+"/trending?limit=5")
+			return err
+		}},
+		{"metrics", func() (err error) {
+			data.RecentMetrics, err = a.fetchObjects(ctx, a.config.MetricsURL+"/metrics")
+			return err
+		}},
 	}
 
-	// Fetch recent metrics
-	metrics, err := a.fetchJSON(a.config.MetricsURL + "/metrics")
-	if err == nil {
-		if arr, ok := metrics.([]interface{}); ok {
-			for _, item := range arr {
-				if m, ok := item.(map[string]interface{}); ok {
-					data.RecentMetrics = append(data.RecentMetrics, m)
-				}
-			}
-		}
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		wg.Add(1)
+		go func(i int, fetch func() error) {
+			defer wg.Done()
+			errs[i] = fetch()
+		}(i, src.fetch)
 	}
+	wg.Wait()
 
+	for i, err := range errs {
+		if err != nil {
+			data.Errors = append(data.Errors, fmt.Sprintf("%s: %v", sources[i].name, err))
+		}
+	}
 	return data, nil
 }
 
-func (a *Aggregator) fetchJSON(url string) (interface{}, error) {
-	resp, err := a.client.Get(url)
+// fetchCount reads a {"count": n} response.
+func (a *Aggregator) fetchCount(ctx context.Context, url string) (int, error) {
+	result, err := a.fetchJSON(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	obj, ok := result.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("unexpected response from %s", url)
+	}
+	n, ok := obj["count"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("missing count in response from %s", url)
+	}
+	return int(n), nil
+}
+
+// fetchObjects reads a JSON array of objects, skipping other elements.
+func (a *Aggregator) fetchObjects(ctx context.Context, url string) ([]map[string]interface{}, error) {
+	result, err := a.fetchJSON(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response from %s", url)
+	}
+	var objects []map[string]interface{}
+	for _, item := range arr {
+		if m, ok := item.(map[string]interface{}); ok {
+			objects = append(objects, m)
+		}
+	}
+	return objects, nil
+}
+
+func (a *Aggregator) fetchJSON(ctx context.Context, url string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.callTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -137,8 +293,26 @@ func (a *Aggregator) fetchJSON(url string) (interface{}, error) {
 	return result, nil
 }
 
-// ProxyRequest forwards a request to a backend service.
-func (a *Aggregator) ProxyRequest(service, path string) ([]byte, error) {
+// ProxyRequest forwards a request to a backend service. path may include
+// a query string. The caller must close the response body.
+func (a *Aggregator) ProxyRequest(ctx context.Context, service, method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	baseURL, err := a.serviceURL(service)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return a.client.Do(req)
+}
+
+// serviceURL returns the base URL of a backend service by name.
+func (a *Aggregator) serviceURL(service string) (string, error) {
 	var baseURL string
 	switch service {
 	case "orchestrator":
@@ -158,14 +332,7 @@ func (a *Aggregator) ProxyRequest(service, path string) ([]byte, error) {
 	case "university":
 		baseURL = a.config.UniversityURL
 	default:
-		return nil, fmt.Errorf("unknown service: %s", service)
+		return "", fmt.Errorf("unknown service: %s", service)
 	}
-
-	resp, err := a.client.Get(baseURL + path)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	return io.ReadAll(resp.Body)
+	return baseURL, nil
 }
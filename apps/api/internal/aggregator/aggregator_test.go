@@ -0,0 +1,227 @@
+package aggregator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCountingAggregator points every service at one backend that counts
+// health probes.
+func newCountingAggregator(t *testing.T) (*Aggregator, *atomic.Int64) {
+	t.Helper()
+	var probes atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			probes.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	a := New(Config{
+		OrchestratorURL: srv.URL,
+		ExperimentsURL:  srv.URL,
+		DatasetsURL:     srv.URL,
+		AdaptersURL:     srv.URL,
+		MetricsURL:      srv.URL,
+		DeployURL:       srv.URL,
+		MarketplaceURL:  srv.URL,
+		UniversityURL:   srv.URL,
+	})
+	a.SetStatusTTL(time.Hour)
+	return a, &probes
+}
+
+const servicesProbed = 8
+
+func TestGetSystemStatusCaches(t *testing.T) {
+	a, probes := newCountingAggregator(t)
+	ctx := context.Background()
+
+	first := a.GetSystemStatus(ctx)
+	second := a.GetSystemStatus(ctx)
+	if first != second || first.Orchestrator != "healthy" {
+		t.Fatalf("statuses = %+v, %+v", first, second)
+	}
+	if n := probes.Load(); n != servicesProbed {
+		t.Fatalf("probes = %d, want %d", n, servicesProbed)
+	}
+}
+
+func TestRefreshSystemStatusBypassesCache(t *testing.T) {
+	a, probes := newCountingAggregator(t)
+	ctx := context.Background()
+
+	a.GetSystemStatus(ctx)
+	a.RefreshSystemStatus(ctx)
+	if n := probes.Load(); n != 2*servicesProbed {
+		t.Fatalf("probes = %d, want %d", n, 2*servicesProbed)
+	}
+	a.GetSystemStatus(ctx)
+	if n := probes.Load(); n != 2*servicesProbed {
+		t.Fatalf("probes after refresh = %d, want the refreshed result reused", n)
+	}
+}
+
+func TestCanceledCallerDoesNotPoisonCache(t *testing.T) {
+	a, probes := newCountingAggregator(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if status := a.GetSystemStatus(ctx); status.Orchestrator != "healthy" {
+		t.Fatalf("orchestrator = %q, want healthy despite the canceled caller", status.Orchestrator)
+	}
+	if status := a.GetSystemStatus(context.Background()); status.Metrics != "healthy" {
+		t.Fatalf("metrics = %q, want healthy", status.Metrics)
+	}
+	if n := probes.Load(); n != 2*servicesProbed {
+		t.Fatalf("probes = %d, want the canceled caller's result left uncached", n)
+	}
+}
+
+// newSlowServer returns a backend that answers after delay, or gives up
+// when the caller does. Count endpoints report 1; anything else gets a
+// one-item list.
+func newSlowServer(t *testing.T, delay time.Duration) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/count") {
+			w.Write([]byte(`{"count": 1}`))
+			return
+		}
+		w.Write([]byte(`[{"id": "a1"}]`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// newSlowAggregator points each service at its own slow backend, with the
+// university and marketplace taking twice as long as the rest.
+func newSlowAggregator(t *testing.T, delay time.Duration) *Aggregator {
+	t.Helper()
+	return New(Config{
+		OrchestratorURL: newSlowServer(t, delay),
+		ExperimentsURL:  newSlowServer(t, delay),
+		DatasetsURL:     newSlowServer(t, delay),
+		AdaptersURL:     newSlowServer(t, delay),
+		MetricsURL:      newSlowServer(t, delay),
+		DeployURL:       newSlowServer(t, delay),
+		MarketplaceURL:  newSlowServer(t, 2*delay),
+		UniversityURL:   newSlowServer(t, 2*delay),
+	})
+}
+
+func TestAggregatesFetchConcurrently(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	a := newSlowAggregator(t, delay)
+
+	// Sequential calls would take 10*delay for the status and 6*delay for
+	// the dashboard; concurrent ones about the slowest, 2*delay
+	start := time.Now()
+	status := a.RefreshSystemStatus(context.Background())
+	if elapsed := time.Since(start); elapsed < 2*delay || elapsed > 5*delay {
+		t.Fatalf("status took %v, want about the slowest probe (%v)", elapsed, 2*delay)
+	}
+	if status.University != "healthy" || status.Orchestrator != "healthy" {
+		t.Fatalf("status = %+v, want every service healthy", status)
+	}
+
+	start = time.Now()
+	data, err := a.GetDashboard(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*delay || elapsed > 5*delay {
+		t.Fatalf("dashboard took %v, want about the slowest fetch (%v)", elapsed, 2*delay)
+	}
+	if len(data.Errors) != 0 || data.TotalAdapters != 1 || len(data.TrendingAdapters) != 1 {
+		t.Fatalf("dashboard = %+v, want every source fetched", data)
+	}
+}
+
+func TestSlowServiceBoundedByCallTimeout(t *testing.T) {
+	a := newSlowAggregator(t, 0)
+	a.config.MetricsURL = newSlowServer(t, time.Hour)
+	a.config.MarketplaceURL = newSlowServer(t, time.Hour)
+	const timeout = 100 * time.Millisecond
+	a.SetCallTimeout(timeout)
+
+	start := time.Now()
+	status := a.RefreshSystemStatus(context.Background())
+	if elapsed := time.Since(start); elapsed > 5*timeout {
+		t.Fatalf("status took %v, want about one call timeout (%v)", elapsed, timeout)
+	}
+	if status.Metrics != "offline" || status.Marketplace != "offline" || status.Deploy != "healthy" {
+		t.Fatalf("status = %+v, want the hung services offline and the rest healthy", status)
+	}
+
+	start = time.Now()
+	data, err := a.GetDashboard(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*timeout {
+		t.Fatalf("dashboard took %v, want about one call timeout (%v)", elapsed, timeout)
+	}
+	if len(data.Errors) != 2 || data.TotalDatasets != 1 {
+		t.Fatalf("dashboard = %+v, want only metrics and marketplace failed", data)
+	}
+}
+
+func TestDashboardToleratesFailedServices(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/adapters/count":
+			w.Write([]byte(`{"count": 12}`))
+		case "/datasets/count":
+			w.Write([]byte(`{"count": 4}`))
+		case "/trending":
+			if r.URL.Query().Get("limit") != "5" {
+				t.Errorf("trending limit = %q, want 5", r.URL.Query().Get("limit"))
+			}
+			w.Write([]byte(`[{"id": "a1"}, {"id": "a2"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(up.Close)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database unavailable", http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close() // refuses connections
+
+	a := New(Config{
+		AdaptersURL:    up.URL,
+		DatasetsURL:    up.URL,
+		MarketplaceURL: up.URL,
+		ExperimentsURL: failing.URL,
+		MetricsURL:     down.URL,
+	})
+	data, err := a.GetDashboard(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.TotalAdapters != 12 || data.TotalDatasets != 4 || len(data.TrendingAdapters) != 2 {
+		t.Errorf("dashboard = %+v, want counts and trending from the services that are up", data)
+	}
+	if data.TotalExperiments != 0 || data.RecentMetrics != nil {
+		t.Errorf("failed sources filled in: experiments %d, metrics %v", data.TotalExperiments, data.RecentMetrics)
+	}
+	if len(data.Errors) != 2 || !strings.HasPrefix(data.Errors[0], "experiments: status 500") ||
+		!strings.HasPrefix(data.Errors[1], "metrics: ") {
+		t.Errorf("errors = %q, want experiments then metrics", data.Errors)
+	}
+}
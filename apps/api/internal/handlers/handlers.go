@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strings"
 
@@ -66,14 +67,20 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// handleStatus reports backend health, cached briefly unless ?refresh=true.
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
-	status := s.agg.GetSystemStatus()
+	var status aggregator.SystemStatus
+	if r.URL.Query().Get("refresh") == "true" {
+		status = s.agg.RefreshSystemStatus(r.Context())
+	} else {
+		status = s.agg.GetSystemStatus(r.Context())
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
 
 func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
-	data, err := s.agg.GetDashboard()
+	data, err := s.agg.GetDashboard(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -82,6 +89,23 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// proxyRequestHeaders are the client headers forwarded to backends. They
+// only ever go to the backend the path resolves to; the HTTP client drops
+// Authorization if that backend redirects to another host.
+var proxyRequestHeaders = []string{"Content-Type", "Accept", "Authorization", "X-Request-ID"}
+
+// hopHeaders describe a single connection and are not copied back from
+// backend responses.
+var hopHeaders = map[string]bool{
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Content-Length":    true,
+}
+
+// handleProxy forwards a request to a backend service, preserving method,
+// body, query and status.
 func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 	// /proxy/{service}/{path...}
 	path := strings.TrimPrefix(r.URL.Path, "/proxy/")
@@ -97,12 +121,29 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 		subPath = "/" + parts[1]
 	}
 
-	body, err := s.agg.ProxyRequest(service, subPath)
+	if r.URL.RawQuery != "" {
+		subPath += "?" + r.URL.RawQuery
+	}
+
+	header := make(http.Header)
+	for _, name := range proxyRequestHeaders {
+		if v := r.Header.Values(name); len(v) > 0 {
+			header[name] = v
+		}
+	}
+
+	resp, err := s.agg.ProxyRequest(r.Context(), service, r.Method, subPath, r.Body, header)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
+	defer resp.Body.Close()
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(body)
+	for k, v := range resp.Header {
+		if !hopHeaders[k] {
+			w.Header()[k] = v
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openlora/api/internal/aggregator"
+)
+
+// received is what a backend saw of a proxied request.
+type received struct {
+	method, path, query, body string
+	header                    http.Header
+}
+
+// newProxyServer returns a Core API server whose experiments service is a
+// backend that records each request on the returned channel and answers
+// 201 with an X-Backend header.
+func newProxyServer(t *testing.T) (*Server, <-chan received) {
+	t.Helper()
+	reqs := make(chan received, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs <- received{r.Method, r.URL.Path, r.URL.RawQuery, string(body), r.Header.Clone()}
+		w.Header().Set("X-Backend", "experiments")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "exp-1"}`))
+	}))
+	t.Cleanup(backend.Close)
+	return NewServer(aggregator.New(aggregator.Config{ExperimentsURL: backend.URL})), reqs
+}
+
+// backendRequest returns the request the backend recorded. Proxying is
+// synchronous, so by the time ServeHTTP returns it has either arrived or
+// never will.
+func backendRequest(t *testing.T, reqs <-chan received) received {
+	t.Helper()
+	select {
+	case got := <-reqs:
+		return got
+	default:
+		t.Fatal("request never reached the backend")
+		return received{}
+	}
+}
+
+func TestProxyForwardsMethodAndBody(t *testing.T) {
+	srv, reqs := newProxyServer(t)
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			body := `{"name": "lr-sweep"}`
+			req := httptest.NewRequest(method, "/proxy/experiments/experiments/exp-1?dry_run=true", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			got := backendRequest(t, reqs)
+			if got.method != method || got.path != "/experiments/exp-1" || got.query != "dry_run=true" {
+				t.Errorf("backend got %s %s?%s, want %s /experiments/exp-1?dry_run=true", got.method, got.path, got.query, method)
+			}
+			if got.body != body || got.header.Get("Content-Type") != "application/json" {
+				t.Errorf("backend got body %q as %q, want %q as application/json", got.body, got.header.Get("Content-Type"), body)
+			}
+
+			// The backend's status, headers and body come back unchanged
+			if rec.Code != http.StatusCreated || rec.Header().Get("X-Backend") != "experiments" || rec.Body.String() != `{"id": "exp-1"}` {
+				t.Errorf("client got %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxyRootPath(t *testing.T) {
+	srv, reqs := newProxyServer(t)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy/experiments", nil))
+	if got := backendRequest(t, reqs); got.path != "/" || got.body != "" {
+		t.Errorf("backend got %s with body %q, want / with none", got.path, got.body)
+	}
+}
+
+func TestProxyForwardsAuthToResolvedBackendOnly(t *testing.T) {
+	srv, reqs := newProxyServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/proxy/experiments/experiments", nil)
+	req.Header.Set("Authorization", "Bearer token-1")
+	req.Header.Set("X-Request-ID", "req-42")
+	req.Header.Set("Cookie", "session=secret")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := backendRequest(t, reqs)
+	if got.header.Get("Authorization") != "Bearer token-1" || got.header.Get("X-Request-ID") != "req-42" {
+		t.Errorf("backend headers = %v, want Authorization and X-Request-ID forwarded", got.header)
+	}
+	if got.header.Get("Cookie") != "" {
+		t.Errorf("Cookie forwarded: %q", got.header.Get("Cookie"))
+	}
+
+	// An unknown service fails as before, and nothing is sent anywhere
+	req = httptest.NewRequest(http.MethodGet, "/proxy/billing/invoices", nil)
+	req.Header.Set("Authorization", "Bearer token-1")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "unknown service: billing") {
+		t.Errorf("unknown service = %d %q, want 502 unknown service", rec.Code, rec.Body.String())
+	}
+	select {
+	case got := <-reqs:
+		t.Errorf("unknown service reached a backend: %+v", got)
+	default:
+	}
+}
+
+func TestProxyDropsAuthOnCrossHostRedirect(t *testing.T) {
+	auth := make(chan string, 1)
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth <- r.Header.Get("Authorization")
+	}))
+	t.Cleanup(elsewhere.Close)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Same machine, different host name
+		http.Redirect(w, r, strings.Replace(elsewhere.URL, "127.0.0.1", "localhost", 1)+"/steal", http.StatusFound)
+	}))
+	t.Cleanup(backend.Close)
+	srv := NewServer(aggregator.New(aggregator.Config{ExperimentsURL: backend.URL}))
+
+	req := httptest.NewRequest(http.MethodGet, "/proxy/experiments/experiments", nil)
+	req.Header.Set("Authorization", "Bearer token-1")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case got := <-auth:
+		if got != "" {
+			t.Fatalf("redirect target got Authorization %q", got)
+		}
+	default:
+		t.Fatal("redirect was not followed")
+	}
+}
@@ -4,18 +4,22 @@ package main
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
+	"openlora/core/logging"
+	"openlora/core/svcauth"
 	"openlora/datasets/internal/api"
+	"openlora/datasets/internal/blob"
 	"openlora/datasets/internal/store"
 
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	log.Println("📊 OpenLoRA Dataset Registry starting...")
+	logging.Setup("datasets")
+	slog.Info("📊 OpenLoRA Dataset Registry starting...")
 
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
@@ -24,11 +28,14 @@ func main() {
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 	defer db.Close()
 
 	datasetStore := store.NewDatasetStore(db)
+	if root := os.Getenv("DATASETS_BLOB_ROOT"); root != "" {
+		datasetStore.SetBlobStore(blob.NewLocal(root))
+	}
 	server := api.NewServer(datasetStore)
 
 	port := os.Getenv("PORT")
@@ -36,8 +43,8 @@ func main() {
 		port = "8083"
 	}
 
-	log.Printf("🌐 Listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	slog.Info("🌐 Listening", "port", port)
+	if err := http.ListenAndServe(":"+port, svcauth.Middleware(os.Getenv(svcauth.SecretEnv), server)); err != nil {
+		logging.Fatal("Server failed", "error", err)
 	}
 }
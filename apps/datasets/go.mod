@@ -3,6 +3,10 @@ module openlora/datasets
 go 1.21
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/google/uuid v1.5.0
 	github.com/lib/pq v1.10.9
+	openlora/core v0.0.0
 )
+
+replace openlora/core => ../../packages/core-go
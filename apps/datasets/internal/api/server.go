@@ -2,10 +2,15 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"openlora/core/jsonbody"
 	"openlora/datasets/internal/store"
 
 	"github.com/google/uuid"
@@ -28,8 +33,11 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/health", s.handleHealth)
 	s.mux.HandleFunc("/datasets", s.handleDatasets)
 	s.mux.HandleFunc("/datasets/", s.handleDatasetByID)
+	s.mux.HandleFunc("/datasets/merge", s.handleMerge)
+	s.mux.HandleFunc("/datasets/count", s.handleCount)
 	s.mux.HandleFunc("/versions", s.handleVersions)
 	s.mux.HandleFunc("/lineage", s.handleLineage)
+	s.mux.HandleFunc("/lineage/graph", s.handleLineageGraph)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -40,6 +48,22 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// handleCount reports how many datasets exist, without listing them.
+func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	n, err := s.store.Count()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"count": n})
+}
+
 func (s *Server) handleDatasets(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -55,7 +79,7 @@ func (s *Server) handleDatasets(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var ds store.Dataset
-		if err := json.NewDecoder(r.Body).Decode(&ds); err != nil {
+		if err := jsonbody.Decode(w, r, &ds); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -63,19 +87,87 @@ func (s *Server) handleDatasets(w http.ResponseWriter, r *http.Request) {
 		ds.CreatedAt = time.Now()
 		ds.UpdatedAt = time.Now()
 
-		if err := s.store.Register(&ds); err != nil {
+		err := s.store.RegisterValidated(&ds, r.URL.Query().Get("check_storage") == "true")
+		if errors.Is(err, store.ErrUnsupportedFormat) || errors.Is(err, store.ErrStorageNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(ds)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
 	}
 }
 
+func (s *Server) handleMerge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		Name    string              `json:"name"`
+		Sources []store.MergeSource `json:"sources"`
+		Dedup   bool                `json:"dedup"`
+	}
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name required", http.StatusBadRequest)
+		return
+	}
+
+	ds, v, err := s.store.Merge(req.Name, req.Sources, req.Dedup)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrNotLineDelimited):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, store.ErrNoBlobStore):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"dataset": ds, "version": v})
+}
+
 func (s *Server) handleDatasetByID(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Path[len("/datasets/"):]
+	// /datasets/{id}[/schema|/split|/versions/tag/{tag}]
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/datasets/"):], "/"), "/")
+	id := parts[0]
+
+	switch {
+	case len(parts) == 1:
+		s.handleGetDataset(w, r, id)
+	case len(parts) == 2 && parts[1] == "schema":
+		s.handleSchema(w, r, id)
+	case len(parts) == 2 && parts[1] == "split":
+		s.handleSplit(w, r, id)
+	case len(parts) == 4 && parts[1] == "versions" && parts[2] == "tag":
+		s.handleVersionTag(w, r, id, parts[3])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleGetDataset(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
 	ds, err := s.store.Get(id)
 	if err != nil {
 		http.Error(w, "Not found", http.StatusNotFound)
@@ -85,6 +177,126 @@ func (s *Server) handleDatasetByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(ds)
 }
 
+func (s *Server) handleSplit(w http.ResponseWriter, r *http.Request, parentID string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		Ratios map[string]float64 `json:"ratios"`
+		Seed   int64              `json:"seed"`
+	}
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Ratios == nil {
+		req.Ratios = map[string]float64{"train": 0.8, "val": 0.1, "test": 0.1}
+	}
+
+	children, err := s.store.CreateSplit(parentID, req.Ratios, req.Seed)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrInvalidRatios), errors.Is(err, store.ErrNotLineDelimited):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, store.ErrNoBlobStore):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(children)
+}
+
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request, datasetID string) {
+	switch r.Method {
+	case http.MethodGet:
+		schema, err := s.store.GetSchema(datasetID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Schema not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schema)
+
+	case http.MethodPut:
+		var schema store.DatasetSchema
+		if err := jsonbody.Decode(w, r, &schema); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := s.store.Get(datasetID); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		err := s.store.SetSchema(datasetID, &schema)
+		if errors.Is(err, store.ErrInvalidSchema) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schema)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut)
+	}
+}
+
+func (s *Server) handleVersionTag(w http.ResponseWriter, r *http.Request, datasetID, tag string) {
+	switch r.Method {
+	case http.MethodGet:
+		v, err := s.store.GetVersionByTag(datasetID, tag)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Tag not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+
+	case http.MethodPut:
+		var req struct {
+			Version int `json:"version"`
+		}
+		if err := jsonbody.Decode(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t, err := s.store.TagVersion(datasetID, req.Version, tag)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Version not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut)
+	}
+}
+
 func (s *Server) handleVersions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -100,10 +312,23 @@ func (s *Server) handleVersions(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var v store.DatasetVersion
-		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		if err := jsonbody.Decode(w, r, &v); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+
+		// Without an explicit version the server numbers it and dedups
+		// identical re-uploads against the latest version
+		if v.Version == 0 {
+			created, err := s.store.CreateVersionAuto(v.DatasetID, v.Checksum, v.RowCount, v.SizeBytes)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(created)
+			return
+		}
+
 		v.ID = uuid.New().String()
 		v.CreatedAt = time.Now()
 
@@ -114,11 +339,16 @@ func (s *Server) handleVersions(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(v)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
 	}
 }
 
 func (s *Server) handleLineage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	datasetID := r.URL.Query().Get("dataset_id")
 	lineage, err := s.store.GetLineage(datasetID)
@@ -128,3 +358,40 @@ func (s *Server) handleLineage(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewEncoder(w).Encode(lineage)
 }
+
+func (s *Server) handleLineageGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	datasetID := r.URL.Query().Get("dataset_id")
+	if datasetID == "" {
+		http.Error(w, "dataset_id required", http.StatusBadRequest)
+		return
+	}
+	maxDepth := 0
+	if v := r.URL.Query().Get("max_depth"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 1 {
+			http.Error(w, "invalid max_depth", http.StatusBadRequest)
+			return
+		}
+		maxDepth = d
+	}
+
+	graph, err := s.store.GetLineageGraph(datasetID, maxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(graph)
+}
+
+// methodNotAllowed responds with 405 and an Allow header listing the
+// methods the route supports.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
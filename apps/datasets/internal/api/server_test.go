@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"openlora/datasets/internal/store"
+)
+
+func TestMethodNotAllowedListsAllowedMethods(t *testing.T) {
+	// Method checks run before any store access, so no database is needed
+	srv := NewServer(store.NewDatasetStore(nil))
+
+	tests := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{http.MethodPost, "/datasets/count", "GET"},
+		{http.MethodDelete, "/datasets", "GET, POST"},
+		{http.MethodGet, "/datasets/merge", "POST"},
+		{http.MethodPost, "/datasets/d1", "GET"},
+		{http.MethodGet, "/datasets/d1/split", "POST"},
+		{http.MethodPost, "/datasets/d1/schema", "GET, PUT"},
+		{http.MethodDelete, "/datasets/d1/versions/tag/prod", "GET, PUT"},
+		{http.MethodPut, "/versions", "GET, POST"},
+		{http.MethodPost, "/lineage", "GET"},
+		{http.MethodPost, "/lineage/graph", "GET"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s = %d with Allow %q, want 405 with %q", tt.method, tt.path, rec.Code, rec.Header().Get("Allow"), tt.allow)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package blob stores dataset contents by key.
+package blob
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that would escape the store root.
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Store reads and writes blobs addressed by slash-separated keys.
+type Store interface {
+	Open(key string) (io.ReadCloser, error)
+	Create(key string) (io.WriteCloser, error)
+}
+
+// Local is a Store backed by a directory on the local filesystem.
+type Local struct {
+	root string
+}
+
+// NewLocal creates a store rooted at dir.
+func NewLocal(dir string) *Local {
+	return &Local{root: dir}
+}
+
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.root, filepath.FromSlash(clean)), nil
+}
+
+// Open opens the blob at key for reading.
+func (l *Local) Open(key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Create opens the blob at key for writing, replacing any existing one.
+func (l *Local) Create(key string) (io.WriteCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(p)
+}
@@ -0,0 +1,73 @@
+package store
+
+// DefaultLineageDepth bounds GetLineageGraph when no depth is given.
+const DefaultLineageDepth = 10
+
+// LineageNode is a dataset in a lineage graph, at its shortest distance
+// from the root.
+type LineageNode struct {
+	DatasetID string `json:"dataset_id"`
+	Depth     int    `json:"depth"`
+}
+
+// LineageEdge points from a source dataset to the dataset derived from it.
+type LineageEdge struct {
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Operation LineageOperation `json:"operation"`
+	EntryID   string           `json:"entry_id"`
+}
+
+// LineageGraph is the ancestry of a dataset.
+type LineageGraph struct {
+	Root  string        `json:"root"`
+	Nodes []LineageNode `json:"nodes"`
+	Edges []LineageEdge `json:"edges"`
+	// Truncated is set when ancestors exist beyond the depth limit.
+	Truncated bool `json:"truncated"`
+}
+
+// GetLineageGraph walks SourceIDs from datasetID back through its
+// ancestors, up to maxDepth hops (DefaultLineageDepth if maxDepth <= 0).
+// Each dataset is expanded once, so cyclic lineage terminates.
+func (s *DatasetStore) GetLineageGraph(datasetID string, maxDepth int) (*LineageGraph, error) {
+	return buildLineageGraph(datasetID, maxDepth, s.GetLineage)
+}
+
+func buildLineageGraph(root string, maxDepth int, lineageOf func(string) ([]*LineageEntry, error)) (*LineageGraph, error) {
+	if maxDepth <= 0 {
+		maxDepth = DefaultLineageDepth
+	}
+
+	g := &LineageGraph{Root: root, Nodes: []LineageNode{{DatasetID: root}}, Edges: []LineageEdge{}}
+	seen := map[string]bool{root: true}
+	frontier := []string{root}
+
+	for depth := 1; len(frontier) > 0; depth++ {
+		var next []string
+		for _, id := range frontier {
+			entries, err := lineageOf(id)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				for _, src := range e.SourceIDs {
+					if depth > maxDepth {
+						g.Truncated = true
+						continue
+					}
+					g.Edges = append(g.Edges, LineageEdge{From: src, To: id, Operation: e.Operation, EntryID: e.ID})
+					if seen[src] {
+						continue
+					}
+					seen[src] = true
+					g.Nodes = append(g.Nodes, LineageNode{DatasetID: src, Depth: depth})
+					next = append(next, src)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return g, nil
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lineageMap maps a dataset ID to the entries that produced it.
+type lineageMap map[string][]*LineageEntry
+
+func (m lineageMap) lineageOf(id string) ([]*LineageEntry, error) {
+	return m[id], nil
+}
+
+func TestBuildLineageGraph(t *testing.T) {
+	tests := []struct {
+		name      string
+		lineage   lineageMap
+		maxDepth  int
+		nodes     []LineageNode
+		edges     []LineageEdge
+		truncated bool
+	}{
+		{
+			name: "merge of two sources",
+			lineage: lineageMap{
+				"merged":  {{ID: "e1", Operation: OpMerged, SourceIDs: []string{"clean-a", "b"}}},
+				"clean-a": {{ID: "e2", Operation: OpFiltered, SourceIDs: []string{"raw-a"}}},
+			},
+			nodes: []LineageNode{{"merged", 0}, {"clean-a", 1}, {"b", 1}, {"raw-a", 2}},
+			edges: []LineageEdge{
+				{From: "clean-a", To: "merged", Operation: OpMerged, EntryID: "e1"},
+				{From: "b", To: "merged", Operation: OpMerged, EntryID: "e1"},
+				{From: "raw-a", To: "clean-a", Operation: OpFiltered, EntryID: "e2"},
+			},
+		},
+		{
+			name: "shared ancestor appears once",
+			lineage: lineageMap{
+				"merged": {{ID: "e1", Operation: OpMerged, SourceIDs: []string{"a", "b"}}},
+				"a":      {{ID: "e2", Operation: OpFiltered, SourceIDs: []string{"raw"}}},
+				"b":      {{ID: "e3", Operation: OpSampled, SourceIDs: []string{"raw"}}},
+			},
+			nodes: []LineageNode{{"merged", 0}, {"a", 1}, {"b", 1}, {"raw", 2}},
+			edges: []LineageEdge{
+				{From: "a", To: "merged", Operation: OpMerged, EntryID: "e1"},
+				{From: "b", To: "merged", Operation: OpMerged, EntryID: "e1"},
+				{From: "raw", To: "a", Operation: OpFiltered, EntryID: "e2"},
+				{From: "raw", To: "b", Operation: OpSampled, EntryID: "e3"},
+			},
+		},
+		{
+			name: "cycle terminates",
+			lineage: lineageMap{
+				"x": {{ID: "e1", Operation: OpTransformed, SourceIDs: []string{"y"}}},
+				"y": {{ID: "e2", Operation: OpTransformed, SourceIDs: []string{"x"}}},
+			},
+			nodes: []LineageNode{{"x", 0}, {"y", 1}},
+			edges: []LineageEdge{
+				{From: "y", To: "x", Operation: OpTransformed, EntryID: "e1"},
+				{From: "x", To: "y", Operation: OpTransformed, EntryID: "e2"},
+			},
+		},
+		{
+			name: "depth limit truncates",
+			lineage: lineageMap{
+				"c": {{ID: "e1", Operation: OpFiltered, SourceIDs: []string{"b"}}},
+				"b": {{ID: "e2", Operation: OpFiltered, SourceIDs: []string{"a"}}},
+			},
+			maxDepth:  1,
+			nodes:     []LineageNode{{"c", 0}, {"b", 1}},
+			edges:     []LineageEdge{{From: "b", To: "c", Operation: OpFiltered, EntryID: "e1"}},
+			truncated: true,
+		},
+		{
+			name:    "no lineage",
+			lineage: lineageMap{},
+			nodes:   []LineageNode{{"solo", 0}},
+			edges:   []LineageEdge{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := tt.nodes[0].DatasetID
+			g, err := buildLineageGraph(root, tt.maxDepth, tt.lineage.lineageOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.Root != root || g.Truncated != tt.truncated {
+				t.Errorf("root %q truncated %v, want %q %v", g.Root, g.Truncated, root, tt.truncated)
+			}
+			if !reflect.DeepEqual(g.Nodes, tt.nodes) {
+				t.Errorf("nodes = %v, want %v", g.Nodes, tt.nodes)
+			}
+			if !reflect.DeepEqual(g.Edges, tt.edges) {
+				t.Errorf("edges = %v, want %v", g.Edges, tt.edges)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"openlora/datasets/internal/blob"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNoBlobStore is returned by operations that need dataset contents
+	// when no blob store is configured.
+	ErrNoBlobStore = errors.New("no blob store configured")
+	// ErrNotLineDelimited is returned when merging or splitting a dataset
+	// that is not line-delimited.
+	ErrNotLineDelimited = errors.New("operation supports jsonl datasets only")
+)
+
+// MergeSource names one dataset version to merge.
+type MergeSource struct {
+	DatasetID string `json:"dataset_id"`
+	Version   int    `json:"version"`
+}
+
+// SetBlobStore configures where dataset contents are read and written.
+func (s *DatasetStore) SetBlobStore(b blob.Store) {
+	s.blobs = b
+}
+
+// VersionKey is the blob key holding a version's contents.
+func VersionKey(ds *Dataset, version int) string {
+	return path.Join(ds.StoragePath, fmt.Sprintf("v%d.%s", version, ds.Format))
+}
+
+// Merge concatenates the given source versions, in order, into a new
+// dataset called name. With dedup, repeated records are kept only on their
+// first occurrence. The new dataset gets version 1 and a "merged" lineage
+// entry listing every source dataset.
+func (s *DatasetStore) Merge(name string, sources []MergeSource, dedup bool) (*Dataset, *DatasetVersion, error) {
+	if s.blobs == nil {
+		return nil, nil, ErrNoBlobStore
+	}
+	if len(sources) < 2 {
+		return nil, nil, errors.New("merge needs at least two sources")
+	}
+
+	keys := make([]string, len(sources))
+	sourceIDs := make([]string, len(sources))
+	var owner string
+	for i, src := range sources {
+		ds, err := s.Get(src.DatasetID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("source %s: %w", src.DatasetID, err)
+		}
+		if ds.Format != "jsonl" {
+			return nil, nil, fmt.Errorf("%w: %s is %s", ErrNotLineDelimited, ds.ID, ds.Format)
+		}
+		var exists bool
+		if err := s.db.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM dataset_versions WHERE dataset_id = $1 AND version = $2)
+		`, src.DatasetID, src.Version).Scan(&exists); err != nil {
+			return nil, nil, err
+		}
+		if !exists {
+			return nil, nil, fmt.Errorf("source %s v%d: %w", src.DatasetID, src.Version, sql.ErrNoRows)
+		}
+		if owner == "" {
+			owner = ds.OwnerID
+		}
+		keys[i] = VersionKey(ds, src.Version)
+		sourceIDs[i] = ds.ID
+	}
+
+	now := time.Now()
+	merged := &Dataset{
+		ID:          uuid.New().String(),
+		Name:        name,
+		OwnerID:     owner,
+		Format:      "jsonl",
+		Description: fmt.Sprintf("Merge of %d datasets", len(sources)),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	merged.StoragePath = path.Join("datasets", merged.ID)
+
+	out, err := s.blobs.Create(VersionKey(merged, 1))
+	if err != nil {
+		return nil, nil, err
+	}
+	stats, err := mergeLines(out, s.blobs, keys, dedup)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.Register(merged); err != nil {
+		return nil, nil, err
+	}
+	v, err := s.CreateVersionAuto(merged.ID, stats.checksum, stats.rows, stats.bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.RecordLineage(&LineageEntry{
+		ID:          uuid.New().String(),
+		DatasetID:   merged.ID,
+		VersionID:   v.ID,
+		Operation:   OpMerged,
+		SourceIDs:   sourceIDs,
+		Actor:       owner,
+		Description: fmt.Sprintf("merged %d sources, %d rows (%d duplicates dropped)", len(sources), stats.rows, stats.dropped),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return merged, v, nil
+}
+
+type mergeStats struct {
+	rows, bytes, dropped int64
+	checksum             string
+}
+
+// mergeLines copies every non-empty line of each blob to w, skipping
+// lines already written when dedup is set.
+func mergeLines(w io.Writer, blobs blob.Store, keys []string, dedup bool) (mergeStats, error) {
+	var stats mergeStats
+	h := sha256.New()
+	out := io.MultiWriter(w, h)
+	seen := make(map[[sha256.Size]byte]struct{})
+
+	for _, key := range keys {
+		in, err := blobs.Open(key)
+		if err != nil {
+			return stats, err
+		}
+		r := bufio.NewReader(in)
+		for {
+			line, err := r.ReadBytes('\n')
+			if rec := bytes.TrimRight(line, "\r\n"); len(bytes.TrimSpace(rec)) > 0 {
+				dup := false
+				if dedup {
+					sum := sha256.Sum256(rec)
+					_, dup = seen[sum]
+					seen[sum] = struct{}{}
+				}
+				if dup {
+					stats.dropped++
+				} else {
+					n, werr := out.Write(append(rec, '\n'))
+					if werr != nil {
+						in.Close()
+						return stats, werr
+					}
+					stats.rows++
+					stats.bytes += int64(n)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				in.Close()
+				return stats, err
+			}
+		}
+		in.Close()
+	}
+
+	stats.checksum = hex.EncodeToString(h.Sum(nil))
+	return stats, nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"openlora/datasets/internal/blob"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// writeBlob stores content at key in dir's blob store.
+func writeBlob(t *testing.T, dir, key, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readBlob returns the content stored at key.
+func readBlob(t *testing.T, blobs blob.Store, key string) string {
+	t.Helper()
+	r, err := blobs.Open(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Two small sources sharing {"x":2}; a repeats {"x":1} internally, has a
+// blank line and b uses CRLF with no trailing newline.
+const (
+	mergeSourceA = "{\"x\":1}\n{\"x\":2}\n\n{\"x\":1}\n"
+	mergeSourceB = "{\"x\":2}\r\n{\"x\":3}"
+)
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		dedup   bool
+		want    string
+		dropped int64
+	}{
+		{
+			name: "concatenate",
+			want: "{\"x\":1}\n{\"x\":2}\n{\"x\":1}\n{\"x\":2}\n{\"x\":3}\n",
+		},
+		{
+			name:    "dedup keeps first occurrence",
+			dedup:   true,
+			want:    "{\"x\":1}\n{\"x\":2}\n{\"x\":3}\n",
+			dropped: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeBlob(t, dir, "a.jsonl", mergeSourceA)
+			writeBlob(t, dir, "b.jsonl", mergeSourceB)
+			blobs := blob.NewLocal(dir)
+
+			out, err := blobs.Create("merged.jsonl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := mergeLines(out, blobs, []string{"a.jsonl", "b.jsonl"}, tt.dedup)
+			out.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readBlob(t, blobs, "merged.jsonl"); got != tt.want {
+				t.Errorf("merged =\n%q\nwant\n%q", got, tt.want)
+			}
+			if wantRows := int64(strings.Count(tt.want, "\n")); stats.rows != wantRows || stats.bytes != int64(len(tt.want)) || stats.dropped != tt.dropped {
+				t.Errorf("stats = %d rows, %d bytes, %d dropped; want %d, %d, %d",
+					stats.rows, stats.bytes, stats.dropped, wantRows, len(tt.want), tt.dropped)
+			}
+		})
+	}
+}
+
+var datasetColumns = []string{"id", "name", "description", "owner_id", "format", "storage_path", "tags", "metadata", "created_at", "updated_at"}
+
+func TestMergeRecordsLineage(t *testing.T) {
+	dir := t.TempDir()
+	writeBlob(t, dir, "datasets/a/v1.jsonl", mergeSourceA)
+	writeBlob(t, dir, "datasets/b/v2.jsonl", mergeSourceB)
+
+	s, mock := newMockStore(t)
+	s.SetBlobStore(blob.NewLocal(dir))
+
+	for _, src := range []struct {
+		id      string
+		version int
+	}{{"a", 1}, {"b", 2}} {
+		mock.ExpectQuery(`FROM datasets WHERE id = \$1`).WithArgs(src.id).WillReturnRows(sqlmock.NewRows(datasetColumns).
+			AddRow(src.id, src.id, "", "alice", "jsonl", "datasets/"+src.id, []byte(`[]`), []byte(`{}`), time.Now(), time.Now()))
+		mock.ExpectQuery(`SELECT EXISTS`).WithArgs(src.id, src.version).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	}
+	mock.ExpectExec(`INSERT INTO datasets`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FROM dataset_versions WHERE dataset_id = \$1 ORDER BY version DESC LIMIT 1`).WillReturnRows(sqlmock.NewRows(versionColumns))
+	mock.ExpectExec(`INSERT INTO dataset_versions`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`INSERT INTO dataset_lineage`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), OpMerged, []byte(`["a","b"]`), "alice",
+			"merged 2 sources, 3 rows (2 duplicates dropped)", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ds, v, err := s.Merge("combined", []MergeSource{{DatasetID: "a", Version: 1}, {DatasetID: "b", Version: 2}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.OwnerID != "alice" || v.Version != 1 || v.RowCount != 3 {
+		t.Fatalf("merged dataset %+v version %+v, want alice's v1 with 3 rows", ds, v)
+	}
+	if got := readBlob(t, blob.NewLocal(dir), VersionKey(ds, 1)); got != "{\"x\":1}\n{\"x\":2}\n{\"x\":3}\n" {
+		t.Errorf("merged content = %q", got)
+	}
+}
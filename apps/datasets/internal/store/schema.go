@@ -0,0 +1,78 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidSchema is returned by SetSchema for empty or duplicate column
+// names.
+var ErrInvalidSchema = errors.New("invalid dataset schema")
+
+// ColumnStat describes one column of a dataset. Min and Max hold whatever
+// type the column's values have; UniqueEstimate may be approximate.
+type ColumnStat struct {
+	Name           string      `json:"name"`
+	DType          string      `json:"dtype"` // string, int, float, bool, object, ...
+	NullCount      int64       `json:"null_count"`
+	Min            interface{} `json:"min,omitempty"`
+	Max            interface{} `json:"max,omitempty"`
+	UniqueEstimate int64       `json:"unique_estimate,omitempty"`
+}
+
+// DatasetSchema is the registered column layout and statistics of a dataset.
+type DatasetSchema struct {
+	DatasetID string       `json:"dataset_id"`
+	Columns   []ColumnStat `json:"columns"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Validate checks that every column has a name and names are unique.
+func (sc *DatasetSchema) Validate() error {
+	seen := make(map[string]bool, len(sc.Columns))
+	for i, col := range sc.Columns {
+		if col.Name == "" {
+			return fmt.Errorf("%w: column %d has no name", ErrInvalidSchema, i)
+		}
+		if seen[col.Name] {
+			return fmt.Errorf("%w: duplicate column %q", ErrInvalidSchema, col.Name)
+		}
+		seen[col.Name] = true
+	}
+	return nil
+}
+
+// SetSchema registers or replaces the schema of a dataset.
+func (s *DatasetStore) SetSchema(datasetID string, schema *DatasetSchema) error {
+	if err := schema.Validate(); err != nil {
+		return err
+	}
+	schema.DatasetID = datasetID
+	schema.UpdatedAt = time.Now()
+	columnsJSON, _ := json.Marshal(schema.Columns)
+
+	_, err := s.db.Exec(`
+		INSERT INTO dataset_schemas (dataset_id, columns, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (dataset_id) DO UPDATE SET columns = EXCLUDED.columns, updated_at = EXCLUDED.updated_at
+	`, datasetID, columnsJSON, schema.UpdatedAt)
+	return err
+}
+
+// GetSchema retrieves the registered schema of a dataset.
+func (s *DatasetStore) GetSchema(datasetID string) (*DatasetSchema, error) {
+	schema := &DatasetSchema{}
+	var columnsJSON []byte
+
+	err := s.db.QueryRow(`
+		SELECT dataset_id, columns, updated_at FROM dataset_schemas WHERE dataset_id = $1
+	`, datasetID).Scan(&schema.DatasetID, &columnsJSON, &schema.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(columnsJSON, &schema.Columns)
+	return schema, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// captureArg matches any argument and remembers it.
+type captureArg struct {
+	value driver.Value
+}
+
+func (c *captureArg) Match(v driver.Value) bool {
+	c.value = v
+	return true
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	s, mock := newMockStore(t)
+	schema := &DatasetSchema{Columns: []ColumnStat{
+		{Name: "prompt", DType: "string", NullCount: 2, UniqueEstimate: 980},
+		{Name: "score", DType: "float", Min: 0.5, Max: 9.5},
+		{Name: "label", DType: "bool", NullCount: 1},
+	}}
+
+	columns := &captureArg{}
+	mock.ExpectExec(`INSERT INTO dataset_schemas`).
+		WithArgs("ds-1", columns, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.SetSchema("ds-1", schema); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM dataset_schemas WHERE dataset_id = \$1`).WithArgs("ds-1").
+		WillReturnRows(sqlmock.NewRows([]string{"dataset_id", "columns", "updated_at"}).AddRow("ds-1", columns.value, updated))
+	got, err := s.GetSchema("ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DatasetID != "ds-1" || !got.UpdatedAt.Equal(updated) {
+		t.Errorf("GetSchema = %s at %v, want ds-1 at %v", got.DatasetID, got.UpdatedAt, updated)
+	}
+	if !reflect.DeepEqual(got.Columns, schema.Columns) {
+		t.Errorf("columns =\n%+v\nwant\n%+v", got.Columns, schema.Columns)
+	}
+}
+
+func TestSetSchemaRejectsBadColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []ColumnStat
+	}{
+		{"duplicate name", []ColumnStat{{Name: "prompt"}, {Name: "score"}, {Name: "prompt"}}},
+		{"empty name", []ColumnStat{{Name: "prompt"}, {Name: ""}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newMockStore(t) // no INSERT expected
+			err := s.SetSchema("ds-1", &DatasetSchema{Columns: tt.columns})
+			if !errors.Is(err, ErrInvalidSchema) {
+				t.Fatalf("err = %v, want ErrInvalidSchema", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"math/rand"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidRatios is returned by CreateSplit when the ratios are not all
+// positive or don't sum to 1.
+var ErrInvalidRatios = errors.New("invalid split ratios")
+
+// validateRatios checks ratios and returns their names in sorted order.
+func validateRatios(ratios map[string]float64) ([]string, error) {
+	if len(ratios) < 2 {
+		return nil, fmt.Errorf("%w: need at least two splits", ErrInvalidRatios)
+	}
+
+	names := make([]string, 0, len(ratios))
+	sum := 0.0
+	for name, r := range ratios {
+		if name == "" {
+			return nil, fmt.Errorf("%w: empty split name", ErrInvalidRatios)
+		}
+		if r <= 0 {
+			return nil, fmt.Errorf("%w: %s must be positive, got %g", ErrInvalidRatios, name, r)
+		}
+		sum += r
+		names = append(names, name)
+	}
+	if math.Abs(sum-1) > 1e-6 {
+		return nil, fmt.Errorf("%w: ratios sum to %g, want 1", ErrInvalidRatios, sum)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// CreateSplit partitions the latest version of a dataset into one child
+// dataset per ratio (e.g. train/val/test), named "<parent>-<split>". Each
+// record is assigned independently with a generator seeded by seed, so the
+// same seed reproduces the same split. Every child gets a lineage entry
+// pointing back at the parent.
+func (s *DatasetStore) CreateSplit(parentID string, ratios map[string]float64, seed int64) ([]*Dataset, error) {
+	names, err := validateRatios(ratios)
+	if err != nil {
+		return nil, err
+	}
+	if s.blobs == nil {
+		return nil, ErrNoBlobStore
+	}
+
+	parent, err := s.Get(parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.Format != "jsonl" {
+		return nil, fmt.Errorf("%w: %s is %s", ErrNotLineDelimited, parent.ID, parent.Format)
+	}
+	versions, err := s.GetVersions(parentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("dataset %s has no versions to split", parentID)
+	}
+	latest := versions[0]
+
+	now := time.Now()
+	children := make([]*Dataset, len(names))
+	outs := make([]*splitOutput, len(names))
+	for i, name := range names {
+		child := &Dataset{
+			ID:          uuid.New().String(),
+			Name:        parent.Name + "-" + name,
+			Description: fmt.Sprintf("%s split (%.0f%%) of %s v%d", name, ratios[name]*100, parent.Name, latest.Version),
+			OwnerID:     parent.OwnerID,
+			Format:      parent.Format,
+			Tags:        append(append([]string(nil), parent.Tags...), "split:"+name),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		child.StoragePath = path.Join("datasets", child.ID)
+
+		w, err := s.blobs.Create(VersionKey(child, 1))
+		if err != nil {
+			closeOutputs(outs)
+			return nil, err
+		}
+		children[i] = child
+		outs[i] = &splitOutput{w: w, h: sha256.New()}
+	}
+
+	in, err := s.blobs.Open(VersionKey(parent, latest.Version))
+	if err != nil {
+		closeOutputs(outs)
+		return nil, err
+	}
+	err = splitLines(in, outs, names, ratios, rand.New(rand.NewSource(seed)))
+	in.Close()
+	if cerr := closeOutputs(outs); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, child := range children {
+		if err := s.Register(child); err != nil {
+			return nil, err
+		}
+		out := outs[i]
+		v, err := s.CreateVersionAuto(child.ID, hex.EncodeToString(out.h.Sum(nil)), out.rows, out.bytes)
+		if err != nil {
+			return nil, err
+		}
+		err = s.RecordLineage(&LineageEntry{
+			ID:          uuid.New().String(),
+			DatasetID:   child.ID,
+			VersionID:   v.ID,
+			Operation:   OpFiltered,
+			SourceIDs:   []string{parent.ID},
+			Actor:       parent.OwnerID,
+			Description: fmt.Sprintf("%s split of v%d: ratio %g, seed %d, %d rows", names[i], latest.Version, ratios[names[i]], seed, out.rows),
+			CreatedAt:   now,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return children, nil
+}
+
+type splitOutput struct {
+	w           io.WriteCloser
+	h           hash.Hash
+	rows, bytes int64
+}
+
+func closeOutputs(outs []*splitOutput) error {
+	var first error
+	for _, o := range outs {
+		if o == nil {
+			continue
+		}
+		if err := o.w.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// splitLines sends each non-empty line of in to one of outs, chosen by
+// drawing from rng against the cumulative ratios of names.
+func splitLines(in io.Reader, outs []*splitOutput, names []string, ratios map[string]float64, rng *rand.Rand) error {
+	cum := make([]float64, len(names))
+	total := 0.0
+	for i, name := range names {
+		total += ratios[name]
+		cum[i] = total
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadBytes('\n')
+		if rec := bytes.TrimRight(line, "\r\n"); len(bytes.TrimSpace(rec)) > 0 {
+			x := rng.Float64() * total
+			i := sort.SearchFloat64s(cum, x)
+			if i == len(outs) {
+				i--
+			}
+			o := outs[i]
+			n, werr := io.MultiWriter(o.w, o.h).Write(append(rec, '\n'))
+			if werr != nil {
+				return werr
+			}
+			o.rows++
+			o.bytes += int64(n)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"openlora/datasets/internal/blob"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidateRatios(t *testing.T) {
+	tests := []struct {
+		name   string
+		ratios map[string]float64
+		want   []string
+		err    bool
+	}{
+		{name: "train val test", ratios: map[string]float64{"train": 0.8, "val": 0.1, "test": 0.1}, want: []string{"test", "train", "val"}},
+		{name: "rounding tolerated", ratios: map[string]float64{"a": 1.0 / 3, "b": 1.0 / 3, "c": 1.0 / 3}, want: []string{"a", "b", "c"}},
+		{name: "sum below one", ratios: map[string]float64{"train": 0.7, "val": 0.1, "test": 0.1}, err: true},
+		{name: "sum above one", ratios: map[string]float64{"train": 0.8, "val": 0.2, "test": 0.1}, err: true},
+		{name: "zero ratio", ratios: map[string]float64{"train": 1, "val": 0}, err: true},
+		{name: "negative ratio", ratios: map[string]float64{"train": 1.2, "val": -0.2}, err: true},
+		{name: "single split", ratios: map[string]float64{"train": 1}, err: true},
+		{name: "empty name", ratios: map[string]float64{"train": 0.5, "": 0.5}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := validateRatios(tt.ratios)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidRatios) {
+					t.Fatalf("err = %v, want ErrInvalidRatios", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+				t.Fatalf("names = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSplitRejectsRatiosBeforeReading(t *testing.T) {
+	s, mock := newMockStore(t)
+	s.SetBlobStore(blob.NewLocal(t.TempDir()))
+
+	_, err := s.CreateSplit("parent", map[string]float64{"train": 0.9, "test": 0.2}, 1)
+	if !errors.Is(err, ErrInvalidRatios) {
+		t.Fatalf("err = %v, want ErrInvalidRatios", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateSplitRecordsLineage(t *testing.T) {
+	dir := t.TempDir()
+	var parentData strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&parentData, "{\"i\":%d}\n", i)
+	}
+	writeBlob(t, dir, "datasets/parent/v2.jsonl", parentData.String())
+
+	s, mock := newMockStore(t)
+	s.SetBlobStore(blob.NewLocal(dir))
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM datasets WHERE id = \$1`).WithArgs("parent").WillReturnRows(sqlmock.NewRows(datasetColumns).
+		AddRow("parent", "chats", "", "alice", "jsonl", "datasets/parent", []byte(`[]`), []byte(`{}`), now, now))
+	mock.ExpectQuery(`FROM dataset_versions WHERE dataset_id = \$1 ORDER BY version DESC`).WithArgs("parent").
+		WillReturnRows(sqlmock.NewRows(versionColumns).
+			AddRow("pv2", "parent", 2, "sum2", 50, 400, "pv1", now).
+			AddRow("pv1", "parent", 1, "sum1", 40, 320, nil, now))
+
+	type child struct{ dataset, version, lineageDataset, lineageVersion captureArg }
+	children := make([]*child, 3)
+	for i := range children {
+		c := &child{}
+		children[i] = c
+		mock.ExpectExec(`INSERT INTO datasets`).
+			WithArgs(&c.dataset, sqlmock.AnyArg(), sqlmock.AnyArg(), "alice", "jsonl", sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(`pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`FROM dataset_versions WHERE dataset_id = \$1 ORDER BY version DESC LIMIT 1`).WillReturnRows(sqlmock.NewRows(versionColumns))
+		mock.ExpectExec(`INSERT INTO dataset_versions`).
+			WithArgs(&c.version, sqlmock.AnyArg(), 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectExec(`INSERT INTO dataset_lineage`).
+			WithArgs(sqlmock.AnyArg(), &c.lineageDataset, &c.lineageVersion, OpFiltered, []byte(`["parent"]`), "alice",
+				sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	ratios := map[string]float64{"train": 0.6, "val": 0.2, "test": 0.2}
+	got, err := s.CreateSplit("parent", ratios, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	wantNames := []string{"chats-test", "chats-train", "chats-val"}
+	blobs := blob.NewLocal(dir)
+	total := 0
+	for i, ds := range got {
+		if ds.Name != wantNames[i] {
+			t.Errorf("child %d name = %q, want %q", i, ds.Name, wantNames[i])
+		}
+		c := children[i]
+		if c.dataset.value != ds.ID || c.lineageDataset.value != ds.ID {
+			t.Errorf("child %s: registered %v, lineage for %v", ds.ID, c.dataset.value, c.lineageDataset.value)
+		}
+		if c.lineageVersion.value != c.version.value {
+			t.Errorf("child %s: lineage version %v, created version %v", ds.ID, c.lineageVersion.value, c.version.value)
+		}
+		total += strings.Count(readBlob(t, blobs, VersionKey(ds, 1)), "\n")
+	}
+	if total != 50 {
+		t.Fatalf("children hold %d rows, want all 50 from the parent", total)
+	}
+}
@@ -4,7 +4,15 @@ package store
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"time"
+
+	"openlora/datasets/internal/blob"
+
+	"github.com/google/uuid"
 )
 
 // Dataset represents a registered dataset.
@@ -13,7 +21,7 @@ type Dataset struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description,omitempty"`
 	OwnerID     string                 `json:"owner_id"`
-	Format      string                 `json:"format"` // jsonl, parquet, csv
+	Format      string                 `json:"format"` // one of SupportedFormats
 	StoragePath string                 `json:"storage_path"`
 	Tags        []string               `json:"tags,omitempty"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
@@ -33,21 +41,57 @@ type DatasetVersion struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// VersionTag is a named, movable pointer to a dataset version (e.g. "golden").
+type VersionTag struct {
+	DatasetID string    `json:"dataset_id"`
+	Tag       string    `json:"tag"`
+	VersionID string    `json:"version_id"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LineageOperation is the kind of change a lineage entry records.
+type LineageOperation string
+
+const (
+	OpCreated      LineageOperation = "created"
+	OpFiltered     LineageOperation = "filtered"
+	OpTransformed  LineageOperation = "transformed"
+	OpMerged       LineageOperation = "merged"
+	OpSplit        LineageOperation = "split"
+	OpDeduplicated LineageOperation = "deduplicated"
+	OpSampled      LineageOperation = "sampled"
+)
+
+// ErrInvalidOperation is returned when a lineage entry names an unknown
+// operation.
+var ErrInvalidOperation = errors.New("invalid lineage operation")
+
+// Valid reports whether op is one of the known lineage operations.
+func (op LineageOperation) Valid() bool {
+	switch op {
+	case OpCreated, OpFiltered, OpTransformed, OpMerged, OpSplit, OpDeduplicated, OpSampled:
+		return true
+	}
+	return false
+}
+
 // LineageEntry represents a lineage record.
 type LineageEntry struct {
-	ID          string    `json:"id"`
-	DatasetID   string    `json:"dataset_id"`
-	VersionID   string    `json:"version_id"`
-	Operation   string    `json:"operation"` // created, filtered, transformed, merged
-	SourceIDs   []string  `json:"source_ids,omitempty"`
-	Actor       string    `json:"actor"`
-	Description string    `json:"description,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string           `json:"id"`
+	DatasetID   string           `json:"dataset_id"`
+	VersionID   string           `json:"version_id"`
+	Operation   LineageOperation `json:"operation"`
+	SourceIDs   []string         `json:"source_ids,omitempty"`
+	Actor       string           `json:"actor"`
+	Description string           `json:"description,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
 }
 
 // DatasetStore handles dataset persistence.
 type DatasetStore struct {
-	db *sql.DB
+	db    *sql.DB
+	blobs blob.Store
 }
 
 // NewDatasetStore creates a new store.
@@ -55,8 +99,61 @@ func NewDatasetStore(db *sql.DB) *DatasetStore {
 	return &DatasetStore{db: db}
 }
 
+// SupportedFormats lists the dataset formats Register accepts.
+var SupportedFormats = []string{"jsonl", "parquet", "csv"}
+
+var (
+	// ErrUnsupportedFormat is returned by Register for a format not in
+	// SupportedFormats.
+	ErrUnsupportedFormat = errors.New("unsupported dataset format")
+	// ErrStorageNotFound is returned by RegisterValidated when a local
+	// storage path does not exist.
+	ErrStorageNotFound = errors.New("storage path not found")
+)
+
+func validateFormat(format string) error {
+	for _, f := range SupportedFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q (want one of %s)", ErrUnsupportedFormat, format, strings.Join(SupportedFormats, ", "))
+}
+
+// RegisterValidated registers ds like Register. With checkStorage set, a
+// local StoragePath (a plain path or file:// URL) must also exist; remote
+// paths such as s3:// are not checked.
+func (s *DatasetStore) RegisterValidated(ds *Dataset, checkStorage bool) error {
+	if err := validateFormat(ds.Format); err != nil {
+		return err
+	}
+	if checkStorage {
+		if p, ok := localPath(ds.StoragePath); ok {
+			if _, err := os.Stat(p); err != nil {
+				return fmt.Errorf("%w: %s", ErrStorageNotFound, ds.StoragePath)
+			}
+		}
+	}
+	return s.Register(ds)
+}
+
+// localPath returns the filesystem path for a local storage path.
+func localPath(storagePath string) (string, bool) {
+	if p, ok := strings.CutPrefix(storagePath, "file://"); ok {
+		return p, true
+	}
+	if strings.Contains(storagePath, "://") {
+		return "", false
+	}
+	return storagePath, storagePath != ""
+}
+
 // Register creates a new dataset.
 func (s *DatasetStore) Register(ds *Dataset) error {
+	if err := validateFormat(ds.Format); err != nil {
+		return err
+	}
+
 	tagsJSON, _ := json.Marshal(ds.Tags)
 	metaJSON, _ := json.Marshal(ds.Metadata)
 
@@ -114,6 +211,13 @@ func (s *DatasetStore) List(ownerID string, limit int) ([]*Dataset, error) {
 	return datasets, nil
 }
 
+// Count returns the number of datasets across all owners.
+func (s *DatasetStore) Count() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM datasets`).Scan(&n)
+	return n, err
+}
+
 // CreateVersion creates a new version.
 func (s *DatasetStore) CreateVersion(v *DatasetVersion) error {
 	_, err := s.db.Exec(`
@@ -123,6 +227,66 @@ func (s *DatasetStore) CreateVersion(v *DatasetVersion) error {
 	return err
 }
 
+// CreateVersionAuto records a new version of a dataset, numbering it one
+// past the latest and linking ParentID to it. If checksum matches the
+// latest version's, that version is returned and nothing is inserted.
+// Concurrent calls for the same dataset are serialized.
+func (s *DatasetStore) CreateVersionAuto(datasetID, checksum string, rowCount, sizeBytes int64) (*DatasetVersion, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, "dataset:"+datasetID); err != nil {
+		return nil, err
+	}
+
+	latest := &DatasetVersion{}
+	var parentID sql.NullString
+	err = tx.QueryRow(`
+		SELECT id, dataset_id, version, checksum, row_count, size_bytes, parent_id, created_at
+		FROM dataset_versions WHERE dataset_id = $1 ORDER BY version DESC LIMIT 1
+	`, datasetID).Scan(&latest.ID, &latest.DatasetID, &latest.Version, &latest.Checksum, &latest.RowCount, &latest.SizeBytes, &parentID, &latest.CreatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		latest = nil
+	case err != nil:
+		return nil, err
+	case latest.Checksum == checksum:
+		if parentID.Valid {
+			latest.ParentID = parentID.String
+		}
+		return latest, nil
+	}
+
+	v := &DatasetVersion{
+		ID:        uuid.New().String(),
+		DatasetID: datasetID,
+		Version:   1,
+		Checksum:  checksum,
+		RowCount:  rowCount,
+		SizeBytes: sizeBytes,
+		CreatedAt: time.Now(),
+	}
+	if latest != nil {
+		v.Version = latest.Version + 1
+		v.ParentID = latest.ID
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO dataset_versions (id, dataset_id, version, checksum, row_count, size_bytes, parent_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, v.ID, v.DatasetID, v.Version, v.Checksum, v.RowCount, v.SizeBytes, sql.NullString{String: v.ParentID, Valid: v.ParentID != ""}, v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
 // GetVersions retrieves all versions of a dataset.
 func (s *DatasetStore) GetVersions(datasetID string) ([]*DatasetVersion, error) {
 	rows, err := s.db.Query(`
@@ -150,8 +314,59 @@ func (s *DatasetStore) GetVersions(datasetID string) ([]*DatasetVersion, error)
 	return versions, nil
 }
 
+// TagVersion points a tag at a version of a dataset. Tags are unique per
+// dataset, so tagging another version moves the tag.
+func (s *DatasetStore) TagVersion(datasetID string, version int, tag string) (*VersionTag, error) {
+	t := &VersionTag{DatasetID: datasetID, Tag: tag, Version: version, UpdatedAt: time.Now()}
+
+	err := s.db.QueryRow(`
+		SELECT id FROM dataset_versions WHERE dataset_id = $1 AND version = $2
+	`, datasetID, version).Scan(&t.VersionID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO dataset_version_tags (dataset_id, tag, version_id, version, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (dataset_id, tag) DO UPDATE
+		SET version_id = EXCLUDED.version_id, version = EXCLUDED.version, updated_at = EXCLUDED.updated_at
+	`, t.DatasetID, t.Tag, t.VersionID, t.Version, t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// GetVersionByTag resolves a tag to the version it currently points at.
+func (s *DatasetStore) GetVersionByTag(datasetID, tag string) (*DatasetVersion, error) {
+	v := &DatasetVersion{}
+	var parentID sql.NullString
+
+	err := s.db.QueryRow(`
+		SELECT v.id, v.dataset_id, v.version, v.checksum, v.row_count, v.size_bytes, v.parent_id, v.created_at
+		FROM dataset_version_tags t
+		JOIN dataset_versions v ON v.id = t.version_id
+		WHERE t.dataset_id = $1 AND t.tag = $2
+	`, datasetID, tag).Scan(&v.ID, &v.DatasetID, &v.Version, &v.Checksum, &v.RowCount, &v.SizeBytes, &parentID, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		v.ParentID = parentID.String
+	}
+
+	return v, nil
+}
+
 // RecordLineage adds a lineage entry.
 func (s *DatasetStore) RecordLineage(entry *LineageEntry) error {
+	if !entry.Operation.Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidOperation, entry.Operation)
+	}
+
 	sourceJSON, _ := json.Marshal(entry.SourceIDs)
 
 	_, err := s.db.Exec(`
@@ -0,0 +1,381 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// newMockStore returns a store over a mock database whose expectations
+// must all be met by the end of the test.
+func newMockStore(t *testing.T) (*DatasetStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return NewDatasetStore(db), mock
+}
+
+var versionColumns = []string{"id", "dataset_id", "version", "checksum", "row_count", "size_bytes", "parent_id", "created_at"}
+
+func TestTagMovesBetweenVersions(t *testing.T) {
+	s, mock := newMockStore(t)
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	versions := map[int]string{1: "ver-1", 2: "ver-2"}
+
+	for _, version := range []int{1, 2} {
+		mock.ExpectQuery(`SELECT id FROM dataset_versions WHERE dataset_id = \$1 AND version = \$2`).
+			WithArgs("ds-1", version).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(versions[version]))
+		// Re-tagging upserts on (dataset_id, tag), so the tag moves instead
+		// of failing on the unique key
+		mock.ExpectExec(`INSERT INTO dataset_version_tags .* ON CONFLICT \(dataset_id, tag\) DO UPDATE`).
+			WithArgs("ds-1", "golden", versions[version], version, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`FROM dataset_version_tags t\s+JOIN dataset_versions v ON v.id = t.version_id\s+WHERE t.dataset_id = \$1 AND t.tag = \$2`).
+			WithArgs("ds-1", "golden").
+			WillReturnRows(sqlmock.NewRows(versionColumns).AddRow(versions[version], "ds-1", version, "sum", 10, 100, nil, created))
+
+		tag, err := s.TagVersion("ds-1", version, "golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.VersionID != versions[version] || tag.Version != version {
+			t.Fatalf("tag = %+v, want it on version %d", tag, version)
+		}
+		v, err := s.GetVersionByTag("ds-1", "golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.ID != versions[version] || v.Version != version {
+			t.Fatalf("golden resolved to %+v, want version %d", v, version)
+		}
+	}
+}
+
+func TestTagUnknownVersion(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`SELECT id FROM dataset_versions`).
+		WithArgs("ds-1", 9).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if _, err := s.TagVersion("ds-1", 9, "golden"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("tagging a missing version = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestRecordLineageValidatesOperation(t *testing.T) {
+	valid := []LineageOperation{OpCreated, OpFiltered, OpTransformed, OpMerged, OpSplit, OpDeduplicated, OpSampled}
+	for _, op := range valid {
+		t.Run(string(op), func(t *testing.T) {
+			s, mock := newMockStore(t)
+			mock.ExpectExec(`INSERT INTO dataset_lineage`).
+				WithArgs("l1", "ds-1", "v1", op, sqlmock.AnyArg(), "alice", "", sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			if err := s.RecordLineage(&LineageEntry{ID: "l1", DatasetID: "ds-1", VersionID: "v1", Operation: op, Actor: "alice"}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	for _, op := range []LineageOperation{"", "filterd", "Filtered", "deleted"} {
+		t.Run("invalid "+string(op), func(t *testing.T) {
+			s, _ := newMockStore(t) // no INSERT expected
+			err := s.RecordLineage(&LineageEntry{ID: "l1", DatasetID: "ds-1", Operation: op})
+			if !errors.Is(err, ErrInvalidOperation) {
+				t.Fatalf("err = %v, want ErrInvalidOperation", err)
+			}
+		})
+	}
+}
+
+// versionDB is an in-memory database/sql connector that understands only
+// the statements CreateVersionAuto issues. Its advisory lock blocks until
+// the holding transaction ends and (dataset_id, version) is unique, as in
+// Postgres.
+type versionDB struct {
+	mu       sync.Mutex
+	locks    map[string]*sync.Mutex
+	versions []DatasetVersion
+}
+
+func newVersionDB() *versionDB {
+	return &versionDB{locks: make(map[string]*sync.Mutex)}
+}
+
+func (d *versionDB) Connect(context.Context) (driver.Conn, error) { return &versionConn{db: d}, nil }
+func (d *versionDB) Driver() driver.Driver                        { return nil }
+
+// list returns the stored versions of datasetID, oldest first.
+func (d *versionDB) list(datasetID string) []DatasetVersion {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var result []DatasetVersion
+	for _, v := range d.versions {
+		if v.DatasetID == datasetID {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result
+}
+
+type versionConn struct {
+	db   *versionDB
+	held []*sync.Mutex
+}
+
+func (c *versionConn) Prepare(query string) (driver.Stmt, error) {
+	return &versionStmt{conn: c, query: query}, nil
+}
+func (c *versionConn) Close() error              { return nil }
+func (c *versionConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *versionConn) Commit() error             { c.release(); return nil }
+func (c *versionConn) Rollback() error           { c.release(); return nil }
+
+func (c *versionConn) release() {
+	for _, l := range c.held {
+		l.Unlock()
+	}
+	c.held = nil
+}
+
+type versionStmt struct {
+	conn  *versionConn
+	query string
+}
+
+func (s *versionStmt) Close() error  { return nil }
+func (s *versionStmt) NumInput() int { return -1 }
+
+func (s *versionStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.conn.db
+	switch {
+	case strings.Contains(s.query, "pg_advisory_xact_lock"):
+		d.mu.Lock()
+		l, ok := d.locks[args[0].(string)]
+		if !ok {
+			l = &sync.Mutex{}
+			d.locks[args[0].(string)] = l
+		}
+		d.mu.Unlock()
+		l.Lock()
+		s.conn.held = append(s.conn.held, l)
+		return driver.RowsAffected(0), nil
+	case strings.Contains(s.query, "INSERT INTO dataset_versions"):
+		v := DatasetVersion{ID: args[0].(string), DatasetID: args[1].(string), Version: int(args[2].(int64)), Checksum: args[3].(string)}
+		if parent, ok := args[6].(string); ok {
+			v.ParentID = parent
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for _, existing := range d.versions {
+			if existing.DatasetID == v.DatasetID && existing.Version == v.Version {
+				return nil, fmt.Errorf("duplicate key: %s v%d", v.DatasetID, v.Version)
+			}
+		}
+		d.versions = append(d.versions, v)
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("versionDB: unexpected exec %q", s.query)
+}
+
+func (s *versionStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.Contains(s.query, "FROM dataset_versions WHERE dataset_id = $1 ORDER BY version DESC LIMIT 1") {
+		return nil, fmt.Errorf("versionDB: unexpected query %q", s.query)
+	}
+	rows := &versionRows{}
+	if versions := s.conn.db.list(args[0].(string)); len(versions) > 0 {
+		v := versions[len(versions)-1]
+		var parent driver.Value
+		if v.ParentID != "" {
+			parent = v.ParentID
+		}
+		rows.values = [][]driver.Value{{v.ID, v.DatasetID, int64(v.Version), v.Checksum, v.RowCount, v.SizeBytes, parent, v.CreatedAt}}
+	}
+	// Let other writers run between the read and the insert, as a network
+	// round trip would
+	runtime.Gosched()
+	return rows, nil
+}
+
+type versionRows struct {
+	values [][]driver.Value
+}
+
+func (r *versionRows) Columns() []string {
+	return []string{"id", "dataset_id", "version", "checksum", "row_count", "size_bytes", "parent_id", "created_at"}
+}
+func (r *versionRows) Close() error { return nil }
+
+func (r *versionRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestCreateVersionAutoNumbersAndDedups(t *testing.T) {
+	db := newVersionDB()
+	conn := sql.OpenDB(db)
+	defer conn.Close()
+	s := NewDatasetStore(conn)
+
+	v1, err := s.CreateVersionAuto("ds-1", "sum-a", 100, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.Version != 1 || v1.ParentID != "" {
+		t.Fatalf("first version = %+v, want version 1 with no parent", v1)
+	}
+
+	v2, err := s.CreateVersionAuto("ds-1", "sum-b", 120, 1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Version != 2 || v2.ParentID != v1.ID {
+		t.Fatalf("second version = %+v, want version 2 with parent %s", v2, v1.ID)
+	}
+
+	// Identical data returns the latest version instead of a new one
+	again, err := s.CreateVersionAuto("ds-1", "sum-b", 120, 1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != v2.ID || again.ParentID != v1.ID {
+		t.Fatalf("dedup returned %+v, want version %s", again, v2.ID)
+	}
+	if n := len(db.list("ds-1")); n != 2 {
+		t.Fatalf("stored %d versions, want 2", n)
+	}
+
+	// Only the latest checksum dedups; reverting to old data is a new version
+	v3, err := s.CreateVersionAuto("ds-1", "sum-a", 100, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v3.Version != 3 || v3.ParentID != v2.ID {
+		t.Fatalf("reverted version = %+v, want version 3 with parent %s", v3, v2.ID)
+	}
+}
+
+func TestCreateVersionAutoConcurrent(t *testing.T) {
+	const writers = 20
+	db := newVersionDB()
+	conn := sql.OpenDB(db)
+	defer conn.Close()
+	s := NewDatasetStore(conn)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		// Distinct data on one dataset, identical data on another
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.CreateVersionAuto("distinct", fmt.Sprintf("sum-%d", i), 1, 1)
+			errs <- err
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := s.CreateVersionAuto("identical", "same", 1, 1)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("CreateVersionAuto: %v", err)
+		}
+	}
+
+	versions := db.list("distinct")
+	if len(versions) != writers {
+		t.Fatalf("distinct dataset has %d versions, want %d", len(versions), writers)
+	}
+	for i, v := range versions {
+		wantParent := ""
+		if i > 0 {
+			wantParent = versions[i-1].ID
+		}
+		if v.Version != i+1 || v.ParentID != wantParent {
+			t.Fatalf("version %d = %+v, want number %d with parent %q", i, v, i+1, wantParent)
+		}
+	}
+	if n := len(db.list("identical")); n != 1 {
+		t.Fatalf("identical dataset has %d versions, want 1", n)
+	}
+}
+
+func TestRegisterValidatesFormat(t *testing.T) {
+	for _, format := range SupportedFormats {
+		t.Run(format, func(t *testing.T) {
+			s, mock := newMockStore(t)
+			mock.ExpectExec(`INSERT INTO datasets`).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), format,
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			if err := s.Register(&Dataset{ID: "ds-1", Name: "chat", Format: format}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	for _, format := range []string{"jsnl", "JSONL", "", "xlsx"} {
+		t.Run("reject "+format, func(t *testing.T) {
+			s, _ := newMockStore(t) // no INSERT expected
+			err := s.Register(&Dataset{ID: "ds-1", Name: "chat", Format: format})
+			if !errors.Is(err, ErrUnsupportedFormat) {
+				t.Fatalf("err = %v, want ErrUnsupportedFormat", err)
+			}
+		})
+	}
+}
+
+func TestRegisterValidatedChecksLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		path    string
+		wantErr error
+	}{
+		{name: "existing path", path: dir},
+		{name: "existing file URL", path: "file://" + dir},
+		{name: "missing path", path: dir + "/missing", wantErr: ErrStorageNotFound},
+		{name: "missing file URL", path: "file://" + dir + "/missing", wantErr: ErrStorageNotFound},
+		{name: "remote path not checked", path: "s3://bucket/missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockStore(t)
+			if tt.wantErr == nil {
+				mock.ExpectExec(`INSERT INTO datasets`).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			err := s.RegisterValidated(&Dataset{ID: "ds-1", Format: "jsonl", StoragePath: tt.path}, true)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,28 +3,77 @@
 package main
 
 import (
-	"log"
+	"database/sql"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"openlora/core/logging"
+	"openlora/core/svcauth"
 	"openlora/deploy/internal/api"
 	"openlora/deploy/internal/deployment"
+
+	_ "github.com/lib/pq"
 )
 
 func main() {
-	log.Println("🚀 OpenDeploy Deployment Control Plane starting...")
+	logging.Setup("deploy")
+	slog.Info("🚀 OpenDeploy Deployment Control Plane starting...")
 
 	// Initialize deployment manager
 	deployMgr := deployment.NewManager()
+	if v := os.Getenv("MAX_DEPLOYMENTS_PER_ADAPTER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logging.Fatal("Invalid MAX_DEPLOYMENTS_PER_ADAPTER", "value", v)
+		}
+		deployMgr.SetMaxPerAdapter(n)
+	}
+	if v := os.Getenv("HEALTH_PROBE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logging.Fatal("Invalid HEALTH_PROBE_INTERVAL", "value", v)
+		}
+		deployMgr.SetProbeInterval(d)
+	}
+	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
+		db, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			logging.Fatal("Failed to connect to database", "error", err)
+		}
+		defer db.Close()
+
+		deployMgr.SetStore(deployment.NewPostgresStore(db))
+		if err := deployMgr.Load(); err != nil {
+			logging.Fatal("Failed to load deployments", "error", err)
+		}
+	} else {
+		slog.Warn("DATABASE_URL not set; deployments will not survive a restart")
+	}
 	server := api.NewServer(deployMgr)
+	server.SetAdminToken(os.Getenv("DEPLOY_ADMIN_TOKEN"))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8086"
 	}
 
-	log.Printf("🌐 Listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
-	}
+	go func() {
+		slog.Info("🌐 Listening", "port", port)
+		if err := http.ListenAndServe(":"+port, svcauth.Middleware(os.Getenv(svcauth.SecretEnv), server)); err != nil {
+			logging.Fatal("Server failed", "error", err)
+		}
+	}()
+
+	// Wait for shutdown signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("Shutting down...")
+	deployMgr.Close()
 }
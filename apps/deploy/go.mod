@@ -2,4 +2,10 @@ module openlora/deploy
 
 go 1.21
 
-require github.com/google/uuid v1.5.0
+require (
+	github.com/google/uuid v1.5.0
+	github.com/lib/pq v1.10.9
+	openlora/core v0.0.0
+)
+
+replace openlora/core => ../../packages/core-go
@@ -2,16 +2,22 @@
 package api
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 
+	"openlora/core/jsonbody"
 	"openlora/deploy/internal/deployment"
 )
 
 // Server is the HTTP API server.
 type Server struct {
-	manager *deployment.Manager
-	mux     *http.ServeMux
+	manager    *deployment.Manager
+	mux        *http.ServeMux
+	adminToken string
 }
 
 // NewServer creates an API server.
@@ -21,11 +27,26 @@ func NewServer(m *deployment.Manager) *Server {
 	return srv
 }
 
+// SetAdminToken sets the X-Admin-Token value that lets a request bypass
+// deployment limits with ?override=true. Overrides are refused when unset.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+func (s *Server) isAdmin(r *http.Request) bool {
+	token := r.Header.Get("X-Admin-Token")
+	return s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
 func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/health", s.handleHealth)
 	s.mux.HandleFunc("/deployments", s.handleDeployments)
 	s.mux.HandleFunc("/deployments/", s.handleDeploymentByID)
 	s.mux.HandleFunc("/deployments/traffic", s.handleTraffic)
+	s.mux.HandleFunc("/deployments/traffic/split", s.handleTrafficSplit)
+	s.mux.HandleFunc("/deployments/diff", s.handleDiff)
+	s.mux.HandleFunc("/deployments/by-adapter", s.handleByAdapter)
+	s.mux.HandleFunc("/routing", s.handleRouting)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -47,23 +68,65 @@ func (s *Server) handleDeployments(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var d deployment.Deployment
-		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		if err := jsonbody.Decode(w, r, &d); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var opts deployment.DeployOptions
+		if r.URL.Query().Get("override") == "true" {
+			if !s.isAdmin(r) {
+				http.Error(w, "override requires admin token", http.StatusForbidden)
+				return
+			}
+			opts.IgnoreLimit = true
+		}
+		err := s.manager.DeployWith(&d, opts)
+		if errors.Is(err, deployment.ErrDeploymentLimit) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, deployment.ErrInvalidLimits) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if err := s.manager.Deploy(&d); err != nil {
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(d)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
 	}
 }
 
 func (s *Server) handleDeploymentByID(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Path[len("/deployments/"):]
+	// /deployments/{id}[/rollback|/revisions|/limits|/promote]
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/deployments/"):], "/"), "/")
+	id := parts[0]
+
+	switch {
+	case len(parts) == 1:
+		s.handleGetDeployment(w, r, id)
+	case len(parts) == 2 && parts[1] == "rollback":
+		s.handleRollback(w, r, id)
+	case len(parts) == 2 && parts[1] == "revisions":
+		s.handleRevisions(w, r, id)
+	case len(parts) == 2 && parts[1] == "limits":
+		s.handleLimits(w, r, id)
+	case len(parts) == 2 && parts[1] == "promote":
+		s.handlePromote(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleGetDeployment(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
 	d, err := s.manager.Get(id)
 	if err != nil {
 		http.Error(w, "Not found", http.StatusNotFound)
@@ -73,9 +136,173 @@ func (s *Server) handleDeploymentByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(d)
 }
 
+// handleRollback performs a rollback on POST; GET reports whether one is
+// possible.
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method == http.MethodGet {
+		ok, reason := s.manager.CanRollback(id)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"eligible": ok, "reason": reason})
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+
+	rev, err := s.manager.Rollback(id)
+	switch {
+	case errors.Is(err, deployment.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	case errors.Is(err, deployment.ErrNoPreviousRevision):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev)
+}
+
+// handlePromote clones a healthy deployment into a later environment,
+// production unless the body names another.
+func (s *Server) handlePromote(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		Environment deployment.Environment `json:"environment"`
+	}
+	if err := jsonbody.Decode(w, r, &req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Environment == "" {
+		req.Environment = deployment.EnvProd
+	}
+
+	d, err := s.manager.Promote(id, req.Environment)
+	switch {
+	case errors.Is(err, deployment.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	case errors.Is(err, deployment.ErrNotPromotable), errors.Is(err, deployment.ErrDeploymentLimit):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+func (s *Server) handleByAdapter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	q := r.URL.Query()
+	adapterID := q.Get("adapter_id")
+	if adapterID == "" {
+		http.Error(w, "adapter_id required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.manager.ListByAdapter(adapterID, deployment.Environment(q.Get("env"))))
+}
+
+func (s *Server) handleRevisions(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	revs, err := s.manager.Revisions(id)
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revs)
+}
+
+// handleLimits lets an admin adjust a deployment's throttling limits.
+func (s *Server) handleLimits(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, http.MethodPut)
+		return
+	}
+	if !s.isAdmin(r) {
+		http.Error(w, "changing limits requires admin token", http.StatusForbidden)
+		return
+	}
+
+	var limits deployment.Limits
+	if err := jsonbody.Decode(w, r, &limits); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := s.manager.SetLimits(id, limits)
+	switch {
+	case errors.Is(err, deployment.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	case errors.Is(err, deployment.ErrInvalidLimits):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limits)
+}
+
+// handleRouting returns the routing plan a proxy enforces for an adapter.
+func (s *Server) handleRouting(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	q := r.URL.Query()
+	adapterID := q.Get("adapter_id")
+	env := deployment.Environment(q.Get("env"))
+	if adapterID == "" || env == "" {
+		http.Error(w, "adapter_id and env required", http.StatusBadRequest)
+		return
+	}
+
+	// Fall back to the region the gateway routed the caller to
+	region := q.Get("region")
+	if region == "" {
+		region = r.Header.Get("X-OpenLoRA-Region")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"adapter_id":  adapterID,
+		"environment": env,
+		"region":      region,
+		"targets":     s.manager.RoutingPlan(adapterID, env, region),
+	})
+}
+
 func (s *Server) handleTraffic(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodPost)
 		return
 	}
 
@@ -83,7 +310,7 @@ func (s *Server) handleTraffic(w http.ResponseWriter, r *http.Request) {
 		ID         string `json:"id"`
 		Percentage int    `json:"percentage"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := jsonbody.Decode(w, r, &req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -96,3 +323,77 @@ func (s *Server) handleTraffic(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
 }
+
+func (s *Server) handleTrafficSplit(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		plan := s.manager.GetTrafficPlan(q.Get("adapter_id"), deployment.Environment(q.Get("env")))
+		if plan == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan)
+
+	case http.MethodPost:
+		var req struct {
+			AdapterID   string                 `json:"adapter_id"`
+			Environment deployment.Environment `json:"environment"`
+			Weights     map[string]int         `json:"weights"`
+		}
+		if err := jsonbody.Decode(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		plan, err := s.manager.SetTrafficSplit(req.AdapterID, req.Environment, req.Weights)
+		switch {
+		case errors.Is(err, deployment.ErrNotFound), errors.Is(err, deployment.ErrInvalidSplit):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	idA := r.URL.Query().Get("a")
+	idB := r.URL.Query().Get("b")
+	if idA == "" || idB == "" {
+		http.Error(w, "a and b deployment IDs required", http.StatusBadRequest)
+		return
+	}
+
+	diffs, err := s.manager.DiffDeployments(idA, idB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"a":           idA,
+		"b":           idB,
+		"differences": diffs,
+	})
+}
+
+// methodNotAllowed responds with 405 and an Allow header listing the
+// methods the route supports.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
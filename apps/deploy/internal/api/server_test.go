@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"openlora/deploy/internal/deployment"
+)
+
+func newTestServer(t *testing.T) (*Server, *deployment.Manager) {
+	t.Helper()
+	m := deployment.NewManager()
+	t.Cleanup(m.Close)
+	return NewServer(m), m
+}
+
+func TestMethodNotAllowedListsAllowedMethods(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	tests := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{http.MethodDelete, "/deployments", "GET, POST"},
+		{http.MethodPost, "/deployments/d1", "GET"},
+		{http.MethodDelete, "/deployments/d1/rollback", "GET, POST"},
+		{http.MethodPost, "/deployments/d1/revisions", "GET"},
+		{http.MethodPost, "/deployments/d1/limits", "PUT"},
+		{http.MethodGet, "/deployments/d1/promote", "POST"},
+		{http.MethodGet, "/deployments/traffic", "POST"},
+		{http.MethodPut, "/deployments/traffic/split", "GET, POST"},
+		{http.MethodPost, "/deployments/diff", "GET"},
+		{http.MethodPost, "/deployments/by-adapter", "GET"},
+		{http.MethodPost, "/routing", "GET"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s = %d with Allow %q, want 405 with %q", tt.method, tt.path, rec.Code, rec.Header().Get("Allow"), tt.allow)
+		}
+	}
+}
+
+// healthyChecker reports every deployment healthy.
+type healthyChecker struct{}
+
+func (healthyChecker) Check(context.Context, deployment.Deployment) error { return nil }
+
+func TestLimitsEndpointAndRouting(t *testing.T) {
+	srv, m := newTestServer(t)
+	srv.SetAdminToken("s3cret")
+	m.SetHealthChecker(healthyChecker{})
+	m.SetProbeInterval(time.Millisecond)
+	if err := m.Deploy(&deployment.Deployment{ID: "d1", AdapterID: "adapter-1", Version: 1, Environment: deployment.EnvProd, TrafficPct: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"no token", "/deployments/d1/limits", "", `{"max_in_flight": 4}`, http.StatusForbidden},
+		{"wrong token", "/deployments/d1/limits", "guess", `{"max_in_flight": 4}`, http.StatusForbidden},
+		{"negative", "/deployments/d1/limits", "s3cret", `{"max_in_flight": -4}`, http.StatusBadRequest},
+		{"unknown deployment", "/deployments/missing/limits", "s3cret", `{"max_in_flight": 4}`, http.StatusNotFound},
+		{"admin", "/deployments/d1/limits", "s3cret", `{"max_in_flight": 16, "requests_per_second": 5}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("X-Admin-Token", tt.token)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+
+	// The proxy sees the new caps once the deployment is healthy
+	want := deployment.Limits{MaxInFlight: 16, RequestsPerSecond: 5}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/routing?adapter_id=adapter-1&env=production", nil))
+		var plan struct {
+			Targets []deployment.RouteTarget `json:"targets"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&plan); err != nil {
+			t.Fatal(err)
+		}
+		if len(plan.Targets) == 1 {
+			if plan.Targets[0].Limits != want {
+				t.Fatalf("routed limits = %+v, want %+v", plan.Targets[0].Limits, want)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("targets = %+v, want d1", plan.Targets)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestByAdapterAndRollbackEligibility(t *testing.T) {
+	srv, m := newTestServer(t)
+	for _, d := range []*deployment.Deployment{
+		{ID: "d1", AdapterID: "adapter-1", Version: 1, Environment: deployment.EnvStaging},
+		{ID: "d2", AdapterID: "adapter-1", Version: 1, Environment: deployment.EnvProd},
+		{ID: "d3", AdapterID: "adapter-2", Version: 1, Environment: deployment.EnvProd},
+	} {
+		if err := m.Deploy(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(path string, v interface{}) int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code
+	}
+
+	for path, want := range map[string][]string{
+		"/deployments/by-adapter?adapter_id=adapter-1":                []string{"d1", "d2"},
+		"/deployments/by-adapter?adapter_id=adapter-1&env=production": []string{"d2"},
+		"/deployments/by-adapter?adapter_id=adapter-3":                []string{},
+	} {
+		var deps []deployment.Deployment
+		if code := get(path, &deps); code != http.StatusOK {
+			t.Fatalf("GET %s = %d", path, code)
+		}
+		ids := []string{}
+		for _, d := range deps {
+			ids = append(ids, d.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(want, ",") {
+			t.Errorf("GET %s = %v, want %v", path, ids, want)
+		}
+	}
+	if code := get("/deployments/by-adapter", nil); code != http.StatusBadRequest {
+		t.Errorf("without adapter_id = %d, want 400", code)
+	}
+
+	var eligibility struct {
+		Eligible bool   `json:"eligible"`
+		Reason   string `json:"reason"`
+	}
+	if code := get("/deployments/d2/rollback", &eligibility); code != http.StatusOK {
+		t.Fatalf("GET rollback = %d", code)
+	}
+	if eligibility.Eligible || !strings.Contains(eligibility.Reason, "no previous healthy revision") {
+		t.Errorf("eligibility = %+v, want ineligible with a reason", eligibility)
+	}
+}
+
+// downChecker reports every deployment unreachable.
+type downChecker struct{}
+
+func (downChecker) Check(context.Context, deployment.Deployment) error {
+	return errors.New("connection refused")
+}
+
+// waitStatus polls until the deployment reaches want.
+func waitStatus(t *testing.T, m *deployment.Manager, id string, want deployment.DeploymentStatus) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if d, _ := m.Get(id); d != nil && d.Status == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never became %s", id, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPromoteEndpoint(t *testing.T) {
+	srv, m := newTestServer(t)
+	m.SetProbeInterval(time.Millisecond)
+	m.SetHealthChecker(downChecker{})
+	if err := m.Deploy(&deployment.Deployment{ID: "sick", AdapterID: "adapter-2", Version: 1, Environment: deployment.EnvStaging}); err != nil {
+		t.Fatal(err)
+	}
+	m.SetHealthChecker(healthyChecker{})
+	if err := m.Deploy(&deployment.Deployment{ID: "d1", AdapterID: "adapter-1", Version: 1, Environment: deployment.EnvStaging}); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, "sick", deployment.StatusUnhealthy)
+	waitStatus(t, m, "d1", deployment.StatusHealthy)
+
+	promote := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	for _, tt := range []struct {
+		path, body string
+		status     int
+	}{
+		{"/deployments/sick/promote", "", http.StatusConflict},
+		{"/deployments/missing/promote", "", http.StatusNotFound},
+		{"/deployments/d1/promote", `{"environment": "development"}`, http.StatusConflict},
+		{"/deployments/d1/promote", `{"environment": 1}`, http.StatusBadRequest},
+	} {
+		if rec := promote(tt.path, tt.body); rec.Code != tt.status {
+			t.Errorf("POST %s %s = %d, want %d", tt.path, tt.body, rec.Code, tt.status)
+		}
+	}
+
+	rec := promote("/deployments/d1/promote", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("promote = %d: %s", rec.Code, rec.Body.String())
+	}
+	var prod deployment.Deployment
+	if err := json.NewDecoder(rec.Body).Decode(&prod); err != nil {
+		t.Fatal(err)
+	}
+	if prod.Environment != deployment.EnvProd || prod.AdapterID != "adapter-1" || prod.ID == "d1" {
+		t.Fatalf("promoted = %+v, want a new production deployment of adapter-1", prod)
+	}
+}
+
+func TestCreateDeploymentRejectsBadBodies(t *testing.T) {
+	srv, m := newTestServer(t)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"unknown field", `{"adapter_id": "adapter-1", "replicaz": 3}`, `unknown field "replicaz"`},
+		{"oversized", `{"adapter_id": "` + strings.Repeat("x", 2<<20) + `"}`, "request body exceeds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/deployments", strings.NewReader(tt.body)))
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.want) {
+				t.Fatalf("POST = %d %q, want 400 mentioning %q", rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+	if got := m.List(""); len(got) != 0 {
+		t.Fatalf("rejected bodies created %+v", got)
+	}
+}
@@ -2,7 +2,10 @@
 package deployment
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -21,6 +24,12 @@ const (
 	StatusRollingBack DeploymentStatus = "rolling_back"
 )
 
+// Terminal reports whether a deployment in this status no longer holds
+// resources.
+func (s DeploymentStatus) Terminal() bool {
+	return s == StatusFailed
+}
+
 // Environment represents the target environment.
 type Environment string
 
@@ -36,53 +45,325 @@ type Deployment struct {
 	AdapterID   string            `json:"adapter_id"`
 	Version     int               `json:"version"`
 	Environment Environment       `json:"environment"`
+	Region      string            `json:"region,omitempty"`
 	Status      DeploymentStatus  `json:"status"`
 	Replicas    int               `json:"replicas"`
 	TrafficPct  int               `json:"traffic_percentage"` // 0-100
+	Limits      Limits            `json:"limits"`
 	Config      map[string]string `json:"config,omitempty"`
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
 }
 
+// snapshot returns a copy of d that shares no memory with it, so callers
+// can read it while watchers keep updating the original.
+func (d *Deployment) snapshot() *Deployment {
+	c := *d
+	if d.Config != nil {
+		c.Config = make(map[string]string, len(d.Config))
+		for k, v := range d.Config {
+			c.Config[k] = v
+		}
+	}
+	return &c
+}
+
+// Limits throttle the traffic a proxy sends to one deployment. Zero means
+// unlimited.
+type Limits struct {
+	MaxInFlight       int     `json:"max_in_flight"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+}
+
+// ErrInvalidLimits is returned for negative limits.
+var ErrInvalidLimits = errors.New("invalid deployment limits")
+
+// Validate checks that no limit is negative.
+func (l Limits) Validate() error {
+	if l.MaxInFlight < 0 {
+		return fmt.Errorf("%w: max_in_flight must not be negative, got %d", ErrInvalidLimits, l.MaxInFlight)
+	}
+	if l.RequestsPerSecond < 0 {
+		return fmt.Errorf("%w: requests_per_second must not be negative, got %g", ErrInvalidLimits, l.RequestsPerSecond)
+	}
+	return nil
+}
+
+// Revision is a snapshot of a deployment's rollout state. A new revision is
+// recorded for every deploy, traffic change and rollback, and history is
+// kept per adapter and environment.
+type Revision struct {
+	Number       int              `json:"revision"`
+	DeploymentID string           `json:"deployment_id"`
+	AdapterID    string           `json:"adapter_id"`
+	Environment  Environment      `json:"environment"`
+	Version      int              `json:"version"`
+	Replicas     int              `json:"replicas"`
+	TrafficPct   int              `json:"traffic_percentage"`
+	Status       DeploymentStatus `json:"status"`
+	Reason       string           `json:"reason"`
+	RolledBackTo int              `json:"rolled_back_to,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// Revision reasons.
+const (
+	ReasonDeploy   = "deploy"
+	ReasonTraffic  = "traffic"
+	ReasonRollback = "rollback"
+	ReasonPromote  = "promote"
+)
+
+var (
+	// ErrNotFound is returned for an unknown deployment ID.
+	ErrNotFound = errors.New("deployment not found")
+	// ErrNoPreviousRevision is returned by Rollback when there is no earlier
+	// healthy revision to restore.
+	ErrNoPreviousRevision = errors.New("no previous healthy revision")
+	// ErrClosed is returned for changes made after Close.
+	ErrClosed = errors.New("deployment manager closed")
+)
+
+// ErrDeploymentLimit is returned by Deploy when an adapter already has the
+// maximum number of non-terminal deployments.
+var ErrDeploymentLimit = errors.New("deployment limit reached for adapter")
+
+// DeployOptions adjusts how Deploy validates a deployment.
+type DeployOptions struct {
+	// IgnoreLimit bypasses the per-adapter deployment cap.
+	IgnoreLimit bool
+}
+
 // Manager handles deployment operations.
 type Manager struct {
-	mu          sync.RWMutex
-	deployments map[string]*Deployment
+	mu            sync.RWMutex
+	deployments   map[string]*Deployment
+	revisions     map[string][]*Revision  // keyed by revisionKey
+	plans         map[string]*TrafficPlan // keyed by revisionKey
+	maxPerAdapter int                     // 0 means unlimited
+	store         Store                   // nil keeps state in memory only
+
+	checker       HealthChecker
+	probeInterval time.Duration
+	ctx           context.Context
+	cancel        context.CancelFunc
+	watchers      map[string]context.CancelFunc // deployment ID -> its watcher
+	wg            sync.WaitGroup
+	closed        bool
 }
 
-// NewManager creates a new deployment manager.
+// NewManager creates a new deployment manager that health-checks
+// deployments with an HTTPChecker. Call Close to stop the checks.
 func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Manager{
-		deployments: make(map[string]*Deployment),
+		deployments:   make(map[string]*Deployment),
+		revisions:     make(map[string][]*Revision),
+		plans:         make(map[string]*TrafficPlan),
+		checker:       NewHTTPChecker(),
+		probeInterval: DefaultProbeInterval,
+		ctx:           ctx,
+		cancel:        cancel,
+		watchers:      make(map[string]context.CancelFunc),
 	}
 }
 
+// SetHealthChecker replaces the checker used for deployments started after
+// the call.
+func (m *Manager) SetHealthChecker(c HealthChecker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checker = c
+}
+
+// SetProbeInterval sets how often deployments are re-probed.
+func (m *Manager) SetProbeInterval(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.probeInterval = d
+}
+
+// SetStore makes the manager write every change through to store. Call
+// Load afterwards to restore what the store already holds.
+func (m *Manager) SetStore(store Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+}
+
+// Load replaces the manager's state with the store's and restarts health
+// checks for every non-terminal deployment, so statuses saved before a
+// restart are re-probed rather than trusted.
+func (m *Manager) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	if m.store == nil {
+		return nil
+	}
+	state, err := m.store.LoadState()
+	if err != nil {
+		return fmt.Errorf("load deployments: %w", err)
+	}
+
+	for _, cancel := range m.watchers {
+		cancel()
+	}
+	m.watchers = make(map[string]context.CancelFunc)
+	m.deployments = make(map[string]*Deployment, len(state.Deployments))
+	m.revisions = make(map[string][]*Revision)
+	m.plans = make(map[string]*TrafficPlan, len(state.Plans))
+
+	for _, d := range state.Deployments {
+		m.deployments[d.ID] = d.snapshot()
+	}
+	for _, rev := range state.Revisions {
+		key := revisionKey(rev.AdapterID, rev.Environment)
+		copied := *rev
+		m.revisions[key] = append(m.revisions[key], &copied)
+	}
+	for _, plan := range state.Plans {
+		m.plans[revisionKey(plan.AdapterID, plan.Environment)] = plan
+	}
+
+	for _, d := range m.deployments {
+		if d.Status.Terminal() {
+			continue
+		}
+		rev := m.latestRevision(d)
+		if rev == nil {
+			rev = m.recordRevision(d, ReasonDeploy)
+			if err := m.persist(d, rev); err != nil {
+				return err
+			}
+		}
+		m.watch(d.ID, rev)
+	}
+	return nil
+}
+
+// latestRevision returns the newest revision recorded for deployment d, or
+// nil. Callers must hold m.mu.
+func (m *Manager) latestRevision(d *Deployment) *Revision {
+	history := m.revisions[revisionKey(d.AdapterID, d.Environment)]
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].DeploymentID == d.ID {
+			return history[i]
+		}
+	}
+	return nil
+}
+
+// persist writes d and any revisions through to the store. In-memory state
+// stays authoritative: a failed write is reported to the caller and the
+// next successful save of the same records catches the store up. Callers
+// must hold m.mu.
+func (m *Manager) persist(d *Deployment, revs ...*Revision) error {
+	if m.store == nil {
+		return nil
+	}
+	if err := m.store.SaveDeployment(d); err != nil {
+		return fmt.Errorf("persist deployment %s: %w", d.ID, err)
+	}
+	for _, rev := range revs {
+		if err := m.store.SaveRevision(rev); err != nil {
+			return fmt.Errorf("persist revision %d of %s: %w", rev.Number, d.ID, err)
+		}
+	}
+	return nil
+}
+
+func revisionKey(adapterID string, env Environment) string {
+	return adapterID + "/" + string(env)
+}
+
+// recordRevision appends a snapshot of d to its adapter and environment's
+// history. Callers must hold m.mu.
+func (m *Manager) recordRevision(d *Deployment, reason string) *Revision {
+	key := revisionKey(d.AdapterID, d.Environment)
+	rev := &Revision{
+		Number:       len(m.revisions[key]) + 1,
+		DeploymentID: d.ID,
+		AdapterID:    d.AdapterID,
+		Environment:  d.Environment,
+		Version:      d.Version,
+		Replicas:     d.Replicas,
+		TrafficPct:   d.TrafficPct,
+		Status:       d.Status,
+		Reason:       reason,
+		CreatedAt:    d.UpdatedAt,
+	}
+	m.revisions[key] = append(m.revisions[key], rev)
+	return rev
+}
+
+// SetMaxPerAdapter caps how many non-terminal deployments one adapter may
+// have at once. Zero removes the cap.
+func (m *Manager) SetMaxPerAdapter(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxPerAdapter = n
+}
+
 // Deploy creates or updates a deployment.
 func (m *Manager) Deploy(d *Deployment) error {
+	return m.DeployWith(d, DeployOptions{})
+}
+
+// DeployWith creates or updates a deployment with the given options.
+func (m *Manager) DeployWith(d *Deployment, opts DeployOptions) error {
+	if err := d.Limits.Validate(); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	return m.deploy(d, opts, ReasonDeploy)
+}
+
+// deploy stores a copy of d and starts its rollout, recording the revision
+// with the given reason. d is updated to the stored state, but later status
+// changes only reach the manager's copy. Callers must hold m.mu.
+func (m *Manager) deploy(d *Deployment, opts DeployOptions, reason string) error {
+	if m.closed {
+		return ErrClosed
+	}
+	if m.maxPerAdapter > 0 && !opts.IgnoreLimit {
+		if n := m.activeForAdapter(d.AdapterID, d.ID); n >= m.maxPerAdapter {
+			return fmt.Errorf("%w: %s has %d of %d", ErrDeploymentLimit, d.AdapterID, n, m.maxPerAdapter)
+		}
+	}
+
 	if d.ID == "" {
 		d.ID = uuid.New().String()
 		d.CreatedAt = time.Now()
 	}
 	d.UpdatedAt = time.Now()
-	d.Status = StatusPending // Async deployment simulation
+	d.Status = StatusPending
 
-	m.deployments[d.ID] = d
+	stored := d.snapshot()
+	m.deployments[stored.ID] = stored
+	rev := m.recordRevision(stored, reason)
+	m.watch(stored.ID, rev)
+	*d = *stored.snapshot()
 
-	// Simulate deployment process
-	go func(id string) {
-		time.Sleep(2 * time.Second) // Simulate latency
-		m.mu.Lock()
-		if dep, ok := m.deployments[id]; ok {
-			dep.Status = StatusHealthy
-			dep.UpdatedAt = time.Now()
-		}
-		m.mu.Unlock()
-	}(d.ID)
+	return m.persist(stored, rev)
+}
 
-	return nil
+// activeForAdapter counts non-terminal deployments of adapterID other than
+// excludeID. Callers must hold m.mu.
+func (m *Manager) activeForAdapter(adapterID, excludeID string) int {
+	n := 0
+	for _, d := range m.deployments {
+		if d.AdapterID == adapterID && d.ID != excludeID && !d.Status.Terminal() {
+			n++
+		}
+	}
+	return n
 }
 
 // Get retrieves a deployment by ID.
@@ -91,9 +372,9 @@ func (m *Manager) Get(id string) (*Deployment, error) {
 	defer m.mu.RUnlock()
 
 	if d, ok := m.deployments[id]; ok {
-		return d, nil
+		return d.snapshot(), nil
 	}
-	return nil, errors.New("deployment not found")
+	return nil, ErrNotFound
 }
 
 // List retrieves deployments with filters.
@@ -104,12 +385,33 @@ func (m *Manager) List(env Environment) []*Deployment {
 	var result []*Deployment
 	for _, d := range m.deployments {
 		if env == "" || d.Environment == env {
-			result = append(result, d)
+			result = append(result, d.snapshot())
 		}
 	}
 	return result
 }
 
+// ListByAdapter returns the deployments of adapterID, oldest first. An empty
+// env matches every environment.
+func (m *Manager) ListByAdapter(adapterID string, env Environment) []*Deployment {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*Deployment, 0)
+	for _, d := range m.deployments {
+		if d.AdapterID == adapterID && (env == "" || d.Environment == env) {
+			result = append(result, d.snapshot())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
 // SetTraffic updates the traffic split for a deployment.
 func (m *Manager) SetTraffic(id string, percentage int) error {
 	m.mu.Lock()
@@ -117,7 +419,7 @@ func (m *Manager) SetTraffic(id string, percentage int) error {
 
 	d, ok := m.deployments[id]
 	if !ok {
-		return errors.New("deployment not found")
+		return ErrNotFound
 	}
 
 	if percentage < 0 || percentage > 100 {
@@ -126,20 +428,327 @@ func (m *Manager) SetTraffic(id string, percentage int) error {
 
 	d.TrafficPct = percentage
 	d.UpdatedAt = time.Now()
-	return nil
+	return m.persist(d, m.recordRevision(d, ReasonTraffic))
+}
+
+// SetLimits replaces the throttling limits of a deployment.
+func (m *Manager) SetLimits(id string, limits Limits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.deployments[id]
+	if !ok {
+		return ErrNotFound
+	}
+	d.Limits = limits
+	d.UpdatedAt = time.Now()
+	return m.persist(d)
+}
+
+// RouteTarget is one deployment a proxy should send traffic to.
+type RouteTarget struct {
+	DeploymentID string `json:"deployment_id"`
+	Region       string `json:"region,omitempty"`
+	Version      int    `json:"version"`
+	Replicas     int    `json:"replicas"`
+	Weight       int    `json:"weight"`
+	Limits       Limits `json:"limits"`
+}
+
+// RoutingPlan lists the healthy deployments of adapterID in env that
+// receive traffic, with their weights and limits, sorted by deployment ID.
+// With a region, only targets in that region are returned if there are
+// any, otherwise every region serves as the fallback; weights are then
+// relative rather than summing to 100.
+func (m *Manager) RoutingPlan(adapterID string, env Environment, region string) []RouteTarget {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	targets := make([]RouteTarget, 0)
+	for _, d := range m.deployments {
+		if d.AdapterID != adapterID || d.Environment != env || d.Status != StatusHealthy || d.TrafficPct == 0 {
+			continue
+		}
+		targets = append(targets, RouteTarget{
+			DeploymentID: d.ID,
+			Region:       d.Region,
+			Version:      d.Version,
+			Replicas:     d.Replicas,
+			Weight:       d.TrafficPct,
+			Limits:       d.Limits,
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].DeploymentID < targets[j].DeploymentID })
+
+	if region != "" {
+		local := make([]RouteTarget, 0, len(targets))
+		for _, t := range targets {
+			if t.Region == region {
+				local = append(local, t)
+			}
+		}
+		if len(local) > 0 {
+			return local
+		}
+	}
+	return targets
+}
+
+// TrafficPlan is the traffic split across the deployments of one adapter in
+// one environment. Weights are percentages keyed by deployment ID and always
+// sum to 100.
+type TrafficPlan struct {
+	AdapterID   string         `json:"adapter_id"`
+	Environment Environment    `json:"environment"`
+	Weights     map[string]int `json:"weights"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// ErrInvalidSplit is returned by SetTrafficSplit for weights that don't
+// form a complete split.
+var ErrInvalidSplit = errors.New("invalid traffic split")
+
+// SetTrafficSplit sets the traffic percentage of every deployment of
+// adapterID in env at once. Weights must name only deployments of that
+// adapter and environment and sum to exactly 100; deployments left out
+// get 0%. Nothing changes unless the whole split is valid.
+func (m *Manager) SetTrafficSplit(adapterID string, env Environment, weights map[string]int) (*TrafficPlan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("%w: no deployments given", ErrInvalidSplit)
+	}
+	total := 0
+	for id, w := range weights {
+		d, ok := m.deployments[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		if d.AdapterID != adapterID || d.Environment != env {
+			return nil, fmt.Errorf("%w: %s belongs to %s in %s", ErrInvalidSplit, id, d.AdapterID, d.Environment)
+		}
+		if w < 0 || w > 100 {
+			return nil, fmt.Errorf("%w: weight for %s must be 0-100, got %d", ErrInvalidSplit, id, w)
+		}
+		total += w
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("%w: weights sum to %d, want 100", ErrInvalidSplit, total)
+	}
+
+	now := time.Now()
+	plan := &TrafficPlan{AdapterID: adapterID, Environment: env, Weights: make(map[string]int), UpdatedAt: now}
+	for _, d := range m.deployments {
+		if d.AdapterID != adapterID || d.Environment != env {
+			continue
+		}
+		w := weights[d.ID]
+		if w > 0 || d.TrafficPct != 0 {
+			plan.Weights[d.ID] = w
+		}
+		if d.TrafficPct == w {
+			continue
+		}
+		d.TrafficPct = w
+		d.UpdatedAt = now
+		if err := m.persist(d, m.recordRevision(d, ReasonTraffic)); err != nil {
+			return nil, err
+		}
+	}
+	m.plans[revisionKey(adapterID, env)] = plan
+	if m.store != nil {
+		if err := m.store.SavePlan(plan); err != nil {
+			return nil, fmt.Errorf("persist traffic plan for %s in %s: %w", adapterID, env, err)
+		}
+	}
+
+	return copyPlan(plan), nil
+}
+
+// GetTrafficPlan returns the last split applied to adapterID in env, or nil
+// if none has been.
+func (m *Manager) GetTrafficPlan(adapterID string, env Environment) *TrafficPlan {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	plan, ok := m.plans[revisionKey(adapterID, env)]
+	if !ok {
+		return nil
+	}
+	return copyPlan(plan)
+}
+
+func copyPlan(p *TrafficPlan) *TrafficPlan {
+	c := *p
+	c.Weights = make(map[string]int, len(p.Weights))
+	for id, w := range p.Weights {
+		c.Weights[id] = w
+	}
+	return &c
 }
 
-// Rollback reverts a deployment to a previous state (simplified).
-func (m *Manager) Rollback(id string) error {
+// Rollback restores the version, replicas and traffic split of the most
+// recent healthy revision before the current one for the deployment's
+// adapter and environment, and records that as a new revision. It returns
+// ErrNoPreviousRevision if there is nothing to go back to.
+func (m *Manager) Rollback(id string) (*Revision, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.closed {
+		return nil, ErrClosed
+	}
 	d, ok := m.deployments[id]
 	if !ok {
-		return errors.New("deployment not found")
+		return nil, ErrNotFound
+	}
+
+	target := m.rollbackTarget(d)
+	if target == nil {
+		return nil, fmt.Errorf("%w for %s in %s", ErrNoPreviousRevision, d.AdapterID, d.Environment)
 	}
 
+	d.Version = target.Version
+	d.Replicas = target.Replicas
+	d.TrafficPct = target.TrafficPct
 	d.Status = StatusRollingBack
-	// Logic to revert would go here
+	d.UpdatedAt = time.Now()
+
+	rev := m.recordRevision(d, ReasonRollback)
+	rev.RolledBackTo = target.Number
+	m.watch(d.ID, rev)
+	if err := m.persist(d, rev); err != nil {
+		return nil, err
+	}
+
+	copied := *rev
+	return &copied, nil
+}
+
+// rollbackTarget returns the most recent healthy revision before the
+// current one for d's adapter and environment, or nil. Callers must hold
+// m.mu.
+func (m *Manager) rollbackTarget(d *Deployment) *Revision {
+	history := m.revisions[revisionKey(d.AdapterID, d.Environment)]
+	for i := len(history) - 2; i >= 0; i-- {
+		if history[i].Status == StatusHealthy {
+			return history[i]
+		}
+	}
 	return nil
 }
+
+// CanRollback reports whether Rollback(id) would succeed, with a reason
+// saying what it would restore or why it can't.
+func (m *Manager) CanRollback(id string) (bool, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return false, ErrClosed.Error()
+	}
+	d, ok := m.deployments[id]
+	if !ok {
+		return false, ErrNotFound.Error()
+	}
+	target := m.rollbackTarget(d)
+	if target == nil {
+		return false, fmt.Sprintf("%v for %s in %s", ErrNoPreviousRevision, d.AdapterID, d.Environment)
+	}
+	return true, fmt.Sprintf("would restore revision %d (version %d, %d replicas, %d%% traffic)",
+		target.Number, target.Version, target.Replicas, target.TrafficPct)
+}
+
+// Revisions returns the revision history of the deployment's adapter and
+// environment, oldest first.
+func (m *Manager) Revisions(id string) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, ok := m.deployments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	history := m.revisions[revisionKey(d.AdapterID, d.Environment)]
+	result := make([]Revision, len(history))
+	for i, rev := range history {
+		result[i] = *rev
+	}
+	return result, nil
+}
+
+// FieldDiff describes a single field that differs between two deployments.
+type FieldDiff struct {
+	Field string      `json:"field"`
+	A     interface{} `json:"a"`
+	B     interface{} `json:"b"`
+}
+
+// DiffDeployments returns the field-level differences between two deployments.
+// Config entries are compared per key and reported as "config.<key>".
+func (m *Manager) DiffDeployments(idA, idB string) ([]FieldDiff, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	a, ok := m.deployments[idA]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, idA)
+	}
+	b, ok := m.deployments[idB]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, idB)
+	}
+
+	diffs := make([]FieldDiff, 0)
+	add := func(field string, va, vb interface{}) {
+		if va != vb {
+			diffs = append(diffs, FieldDiff{Field: field, A: va, B: vb})
+		}
+	}
+
+	add("adapter_id", a.AdapterID, b.AdapterID)
+	add("version", a.Version, b.Version)
+	add("environment", a.Environment, b.Environment)
+	add("region", a.Region, b.Region)
+	add("status", a.Status, b.Status)
+	add("replicas", a.Replicas, b.Replicas)
+	add("traffic_percentage", a.TrafficPct, b.TrafficPct)
+	add("limits.max_in_flight", a.Limits.MaxInFlight, b.Limits.MaxInFlight)
+	add("limits.requests_per_second", a.Limits.RequestsPerSecond, b.Limits.RequestsPerSecond)
+
+	keys := make(map[string]struct{})
+	for k := range a.Config {
+		keys[k] = struct{}{}
+	}
+	for k := range b.Config {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		va, okA := a.Config[k]
+		vb, okB := b.Config[k]
+		if okA != okB || va != vb {
+			diffs = append(diffs, FieldDiff{Field: "config." + k, A: configValue(va, okA), B: configValue(vb, okB)})
+		}
+	}
+
+	return diffs, nil
+}
+
+func configValue(v string, ok bool) interface{} {
+	if !ok {
+		return nil
+	}
+	return v
+}
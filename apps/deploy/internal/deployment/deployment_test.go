@@ -0,0 +1,397 @@
+package deployment
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffDeployments(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	live := &Deployment{AdapterID: "adapter-1", Version: 1, Environment: EnvProd, Replicas: 2,
+		Config: map[string]string{"max_tokens": "512", "dtype": "bf16", "quant": "int8"}}
+	candidate := &Deployment{AdapterID: "adapter-1", Version: 2, Environment: EnvProd, Replicas: 4,
+		Config: map[string]string{"max_tokens": "1024", "dtype": "bf16", "cache": "on"}}
+	for _, d := range []*Deployment{live, candidate} {
+		if err := m.Deploy(d); err != nil {
+			t.Fatal(err)
+		}
+		waitStatus(t, m, d.ID, StatusHealthy)
+	}
+
+	diffs, err := m.DiffDeployments(live.ID, candidate.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldDiff{
+		{Field: "version", A: 1, B: 2},
+		{Field: "replicas", A: 2, B: 4},
+		{Field: "config.cache", A: nil, B: "on"},
+		{Field: "config.max_tokens", A: "512", B: "1024"},
+		{Field: "config.quant", A: "int8", B: nil},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Fatalf("diffs = %+v\nwant %+v", diffs, want)
+	}
+
+	if diffs, err := m.DiffDeployments(live.ID, live.ID); err != nil || len(diffs) != 0 {
+		t.Fatalf("self diff = %+v, %v; want none", diffs, err)
+	}
+	if _, err := m.DiffDeployments(live.ID, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("diff with unknown ID = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeployPerAdapterLimit(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	m.SetMaxPerAdapter(2)
+
+	var first *Deployment
+	for i := 0; i < 2; i++ {
+		d := &Deployment{AdapterID: "adapter-1", Version: i + 1, Environment: EnvStaging}
+		if err := m.Deploy(d); err != nil {
+			t.Fatalf("deploy %d: %v", i+1, err)
+		}
+		if first == nil {
+			first = d
+		}
+	}
+
+	over := &Deployment{AdapterID: "adapter-1", Version: 3, Environment: EnvStaging}
+	if err := m.Deploy(over); !errors.Is(err, ErrDeploymentLimit) {
+		t.Fatalf("third deploy = %v, want ErrDeploymentLimit", err)
+	}
+	if over.ID != "" {
+		t.Fatalf("rejected deploy was assigned ID %q", over.ID)
+	}
+	if err := m.Deploy(&Deployment{AdapterID: "adapter-2", Version: 1, Environment: EnvStaging}); err != nil {
+		t.Fatalf("other adapter: %v", err)
+	}
+
+	// Redeploying an existing deployment does not count against itself
+	first.Replicas = 3
+	if err := m.Deploy(first); err != nil {
+		t.Fatalf("redeploy at cap: %v", err)
+	}
+
+	if err := m.DeployWith(over, DeployOptions{IgnoreLimit: true}); err != nil {
+		t.Fatalf("deploy with override: %v", err)
+	}
+	if got := len(m.ListByAdapter("adapter-1", "")); got != 3 {
+		t.Fatalf("adapter-1 has %d deployments, want 3", got)
+	}
+}
+
+func TestDeployLimitIgnoresTerminal(t *testing.T) {
+	store := newMemStore()
+	store.deployments["failed"] = &Deployment{ID: "failed", AdapterID: "adapter-1", Version: 1, Environment: EnvStaging, Status: StatusFailed}
+	m, _ := newStoredManager(t, store, nil)
+	m.SetMaxPerAdapter(1)
+
+	d := &Deployment{AdapterID: "adapter-1", Version: 2, Environment: EnvStaging}
+	if err := m.Deploy(d); err != nil {
+		t.Fatalf("failed deployment held a slot: %v", err)
+	}
+	next := &Deployment{AdapterID: "adapter-1", Version: 3, Environment: EnvStaging}
+	if err := m.Deploy(next); !errors.Is(err, ErrDeploymentLimit) {
+		t.Fatalf("deploy at cap = %v, want ErrDeploymentLimit", err)
+	}
+
+	m.SetMaxPerAdapter(0)
+	if err := m.Deploy(next); err != nil {
+		t.Fatalf("deploy with cap removed: %v", err)
+	}
+}
+
+func TestRollbackRestoresPreviousHealthyRevision(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	d := &Deployment{AdapterID: "adapter-1", Version: 1, Environment: EnvProd, Replicas: 2, TrafficPct: 100}
+	if err := m.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, d.ID, StatusHealthy)
+
+	d.Version, d.Replicas, d.TrafficPct = 2, 4, 50
+	if err := m.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, d.ID, StatusHealthy)
+
+	rev, err := m.Rollback(d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.Number != 3 || rev.RolledBackTo != 1 || rev.Reason != ReasonRollback {
+		t.Fatalf("rollback revision = %+v, want revision 3 restoring 1", rev)
+	}
+
+	got := waitStatus(t, m, d.ID, StatusHealthy)
+	if got.Version != 1 || got.Replicas != 2 || got.TrafficPct != 100 {
+		t.Fatalf("after rollback: version %d, %d replicas, %d%% traffic; want v1 state", got.Version, got.Replicas, got.TrafficPct)
+	}
+
+	revs, err := m.Revisions(d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, r := range revs {
+		versions = append(versions, r.Version)
+	}
+	if !reflect.DeepEqual(versions, []int{1, 2, 1}) {
+		t.Fatalf("revision versions = %v, want [1 2 1]", versions)
+	}
+}
+
+func TestRollbackWithoutHealthyPreviousRevision(t *testing.T) {
+	m, checker := newTestManager(t, errors.New("connection refused"))
+	d := &Deployment{AdapterID: "adapter-1", Version: 1, Environment: EnvProd, Replicas: 1}
+	if err := m.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, d.ID, StatusUnhealthy)
+	if _, err := m.Rollback(d.ID); !errors.Is(err, ErrNoPreviousRevision) {
+		t.Fatalf("rollback of first revision = %v, want ErrNoPreviousRevision", err)
+	}
+
+	// v1 never became healthy, so it isn't a rollback target either. The
+	// checker recovers only after v2 has replaced v1's watcher.
+	d.Version = 2
+	if err := m.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	checker.set(nil)
+	waitStatus(t, m, d.ID, StatusHealthy)
+	if _, err := m.Rollback(d.ID); !errors.Is(err, ErrNoPreviousRevision) {
+		t.Fatalf("rollback past unhealthy revision = %v, want ErrNoPreviousRevision", err)
+	}
+
+	if _, err := m.Rollback("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("rollback of unknown ID = %v, want ErrNotFound", err)
+	}
+}
+
+// trafficOf returns the traffic percentage of each deployment ID.
+func trafficOf(t *testing.T, m *Manager, ids ...string) map[string]int {
+	t.Helper()
+	got := make(map[string]int, len(ids))
+	for _, id := range ids {
+		d, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[id] = d.TrafficPct
+	}
+	return got
+}
+
+func TestSetTrafficSplit(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	var ids []string
+	for _, d := range []*Deployment{
+		{ID: "blue", AdapterID: "adapter-1", Version: 1, Environment: EnvProd, TrafficPct: 100},
+		{ID: "green", AdapterID: "adapter-1", Version: 2, Environment: EnvProd},
+		{ID: "canary", AdapterID: "adapter-1", Version: 3, Environment: EnvProd},
+		{ID: "staging", AdapterID: "adapter-1", Version: 3, Environment: EnvStaging, TrafficPct: 100},
+		{ID: "other", AdapterID: "adapter-2", Version: 1, Environment: EnvProd, TrafficPct: 100},
+	} {
+		if err := m.Deploy(d); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, d.ID)
+	}
+
+	plan, err := m.SetTrafficSplit("adapter-1", EnvProd, map[string]int{"blue": 90, "canary": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"blue": 90, "green": 0, "canary": 10, "staging": 100, "other": 100}
+	if got := trafficOf(t, m, ids...); !reflect.DeepEqual(got, want) {
+		t.Fatalf("traffic = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(plan.Weights, map[string]int{"blue": 90, "canary": 10}) {
+		t.Fatalf("plan weights = %v", plan.Weights)
+	}
+
+	// Cut over to green; the deployments left out drop to zero
+	if _, err := m.SetTrafficSplit("adapter-1", EnvProd, map[string]int{"green": 100}); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]int{"blue": 0, "green": 100, "canary": 0, "staging": 100, "other": 100}
+	if got := trafficOf(t, m, ids...); !reflect.DeepEqual(got, want) {
+		t.Fatalf("traffic after cut-over = %v, want %v", got, want)
+	}
+	if plan := m.GetTrafficPlan("adapter-1", EnvProd); !reflect.DeepEqual(plan.Weights, map[string]int{"blue": 0, "green": 100, "canary": 0}) {
+		t.Fatalf("stored plan weights = %v", plan.Weights)
+	}
+}
+
+func TestSetTrafficSplitRejectsInvalid(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	for _, d := range []*Deployment{
+		{ID: "blue", AdapterID: "adapter-1", Version: 1, Environment: EnvProd, TrafficPct: 100},
+		{ID: "green", AdapterID: "adapter-1", Version: 2, Environment: EnvProd},
+		{ID: "staging", AdapterID: "adapter-1", Version: 2, Environment: EnvStaging},
+		{ID: "other", AdapterID: "adapter-2", Version: 1, Environment: EnvProd},
+	} {
+		if err := m.Deploy(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		weights map[string]int
+		err     error
+	}{
+		{"partial", map[string]int{"blue": 50, "green": 40}, ErrInvalidSplit},
+		{"over 100", map[string]int{"blue": 60, "green": 50}, ErrInvalidSplit},
+		{"negative weight", map[string]int{"blue": 110, "green": -10}, ErrInvalidSplit},
+		{"empty", map[string]int{}, ErrInvalidSplit},
+		{"other environment", map[string]int{"blue": 50, "staging": 50}, ErrInvalidSplit},
+		{"other adapter", map[string]int{"blue": 50, "other": 50}, ErrInvalidSplit},
+		{"unknown deployment", map[string]int{"blue": 50, "missing": 50}, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.SetTrafficSplit("adapter-1", EnvProd, tt.weights); !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			want := map[string]int{"blue": 100, "green": 0}
+			if got := trafficOf(t, m, "blue", "green"); !reflect.DeepEqual(got, want) {
+				t.Fatalf("rejected split changed traffic to %v", got)
+			}
+		})
+	}
+	if plan := m.GetTrafficPlan("adapter-1", EnvProd); plan != nil {
+		t.Fatalf("rejected splits stored a plan: %+v", plan)
+	}
+}
+
+func TestLimitsStoredAndRouted(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	limited := &Deployment{ID: "limited", AdapterID: "adapter-1", Version: 1, Environment: EnvProd, TrafficPct: 70,
+		Limits: Limits{MaxInFlight: 8, RequestsPerSecond: 2.5}}
+	open := &Deployment{ID: "open", AdapterID: "adapter-1", Version: 2, Environment: EnvProd, TrafficPct: 30}
+	for _, d := range []*Deployment{limited, open} {
+		if err := m.Deploy(d); err != nil {
+			t.Fatal(err)
+		}
+		waitStatus(t, m, d.ID, StatusHealthy)
+	}
+
+	if err := m.SetLimits("open", Limits{MaxInFlight: 32}); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := m.Get("open"); d.Limits != (Limits{MaxInFlight: 32}) {
+		t.Fatalf("stored limits = %+v", d.Limits)
+	}
+
+	want := []RouteTarget{
+		{DeploymentID: "limited", Version: 1, Weight: 70, Limits: Limits{MaxInFlight: 8, RequestsPerSecond: 2.5}},
+		{DeploymentID: "open", Version: 2, Weight: 30, Limits: Limits{MaxInFlight: 32}},
+	}
+	if got := m.RoutingPlan("adapter-1", EnvProd, ""); !reflect.DeepEqual(got, want) {
+		t.Fatalf("routing plan = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLimitsRejectInvalid(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	d := &Deployment{ID: "d1", AdapterID: "adapter-1", Version: 1, Environment: EnvProd, Limits: Limits{MaxInFlight: 4}}
+	if err := m.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, limits := range []Limits{{MaxInFlight: -1}, {RequestsPerSecond: -0.5}} {
+		if err := m.SetLimits("d1", limits); !errors.Is(err, ErrInvalidLimits) {
+			t.Errorf("SetLimits(%+v) = %v, want ErrInvalidLimits", limits, err)
+		}
+		if err := m.Deploy(&Deployment{AdapterID: "adapter-1", Version: 2, Environment: EnvProd, Limits: limits}); !errors.Is(err, ErrInvalidLimits) {
+			t.Errorf("Deploy with %+v = %v, want ErrInvalidLimits", limits, err)
+		}
+	}
+	if got, _ := m.Get("d1"); got.Limits != (Limits{MaxInFlight: 4}) {
+		t.Fatalf("limits after rejected updates = %+v", got.Limits)
+	}
+	if err := m.SetLimits("missing", Limits{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SetLimits on unknown ID = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListByAdapterAcrossEnvironments(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	// IDs sort in creation order so ties on CreatedAt don't matter
+	for _, d := range []*Deployment{
+		{ID: "d1", AdapterID: "adapter-1", Version: 1, Environment: EnvDev},
+		{ID: "d2", AdapterID: "adapter-2", Version: 1, Environment: EnvStaging},
+		{ID: "d3", AdapterID: "adapter-1", Version: 2, Environment: EnvStaging},
+		{ID: "d4", AdapterID: "adapter-1", Version: 2, Environment: EnvProd},
+		{ID: "d5", AdapterID: "adapter-2", Version: 2, Environment: EnvProd},
+		{ID: "d6", AdapterID: "adapter-1", Version: 3, Environment: EnvStaging},
+	} {
+		if err := m.Deploy(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		adapterID string
+		env       Environment
+		want      []string
+	}{
+		{"adapter-1", "", []string{"d1", "d3", "d4", "d6"}},
+		{"adapter-1", EnvStaging, []string{"d3", "d6"}},
+		{"adapter-1", EnvProd, []string{"d4"}},
+		{"adapter-2", "", []string{"d2", "d5"}},
+		{"adapter-2", EnvDev, []string{}},
+		{"adapter-3", "", []string{}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, d := range m.ListByAdapter(tt.adapterID, tt.env) {
+			got = append(got, d.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListByAdapter(%s, %q) = %v, want %v", tt.adapterID, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestCanRollback(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	prod := &Deployment{ID: "prod", AdapterID: "adapter-1", Version: 1, Environment: EnvProd, Replicas: 2, TrafficPct: 100}
+	staging := &Deployment{ID: "staging", AdapterID: "adapter-1", Version: 2, Environment: EnvStaging, Replicas: 1}
+	other := &Deployment{ID: "other", AdapterID: "adapter-2", Version: 1, Environment: EnvProd}
+	for _, d := range []*Deployment{prod, staging, other} {
+		if err := m.Deploy(d); err != nil {
+			t.Fatal(err)
+		}
+		waitStatus(t, m, d.ID, StatusHealthy)
+	}
+
+	check := func(id string, want bool, reason string) {
+		t.Helper()
+		ok, got := m.CanRollback(id)
+		if ok != want || !strings.Contains(got, reason) {
+			t.Errorf("CanRollback(%s) = %v, %q; want %v mentioning %q", id, ok, got, want, reason)
+		}
+	}
+	for _, id := range []string{"prod", "staging", "other"} {
+		check(id, false, ErrNoPreviousRevision.Error())
+	}
+	check("missing", false, ErrNotFound.Error())
+
+	prod.Version, prod.Replicas = 2, 4
+	if err := m.Deploy(prod); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, prod.ID, StatusHealthy)
+
+	// History is per adapter and environment, so only production gained a
+	// rollback target
+	check("prod", true, "would restore revision 1 (version 1, 2 replicas, 100% traffic)")
+	check("staging", false, ErrNoPreviousRevision.Error())
+	check("other", false, ErrNoPreviousRevision.Error())
+}
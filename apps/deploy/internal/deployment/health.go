@@ -0,0 +1,146 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HealthChecker probes a deployment. A nil error means it is serving.
+type HealthChecker interface {
+	Check(ctx context.Context, d Deployment) error
+}
+
+// EndpointConfigKey is the Config entry holding a deployment's base URL.
+const EndpointConfigKey = "endpoint"
+
+// DefaultProbeInterval is how often deployments are re-probed.
+const DefaultProbeInterval = 10 * time.Second
+
+// HTTPChecker probes GET {endpoint}/health, where endpoint comes from the
+// deployment's Config. Deployments without an endpoint have nothing to
+// probe and are reported healthy.
+type HTTPChecker struct {
+	Client *http.Client
+}
+
+// NewHTTPChecker creates a checker with a short request timeout.
+func NewHTTPChecker() *HTTPChecker {
+	return &HTTPChecker{Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Check implements HealthChecker.
+func (c *HTTPChecker) Check(ctx context.Context, d Deployment) error {
+	endpoint := d.Config[EndpointConfigKey]
+	if endpoint == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// watch starts probing deployment id, replacing any watcher already running
+// for it. Status moves from pending to deploying, then follows each probe:
+// healthy on success, unhealthy on failure. The observed status is also
+// recorded on rev, the revision that started the rollout, so Rollback can
+// tell which revisions were healthy. Callers must hold m.mu.
+func (m *Manager) watch(id string, rev *Revision) {
+	if cancel, ok := m.watchers[id]; ok {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.watchers[id] = cancel
+
+	if d := m.deployments[id]; d.Status == StatusPending {
+		d.Status = StatusDeploying
+		rev.Status = StatusDeploying
+	}
+
+	checker, interval := m.checker, m.probeInterval
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if !m.probe(ctx, checker, id, rev) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// probe checks the deployment once and records the result. It reports
+// false once the watcher should stop.
+func (m *Manager) probe(ctx context.Context, checker HealthChecker, id string, rev *Revision) bool {
+	m.mu.RLock()
+	d, ok := m.deployments[id]
+	var snapshot Deployment
+	if ok {
+		snapshot = *d
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	err := checker.Check(ctx, snapshot)
+	if ctx.Err() != nil {
+		// Superseded or shutting down; the result is stale
+		return false
+	}
+
+	status := StatusHealthy
+	if err != nil {
+		status = StatusUnhealthy
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok = m.deployments[id]
+	if !ok || (d.Status == status && rev.Status == status) {
+		return ok
+	}
+	if d.Status != status {
+		d.Status = status
+		d.UpdatedAt = time.Now()
+	}
+	rev.Status = status
+	if err := m.persist(d, rev); err != nil {
+		slog.Warn("Failed to persist health status", "deployment_id", id, "error", err)
+	}
+	return true
+}
+
+// Close stops every health-check watcher and waits for them to exit.
+// Deploys after Close fail with ErrClosed.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	m.cancel()
+	m.wg.Wait()
+}
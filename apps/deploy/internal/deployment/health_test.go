@@ -0,0 +1,142 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChecker returns results in order, repeating the last one.
+type fakeChecker struct {
+	mu      sync.Mutex
+	results []error
+	calls   int
+}
+
+func (c *fakeChecker) Check(ctx context.Context, d Deployment) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.calls
+	if i >= len(c.results) {
+		i = len(c.results) - 1
+	}
+	c.calls++
+	return c.results[i]
+}
+
+// set replaces the results returned from now on.
+func (c *fakeChecker) set(results ...error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = results
+	c.calls = 0
+}
+
+func newTestManager(t *testing.T, results ...error) (*Manager, *fakeChecker) {
+	t.Helper()
+	checker := &fakeChecker{results: results}
+	m := NewManager()
+	m.SetHealthChecker(checker)
+	m.SetProbeInterval(time.Millisecond)
+	t.Cleanup(m.Close)
+	return m, checker
+}
+
+// waitStatus polls Get until the deployment reaches want.
+func waitStatus(t *testing.T, m *Manager, id string, want DeploymentStatus) *Deployment {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		d, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Status == want {
+			return d
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status of %s = %s, want %s", id, d.Status, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHealthCheckerDrivesStatus(t *testing.T) {
+	m, checker := newTestManager(t, nil)
+	d := &Deployment{AdapterID: "adapter-1", Version: 1, Environment: EnvDev, Replicas: 1}
+	if err := m.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != StatusDeploying {
+		t.Fatalf("status after Deploy = %s, want %s", d.Status, StatusDeploying)
+	}
+
+	waitStatus(t, m, d.ID, StatusHealthy)
+	checker.set(errors.New("connection refused"))
+	waitStatus(t, m, d.ID, StatusUnhealthy)
+	checker.set(nil)
+	waitStatus(t, m, d.ID, StatusHealthy)
+
+	revs, err := m.Revisions(d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 1 || revs[0].Status != StatusHealthy {
+		t.Fatalf("revisions = %+v, want one healthy revision", revs)
+	}
+}
+
+func TestCloseStopsWatchers(t *testing.T) {
+	m, checker := newTestManager(t, nil)
+	d := &Deployment{AdapterID: "adapter-1", Version: 1, Environment: EnvDev}
+	if err := m.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, d.ID, StatusHealthy)
+
+	m.Close()
+	checker.mu.Lock()
+	calls := checker.calls
+	checker.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	if checker.calls != calls {
+		t.Fatalf("checker called %d times after Close", checker.calls-calls)
+	}
+	if err := m.Deploy(&Deployment{AdapterID: "adapter-1", Version: 2, Environment: EnvDev}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Deploy after Close = %v, want ErrClosed", err)
+	}
+}
+
+// TestReadersGetSnapshots encodes deployments handed out by the manager
+// while probes keep changing their status; run with -race.
+func TestReadersGetSnapshots(t *testing.T) {
+	m, checker := newTestManager(t, nil)
+	d := &Deployment{AdapterID: "adapter-1", Version: 1, Environment: EnvDev, Config: map[string]string{"k": "v"}}
+	if err := m.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := m.Get(d.ID)
+	listed := m.List("")
+	byAdapter := m.ListByAdapter("adapter-1", "")
+
+	checker.set(nil, errors.New("down"), nil, errors.New("down"))
+	// Deliberately unsynchronized with the probes
+	for i := 0; i < 20; i++ {
+		for _, dep := range []*Deployment{d, got, listed[0], byAdapter[0]} {
+			if _, err := json.Marshal(dep); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	got.Config["k"] = "changed"
+	if fresh, _ := m.Get(d.ID); fresh.Config["k"] != "v" {
+		t.Fatalf("config = %q, want the manager's copy unchanged", fresh.Config["k"])
+	}
+}
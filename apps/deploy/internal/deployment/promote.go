@@ -0,0 +1,75 @@
+package deployment
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotPromotable is returned by Promote for a deployment that is not
+// healthy or a target environment that is not further along the pipeline.
+var ErrNotPromotable = errors.New("deployment cannot be promoted")
+
+// stage orders environments along the promotion pipeline. Unknown
+// environments return -1.
+func (e Environment) stage() int {
+	switch e {
+	case EnvDev:
+		return 0
+	case EnvStaging:
+		return 1
+	case EnvProd:
+		return 2
+	}
+	return -1
+}
+
+// Promote clones a healthy deployment into a later environment, carrying
+// over its version, replicas, limits and config. The clone takes all
+// traffic if it is the adapter's only live deployment there and none
+// otherwise, leaving the cut-over to a traffic split. It is subject to the
+// same per-adapter cap as Deploy.
+func (m *Manager) Promote(id string, target Environment) (*Deployment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	src, ok := m.deployments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if src.Status != StatusHealthy {
+		return nil, fmt.Errorf("%w: %s is %s, not %s", ErrNotPromotable, id, src.Status, StatusHealthy)
+	}
+	if target.stage() < 0 || target.stage() <= src.Environment.stage() {
+		return nil, fmt.Errorf("%w: cannot promote from %s to %q", ErrNotPromotable, src.Environment, target)
+	}
+
+	d := &Deployment{
+		AdapterID:   src.AdapterID,
+		Version:     src.Version,
+		Environment: target,
+		Region:      src.Region,
+		Replicas:    src.Replicas,
+		Limits:      src.Limits,
+		Config:      src.snapshot().Config,
+	}
+	if m.liveIn(src.AdapterID, target) == 0 {
+		d.TrafficPct = 100
+	}
+
+	if err := m.deploy(d, DeployOptions{}, ReasonPromote); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// liveIn counts non-terminal deployments of adapterID in env. Callers must
+// hold m.mu.
+func (m *Manager) liveIn(adapterID string, env Environment) int {
+	n := 0
+	for _, d := range m.deployments {
+		if d.AdapterID == adapterID && d.Environment == env && !d.Status.Terminal() {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,105 @@
+package deployment
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPromoteHealthyStaging(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	staging := &Deployment{AdapterID: "adapter-1", Version: 3, Environment: EnvStaging, Region: "us-east", Replicas: 2,
+		TrafficPct: 100, Limits: Limits{MaxInFlight: 4}, Config: map[string]string{"dtype": "bf16"}}
+	if err := m.Deploy(staging); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, staging.ID, StatusHealthy)
+
+	prod, err := m.Promote(staging.ID, EnvProd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prod.ID == staging.ID || prod.Environment != EnvProd {
+		t.Fatalf("promoted = %+v, want a new production deployment", prod)
+	}
+	if prod.Version != 3 || prod.Region != "us-east" || prod.Replicas != 2 || prod.Limits != staging.Limits ||
+		!reflect.DeepEqual(prod.Config, staging.Config) {
+		t.Errorf("promoted = %+v, want staging's version, region, replicas, limits and config", prod)
+	}
+	// The only production deployment takes all of its traffic
+	if prod.TrafficPct != 100 {
+		t.Errorf("traffic = %d, want 100", prod.TrafficPct)
+	}
+	revs, err := m.Revisions(prod.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 1 || revs[0].Reason != ReasonPromote {
+		t.Errorf("revisions = %+v, want one promote revision", revs)
+	}
+	if got, _ := m.Get(staging.ID); got.Environment != EnvStaging || got.Status != StatusHealthy {
+		t.Errorf("staging after promote = %+v, want it left in place", got)
+	}
+
+	// A second promotion lands beside the first with no traffic
+	second, err := m.Promote(staging.ID, EnvProd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.TrafficPct != 0 {
+		t.Errorf("second promotion traffic = %d, want 0", second.TrafficPct)
+	}
+}
+
+func TestPromoteRejected(t *testing.T) {
+	m, checker := newTestManager(t, nil)
+	healthy := &Deployment{AdapterID: "adapter-1", Version: 1, Environment: EnvStaging}
+	if err := m.Deploy(healthy); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, healthy.ID, StatusHealthy)
+
+	checker.set(errors.New("connection refused"))
+	sick := &Deployment{AdapterID: "adapter-2", Version: 1, Environment: EnvStaging}
+	if err := m.Deploy(sick); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, sick.ID, StatusUnhealthy)
+
+	tests := []struct {
+		name   string
+		id     string
+		target Environment
+		want   error
+	}{
+		{"unhealthy", sick.ID, EnvProd, ErrNotPromotable},
+		{"same environment", healthy.ID, EnvStaging, ErrNotPromotable},
+		{"backwards", healthy.ID, EnvDev, ErrNotPromotable},
+		{"unknown environment", healthy.ID, "qa", ErrNotPromotable},
+		{"unknown deployment", "missing", EnvProd, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Promote(tt.id, tt.target); !errors.Is(err, tt.want) {
+				t.Fatalf("Promote = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if got := m.ListByAdapter("adapter-2", EnvProd); len(got) != 0 {
+		t.Fatalf("rejected promotion deployed %+v", got)
+	}
+}
+
+func TestPromoteRespectsAdapterCap(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	staging := &Deployment{AdapterID: "adapter-1", Version: 1, Environment: EnvStaging}
+	if err := m.Deploy(staging); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, staging.ID, StatusHealthy)
+	m.SetMaxPerAdapter(1)
+
+	if _, err := m.Promote(staging.ID, EnvProd); !errors.Is(err, ErrDeploymentLimit) {
+		t.Fatalf("Promote at cap = %v, want ErrDeploymentLimit", err)
+	}
+}
@@ -0,0 +1,142 @@
+package deployment
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// Store persists deployments, their revision history and traffic plans so
+// a restarted Manager can pick up where it left off. Saves are upserts of
+// the whole record.
+type Store interface {
+	LoadState() (*State, error)
+	SaveDeployment(d *Deployment) error
+	SaveRevision(rev *Revision) error
+	SavePlan(plan *TrafficPlan) error
+}
+
+// State is everything a Store holds. Revisions are ordered by adapter,
+// environment and revision number.
+type State struct {
+	Deployments []*Deployment
+	Revisions   []*Revision
+	Plans       []*TrafficPlan
+}
+
+// PostgresStore is a Store backed by the deployments, deployment_revisions
+// and traffic_plans tables.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new Postgres-backed store.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// LoadState implements Store.
+func (s *PostgresStore) LoadState() (*State, error) {
+	state := &State{}
+
+	rows, err := s.db.Query(`
+		SELECT id, adapter_id, version, environment, region, status, replicas, traffic_percentage,
+			max_in_flight, requests_per_second, config, created_at, updated_at
+		FROM deployments
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		d := &Deployment{}
+		var configJSON []byte
+		if err := rows.Scan(&d.ID, &d.AdapterID, &d.Version, &d.Environment, &d.Region, &d.Status, &d.Replicas, &d.TrafficPct,
+			&d.Limits.MaxInFlight, &d.Limits.RequestsPerSecond, &configJSON, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(configJSON, &d.Config)
+		state.Deployments = append(state.Deployments, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	revRows, err := s.db.Query(`
+		SELECT adapter_id, environment, revision, deployment_id, version, replicas, traffic_percentage,
+			status, reason, rolled_back_to, created_at
+		FROM deployment_revisions ORDER BY adapter_id, environment, revision
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer revRows.Close()
+	for revRows.Next() {
+		rev := &Revision{}
+		if err := revRows.Scan(&rev.AdapterID, &rev.Environment, &rev.Number, &rev.DeploymentID, &rev.Version, &rev.Replicas,
+			&rev.TrafficPct, &rev.Status, &rev.Reason, &rev.RolledBackTo, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		state.Revisions = append(state.Revisions, rev)
+	}
+	if err := revRows.Err(); err != nil {
+		return nil, err
+	}
+
+	planRows, err := s.db.Query(`SELECT adapter_id, environment, weights, updated_at FROM traffic_plans`)
+	if err != nil {
+		return nil, err
+	}
+	defer planRows.Close()
+	for planRows.Next() {
+		plan := &TrafficPlan{}
+		var weightsJSON []byte
+		if err := planRows.Scan(&plan.AdapterID, &plan.Environment, &weightsJSON, &plan.UpdatedAt); err != nil {
+			return nil, err
+		}
+		plan.Weights = make(map[string]int)
+		json.Unmarshal(weightsJSON, &plan.Weights)
+		state.Plans = append(state.Plans, plan)
+	}
+	return state, planRows.Err()
+}
+
+// SaveDeployment implements Store.
+func (s *PostgresStore) SaveDeployment(d *Deployment) error {
+	configJSON, _ := json.Marshal(d.Config)
+	_, err := s.db.Exec(`
+		INSERT INTO deployments (id, adapter_id, version, environment, region, status, replicas, traffic_percentage,
+			max_in_flight, requests_per_second, config, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (id) DO UPDATE SET
+			version = EXCLUDED.version, region = EXCLUDED.region, status = EXCLUDED.status, replicas = EXCLUDED.replicas,
+			traffic_percentage = EXCLUDED.traffic_percentage, max_in_flight = EXCLUDED.max_in_flight,
+			requests_per_second = EXCLUDED.requests_per_second, config = EXCLUDED.config,
+			updated_at = EXCLUDED.updated_at
+	`, d.ID, d.AdapterID, d.Version, d.Environment, d.Region, d.Status, d.Replicas, d.TrafficPct,
+		d.Limits.MaxInFlight, d.Limits.RequestsPerSecond, configJSON, d.CreatedAt, d.UpdatedAt)
+	return err
+}
+
+// SaveRevision implements Store.
+func (s *PostgresStore) SaveRevision(rev *Revision) error {
+	_, err := s.db.Exec(`
+		INSERT INTO deployment_revisions (adapter_id, environment, revision, deployment_id, version, replicas,
+			traffic_percentage, status, reason, rolled_back_to, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (adapter_id, environment, revision) DO UPDATE SET status = EXCLUDED.status
+	`, rev.AdapterID, rev.Environment, rev.Number, rev.DeploymentID, rev.Version, rev.Replicas,
+		rev.TrafficPct, rev.Status, rev.Reason, rev.RolledBackTo, rev.CreatedAt)
+	return err
+}
+
+// SavePlan implements Store.
+func (s *PostgresStore) SavePlan(plan *TrafficPlan) error {
+	weightsJSON, _ := json.Marshal(plan.Weights)
+	_, err := s.db.Exec(`
+		INSERT INTO traffic_plans (adapter_id, environment, weights, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (adapter_id, environment) DO UPDATE SET
+			weights = EXCLUDED.weights, updated_at = EXCLUDED.updated_at
+	`, plan.AdapterID, plan.Environment, weightsJSON, plan.UpdatedAt)
+	return err
+}
@@ -0,0 +1,218 @@
+package deployment
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// memStore is an in-memory Store. Records are copied in and out so the
+// manager can't change them behind its back, and saves fail with err when
+// it is set.
+type memStore struct {
+	mu          sync.Mutex
+	deployments map[string]*Deployment
+	revisions   map[string]map[int]Revision // revisionKey -> number
+	plans       map[string]*TrafficPlan
+	err         error
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		deployments: make(map[string]*Deployment),
+		revisions:   make(map[string]map[int]Revision),
+		plans:       make(map[string]*TrafficPlan),
+	}
+}
+
+func (s *memStore) LoadState() (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := &State{}
+	for _, d := range s.deployments {
+		state.Deployments = append(state.Deployments, d.snapshot())
+	}
+	keys := make([]string, 0, len(s.revisions))
+	for key := range s.revisions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for n := 1; n <= len(s.revisions[key]); n++ {
+			rev := s.revisions[key][n]
+			state.Revisions = append(state.Revisions, &rev)
+		}
+	}
+	for _, plan := range s.plans {
+		state.Plans = append(state.Plans, copyPlan(plan))
+	}
+	return state, nil
+}
+
+func (s *memStore) SaveDeployment(d *Deployment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.deployments[d.ID] = d.snapshot()
+	return nil
+}
+
+func (s *memStore) SaveRevision(rev *Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	key := revisionKey(rev.AdapterID, rev.Environment)
+	if s.revisions[key] == nil {
+		s.revisions[key] = make(map[int]Revision)
+	}
+	s.revisions[key][rev.Number] = *rev
+	return nil
+}
+
+func (s *memStore) SavePlan(plan *TrafficPlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.plans[revisionKey(plan.AdapterID, plan.Environment)] = copyPlan(plan)
+	return nil
+}
+
+func (s *memStore) deployment(id string) *Deployment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deployments[id]
+}
+
+// newStoredManager returns a test manager backed by store, loaded from it.
+func newStoredManager(t *testing.T, store Store, results ...error) (*Manager, *fakeChecker) {
+	t.Helper()
+	m, checker := newTestManager(t, results...)
+	m.SetStore(store)
+	if err := m.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return m, checker
+}
+
+func TestStoreSurvivesRestart(t *testing.T) {
+	store := newMemStore()
+	m, _ := newStoredManager(t, store, nil)
+
+	blue := &Deployment{ID: "blue", AdapterID: "adapter-1", Version: 1, Environment: EnvProd, Replicas: 2, TrafficPct: 100,
+		Config: map[string]string{"dtype": "bf16"}}
+	if err := m.Deploy(blue); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, "blue", StatusHealthy)
+	green := &Deployment{ID: "green", AdapterID: "adapter-1", Version: 2, Environment: EnvProd, Replicas: 2}
+	if err := m.Deploy(green); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, "green", StatusHealthy)
+
+	// Updates are written through as they happen
+	if _, err := m.SetTrafficSplit("adapter-1", EnvProd, map[string]int{"blue": 80, "green": 20}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetLimits("green", Limits{MaxInFlight: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if d := store.deployment("green"); d.TrafficPct != 20 || d.Limits.MaxInFlight != 8 || d.Status != StatusHealthy {
+		t.Fatalf("stored green = %+v", d)
+	}
+	revsBefore, _ := m.Revisions("blue")
+	m.Close()
+
+	restarted, _ := newStoredManager(t, store, nil)
+	for _, want := range []*Deployment{
+		{ID: "blue", Version: 1, Replicas: 2, TrafficPct: 80, Config: map[string]string{"dtype": "bf16"}},
+		{ID: "green", Version: 2, Replicas: 2, TrafficPct: 20, Limits: Limits{MaxInFlight: 8}},
+	} {
+		got, err := restarted.Get(want.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != want.Version || got.Replicas != want.Replicas || got.TrafficPct != want.TrafficPct ||
+			got.Limits != want.Limits || !reflect.DeepEqual(got.Config, want.Config) {
+			t.Errorf("reloaded %s = %+v, want %+v", want.ID, got, want)
+		}
+	}
+	if plan := restarted.GetTrafficPlan("adapter-1", EnvProd); plan == nil || !reflect.DeepEqual(plan.Weights, map[string]int{"blue": 80, "green": 20}) {
+		t.Errorf("reloaded plan = %+v", plan)
+	}
+	revsAfter, err := restarted.Revisions("blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(revsAfter, revsBefore) {
+		t.Errorf("reloaded revisions = %+v\nwant %+v", revsAfter, revsBefore)
+	}
+
+	// History carries over, so rollback still works after the restart
+	green.Version = 3
+	if err := restarted.Deploy(green); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, restarted, "green", StatusHealthy)
+	if _, err := restarted.Rollback("green"); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitStatus(t, restarted, "green", StatusHealthy); got.Version != 2 {
+		t.Fatalf("rolled back to version %d, want 2", got.Version)
+	}
+}
+
+func TestLoadReprobesStoredStatus(t *testing.T) {
+	store := newMemStore()
+	m, _ := newStoredManager(t, store, nil)
+	if err := m.Deploy(&Deployment{ID: "d1", AdapterID: "adapter-1", Version: 1, Environment: EnvProd}); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, "d1", StatusHealthy)
+	m.Close()
+	store.deployments["failed"] = &Deployment{ID: "failed", AdapterID: "adapter-1", Version: 0, Environment: EnvDev, Status: StatusFailed}
+
+	// The stored healthy status isn't trusted after a restart; terminal
+	// deployments aren't probed at all
+	restarted, _ := newStoredManager(t, store, errors.New("connection refused"))
+	waitStatus(t, restarted, "d1", StatusUnhealthy)
+	if d, _ := restarted.Get("failed"); d.Status != StatusFailed {
+		t.Fatalf("failed deployment reloaded as %s", d.Status)
+	}
+	if d := store.deployment("d1"); d.Status != StatusUnhealthy {
+		t.Fatalf("stored status = %s, want the re-probed unhealthy", d.Status)
+	}
+}
+
+func TestPersistErrorsReachCaller(t *testing.T) {
+	store := newMemStore()
+	m, _ := newStoredManager(t, store, nil)
+	store.err = errors.New("disk full")
+
+	d := &Deployment{ID: "d1", AdapterID: "adapter-1", Version: 1, Environment: EnvProd}
+	if err := m.Deploy(d); !errors.Is(err, store.err) {
+		t.Fatalf("Deploy = %v, want the store error", err)
+	}
+	// Memory stays authoritative; the next successful save catches up
+	if _, err := m.Get("d1"); err != nil {
+		t.Fatalf("deployment lost after failed persist: %v", err)
+	}
+	store.mu.Lock()
+	store.err = nil
+	store.mu.Unlock()
+	if err := m.SetLimits("d1", Limits{MaxInFlight: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.deployment("d1"); got == nil || got.Limits.MaxInFlight != 2 {
+		t.Fatalf("stored after recovery = %+v", got)
+	}
+}
@@ -4,18 +4,22 @@ package main
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
+	"openlora/core/logging"
+	"openlora/core/svcauth"
 	"openlora/experiments/internal/api"
+	"openlora/experiments/internal/refs"
 	"openlora/experiments/internal/store"
 
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	log.Println("🧪 OpenLoRA Experiment Service starting...")
+	logging.Setup("experiments")
+	slog.Info("🧪 OpenLoRA Experiment Service starting...")
 
 	// Connect to database
 	dbURL := os.Getenv("DATABASE_URL")
@@ -25,12 +29,18 @@ func main() {
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 	defer db.Close()
 
 	// Initialize store
 	expStore := store.NewExperimentStore(db)
+	expStore.EnforceUniqueActiveRuns(os.Getenv("ALLOW_DUPLICATE_ACTIVE_RUNS") != "true")
+	checker := refs.NewChecker(os.Getenv("DEPLOY_URL"), os.Getenv("ADAPTERS_URL"))
+	if secret := os.Getenv(svcauth.SecretEnv); secret != "" {
+		checker.SetTransport(svcauth.NewSigner(secret).Transport(nil))
+	}
+	expStore.SetReferenceChecker(checker)
 
 	// HTTP server
 	server := api.NewServer(expStore)
@@ -39,8 +49,8 @@ func main() {
 		port = "8082"
 	}
 
-	log.Printf("🌐 Listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	slog.Info("🌐 Listening", "port", port)
+	if err := http.ListenAndServe(":"+port, svcauth.Middleware(os.Getenv(svcauth.SecretEnv), server)); err != nil {
+		logging.Fatal("Server failed", "error", err)
 	}
 }
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"openlora/experiments/internal/store"
+)
+
+// lowerIsBetter lists name fragments of metrics that are minimized.
+var lowerIsBetter = []string{"loss", "error", "err", "perplexity", "ppl", "latency", "wer", "cer"}
+
+// defaultGoal guesses whether a metric is minimized or maximized from its
+// name.
+func defaultGoal(metric string) store.Goal {
+	name := strings.ToLower(metric)
+	for _, frag := range lowerIsBetter {
+		if strings.Contains(name, frag) {
+			return store.GoalMin
+		}
+	}
+	return store.GoalMax
+}
+
+// renderCompareMarkdown renders a comparison as a Markdown report: a table
+// of runs by metrics with the best value in each column marked, followed by
+// a per-metric summary. Runs appear in the order they were requested. goals
+// overrides defaultGoal per metric.
+func renderCompareMarkdown(runIDs []string, result *store.CompareResult, goals map[string]store.Goal) string {
+	var runs []string
+	seen := make(map[string]bool)
+	for _, id := range runIDs {
+		if _, ok := result.Metrics[id]; ok && !seen[id] {
+			runs = append(runs, id)
+			seen[id] = true
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, m := range result.Metrics {
+		for name := range m {
+			names[name] = true
+		}
+	}
+	metrics := make([]string, 0, len(names))
+	for name := range names {
+		metrics = append(metrics, name)
+	}
+	sort.Strings(metrics)
+
+	// best[metric] is the winning run; ties go to the earlier run
+	best := make(map[string]string, len(metrics))
+	for _, name := range metrics {
+		goal, ok := goals[name]
+		if !ok {
+			goal = defaultGoal(name)
+		}
+		for _, id := range runs {
+			v, ok := result.Metrics[id][name]
+			if !ok {
+				continue
+			}
+			cur, have := result.Metrics[best[name]][name]
+			if !have || (goal == store.GoalMin && v < cur) || (goal == store.GoalMax && v > cur) {
+				best[name] = id
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Run comparison\n\n")
+
+	if len(runs) == 0 {
+		b.WriteString("No runs found.\n")
+	} else {
+		b.WriteString("| Run |")
+		for _, name := range metrics {
+			fmt.Fprintf(&b, " %s |", escapeCell(name))
+		}
+		b.WriteString("\n|---|")
+		for range metrics {
+			b.WriteString("---:|")
+		}
+		b.WriteString("\n")
+
+		for _, id := range runs {
+			fmt.Fprintf(&b, "| `%s` |", id)
+			for _, name := range metrics {
+				v, ok := result.Metrics[id][name]
+				switch {
+				case !ok:
+					b.WriteString(" – |")
+				case best[name] == id:
+					fmt.Fprintf(&b, " **%s** ★ |", formatValue(v))
+				default:
+					fmt.Fprintf(&b, " %s |", formatValue(v))
+				}
+			}
+			b.WriteString("\n")
+		}
+
+		b.WriteString("\n## Best run per metric\n\n")
+		for _, name := range metrics {
+			goal, ok := goals[name]
+			if !ok {
+				goal = defaultGoal(name)
+			}
+			id := best[name]
+			fmt.Fprintf(&b, "- **%s** (%s): `%s` = %s\n", escapeCell(name), goal, id, formatValue(result.Metrics[id][name]))
+		}
+	}
+
+	if len(result.NotFound) > 0 {
+		b.WriteString("\n## Not found\n\n")
+		for _, id := range result.NotFound {
+			fmt.Fprintf(&b, "- `%s`\n", id)
+		}
+	}
+
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// escapeCell keeps a value from breaking the table layout.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
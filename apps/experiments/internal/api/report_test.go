@@ -0,0 +1,81 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"openlora/experiments/internal/store"
+)
+
+func TestRenderCompareMarkdown(t *testing.T) {
+	result := &store.CompareResult{
+		Metrics: map[string]map[string]float64{
+			"r1": {"eval_loss": 0.42, "accuracy": 0.81},
+			"r2": {"eval_loss": 0.35, "accuracy": 0.79, "tokens|s": 1200},
+			"r3": {"eval_loss": 0.35, "accuracy": 0.86},
+		},
+		NotFound: []string{"typo"},
+	}
+	got := renderCompareMarkdown([]string{"r1", "r2", "typo", "r3", "r1"}, result, nil)
+
+	want := "# Run comparison\n\n" +
+		"| Run | accuracy | eval_loss | tokens\\|s |\n" +
+		"|---|---:|---:|---:|\n" +
+		"| `r1` | 0.81 | 0.42 | – |\n" +
+		"| `r2` | 0.79 | **0.35** ★ | **1200** ★ |\n" +
+		"| `r3` | **0.86** ★ | 0.35 | – |\n" +
+		"\n## Best run per metric\n\n" +
+		"- **accuracy** (max): `r3` = 0.86\n" +
+		"- **eval_loss** (min): `r2` = 0.35\n" +
+		"- **tokens\\|s** (max): `r2` = 1200\n" +
+		"\n## Not found\n\n" +
+		"- `typo`\n"
+	if got != want {
+		t.Fatalf("report =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderCompareMarkdownGoalOverride(t *testing.T) {
+	result := &store.CompareResult{Metrics: map[string]map[string]float64{
+		"r1": {"eval_loss": 0.4, "latency_ms": 120},
+		"r2": {"eval_loss": 0.3, "latency_ms": 90},
+	}}
+	got := renderCompareMarkdown([]string{"r1", "r2"}, result, map[string]store.Goal{"latency_ms": store.GoalMax})
+
+	for _, line := range []string{
+		"| `r1` | 0.4 | **120** ★ |",
+		"| `r2` | **0.3** ★ | 90 |",
+		"- **latency_ms** (max): `r1` = 120",
+		"- **eval_loss** (min): `r2` = 0.3",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("report missing %q:\n%s", line, got)
+		}
+	}
+}
+
+func TestRenderCompareMarkdownNoRuns(t *testing.T) {
+	result := &store.CompareResult{Metrics: map[string]map[string]float64{}, NotFound: []string{"a", "b"}}
+	got := renderCompareMarkdown([]string{"a", "b"}, result, nil)
+
+	want := "# Run comparison\n\nNo runs found.\n\n## Not found\n\n- `a`\n- `b`\n"
+	if got != want {
+		t.Fatalf("report =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDefaultGoal(t *testing.T) {
+	for metric, want := range map[string]store.Goal{
+		"eval_loss":    store.GoalMin,
+		"Perplexity":   store.GoalMin,
+		"p99_latency":  store.GoalMin,
+		"wer":          store.GoalMin,
+		"accuracy":     store.GoalMax,
+		"bleu":         store.GoalMax,
+		"tokens_per_s": store.GoalMax,
+	} {
+		if got := defaultGoal(metric); got != want {
+			t.Errorf("defaultGoal(%q) = %s, want %s", metric, got, want)
+		}
+	}
+}
@@ -2,10 +2,16 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"openlora/core/jsonbody"
 	"openlora/experiments/internal/store"
 
 	"github.com/google/uuid"
@@ -27,10 +33,12 @@ func NewServer(s *store.ExperimentStore) *Server {
 func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/health", s.handleHealth)
 	s.mux.HandleFunc("/experiments", s.handleExperiments)
+	s.mux.HandleFunc("/experiments/count", s.handleCount)
 	s.mux.HandleFunc("/experiments/", s.handleExperimentByID)
 	s.mux.HandleFunc("/runs", s.handleRuns)
 	s.mux.HandleFunc("/runs/", s.handleRunByID)
 	s.mux.HandleFunc("/compare", s.handleCompare)
+	s.mux.HandleFunc("/compare/diff", s.handleCompareDiff)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -41,6 +49,22 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// handleCount reports how many experiments exist, without listing them.
+func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	n, err := s.store.CountExperiments()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"count": n})
+}
+
 func (s *Server) handleExperiments(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -56,7 +80,7 @@ func (s *Server) handleExperiments(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var exp store.Experiment
-		if err := json.NewDecoder(r.Body).Decode(&exp); err != nil {
+		if err := jsonbody.Decode(w, r, &exp); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -71,12 +95,33 @@ func (s *Server) handleExperiments(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(exp)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
 	}
 }
 
 func (s *Server) handleExperimentByID(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Path[len("/experiments/"):]
+	// /experiments/{id}[/rollup|/best]
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/experiments/"):], "/"), "/")
+	id := parts[0]
+
+	switch {
+	case len(parts) == 1:
+		s.handleGetExperiment(w, r, id)
+	case len(parts) == 2 && parts[1] == "rollup":
+		s.handleRollup(w, r, id)
+	case len(parts) == 2 && parts[1] == "best":
+		s.handleBestRun(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleGetExperiment(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
 	exp, err := s.store.GetExperiment(id)
 	if err != nil {
 		http.Error(w, "Not found", http.StatusNotFound)
@@ -86,13 +131,92 @@ func (s *Server) handleExperimentByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(exp)
 }
 
+func (s *Server) handleRollup(w http.ResponseWriter, r *http.Request, experimentID string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		Aggregations map[string]store.Aggregation `json:"aggregations"`
+	}
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.store.Rollup(experimentID, req.Aggregations)
+	if errors.Is(err, store.ErrUnknownAggregation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) handleBestRun(w http.ResponseWriter, r *http.Request, experimentID string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	q := r.URL.Query()
+	metric := q.Get("metric")
+	if metric == "" {
+		http.Error(w, "metric is required", http.StatusBadRequest)
+		return
+	}
+	goal := store.Goal(q.Get("goal"))
+	if goal == "" {
+		goal = store.GoalMin
+	}
+
+	run, err := s.store.GetBestRun(experimentID, metric, goal)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "no run reports "+metric, http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrUnknownGoal):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
 func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	switch r.Method {
 	case http.MethodGet:
-		expID := r.URL.Query().Get("experiment_id")
-		runs, err := s.store.ListRuns(expID)
+		q := r.URL.Query()
+		expID := q.Get("experiment_id")
+
+		var runs []*store.Run
+		var err error
+		if metric := q.Get("sort"); metric != "" {
+			order := q.Get("order")
+			if order == "" {
+				order = "asc"
+			}
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			runs, err = s.store.ListRunsSorted(expID, metric, order, limit)
+			if errors.Is(err, store.ErrInvalidOrder) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			runs, err = s.store.ListRuns(expID)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -101,46 +225,133 @@ func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var run store.Run
-		if err := json.NewDecoder(r.Body).Decode(&run); err != nil {
+		if err := jsonbody.Decode(w, r, &run); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 		run.ID = uuid.New().String()
 		run.CreatedAt = time.Now()
-		run.Status = "pending"
+		run.Status = store.RunPending
 
-		if err := s.store.CreateRun(&run); err != nil {
+		err := s.store.CreateRun(&run)
+		if errors.Is(err, store.ErrDuplicateActiveRun) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(run)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
 	}
 }
 
 func (s *Server) handleRunByID(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Path[len("/runs/"):]
-	run, err := s.store.GetRun(id)
-	if err != nil {
+	// /runs/{id}[/resume]
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/runs/"):], "/"), "/")
+	id := parts[0]
+
+	switch {
+	case len(parts) == 1:
+		s.handleRun(w, r, id)
+	case len(parts) == 2 && parts[1] == "resume":
+		s.handleResumeRun(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		run, err := s.store.GetRun(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(run)
+
+	case http.MethodPatch:
+		var req struct {
+			Status string `json:"status"`
+		}
+		if err := jsonbody.Decode(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		run, err := s.store.UpdateRunStatus(id, req.Status)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrUnknownRunStatus):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, store.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(run)
+		}
+
+	case http.MethodDelete:
+		err := s.store.DeleteRun(id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrRunReferenced):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (s *Server) handleResumeRun(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	run, err := s.store.ResumeRun(id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
+	case errors.Is(err, store.ErrRunNotResumable):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(run)
 }
 
 func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodPost)
 		return
 	}
 
 	var req struct {
 		RunIDs []string `json:"run_ids"`
+		// Goals picks min or max per metric for the markdown report.
+		Goals map[string]store.Goal `json:"goals,omitempty"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := jsonbody.Decode(w, r, &req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -151,6 +362,59 @@ func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("format") == "markdown" {
+		for name, goal := range req.Goals {
+			if goal != store.GoalMin && goal != store.GoalMax {
+				http.Error(w, fmt.Sprintf("%s: %v", name, store.ErrUnknownGoal), http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(renderCompareMarkdown(req.RunIDs, result, req.Goals)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) handleCompareDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		RunIDs        []string   `json:"run_ids"`
+		PrimaryMetric string     `json:"primary_metric"`
+		Goal          store.Goal `json:"goal"`
+	}
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.PrimaryMetric == "" {
+		http.Error(w, "primary_metric is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.store.CompareRunsDiff(req.RunIDs, req.PrimaryMetric, req.Goal)
+	if errors.Is(err, store.ErrUnknownGoal) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+// methodNotAllowed responds with 405 and an Allow header listing the
+// methods the route supports.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
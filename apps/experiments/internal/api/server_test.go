@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"openlora/experiments/internal/store"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMethodNotAllowedListsAllowedMethods(t *testing.T) {
+	// Method checks run before any store access, so no database is needed
+	srv := NewServer(store.NewExperimentStore(nil))
+
+	tests := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{http.MethodPost, "/experiments/count", "GET"},
+		{http.MethodDelete, "/experiments", "GET, POST"},
+		{http.MethodPost, "/experiments/e1", "GET"},
+		{http.MethodGet, "/experiments/e1/rollup", "POST"},
+		{http.MethodPost, "/experiments/e1/best", "GET"},
+		{http.MethodPut, "/runs", "GET, POST"},
+		{http.MethodPut, "/runs/r1", "GET, PATCH, DELETE"},
+		{http.MethodGet, "/runs/r1/resume", "POST"},
+		{http.MethodGet, "/compare", "POST"},
+		{http.MethodGet, "/compare/diff", "POST"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s = %d with Allow %q, want 405 with %q", tt.method, tt.path, rec.Code, rec.Header().Get("Allow"), tt.allow)
+		}
+	}
+}
+
+var runColumns = []string{"id", "experiment_id", "name", "status", "hyperparams", "metrics", "dataset_id", "adapter_id", "started_at", "completed_at", "created_at", "attempt", "history"}
+
+// newCompareServer serves a store whose runs table holds the given run ID
+// to metrics JSON; every other ID is missing. Lookups must happen in ids
+// order.
+func newCompareServer(t *testing.T, ids []string, metrics map[string]string) *Server {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	for _, id := range ids {
+		rows := sqlmock.NewRows(runColumns)
+		if m, ok := metrics[id]; ok {
+			rows.AddRow(id, "exp-1", "run-"+id, store.RunCompleted, []byte(`{}`), []byte(m), "", "", nil, nil, time.Now(), 1, []byte(`[]`))
+		}
+		mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs(id).WillReturnRows(rows)
+	}
+	return NewServer(store.NewExperimentStore(db))
+}
+
+func TestCompareListsNotFound(t *testing.T) {
+	srv := newCompareServer(t, []string{"r1", "typo"}, map[string]string{"r1": `{"loss": 0.4}`})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(`{"run_ids": ["r1", "typo"]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var got store.CompareResult
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Metrics["r1"]["loss"] != 0.4 || len(got.Metrics) != 1 {
+		t.Errorf("metrics = %v, want only r1", got.Metrics)
+	}
+	if len(got.NotFound) != 1 || got.NotFound[0] != "typo" {
+		t.Errorf("not_found = %v, want [typo]", got.NotFound)
+	}
+}
+
+func TestCompareMarkdown(t *testing.T) {
+	metrics := map[string]string{"r1": `{"loss": 0.4, "acc": 0.9}`, "r2": `{"loss": 0.3, "acc": 0.8}`}
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   []string
+	}{
+		{
+			name:   "default goals",
+			body:   `{"run_ids": ["r1", "r2"]}`,
+			status: http.StatusOK,
+			want:   []string{"| Run | acc | loss |", "| `r1` | **0.9** ★ | 0.4 |", "| `r2` | 0.8 | **0.3** ★ |"},
+		},
+		{
+			name:   "goal override",
+			body:   `{"run_ids": ["r1", "r2"], "goals": {"acc": "min"}}`,
+			status: http.StatusOK,
+			want:   []string{"- **acc** (min): `r2` = 0.8"},
+		},
+		{
+			name:   "unknown goal",
+			body:   `{"run_ids": ["r1", "r2"], "goals": {"acc": "best"}}`,
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newCompareServer(t, []string{"r1", "r2"}, metrics)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/compare?format=markdown", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+				t.Errorf("content type = %q, want text/markdown", ct)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(rec.Body.String(), line+"\n") {
+					t.Errorf("report missing %q:\n%s", line, rec.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Package refs looks up resources in other services that depend on a run.
+package refs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"openlora/experiments/internal/store"
+)
+
+// Checker queries the deploy and adapter services for references to a run's
+// adapter.
+type Checker struct {
+	deployURL   string
+	adaptersURL string
+	client      *http.Client
+}
+
+// NewChecker creates a checker. An empty URL disables that check.
+func NewChecker(deployURL, adaptersURL string) *Checker {
+	return &Checker{
+		deployURL:   deployURL,
+		adaptersURL: adaptersURL,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// SetTransport replaces the transport used for lookups, e.g. with one that
+// signs requests.
+func (c *Checker) SetTransport(rt http.RoundTripper) {
+	c.client.Transport = rt
+}
+
+// References returns a description of every deployment and active adapter
+// that depends on the run. Lookup failures are returned as errors so that
+// callers fail closed.
+func (c *Checker) References(run *store.Run) ([]string, error) {
+	if run.AdapterID == "" {
+		return nil, nil
+	}
+
+	var refs []string
+
+	if c.deployURL != "" {
+		var deployments []struct {
+			ID        string `json:"id"`
+			AdapterID string `json:"adapter_id"`
+			Status    string `json:"status"`
+		}
+		if _, err := c.getJSON(c.deployURL+"/deployments", &deployments); err != nil {
+			return nil, fmt.Errorf("deploy service: %w", err)
+		}
+		for _, d := range deployments {
+			if d.AdapterID == run.AdapterID && d.Status != "failed" {
+				refs = append(refs, "deployment "+d.ID)
+			}
+		}
+	}
+
+	if c.adaptersURL != "" {
+		var adapter struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		}
+		found, err := c.getJSON(c.adaptersURL+"/adapters/"+run.AdapterID, &adapter)
+		if err != nil {
+			return nil, fmt.Errorf("adapter service: %w", err)
+		}
+		if found && adapter.Status == "active" {
+			refs = append(refs, "adapter "+adapter.ID)
+		}
+	}
+
+	return refs, nil
+}
+
+// getJSON decodes the response into v. A 404 reports found=false.
+func (c *Checker) getJSON(url string, v interface{}) (bool, error) {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return true, json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,74 @@
+package refs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openlora/experiments/internal/store"
+)
+
+// newServices starts fake deploy and adapter services. Adapters not in
+// adapters are 404s.
+func newServices(t *testing.T, deployments []map[string]string, adapters map[string]string) (deployURL, adaptersURL string) {
+	t.Helper()
+	deploy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(deployments)
+	}))
+	t.Cleanup(deploy.Close)
+	adapterSvc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/adapters/")
+		status, ok := adapters[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "status": status})
+	}))
+	t.Cleanup(adapterSvc.Close)
+	return deploy.URL, adapterSvc.URL
+}
+
+func TestReferences(t *testing.T) {
+	deployURL, adaptersURL := newServices(t,
+		[]map[string]string{
+			{"id": "dep-1", "adapter_id": "deployed", "status": "healthy"},
+			{"id": "dep-2", "adapter_id": "crashed", "status": "failed"},
+		},
+		map[string]string{"deployed": "archived", "active": "active", "crashed": "archived"},
+	)
+	c := NewChecker(deployURL, adaptersURL)
+
+	tests := []struct {
+		adapterID string
+		want      string
+	}{
+		{"deployed", "deployment dep-1"},
+		{"active", "adapter active"},
+		{"crashed", ""},
+		{"unknown", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		refs, err := c.References(&store.Run{ID: "r1", AdapterID: tt.adapterID})
+		if err != nil {
+			t.Fatalf("adapter %q: %v", tt.adapterID, err)
+		}
+		if got := strings.Join(refs, "; "); got != tt.want {
+			t.Errorf("adapter %q: refs = %q, want %q", tt.adapterID, got, tt.want)
+		}
+	}
+}
+
+func TestReferencesFailsClosed(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	if _, err := NewChecker(down.URL, "").References(&store.Run{AdapterID: "a1"}); err == nil {
+		t.Fatal("References succeeded with the deploy service failing")
+	}
+}
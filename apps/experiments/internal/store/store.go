@@ -4,6 +4,9 @@ package store
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -32,11 +35,72 @@ type Run struct {
 	StartedAt    *time.Time             `json:"started_at,omitempty"`
 	CompletedAt  *time.Time             `json:"completed_at,omitempty"`
 	CreatedAt    time.Time              `json:"created_at"`
+	Attempt      int                    `json:"attempt"`
+	History      []RunAttempt           `json:"history,omitempty"`
 }
 
+// RunAttempt records how an earlier attempt of a resumed run ended.
+type RunAttempt struct {
+	Attempt     int        `json:"attempt"`
+	Status      string     `json:"status"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ResumedAt   time.Time  `json:"resumed_at"`
+}
+
+// ErrRunReferenced is returned when deleting a run that other resources
+// still depend on.
+var ErrRunReferenced = errors.New("run is referenced")
+
+// ReferenceChecker reports resources outside this service that depend on a
+// run, such as deployments or active adapters built from it.
+type ReferenceChecker interface {
+	References(run *Run) ([]string, error)
+}
+
+// Run statuses. Completed, failed and cancelled runs are terminal.
+const (
+	RunPending   = "pending"
+	RunRunning   = "running"
+	RunCompleted = "completed"
+	RunFailed    = "failed"
+	RunCancelled = "cancelled"
+)
+
+// runTransitions lists the statuses each status may move to. Terminal runs
+// can only be restarted through ResumeRun.
+var runTransitions = map[string][]string{
+	RunPending: {RunRunning, RunCancelled},
+	RunRunning: {RunCompleted, RunFailed, RunCancelled},
+}
+
+var (
+	// ErrUnknownRunStatus is returned for a status that isn't one of the
+	// Run* constants.
+	ErrUnknownRunStatus = errors.New("unknown run status")
+	// ErrInvalidTransition is returned when a run can't move from its
+	// current status to the requested one.
+	ErrInvalidTransition = errors.New("invalid run status transition")
+)
+
+func canTransition(from, to string) bool {
+	for _, next := range runTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrDuplicateActiveRun is returned when creating a run whose name is
+// already used by a non-terminal run in the same experiment.
+var ErrDuplicateActiveRun = errors.New("an active run with this name already exists in the experiment")
+
 // ExperimentStore handles experiment data persistence.
 type ExperimentStore struct {
-	db *sql.DB
+	db               *sql.DB
+	refs             ReferenceChecker
+	uniqueActiveRuns bool
 }
 
 // NewExperimentStore creates a new store.
@@ -105,28 +169,75 @@ func (s *ExperimentStore) ListExperiments(ownerID string, limit, offset int) ([]
 	return experiments, nil
 }
 
-// CreateRun creates a new run.
+// CountExperiments returns the number of experiments across all owners.
+func (s *ExperimentStore) CountExperiments() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM experiments`).Scan(&n)
+	return n, err
+}
+
+// EnforceUniqueActiveRuns makes CreateRun reject a run whose name matches
+// a non-terminal run in the same experiment.
+func (s *ExperimentStore) EnforceUniqueActiveRuns(enabled bool) {
+	s.uniqueActiveRuns = enabled
+}
+
+// CreateRun creates a new run. New runs start at attempt 1.
 func (s *ExperimentStore) CreateRun(run *Run) error {
+	if run.Attempt == 0 {
+		run.Attempt = 1
+	}
 	hyperparamsJSON, _ := json.Marshal(run.Hyperparams)
 	metricsJSON, _ := json.Marshal(run.Metrics)
+	historyJSON, _ := json.Marshal(run.History)
 
-	_, err := s.db.Exec(`
-		INSERT INTO runs (id, experiment_id, name, status, hyperparams, metrics, dataset_id, adapter_id, started_at, completed_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`, run.ID, run.ExperimentID, run.Name, run.Status, hyperparamsJSON, metricsJSON, run.DatasetID, run.AdapterID, run.StartedAt, run.CompletedAt, run.CreatedAt)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	return err
+	if s.uniqueActiveRuns {
+		// Serialize creates for the same (experiment, name) until commit
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, run.ExperimentID+"/"+run.Name); err != nil {
+			return err
+		}
+
+		var exists bool
+		err := tx.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM runs
+				WHERE experiment_id = $1 AND name = $2 AND status NOT IN ($3, $4, $5)
+			)
+		`, run.ExperimentID, run.Name, RunCompleted, RunFailed, RunCancelled).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrDuplicateActiveRun
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO runs (id, experiment_id, name, status, hyperparams, metrics, dataset_id, adapter_id, started_at, completed_at, created_at, attempt, history)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, run.ID, run.ExperimentID, run.Name, run.Status, hyperparamsJSON, metricsJSON, run.DatasetID, run.AdapterID, run.StartedAt, run.CompletedAt, run.CreatedAt, run.Attempt, historyJSON)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // GetRun retrieves a run by ID.
 func (s *ExperimentStore) GetRun(id string) (*Run, error) {
 	run := &Run{}
-	var hyperparamsJSON, metricsJSON []byte
+	var hyperparamsJSON, metricsJSON, historyJSON []byte
 
 	err := s.db.QueryRow(`
-		SELECT id, experiment_id, name, status, hyperparams, metrics, dataset_id, adapter_id, started_at, completed_at, created_at
+		SELECT id, experiment_id, name, status, hyperparams, metrics, dataset_id, adapter_id, started_at, completed_at, created_at, attempt, history
 		FROM runs WHERE id = $1
-	`, id).Scan(&run.ID, &run.ExperimentID, &run.Name, &run.Status, &hyperparamsJSON, &metricsJSON, &run.DatasetID, &run.AdapterID, &run.StartedAt, &run.CompletedAt, &run.CreatedAt)
+	`, id).Scan(&run.ID, &run.ExperimentID, &run.Name, &run.Status, &hyperparamsJSON, &metricsJSON, &run.DatasetID, &run.AdapterID, &run.StartedAt, &run.CompletedAt, &run.CreatedAt, &run.Attempt, &historyJSON)
 
 	if err != nil {
 		return nil, err
@@ -134,6 +245,7 @@ func (s *ExperimentStore) GetRun(id string) (*Run, error) {
 
 	json.Unmarshal(hyperparamsJSON, &run.Hyperparams)
 	json.Unmarshal(metricsJSON, &run.Metrics)
+	json.Unmarshal(historyJSON, &run.History)
 
 	return run, nil
 }
@@ -141,7 +253,7 @@ func (s *ExperimentStore) GetRun(id string) (*Run, error) {
 // ListRuns retrieves runs for an experiment.
 func (s *ExperimentStore) ListRuns(experimentID string) ([]*Run, error) {
 	rows, err := s.db.Query(`
-		SELECT id, experiment_id, name, status, hyperparams, metrics, dataset_id, adapter_id, started_at, completed_at, created_at
+		SELECT id, experiment_id, name, status, hyperparams, metrics, dataset_id, adapter_id, started_at, completed_at, created_at, attempt, history
 		FROM runs WHERE experiment_id = $1
 		ORDER BY created_at DESC
 	`, experimentID)
@@ -150,32 +262,466 @@ func (s *ExperimentStore) ListRuns(experimentID string) ([]*Run, error) {
 	}
 	defer rows.Close()
 
+	return scanRuns(rows)
+}
+
+func scanRuns(rows *sql.Rows) ([]*Run, error) {
 	var runs []*Run
 	for rows.Next() {
 		run := &Run{}
-		var hyperparamsJSON, metricsJSON []byte
-		if err := rows.Scan(&run.ID, &run.ExperimentID, &run.Name, &run.Status, &hyperparamsJSON, &metricsJSON, &run.DatasetID, &run.AdapterID, &run.StartedAt, &run.CompletedAt, &run.CreatedAt); err != nil {
+		var hyperparamsJSON, metricsJSON, historyJSON []byte
+		if err := rows.Scan(&run.ID, &run.ExperimentID, &run.Name, &run.Status, &hyperparamsJSON, &metricsJSON, &run.DatasetID, &run.AdapterID, &run.StartedAt, &run.CompletedAt, &run.CreatedAt, &run.Attempt, &historyJSON); err != nil {
 			return nil, err
 		}
 		json.Unmarshal(hyperparamsJSON, &run.Hyperparams)
 		json.Unmarshal(metricsJSON, &run.Metrics)
+		json.Unmarshal(historyJSON, &run.History)
 		runs = append(runs, run)
 	}
+	return runs, rows.Err()
+}
+
+// ErrInvalidOrder is returned for a sort order other than asc or desc.
+var ErrInvalidOrder = errors.New("order must be asc or desc")
+
+// ListRunsSorted returns an experiment's runs that report metric, ordered
+// by its value. Ties are broken by creation time, oldest first. A limit of
+// zero or less returns every matching run.
+func (s *ExperimentStore) ListRunsSorted(experimentID, metric, order string, limit int) ([]*Run, error) {
+	// The direction can't be a bind parameter, so only the two literal
+	// keywords are ever interpolated
+	var dir string
+	switch strings.ToLower(order) {
+	case "asc":
+		dir = "ASC"
+	case "desc":
+		dir = "DESC"
+	default:
+		return nil, fmt.Errorf("%w, got %q", ErrInvalidOrder, order)
+	}
+
+	var lim interface{}
+	if limit > 0 {
+		lim = limit
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, experiment_id, name, status, hyperparams, metrics, dataset_id, adapter_id, started_at, completed_at, created_at, attempt, history
+		FROM runs
+		WHERE experiment_id = $1 AND metrics ? $2
+		ORDER BY (metrics->>$2)::float8 `+dir+`, created_at ASC
+		LIMIT $3
+	`, experimentID, metric, lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRuns(rows)
+}
+
+// GetBestRun returns the run with the lowest (GoalMin) or highest (GoalMax)
+// value of metric. It returns sql.ErrNoRows if no run reports the metric.
+func (s *ExperimentStore) GetBestRun(experimentID, metric string, goal Goal) (*Run, error) {
+	var order string
+	switch goal {
+	case GoalMin:
+		order = "asc"
+	case GoalMax:
+		order = "desc"
+	default:
+		return nil, fmt.Errorf("%w, got %q", ErrUnknownGoal, goal)
+	}
+
+	runs, err := s.ListRunsSorted(experimentID, metric, order, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return runs[0], nil
+}
+
+// UpdateRunStatus moves a run to status, enforcing the run state machine.
+// StartedAt is stamped when the run starts and CompletedAt when it reaches
+// a terminal status.
+func (s *ExperimentStore) UpdateRunStatus(id, status string) (*Run, error) {
+	switch status {
+	case RunPending, RunRunning, RunCompleted, RunFailed, RunCancelled:
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownRunStatus, status)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRow(`SELECT status FROM runs WHERE id = $1 FOR UPDATE`, id).Scan(&current)
+	if err != nil {
+		return nil, err
+	}
+	if !canTransition(current, status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current, status)
+	}
+
+	now := time.Now()
+	switch status {
+	case RunRunning:
+		_, err = tx.Exec(`UPDATE runs SET status = $2, started_at = $3 WHERE id = $1`, id, status, now)
+	default:
+		_, err = tx.Exec(`UPDATE runs SET status = $2, completed_at = $3 WHERE id = $1`, id, status, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
-	return runs, nil
+	return s.GetRun(id)
 }
 
-// CompareRuns compares metrics across multiple runs.
-func (s *ExperimentStore) CompareRuns(runIDs []string) (map[string]map[string]float64, error) {
-	result := make(map[string]map[string]float64)
+// ErrRunNotResumable is returned when resuming a run that hasn't failed or
+// been cancelled.
+var ErrRunNotResumable = errors.New("only failed or cancelled runs can be resumed")
+
+// ResumeRun starts a new attempt of a failed or cancelled run. The outcome
+// of the current attempt is appended to History, Attempt is incremented and
+// the run goes back to pending.
+func (s *ExperimentStore) ResumeRun(id string) (*Run, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	run := &Run{ID: id}
+	var historyJSON []byte
+	err = tx.QueryRow(`
+		SELECT status, started_at, completed_at, attempt, history
+		FROM runs WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&run.Status, &run.StartedAt, &run.CompletedAt, &run.Attempt, &historyJSON)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(historyJSON, &run.History)
+
+	if err := resumeAttempt(run, time.Now()); err != nil {
+		return nil, err
+	}
+	historyJSON, _ = json.Marshal(run.History)
+
+	_, err = tx.Exec(`
+		UPDATE runs
+		SET status = $2, started_at = NULL, completed_at = NULL, attempt = $3, history = $4
+		WHERE id = $1
+	`, id, run.Status, run.Attempt, historyJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetRun(id)
+}
+
+// resumeAttempt moves run to its next attempt: the current attempt is
+// appended to History and run goes back to pending with Attempt
+// incremented.
+func resumeAttempt(run *Run, now time.Time) error {
+	if run.Status != RunFailed && run.Status != RunCancelled {
+		return fmt.Errorf("%w: run is %s", ErrRunNotResumable, run.Status)
+	}
+	run.History = append(run.History, RunAttempt{
+		Attempt:     run.Attempt,
+		Status:      run.Status,
+		StartedAt:   run.StartedAt,
+		CompletedAt: run.CompletedAt,
+		ResumedAt:   now,
+	})
+	run.Attempt++
+	run.Status = RunPending
+	run.StartedAt = nil
+	run.CompletedAt = nil
+	return nil
+}
+
+// SetReferenceChecker configures the checks DeleteRun performs before
+// removing a run.
+func (s *ExperimentStore) SetReferenceChecker(c ReferenceChecker) {
+	s.refs = c
+}
+
+// DeleteRun removes a run and its recorded metrics. It refuses with
+// ErrRunReferenced if any deployment or active adapter still depends on it.
+func (s *ExperimentStore) DeleteRun(id string) error {
+	run, err := s.GetRun(id)
+	if err != nil {
+		return err
+	}
+
+	if s.refs != nil {
+		refs, err := s.refs.References(run)
+		if err != nil {
+			return fmt.Errorf("check references: %w", err)
+		}
+		if len(refs) > 0 {
+			return fmt.Errorf("%w by %s", ErrRunReferenced, strings.Join(refs, ", "))
+		}
+	}
+
+	// Metrics are stored on the run row, so deleting it removes them too
+	res, err := s.db.Exec(`DELETE FROM runs WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CompareResult holds the metrics of each compared run, plus the IDs that
+// didn't match any run.
+type CompareResult struct {
+	Metrics  map[string]map[string]float64 `json:"metrics"`
+	NotFound []string                      `json:"not_found"`
+}
+
+// CompareRuns compares metrics across multiple runs. Unknown IDs are listed
+// in NotFound rather than failing the whole comparison.
+func (s *ExperimentStore) CompareRuns(runIDs []string) (*CompareResult, error) {
+	result := &CompareResult{
+		Metrics:  make(map[string]map[string]float64),
+		NotFound: []string{},
+	}
 
 	for _, id := range runIDs {
 		run, err := s.GetRun(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			result.NotFound = append(result.NotFound, id)
+			continue
+		}
 		if err != nil {
+			return nil, err
+		}
+		result.Metrics[id] = run.Metrics
+	}
+
+	return result, nil
+}
+
+// Goal says whether lower or higher values of a metric are better.
+type Goal string
+
+const (
+	GoalMin Goal = "min"
+	GoalMax Goal = "max"
+)
+
+// ErrUnknownGoal is returned for a goal other than min or max.
+var ErrUnknownGoal = errors.New("goal must be min or max")
+
+// MetricDiff is one run's value of a metric relative to the baseline run.
+// Absent is set when the run didn't report the metric; Delta is nil when
+// the baseline didn't.
+type MetricDiff struct {
+	Value  *float64 `json:"value,omitempty"`
+	Delta  *float64 `json:"delta,omitempty"`
+	Absent bool     `json:"absent,omitempty"`
+}
+
+// CompareDiff is the result of CompareRunsDiff.
+type CompareDiff struct {
+	BaselineRunID string                           `json:"baseline_run_id"`
+	PrimaryMetric string                           `json:"primary_metric"`
+	Goal          Goal                             `json:"goal"`
+	BestRunID     string                           `json:"best_run_id,omitempty"`
+	Runs          map[string]map[string]MetricDiff `json:"runs"`
+	NotFound      []string                         `json:"not_found"`
+}
+
+// CompareRunsDiff compares runs against the first one found, reporting each
+// metric's delta from that baseline, and picks the run with the best value
+// of primaryMetric for goal. Runs that lack the primary metric can't win;
+// if none has it, BestRunID is empty.
+func (s *ExperimentStore) CompareRunsDiff(runIDs []string, primaryMetric string, goal Goal) (*CompareDiff, error) {
+	if goal != GoalMin && goal != GoalMax {
+		return nil, fmt.Errorf("%w, got %q", ErrUnknownGoal, goal)
+	}
+
+	var runs []*Run
+	notFound := []string{}
+	for _, id := range runIDs {
+		run, err := s.GetRun(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			notFound = append(notFound, id)
 			continue
 		}
-		result[id] = run.Metrics
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
 	}
 
+	result := diffRuns(runs, primaryMetric, goal)
+	result.NotFound = notFound
 	return result, nil
 }
+
+// diffRuns computes per-metric deltas against runs[0] over the union of
+// all runs' metrics. Ties for best go to the earlier run.
+func diffRuns(runs []*Run, primaryMetric string, goal Goal) *CompareDiff {
+	result := &CompareDiff{
+		PrimaryMetric: primaryMetric,
+		Goal:          goal,
+		Runs:          make(map[string]map[string]MetricDiff),
+	}
+	if len(runs) == 0 {
+		return result
+	}
+	baseline := runs[0]
+	result.BaselineRunID = baseline.ID
+
+	names := make(map[string]bool)
+	for _, run := range runs {
+		for name := range run.Metrics {
+			names[name] = true
+		}
+	}
+
+	var best *float64
+	for _, run := range runs {
+		diffs := make(map[string]MetricDiff, len(names))
+		for name := range names {
+			v, ok := run.Metrics[name]
+			if !ok {
+				diffs[name] = MetricDiff{Absent: true}
+				continue
+			}
+			d := MetricDiff{Value: &v}
+			if base, ok := baseline.Metrics[name]; ok {
+				delta := v - base
+				d.Delta = &delta
+			}
+			diffs[name] = d
+		}
+		result.Runs[run.ID] = diffs
+
+		v, ok := run.Metrics[primaryMetric]
+		if !ok {
+			continue
+		}
+		if best == nil || (goal == GoalMin && v < *best) || (goal == GoalMax && v > *best) {
+			best = &v
+			result.BestRunID = run.ID
+		}
+	}
+
+	return result
+}
+
+// Aggregation names a function used to combine a metric across runs.
+type Aggregation string
+
+const (
+	AggAvg  Aggregation = "avg"
+	AggSum  Aggregation = "sum"
+	AggMin  Aggregation = "min"
+	AggMax  Aggregation = "max"
+	AggLast Aggregation = "last"
+)
+
+// ErrUnknownAggregation is returned for an unsupported aggregation name.
+var ErrUnknownAggregation = errors.New("unknown aggregation")
+
+// RollupResult holds experiment-level metrics combined across runs.
+type RollupResult struct {
+	ExperimentID string                 `json:"experiment_id"`
+	RunCount     int                    `json:"run_count"`
+	Metrics      map[string]float64     `json:"metrics"`
+	Aggregations map[string]Aggregation `json:"aggregations"`
+}
+
+// Rollup combines each metric across an experiment's runs using the
+// aggregation chosen for it, defaulting to avg.
+func (s *ExperimentStore) Rollup(experimentID string, aggs map[string]Aggregation) (*RollupResult, error) {
+	for name, agg := range aggs {
+		switch agg {
+		case AggAvg, AggSum, AggMin, AggMax, AggLast:
+		default:
+			return nil, fmt.Errorf("%w %q for metric %s", ErrUnknownAggregation, agg, name)
+		}
+	}
+
+	runs, err := s.ListRuns(experimentID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := rollupRuns(runs, aggs)
+	result.ExperimentID = experimentID
+	return result, nil
+}
+
+// rollupRuns aggregates run metrics. Runs are expected newest first, as
+// returned by ListRuns, so "last" picks the most recent run's value.
+func rollupRuns(runs []*Run, aggs map[string]Aggregation) *RollupResult {
+	values := make(map[string][]float64)
+	for _, run := range runs {
+		for name, v := range run.Metrics {
+			values[name] = append(values[name], v)
+		}
+	}
+
+	result := &RollupResult{
+		RunCount:     len(runs),
+		Metrics:      make(map[string]float64),
+		Aggregations: make(map[string]Aggregation),
+	}
+
+	for name, vs := range values {
+		agg := aggs[name]
+		if agg == "" {
+			agg = AggAvg
+		}
+
+		var out float64
+		switch agg {
+		case AggLast:
+			out = vs[0]
+		case AggMin:
+			out = vs[0]
+			for _, v := range vs[1:] {
+				if v < out {
+					out = v
+				}
+			}
+		case AggMax:
+			out = vs[0]
+			for _, v := range vs[1:] {
+				if v > out {
+					out = v
+				}
+			}
+		default:
+			for _, v := range vs {
+				out += v
+			}
+			if agg == AggAvg {
+				out /= float64(len(vs))
+			}
+		}
+
+		result.Metrics[name] = out
+		result.Aggregations[name] = agg
+	}
+
+	return result
+}
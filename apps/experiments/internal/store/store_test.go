@@ -0,0 +1,505 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestResumeAttemptIncrementsAcrossResumes(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := &Run{ID: "run-1", Status: RunPending, Attempt: 1}
+
+	for i, outcome := range []string{RunFailed, RunCancelled} {
+		started := start.Add(time.Duration(i) * time.Hour)
+		ended := started.Add(time.Minute)
+		run.Status, run.StartedAt, run.CompletedAt = outcome, &started, &ended
+
+		// The row round-trips through the history column between resumes
+		historyJSON, _ := json.Marshal(run.History)
+		run.History = nil
+		if err := json.Unmarshal(historyJSON, &run.History); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := resumeAttempt(run, ended.Add(time.Second)); err != nil {
+			t.Fatalf("resume %d: %v", i+1, err)
+		}
+		if run.Attempt != i+2 || run.Status != RunPending || run.StartedAt != nil || run.CompletedAt != nil {
+			t.Fatalf("after resume %d: %+v", i+1, run)
+		}
+	}
+
+	if len(run.History) != 2 {
+		t.Fatalf("history = %+v, want two attempts", run.History)
+	}
+	for i, want := range []string{RunFailed, RunCancelled} {
+		h := run.History[i]
+		if h.Attempt != i+1 || h.Status != want || h.StartedAt == nil || h.CompletedAt == nil {
+			t.Fatalf("history[%d] = %+v, want attempt %d %s", i, h, i+1, want)
+		}
+	}
+}
+
+func TestResumeAttemptRejectsActiveRuns(t *testing.T) {
+	for _, status := range []string{RunPending, RunRunning, RunCompleted} {
+		run := &Run{Status: status, Attempt: 1}
+		if err := resumeAttempt(run, time.Now()); !errors.Is(err, ErrRunNotResumable) {
+			t.Fatalf("resume %s = %v, want ErrRunNotResumable", status, err)
+		}
+		if run.Attempt != 1 || len(run.History) != 0 {
+			t.Fatalf("rejected resume changed run: %+v", run)
+		}
+	}
+}
+
+func TestRollupRunsAggregations(t *testing.T) {
+	// Newest first, as ListRuns returns them; the oldest run lacks tokens
+	runs := []*Run{
+		{ID: "r3", Metrics: map[string]float64{"loss": 0.2, "tokens": 300}},
+		{ID: "r2", Metrics: map[string]float64{"loss": 0.6, "tokens": 100}},
+		{ID: "r1", Metrics: map[string]float64{"loss": 0.4}},
+	}
+	tests := []struct {
+		agg    Aggregation
+		loss   float64
+		tokens float64
+	}{
+		{AggAvg, 0.4, 200},
+		{AggSum, 1.2, 400},
+		{AggMin, 0.2, 100},
+		{AggMax, 0.6, 300},
+		{AggLast, 0.2, 300},
+		{"", 0.4, 200},
+	}
+	for _, tt := range tests {
+		name := string(tt.agg)
+		if name == "" {
+			name = "default"
+		}
+		t.Run(name, func(t *testing.T) {
+			aggs := map[string]Aggregation{"loss": tt.agg, "tokens": tt.agg}
+			result := rollupRuns(runs, aggs)
+			if result.RunCount != 3 {
+				t.Fatalf("run count = %d, want 3", result.RunCount)
+			}
+			for metric, want := range map[string]float64{"loss": tt.loss, "tokens": tt.tokens} {
+				if got := result.Metrics[metric]; math.Abs(got-want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", metric, got, want)
+				}
+			}
+			wantAgg := tt.agg
+			if wantAgg == "" {
+				wantAgg = AggAvg
+			}
+			if result.Aggregations["loss"] != wantAgg {
+				t.Errorf("aggregation = %q, want %q", result.Aggregations["loss"], wantAgg)
+			}
+		})
+	}
+}
+
+func TestRollupRejectsUnknownAggregation(t *testing.T) {
+	// Validation happens before any query, so no database is needed
+	s := NewExperimentStore(nil)
+	if _, err := s.Rollup("exp-1", map[string]Aggregation{"loss": "median"}); !errors.Is(err, ErrUnknownAggregation) {
+		t.Fatalf("err = %v, want ErrUnknownAggregation", err)
+	}
+}
+
+// newMockStore returns a store over a mock database whose expectations
+// must all be met by the end of the test.
+func newMockStore(t *testing.T) (*ExperimentStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return NewExperimentStore(db), mock
+}
+
+var runColumns = []string{"id", "experiment_id", "name", "status", "hyperparams", "metrics", "dataset_id", "adapter_id", "started_at", "completed_at", "created_at", "attempt", "history"}
+
+// runRow is a runs row for a completed run with the given ID and metrics.
+func runRow(id, metricsJSON string) *sqlmock.Rows {
+	return sqlmock.NewRows(runColumns).AddRow(id, "exp-1", "run-"+id, RunCompleted, []byte(`{}`), []byte(metricsJSON), "", "adapter-"+id, nil, nil, time.Now(), 1, []byte(`[]`))
+}
+
+// fakeRefs reports the references configured for each run ID.
+type fakeRefs map[string][]string
+
+func (f fakeRefs) References(run *Run) ([]string, error) {
+	return f[run.ID], nil
+}
+
+func TestDeleteRunRefusesReferencedRun(t *testing.T) {
+	s, mock := newMockStore(t)
+	s.SetReferenceChecker(fakeRefs{"r1": {"deployment dep-1", "adapter adapter-r1"}})
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("r1").WillReturnRows(runRow("r1", `{"loss": 0.1}`))
+
+	err := s.DeleteRun("r1")
+	if !errors.Is(err, ErrRunReferenced) || !strings.Contains(err.Error(), "deployment dep-1") {
+		t.Fatalf("err = %v, want ErrRunReferenced naming the deployment", err)
+	}
+	// No DELETE was expected, so ExpectationsWereMet fails if one ran
+}
+
+func TestDeleteRunDeletesUnreferencedRun(t *testing.T) {
+	s, mock := newMockStore(t)
+	s.SetReferenceChecker(fakeRefs{"r1": {"deployment dep-1"}})
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("r2").WillReturnRows(runRow("r2", `{"loss": 0.1}`))
+	mock.ExpectExec(`DELETE FROM runs WHERE id = \$1`).WithArgs("r2").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := s.DeleteRun("r2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteRunMissing(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("nope").WillReturnRows(sqlmock.NewRows(runColumns))
+
+	if err := s.DeleteRun("nope"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+// expectActiveRunCheck expects CreateRun's locked lookup for an active run
+// named name in exp-1, reporting whether one exists.
+func expectActiveRunCheck(mock sqlmock.Sqlmock, name string, exists bool) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(hashtext\(\$1\)\)`).WithArgs("exp-1/" + name).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs("exp-1", name, RunCompleted, RunFailed, RunCancelled).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+}
+
+func TestCreateRunRejectsDuplicateActiveRun(t *testing.T) {
+	s, mock := newMockStore(t)
+	s.EnforceUniqueActiveRuns(true)
+
+	// First run is created
+	expectActiveRunCheck(mock, "baseline", false)
+	mock.ExpectExec(`INSERT INTO runs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := s.CreateRun(&Run{ID: "r1", ExperimentID: "exp-1", Name: "baseline", Status: RunPending}); err != nil {
+		t.Fatalf("first CreateRun: %v", err)
+	}
+
+	// A second run with the same name is rejected while the first is active
+	expectActiveRunCheck(mock, "baseline", true)
+	mock.ExpectRollback()
+	err := s.CreateRun(&Run{ID: "r2", ExperimentID: "exp-1", Name: "baseline", Status: RunPending})
+	if !errors.Is(err, ErrDuplicateActiveRun) {
+		t.Fatalf("duplicate CreateRun err = %v, want ErrDuplicateActiveRun", err)
+	}
+
+	// The first run completes
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM runs WHERE id = \$1 FOR UPDATE`).WithArgs("r1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(RunRunning))
+	mock.ExpectExec(`UPDATE runs SET status = \$2, completed_at = \$3 WHERE id = \$1`).
+		WithArgs("r1", RunCompleted, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("r1").WillReturnRows(runRow("r1", `{}`))
+	if _, err := s.UpdateRunStatus("r1", RunCompleted); err != nil {
+		t.Fatalf("completing r1: %v", err)
+	}
+
+	// Now the name is free again
+	expectActiveRunCheck(mock, "baseline", false)
+	mock.ExpectExec(`INSERT INTO runs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := s.CreateRun(&Run{ID: "r2", ExperimentID: "exp-1", Name: "baseline", Status: RunPending}); err != nil {
+		t.Fatalf("CreateRun after completion: %v", err)
+	}
+}
+
+func TestCreateRunAllowsDuplicatesWhenNotEnforced(t *testing.T) {
+	s, mock := newMockStore(t)
+
+	for _, id := range []string{"r1", "r2"} {
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO runs`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		if err := s.CreateRun(&Run{ID: id, ExperimentID: "exp-1", Name: "baseline", Status: RunPending}); err != nil {
+			t.Fatalf("CreateRun %s: %v", id, err)
+		}
+	}
+}
+
+func TestCompareRunsReportsNotFound(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("r1").WillReturnRows(runRow("r1", `{"loss": 0.4}`))
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("r-typo").WillReturnRows(sqlmock.NewRows(runColumns))
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("r2").WillReturnRows(runRow("r2", `{"loss": 0.2, "acc": 0.9}`))
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("gone").WillReturnRows(sqlmock.NewRows(runColumns))
+
+	result, err := s.CompareRuns([]string{"r1", "r-typo", "r2", "gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]float64{
+		"r1": {"loss": 0.4},
+		"r2": {"loss": 0.2, "acc": 0.9},
+	}
+	if !reflect.DeepEqual(result.Metrics, want) {
+		t.Errorf("metrics = %v, want %v", result.Metrics, want)
+	}
+	if !reflect.DeepEqual(result.NotFound, []string{"r-typo", "gone"}) {
+		t.Errorf("not found = %v, want [r-typo gone]", result.NotFound)
+	}
+}
+
+func TestCompareRunsFailsOnQueryError(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("r1").WillReturnError(errors.New("connection reset"))
+
+	if _, err := s.CompareRuns([]string{"r1", "r2"}); err == nil {
+		t.Fatal("query error was reported as a missing run")
+	}
+}
+
+func TestDiffRunsPicksBestForGoal(t *testing.T) {
+	// r2 lacks the primary metric, so it can't win even though its
+	// accuracy would be best
+	runs := []*Run{
+		{ID: "r1", Metrics: map[string]float64{"loss": 0.5, "acc": 0.7}},
+		{ID: "r2", Metrics: map[string]float64{"acc": 0.95}},
+		{ID: "r3", Metrics: map[string]float64{"loss": 0.3, "acc": 0.8}},
+		{ID: "r4", Metrics: map[string]float64{"loss": 0.9, "acc": 0.6}},
+	}
+	tests := []struct {
+		goal   Goal
+		metric string
+		best   string
+	}{
+		{GoalMin, "loss", "r3"},
+		{GoalMax, "loss", "r4"},
+		{GoalMax, "acc", "r2"},
+		{GoalMin, "acc", "r4"},
+		{GoalMin, "perplexity", ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.goal)+" "+tt.metric, func(t *testing.T) {
+			result := diffRuns(runs, tt.metric, tt.goal)
+			if result.BestRunID != tt.best {
+				t.Errorf("best = %q, want %q", result.BestRunID, tt.best)
+			}
+			if result.BaselineRunID != "r1" || len(result.Runs) != len(runs) {
+				t.Errorf("baseline %q over %d runs, want r1 over %d", result.BaselineRunID, len(result.Runs), len(runs))
+			}
+		})
+	}
+}
+
+func TestDiffRunsDeltasAndAbsentMetrics(t *testing.T) {
+	runs := []*Run{
+		{ID: "base", Metrics: map[string]float64{"loss": 0.5}},
+		{ID: "missing", Metrics: map[string]float64{"acc": 0.9}},
+		{ID: "better", Metrics: map[string]float64{"loss": 0.25, "acc": 0.8}},
+	}
+	result := diffRuns(runs, "loss", GoalMin)
+
+	if d := result.Runs["missing"]["loss"]; !d.Absent || d.Value != nil || d.Delta != nil {
+		t.Errorf("missing loss = %+v, want absent", d)
+	}
+	if d := result.Runs["better"]["loss"]; d.Absent || d.Delta == nil || math.Abs(*d.Delta+0.25) > 1e-9 {
+		t.Errorf("better loss = %+v, want delta -0.25", d)
+	}
+	// The baseline has no acc, so there is a value but nothing to diff it against
+	if d := result.Runs["better"]["acc"]; d.Value == nil || *d.Value != 0.8 || d.Delta != nil {
+		t.Errorf("better acc = %+v, want value 0.8 without delta", d)
+	}
+	if d := result.Runs["base"]["acc"]; !d.Absent {
+		t.Errorf("base acc = %+v, want absent", d)
+	}
+	if d := result.Runs["base"]["loss"]; d.Delta == nil || *d.Delta != 0 {
+		t.Errorf("base loss = %+v, want zero delta", d)
+	}
+	if result.BestRunID != "better" {
+		t.Errorf("best = %q, want better", result.BestRunID)
+	}
+}
+
+func TestDiffRunsTieGoesToEarlierRun(t *testing.T) {
+	runs := []*Run{
+		{ID: "a", Metrics: map[string]float64{"loss": 0.5}},
+		{ID: "b", Metrics: map[string]float64{"loss": 0.3}},
+		{ID: "c", Metrics: map[string]float64{"loss": 0.3}},
+	}
+	if best := diffRuns(runs, "loss", GoalMin).BestRunID; best != "b" {
+		t.Fatalf("best = %q, want b", best)
+	}
+}
+
+func TestCompareRunsDiffRejectsUnknownGoal(t *testing.T) {
+	// Validation happens before any query, so no database is needed
+	s := NewExperimentStore(nil)
+	if _, err := s.CompareRunsDiff([]string{"r1"}, "loss", "lowest"); !errors.Is(err, ErrUnknownGoal) {
+		t.Fatalf("err = %v, want ErrUnknownGoal", err)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	statuses := []string{RunPending, RunRunning, RunCompleted, RunFailed, RunCancelled}
+	legal := map[[2]string]bool{
+		{RunPending, RunRunning}:   true,
+		{RunPending, RunCancelled}: true,
+		{RunRunning, RunCompleted}: true,
+		{RunRunning, RunFailed}:    true,
+		{RunRunning, RunCancelled}: true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got, want := canTransition(from, to), legal[[2]string{from, to}]; got != want {
+				t.Errorf("canTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestUpdateRunStatusStampsTimes(t *testing.T) {
+	tests := []struct {
+		from, to string
+		column   string
+	}{
+		{RunPending, RunRunning, "started_at"},
+		{RunPending, RunCancelled, "completed_at"},
+		{RunRunning, RunCompleted, "completed_at"},
+		{RunRunning, RunFailed, "completed_at"},
+		{RunRunning, RunCancelled, "completed_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			s, mock := newMockStore(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT status FROM runs WHERE id = \$1 FOR UPDATE`).WithArgs("r1").
+				WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(tt.from))
+			mock.ExpectExec(`UPDATE runs SET status = \$2, `+tt.column+` = \$3 WHERE id = \$1`).
+				WithArgs("r1", tt.to, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			mock.ExpectQuery(`FROM runs WHERE id = \$1`).WithArgs("r1").WillReturnRows(runRow("r1", `{}`))
+
+			if _, err := s.UpdateRunStatus("r1", tt.to); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateRunStatusRejectsIllegalTransition(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM runs WHERE id = \$1 FOR UPDATE`).WithArgs("r1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(RunCompleted))
+	mock.ExpectRollback()
+
+	// No UPDATE was expected, so ExpectationsWereMet fails if one ran
+	if _, err := s.UpdateRunStatus("r1", RunRunning); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("err = %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestUpdateRunStatusRejectsUnknownStatus(t *testing.T) {
+	// Validation happens before any query, so no database is needed
+	s := NewExperimentStore(nil)
+	if _, err := s.UpdateRunStatus("r1", "paused"); !errors.Is(err, ErrUnknownRunStatus) {
+		t.Fatalf("err = %v, want ErrUnknownRunStatus", err)
+	}
+}
+
+func TestListRunsSortedQuery(t *testing.T) {
+	tests := []struct {
+		order string
+		limit int
+		dir   string
+		lim   interface{}
+	}{
+		{"asc", 0, "ASC", nil},
+		{"DESC", 2, "DESC", 2},
+		{"desc", -1, "DESC", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			s, mock := newMockStore(t)
+			rows := sqlmock.NewRows(runColumns)
+			for _, r := range []struct{ id, metrics string }{{"r2", `{"eval_loss": 0.2}`}, {"r3", `{"eval_loss": 0.3}`}, {"r1", `{"eval_loss": 0.5}`}} {
+				rows.AddRow(r.id, "exp-1", "run-"+r.id, RunCompleted, []byte(`{}`), []byte(r.metrics), "", "", nil, nil, time.Now(), 1, []byte(`[]`))
+			}
+			mock.ExpectQuery(`WHERE experiment_id = \$1 AND metrics \? \$2\s+ORDER BY \(metrics->>\$2\)::float8 `+tt.dir+`, created_at ASC\s+LIMIT \$3`).
+				WithArgs("exp-1", "eval_loss", tt.lim).WillReturnRows(rows)
+
+			runs, err := s.ListRunsSorted("exp-1", "eval_loss", tt.order, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range runs {
+				got = append(got, r.ID)
+			}
+			// Rows come back in the order the database sorted them
+			if strings.Join(got, ",") != "r2,r3,r1" {
+				t.Fatalf("runs = %v, want database order", got)
+			}
+		})
+	}
+}
+
+func TestListRunsSortedRejectsOrder(t *testing.T) {
+	// Validation happens before any query, so no database is needed
+	s := NewExperimentStore(nil)
+	if _, err := s.ListRunsSorted("exp-1", "eval_loss", "sideways; DROP TABLE runs", 0); !errors.Is(err, ErrInvalidOrder) {
+		t.Fatalf("err = %v, want ErrInvalidOrder", err)
+	}
+	if _, err := s.GetBestRun("exp-1", "eval_loss", "lowest"); !errors.Is(err, ErrUnknownGoal) {
+		t.Fatalf("err = %v, want ErrUnknownGoal", err)
+	}
+}
+
+func TestGetBestRun(t *testing.T) {
+	tests := []struct {
+		goal Goal
+		dir  string
+		best string
+	}{
+		{GoalMin, "ASC", "r2"},
+		{GoalMax, "DESC", "r1"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.goal), func(t *testing.T) {
+			s, mock := newMockStore(t)
+			mock.ExpectQuery(`ORDER BY \(metrics->>\$2\)::float8 `+tt.dir).WithArgs("exp-1", "eval_loss", 1).
+				WillReturnRows(runRow(tt.best, `{"eval_loss": 0.2}`))
+
+			run, err := s.GetBestRun("exp-1", "eval_loss", tt.goal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if run.ID != tt.best {
+				t.Fatalf("best = %s, want %s", run.ID, tt.best)
+			}
+		})
+	}
+
+	t.Run("no run reports the metric", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectQuery(`metrics \? \$2`).WithArgs("exp-1", "bleu", 1).WillReturnRows(sqlmock.NewRows(runColumns))
+		if _, err := s.GetBestRun("exp-1", "bleu", GoalMax); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("err = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type contextKey string
+
+const userIDKey contextKey = "user_id"
+
+var (
+	errMalformedToken = errors.New("malformed token")
+	errTokenExpired   = errors.New("token expired")
+	errNotYetValid    = errors.New("token not yet valid")
+	errBadSignature   = errors.New("invalid token signature")
+	errNoKeySource    = errors.New("no token key source configured")
+)
+
+// UserIDFromContext returns the authenticated user ID, if any.
+func UserIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey).(string)
+	return id
+}
+
+// TokenValidator verifies bearer JWTs against a shared secret (HS256)
+// or a JWKS endpoint (RS256).
+type TokenValidator struct {
+	secret          []byte
+	jwks            *jwksCache
+	now             func() time.Time
+	allowUnverified bool
+}
+
+// NewTokenValidator creates a validator from JWT_SECRET / JWKS_URL.
+func NewTokenValidator(secret, jwksURL string) *TokenValidator {
+	v := &TokenValidator{now: time.Now}
+	if secret != "" {
+		v.secret = []byte(secret)
+	}
+	if jwksURL != "" {
+		v.jwks = &jwksCache{url: jwksURL, client: &http.Client{Timeout: 5 * time.Second}, now: time.Now}
+	}
+	return v
+}
+
+// Enabled reports whether any key source is configured.
+func (v *TokenValidator) Enabled() bool {
+	return v.secret != nil || v.jwks != nil
+}
+
+// SetAllowUnverified lets tokens through unverified when no key source is
+// configured, for local development only. Without it such tokens are
+// rejected.
+func (v *TokenValidator) SetAllowUnverified(allow bool) {
+	v.allowUnverified = allow
+}
+
+// Validate checks the token and returns the user ID it was issued to.
+func (v *TokenValidator) Validate(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", errMalformedToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errMalformedToken
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch header.Alg {
+	case "HS256":
+		if v.secret == nil {
+			return "", errBadSignature
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return "", errBadSignature
+		}
+	case "RS256":
+		if v.jwks == nil {
+			return "", errBadSignature
+		}
+		key, err := v.jwks.key(header.Kid)
+		if err != nil {
+			return "", err
+		}
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return "", errBadSignature
+		}
+	default:
+		return "", fmt.Errorf("%w: unsupported alg %q", errMalformedToken, header.Alg)
+	}
+
+	var claims struct {
+		Sub    string `json:"sub"`
+		UserID string `json:"user_id"`
+		Exp    *int64 `json:"exp"`
+		Nbf    *int64 `json:"nbf"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", errMalformedToken
+	}
+	now := v.now().Unix()
+	if claims.Exp == nil || now >= *claims.Exp {
+		return "", errTokenExpired
+	}
+	if claims.Nbf != nil && now < *claims.Nbf {
+		return "", errNotYetValid
+	}
+
+	userID := claims.Sub
+	if userID == "" {
+		userID = claims.UserID
+	}
+	if userID == "" {
+		return "", fmt.Errorf("%w: missing subject", errMalformedToken)
+	}
+	return userID, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// jwksCache fetches and caches RSA public keys by key ID. mu guards the
+// cached state and is never held across a fetch; fetchMu serializes
+// fetches.
+type jwksCache struct {
+	url     string
+	client  *http.Client
+	now     func() time.Time
+	fetchMu sync.Mutex
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	lastErr     error
+}
+
+const (
+	jwksRefresh = 10 * time.Minute
+	// jwksMinRefetch is the least time between fetches, so tokens with
+	// unknown key IDs cannot drive a fetch per request.
+	jwksMinRefetch = time.Minute
+)
+
+// key returns the public key for kid, refetching the key set when it is
+// older than jwksRefresh or lacks kid. If a refresh fails, a key that was
+// already cached keeps being used.
+func (c *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	k, fresh := c.lookup(kid)
+	if k != nil && fresh {
+		return k, nil
+	}
+	if err := c.maybeRefresh(); err != nil {
+		if k != nil {
+			slog.Warn("JWKS refresh failed; using cached key", "kid", kid, "error", err)
+			return k, nil
+		}
+		return nil, err
+	}
+	if k, _ := c.lookup(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key id %q", errBadSignature, kid)
+}
+
+// lookup returns the cached key for kid, or nil, and whether the cached
+// set is younger than jwksRefresh.
+func (c *jwksCache) lookup(kid string) (*rsa.PublicKey, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.keys[kid], c.now().Sub(c.fetchedAt) < jwksRefresh
+}
+
+// maybeRefresh fetches the key set unless a fetch was attempted within
+// jwksMinRefetch, in which case it returns that attempt's error.
+func (c *jwksCache) maybeRefresh() error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	c.mu.Lock()
+	if !c.attemptedAt.IsZero() && c.now().Sub(c.attemptedAt) < jwksMinRefetch {
+		err := c.lastErr
+		c.mu.Unlock()
+		return err
+	}
+	c.attemptedAt = c.now()
+	c.mu.Unlock()
+
+	keys, err := c.fetch()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastErr = err
+	if err != nil {
+		return err
+	}
+	c.keys = keys
+	c.fetchedAt = c.now()
+	return nil
+}
+
+// fetch downloads and parses the key set.
+func (c *jwksCache) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func authMiddleware(validator *TokenValidator, requireAuth bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Never trust identity asserted by the client
+		r.Header.Del("X-User-ID")
+
+		// Skip auth for health checks
+		if strings.HasSuffix(r.URL.Path, "/health") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get("Authorization")
+		if query := r.URL.Query(); query.Has("token") {
+			if token == "" {
+				token = query.Get("token")
+			}
+			// Keep credentials out of backend URLs and their logs
+			query.Del("token")
+			r.URL.RawQuery = query.Encode()
+		}
+		token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+
+		if token == "" {
+			if requireAuth {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !validator.Enabled() {
+			if validator.allowUnverified {
+				next.ServeHTTP(w, r)
+				return
+			}
+			http.Error(w, "Unauthorized: "+errNoKeySource.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := validator.Validate(token)
+		if err != nil {
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		r.Header.Set("X-User-ID", userID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey, userID)))
+	})
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type jwksFixture struct {
+	key     *rsa.PrivateKey
+	fetches atomic.Int64
+	failing atomic.Bool
+	now     time.Time
+	v       *TokenValidator
+}
+
+func newJWKSFixture(t *testing.T) *jwksFixture {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &jwksFixture{key: key, now: time.Unix(1700000000, 0)}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.fetches.Add(1)
+		if f.failing.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(srv.Close)
+
+	f.v = NewTokenValidator("", srv.URL)
+	clock := func() time.Time { return f.now }
+	f.v.now = clock
+	f.v.jwks.now = clock
+	return f
+}
+
+func (f *jwksFixture) token(t *testing.T, kid string) string {
+	t.Helper()
+	enc := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := enc(map[string]string{"alg": "RS256", "kid": kid}) + "." +
+		enc(map[string]interface{}{"sub": "alice", "exp": f.now.Add(time.Hour).Unix()})
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestUnknownKidRefetchIsRateLimited(t *testing.T) {
+	f := newJWKSFixture(t)
+	unknown := f.token(t, "rotated-away")
+
+	for i := 0; i < 5; i++ {
+		if _, err := f.v.Validate(unknown); err == nil {
+			t.Fatal("token with unknown kid validated")
+		}
+	}
+	if n := f.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	f.now = f.now.Add(jwksMinRefetch)
+	f.v.Validate(unknown)
+	if n := f.fetches.Load(); n != 2 {
+		t.Fatalf("fetches after %s = %d, want 2", jwksMinRefetch, n)
+	}
+}
+
+func TestStaleKeyUsedWhenRefreshFails(t *testing.T) {
+	f := newJWKSFixture(t)
+	if _, err := f.v.Validate(f.token(t, "k1")); err != nil {
+		t.Fatal(err)
+	}
+
+	f.failing.Store(true)
+	f.now = f.now.Add(jwksRefresh + time.Second)
+	userID, err := f.v.Validate(f.token(t, "k1"))
+	if err != nil || userID != "alice" {
+		t.Fatalf("Validate after failed refresh = %q, %v; want the stale key used", userID, err)
+	}
+	if n := f.fetches.Load(); n != 2 {
+		t.Fatalf("fetches = %d, want a refresh attempt", n)
+	}
+}
+
+// hsToken signs claims with HS256 under secret.
+func hsToken(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	enc := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := enc(map[string]string{"alg": "HS256"}) + "." + enc(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateHS256(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := NewTokenValidator("s3cret", "")
+	v.now = func() time.Time { return now }
+	hour := int64(time.Hour / time.Second)
+	valid := hsToken(t, "s3cret", map[string]interface{}{"sub": "alice", "exp": now.Unix() + hour})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", valid, nil},
+		{"user_id claim", hsToken(t, "s3cret", map[string]interface{}{"user_id": "alice", "exp": now.Unix() + hour}), nil},
+		{"expired", hsToken(t, "s3cret", map[string]interface{}{"sub": "alice", "exp": now.Unix()}), errTokenExpired},
+		{"no exp", hsToken(t, "s3cret", map[string]interface{}{"sub": "alice"}), errTokenExpired},
+		{"not yet valid", hsToken(t, "s3cret", map[string]interface{}{"sub": "alice", "exp": now.Unix() + hour, "nbf": now.Unix() + 60}), errNotYetValid},
+		{"nbf passed", hsToken(t, "s3cret", map[string]interface{}{"sub": "alice", "exp": now.Unix() + hour, "nbf": now.Unix()}), nil},
+		{"wrong secret", hsToken(t, "other", map[string]interface{}{"sub": "alice", "exp": now.Unix() + hour}), errBadSignature},
+		{"two segments", "abc.def", errMalformedToken},
+		{"bad base64", "a!b.c!d.e!f", errMalformedToken},
+		{"tampered claims", valid[:strings.Index(valid, ".")+1] + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory","exp":9999999999}`)) + valid[strings.LastIndex(valid, "."):], errBadSignature},
+		{"no subject", hsToken(t, "s3cret", map[string]interface{}{"exp": now.Unix() + hour}), errMalformedToken},
+	}
+	for _, tt := range tests {
+		userID, err := v.Validate(tt.token)
+		if tt.wantErr == nil {
+			if err != nil || userID != "alice" {
+				t.Errorf("%s: Validate = %q, %v; want alice", tt.name, userID, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// serveAuth runs one request through authMiddleware and returns the
+// response plus the request the next handler saw, if any.
+func serveAuth(v *TokenValidator, requireAuth bool, req *http.Request) (*httptest.ResponseRecorder, *http.Request) {
+	var seen *http.Request
+	h := authMiddleware(v, requireAuth, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r }))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, seen
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	v := NewTokenValidator("s3cret", "")
+	token := hsToken(t, "s3cret", map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-User-ID", "mallory")
+	rec, seen := serveAuth(v, true, req)
+	if seen == nil || seen.Header.Get("X-User-ID") != "alice" || UserIDFromContext(seen.Context()) != "alice" {
+		t.Fatalf("valid token: status %d, want alice forwarded", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/adapters?token="+token+"&limit=5", nil)
+	if _, seen := serveAuth(v, true, req); seen == nil || seen.URL.RawQuery != "limit=5" {
+		t.Fatalf("query token: forwarded %v, want the token stripped", seen)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+	req.Header.Set("Authorization", "Bearer not-a-jwt")
+	if rec, seen := serveAuth(v, false, req); rec.Code != http.StatusUnauthorized || seen != nil {
+		t.Fatalf("malformed token: status %d, want 401", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+	if rec, _ := serveAuth(v, true, req); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("missing token: status %d, want 401", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/adapters/health", nil)
+	if _, seen := serveAuth(v, true, req); seen == nil {
+		t.Fatal("health check was not let through")
+	}
+}
+
+func TestAuthMiddlewareFailsClosedWithoutKeys(t *testing.T) {
+	v := NewTokenValidator("", "")
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+		req.Header.Set("Authorization", "Bearer anything")
+		return req
+	}
+
+	if rec, seen := serveAuth(v, false, newReq()); rec.Code != http.StatusUnauthorized || seen != nil {
+		t.Fatalf("status %d, want 401 without a key source", rec.Code)
+	}
+
+	v.SetAllowUnverified(true)
+	_, seen := serveAuth(v, false, newReq())
+	if seen == nil || seen.Header.Get("X-User-ID") != "" {
+		t.Fatal("dev mode should pass the request through without an identity")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultServices returns the built-in routes, with backends overridable
+// through the per-service *_URL environment variables.
+func defaultServices() []ServiceConfig {
+	return []ServiceConfig{
+		{Name: "orchestrator", Prefix: "/api/v1/orchestrator", Backend: getEnv("ORCHESTRATOR_URL", "http://localhost:8081")},
+		{Name: "experiments", Prefix: "/api/v1/experiments", Backend: getEnv("EXPERIMENTS_URL", "http://localhost:8082")},
+		{Name: "datasets", Prefix: "/api/v1/datasets", Backend: getEnv("DATASETS_URL", "http://localhost:8083")},
+		{Name: "adapters", Prefix: "/api/v1/adapters", Backend: getEnv("ADAPTERS_URL", "http://localhost:8084")},
+		{Name: "metrics", Prefix: "/api/v1/metrics", Backend: getEnv("METRICS_URL", "http://localhost:8085")},
+		{Name: "deploy", Prefix: "/api/v1/deploy", Backend: getEnv("DEPLOY_URL", "http://localhost:8086")},
+		{Name: "marketplace", Prefix: "/api/v1/marketplace", Backend: getEnv("MARKETPLACE_URL", "http://localhost:8087")},
+		{Name: "university", Prefix: "/api/v1/university", Backend: getEnv("UNIVERSITY_URL", "http://localhost:8088")},
+	}
+}
+
+// loadServices reads service routes from a JSON or YAML file, chosen by
+// extension. An empty path yields the defaults.
+func loadServices(path string) ([]ServiceConfig, error) {
+	if path == "" {
+		return defaultServices(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read gateway config: %w", err)
+	}
+
+	var services []ServiceConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &services)
+	default:
+		err = json.Unmarshal(data, &services)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse gateway config %s: %w", path, err)
+	}
+
+	if err := validateServices(services); err != nil {
+		return nil, fmt.Errorf("invalid gateway config %s: %w", path, err)
+	}
+	return services, nil
+}
+
+// validateServices checks names and prefixes are unique, prefixes do not
+// shadow each other, and backends are absolute http(s) URLs.
+func validateServices(services []ServiceConfig) error {
+	if len(services) == 0 {
+		return fmt.Errorf("no services configured")
+	}
+
+	names := make(map[string]bool)
+	for i, svc := range services {
+		if svc.Name == "" {
+			return fmt.Errorf("service %d: name is required", i)
+		}
+		if names[svc.Name] {
+			return fmt.Errorf("service %q: duplicate name", svc.Name)
+		}
+		names[svc.Name] = true
+
+		if !strings.HasPrefix(svc.Prefix, "/") || strings.HasSuffix(svc.Prefix, "/") {
+			return fmt.Errorf("service %q: prefix %q must start with / and not end with /", svc.Name, svc.Prefix)
+		}
+
+		u, err := url.Parse(svc.Backend)
+		if err != nil {
+			return fmt.Errorf("service %q: backend %q: %w", svc.Name, svc.Backend, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("service %q: backend %q must be an absolute http(s) URL", svc.Name, svc.Backend)
+		}
+		if err := validateRegions(svc); err != nil {
+			return err
+		}
+
+		for _, other := range services[:i] {
+			if svc.Prefix == other.Prefix {
+				return fmt.Errorf("services %q and %q: duplicate prefix %q", other.Name, svc.Name, svc.Prefix)
+			}
+			if strings.HasPrefix(svc.Prefix, other.Prefix+"/") || strings.HasPrefix(other.Prefix, svc.Prefix+"/") {
+				return fmt.Errorf("services %q and %q: prefixes %q and %q overlap", other.Name, svc.Name, other.Prefix, svc.Prefix)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadServicesValidFile(t *testing.T) {
+	want := []ServiceConfig{
+		{Name: "adapters", Prefix: "/api/v1/adapters", Backend: "http://adapters:8084", Regions: map[string]string{"eu": "https://adapters.eu:8084"}},
+		{Name: "metrics", Prefix: "/api/v1/metrics", Backend: "http://metrics:8085"},
+	}
+	files := map[string]string{
+		"gateway.json": `[
+			{"name": "adapters", "prefix": "/api/v1/adapters", "backend": "http://adapters:8084", "regions": {"eu": "https://adapters.eu:8084"}},
+			{"name": "metrics", "prefix": "/api/v1/metrics", "backend": "http://metrics:8085"}
+		]`,
+		"gateway.yaml": `
+- name: adapters
+  prefix: /api/v1/adapters
+  backend: http://adapters:8084
+  regions:
+    eu: https://adapters.eu:8084
+- name: metrics
+  prefix: /api/v1/metrics
+  backend: http://metrics:8085
+`,
+	}
+	for name, data := range files {
+		got, err := loadServices(writeConfig(t, name, data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: services = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestLoadServicesRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "duplicate prefix",
+			data: `[{"name": "a", "prefix": "/api/v1/x", "backend": "http://a"},
+				{"name": "b", "prefix": "/api/v1/x", "backend": "http://b"}]`,
+			wantErr: `duplicate prefix "/api/v1/x"`,
+		},
+		{
+			name: "overlapping prefix",
+			data: `[{"name": "a", "prefix": "/api/v1", "backend": "http://a"},
+				{"name": "b", "prefix": "/api/v1/x", "backend": "http://b"}]`,
+			wantErr: "overlap",
+		},
+		{
+			name:    "unparseable backend",
+			data:    `[{"name": "a", "prefix": "/api/v1/a", "backend": "http://a b:80%zz"}]`,
+			wantErr: `parse "http://a b:80%zz"`,
+		},
+		{
+			name:    "relative backend",
+			data:    `[{"name": "a", "prefix": "/api/v1/a", "backend": "adapters:8084"}]`,
+			wantErr: "absolute http(s) URL",
+		},
+		{
+			name:    "malformed json",
+			data:    `[{"name": "a",`,
+			wantErr: "parse gateway config",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadServices(writeConfig(t, "gateway.json", tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
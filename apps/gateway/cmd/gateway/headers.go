@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders are connection-scoped headers that must not be forwarded
+// by proxies (RFC 9110 section 7.6.1).
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// gatewayHeaders are set by the gateway itself and always reach backends.
+var gatewayHeaders = map[string]bool{
+	"X-Request-Id":      true,
+	"X-User-Id":         true,
+	"X-Forwarded-For":   true,
+	"X-Forwarded-Host":  true,
+	"X-Forwarded-Proto": true,
+}
+
+// HeaderPolicy controls which client request headers are forwarded to
+// backends. A non-empty allowlist forwards only the listed headers; the
+// denylist is applied afterwards.
+type HeaderPolicy struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// NewHeaderPolicy builds a policy from comma-separated header names.
+func NewHeaderPolicy(allow, deny string) *HeaderPolicy {
+	return &HeaderPolicy{
+		allow: parseHeaderList(allow),
+		deny:  parseHeaderList(deny),
+	}
+}
+
+func parseHeaderList(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			set[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return set
+}
+
+// Apply strips hop-by-hop headers and any header the policy rejects.
+func (p *HeaderPolicy) Apply(h http.Header) {
+	// Headers named in Connection are hop-by-hop too
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+
+	for name := range h {
+		if gatewayHeaders[name] {
+			continue
+		}
+		if (len(p.allow) > 0 && !p.allow[name]) || p.deny[name] {
+			h.Del(name)
+		}
+	}
+}
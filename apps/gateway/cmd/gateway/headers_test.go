@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// proxyHeaders sends req through a proxy built with policy and returns the
+// headers the backend received.
+func proxyHeaders(t *testing.T, policy *HeaderPolicy, req *http.Request) http.Header {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(r.Header)
+	}))
+	t.Cleanup(backend.Close)
+	svc := ServiceConfig{Name: "adapters", Prefix: "/api/v1/adapters", Backend: backend.URL}
+
+	rec := httptest.NewRecorder()
+	createProxy(svc, newRegionRouter(svc, "", nil), policy, nil).ServeHTTP(rec, req)
+	var got http.Header
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode backend headers: %v", err)
+	}
+	return got
+}
+
+func TestHeaderPolicyDenylistAndForwarding(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://gateway.example/api/v1/adapters", nil)
+	req.RemoteAddr = "203.0.113.7:5555"
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Internal-Token", "t")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Connection", "X-Conn-Scoped")
+	req.Header.Set("X-Conn-Scoped", "1")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("Proxy-Authorization", "Basic abc")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	got := proxyHeaders(t, NewHeaderPolicy("", "Cookie, x-internal-token"), req)
+
+	for _, name := range []string{"Cookie", "X-Internal-Token", "X-Conn-Scoped", "Keep-Alive", "Proxy-Authorization"} {
+		if v := got.Get(name); v != "" {
+			t.Errorf("%s = %q reached the backend, want it stripped", name, v)
+		}
+	}
+	if got.Get("Accept") != "application/json" {
+		t.Errorf("Accept = %q, want it forwarded", got.Get("Accept"))
+	}
+	if v := got.Get("X-Forwarded-For"); v != "198.51.100.1, 203.0.113.7" {
+		t.Errorf("X-Forwarded-For = %q, want the client IP appended to the prior chain", v)
+	}
+	if v := got.Get("X-Forwarded-Proto"); v != "http" {
+		t.Errorf("X-Forwarded-Proto = %q, want http", v)
+	}
+	if v := got.Get("X-Forwarded-Host"); v != "gateway.example" {
+		t.Errorf("X-Forwarded-Host = %q, want gateway.example", v)
+	}
+}
+
+func TestHeaderPolicyAllowlist(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer t")
+	req.Header.Set("X-Debug", "1")
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	got := proxyHeaders(t, NewHeaderPolicy("accept, authorization, x-debug", "X-Debug"), req)
+
+	if got.Get("Accept") == "" || got.Get("Authorization") == "" {
+		t.Errorf("allowed headers missing: %v", got)
+	}
+	if got.Get("X-Debug") != "" {
+		t.Error("X-Debug forwarded; the denylist applies after the allowlist")
+	}
+	if got.Get("User-Agent") != "" {
+		t.Errorf("User-Agent = %q forwarded despite not being allowed", got.Get("User-Agent"))
+	}
+	if got.Get("X-Forwarded-For") == "" {
+		t.Error("X-Forwarded-For missing; gateway headers bypass the allowlist")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BackendHealth is the last observed health of one backend of a service:
+// its default backend, or the backend for Region.
+type BackendHealth struct {
+	Name        string    `json:"name"`
+	Region      string    `json:"region,omitempty"`
+	Backend     string    `json:"backend"`
+	Healthy     bool      `json:"healthy"`
+	LastChecked time.Time `json:"last_checked,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// HealthMonitor periodically polls each backend's /health endpoint,
+// including every regional backend.
+type HealthMonitor struct {
+	mu       sync.RWMutex
+	status   map[string]*BackendHealth // keyed by healthKey
+	client   *http.Client
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewHealthMonitor creates a monitor for the given services. Backends are
+// considered healthy until the first probe says otherwise.
+func NewHealthMonitor(services []ServiceConfig, interval time.Duration) *HealthMonitor {
+	h := &HealthMonitor{
+		status:   make(map[string]*BackendHealth),
+		client:   &http.Client{Timeout: 2 * time.Second},
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+	for _, svc := range services {
+		h.status[healthKey(svc.Name, "")] = &BackendHealth{Name: svc.Name, Backend: svc.Backend, Healthy: true}
+		for region, backend := range svc.Regions {
+			h.status[healthKey(svc.Name, region)] = &BackendHealth{Name: svc.Name, Region: region, Backend: backend, Healthy: true}
+		}
+	}
+	return h
+}
+
+// healthKey identifies a service's backend for region, or its default
+// backend when region is empty.
+func healthKey(name, region string) string {
+	if region == "" {
+		return name
+	}
+	return name + "@" + region
+}
+
+// Start probes all backends immediately and then on every interval.
+func (h *HealthMonitor) Start() {
+	h.CheckAll()
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stopCh:
+				return
+			case <-ticker.C:
+				h.CheckAll()
+			}
+		}
+	}()
+}
+
+// Stop ends background polling.
+func (h *HealthMonitor) Stop() {
+	close(h.stopCh)
+}
+
+// CheckAll probes every backend once, concurrently.
+func (h *HealthMonitor) CheckAll() {
+	h.mu.RLock()
+	targets := make(map[string]string, len(h.status))
+	for key, st := range h.status {
+		targets[key] = st.Backend
+	}
+	h.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for key, backend := range targets {
+		wg.Add(1)
+		go func(key, backend string) {
+			defer wg.Done()
+			healthy, errMsg := h.probe(backend)
+
+			h.mu.Lock()
+			if st, ok := h.status[key]; ok {
+				st.Healthy = healthy
+				st.Error = errMsg
+				st.LastChecked = time.Now()
+			}
+			h.mu.Unlock()
+		}(key, backend)
+	}
+	wg.Wait()
+}
+
+func (h *HealthMonitor) probe(backend string) (bool, string) {
+	resp, err := h.client.Get(backend + "/health")
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, resp.Status
+	}
+	return true, ""
+}
+
+// IsHealthy reports the last known health of a service's backend for
+// region, or of its default backend when region is empty.
+func (h *HealthMonitor) IsHealthy(name, region string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	st, ok := h.status[healthKey(name, region)]
+	return !ok || st.Healthy
+}
+
+// Snapshot returns the health of all backends sorted by name.
+func (h *HealthMonitor) Snapshot() []BackendHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make([]BackendHealth, 0, len(h.status))
+	for _, st := range h.status {
+		result = append(result, *st)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Region < result[j].Region
+	})
+	return result
+}
+
+// healthGateMiddleware short-circuits requests whose backend, as chosen by
+// router, is marked down.
+func healthGateMiddleware(name string, monitor *HealthMonitor, router *regionRouter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, region := router.target(r); !monitor.IsHealthy(name, region) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "service unavailable",
+				"service": name,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
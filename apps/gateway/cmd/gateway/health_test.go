@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newBackend serves /health with 200 while up is true and 503 otherwise.
+func newBackend(t *testing.T, up *atomic.Bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRegionalBackendHealth(t *testing.T) {
+	var defaultUp, euUp atomic.Bool
+	defaultUp.Store(true)
+	euUp.Store(true)
+	def := newBackend(t, &defaultUp)
+	eu := newBackend(t, &euUp)
+
+	svc := ServiceConfig{Name: "adapters", Prefix: "/api/v1/adapters", Backend: def.URL, Regions: map[string]string{"eu": eu.URL}}
+	monitor := NewHealthMonitor([]ServiceConfig{svc}, time.Hour)
+	router := newRegionRouter(svc, "", monitor)
+	gate := healthGateMiddleware(svc.Name, monitor, router, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+	req.Header.Set(regionHeader, "eu")
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		gate.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	monitor.CheckAll()
+	if u, region := router.target(req); u.Host != hostOf(t, eu.URL) || region != "eu" {
+		t.Fatalf("healthy region routed to %s (%q), want eu", u, region)
+	}
+
+	euUp.Store(false)
+	monitor.CheckAll()
+	if u, region := router.target(req); u.Host != hostOf(t, def.URL) || region != "" {
+		t.Fatalf("unhealthy region routed to %s (%q), want the default backend", u, region)
+	}
+	if code := serve(); code != http.StatusOK {
+		t.Fatalf("gate = %d, want the request let through to the default backend", code)
+	}
+
+	defaultUp.Store(false)
+	monitor.CheckAll()
+	if code := serve(); code != http.StatusServiceUnavailable {
+		t.Fatalf("gate = %d, want 503 with every backend down", code)
+	}
+
+	snap := monitor.Snapshot()
+	if len(snap) != 2 || snap[0].Region != "" || snap[1].Region != "eu" {
+		t.Fatalf("snapshot = %+v, want the default and eu backends", snap)
+	}
+}
+
+func hostOf(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func TestFlappingBackendIsGated(t *testing.T) {
+	var up atomic.Bool
+	up.Store(true)
+	backend := newBackend(t, &up)
+	svc := ServiceConfig{Name: "orchestrator", Prefix: "/api/v1/jobs", Backend: backend.URL}
+	monitor := NewHealthMonitor([]ServiceConfig{svc}, time.Hour)
+	router := newRegionRouter(svc, "", monitor)
+	handler := healthGateMiddleware(svc.Name, monitor, router, createProxy(svc, router, NewHeaderPolicy("", ""), nil))
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/jobs", nil))
+		return rec
+	}
+	assertUnavailable := func(state string) {
+		t.Helper()
+		rec := serve()
+		var body map[string]string
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: decode body: %v", state, err)
+		}
+		if rec.Code != http.StatusServiceUnavailable || body["error"] != "service unavailable" || body["service"] != "orchestrator" {
+			t.Fatalf("%s: got %d %v, want a JSON 503 naming the service", state, rec.Code, body)
+		}
+		if snap := monitor.Snapshot(); snap[0].Healthy || snap[0].Error == "" {
+			t.Fatalf("%s: snapshot = %+v, want unhealthy with an error", state, snap[0])
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		up.Store(true)
+		monitor.CheckAll()
+		if rec := serve(); rec.Code != http.StatusOK {
+			t.Fatalf("flap %d: healthy backend answered %d", i, rec.Code)
+		}
+		up.Store(false)
+		monitor.CheckAll()
+		assertUnavailable("failing /health")
+	}
+
+	// A backend that is gone entirely gets the same clean 503 rather than
+	// the proxy's bad gateway
+	up.Store(true)
+	monitor.CheckAll()
+	backend.Close()
+	monitor.CheckAll()
+	assertUnavailable("offline")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// statusRecorder captures the status code written by downstream handlers.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses working through the wrapper.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// requestIDMiddleware ensures every request carries an X-Request-ID, which
+// is forwarded to the backend and echoed back to the client.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = newRequestID()
+			r.Header.Set(requestIDHeader, id)
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+type servedByKey struct{}
+
+// setServedBy records the backend a request was proxied to for the access
+// log. It does nothing outside accessLogMiddleware.
+func setServedBy(ctx context.Context, backend string) {
+	if servedBy, ok := ctx.Value(servedByKey{}).(*string); ok {
+		*servedBy = backend
+	}
+}
+
+// accessLogMiddleware writes one log line per request to service, naming
+// the backend it was proxied to, if any.
+func accessLogMiddleware(service string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		var backend string
+
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), servedByKey{}, &backend)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		slog.Info("access",
+			"request_id", r.Header.Get(requestIDHeader),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"service", service,
+			"backend", backend,
+			"status", rec.status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
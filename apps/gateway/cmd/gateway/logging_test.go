@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDGeneratedAndForwarded(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(requestIDHeader)))
+	}))
+	t.Cleanup(backend.Close)
+	svc := ServiceConfig{Name: "adapters", Prefix: "/api/v1/adapters", Backend: backend.URL}
+	// An allowlist that leaves the ID out must not stop it being forwarded
+	handler := requestIDMiddleware(createProxy(svc, newRegionRouter(svc, "", nil), NewHeaderPolicy("Accept", ""), nil))
+
+	serve := func(incoming string) (echoed, forwarded string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+		if incoming != "" {
+			req.Header.Set(requestIDHeader, incoming)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Header().Get(requestIDHeader), rec.Body.String()
+	}
+
+	first, forwarded := serve("")
+	if first == "" || forwarded != first {
+		t.Fatalf("generated ID: echoed %q, backend saw %q; want the same non-empty ID", first, forwarded)
+	}
+	if second, _ := serve(""); second == first {
+		t.Fatalf("two requests both got ID %q", first)
+	}
+
+	echoed, forwarded := serve("trace-abc-123")
+	if echoed != "trace-abc-123" || forwarded != "trace-abc-123" {
+		t.Fatalf("incoming ID: echoed %q, backend saw %q; want trace-abc-123 kept", echoed, forwarded)
+	}
+}
@@ -4,34 +4,54 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"os"
 	"strings"
+	"time"
+
+	"openlora/core/logging"
+	"openlora/core/svcauth"
 )
 
 // ServiceConfig defines a backend service.
 type ServiceConfig struct {
-	Name    string `json:"name"`
-	Prefix  string `json:"prefix"`
-	Backend string `json:"backend"`
+	Name    string `json:"name" yaml:"name"`
+	Prefix  string `json:"prefix" yaml:"prefix"`
+	Backend string `json:"backend" yaml:"backend"`
+	// Regions optionally maps region names to regional backends, chosen by
+	// the client's X-OpenLoRA-Region header or GATEWAY_REGION.
+	Regions map[string]string `json:"regions,omitempty" yaml:"regions,omitempty"`
 }
 
 func main() {
-	log.Println("🚪 OpenLoRA API Gateway starting...")
+	logging.Setup("gateway")
+	slog.Info("🚪 OpenLoRA API Gateway starting...")
 
 	// Service routes
-	services := []ServiceConfig{
-		{Name: "orchestrator", Prefix: "/api/v1/orchestrator", Backend: getEnv("ORCHESTRATOR_URL", "http://localhost:8081")},
-		{Name: "experiments", Prefix: "/api/v1/experiments", Backend: getEnv("EXPERIMENTS_URL", "http://localhost:8082")},
-		{Name: "datasets", Prefix: "/api/v1/datasets", Backend: getEnv("DATASETS_URL", "http://localhost:8083")},
-		{Name: "adapters", Prefix: "/api/v1/adapters", Backend: getEnv("ADAPTERS_URL", "http://localhost:8084")},
-		{Name: "metrics", Prefix: "/api/v1/metrics", Backend: getEnv("METRICS_URL", "http://localhost:8085")},
-		{Name: "deploy", Prefix: "/api/v1/deploy", Backend: getEnv("DEPLOY_URL", "http://localhost:8086")},
-		{Name: "marketplace", Prefix: "/api/v1/marketplace", Backend: getEnv("MARKETPLACE_URL", "http://localhost:8087")},
-		{Name: "university", Prefix: "/api/v1/university", Backend: getEnv("UNIVERSITY_URL", "http://localhost:8088")},
+	services, err := loadServices(os.Getenv("GATEWAY_CONFIG"))
+	if err != nil {
+		logging.Fatal("Failed to load services", "error", err)
+	}
+
+	requireAuth := getEnv("REQUIRE_AUTH", "false") == "true"
+	validator := NewTokenValidator(os.Getenv("JWT_SECRET"), os.Getenv("JWKS_URL"))
+	if getEnv("AUTH_ALLOW_UNVERIFIED", "false") == "true" {
+		slog.Warn("AUTH_ALLOW_UNVERIFIED is set; tokens are not verified when no key source is configured")
+		validator.SetAllowUnverified(true)
+	}
+	if requireAuth && !validator.Enabled() {
+		logging.Fatal("REQUIRE_AUTH is set but neither JWT_SECRET nor JWKS_URL is configured")
+	}
+
+	headerPolicy := NewHeaderPolicy(os.Getenv("GATEWAY_FORWARD_HEADERS"), os.Getenv("GATEWAY_STRIP_HEADERS"))
+
+	// Sign proxied requests so backends can reject traffic that bypasses
+	// the gateway
+	var transport http.RoundTripper
+	if secret := os.Getenv(svcauth.SecretEnv); secret != "" {
+		transport = svcauth.NewSigner(secret).Transport(nil)
 	}
 
 	mux := http.NewServeMux()
@@ -55,59 +75,98 @@ func main() {
 		json.NewEncoder(w).Encode(map[string]string{"status": "healthy", "service": "gateway"})
 	})
 
+	// Backend health polling
+	interval, err := time.ParseDuration(getEnv("HEALTH_CHECK_INTERVAL", "10s"))
+	if err != nil {
+		logging.Fatal("Invalid HEALTH_CHECK_INTERVAL", "error", err)
+	}
+	monitor := NewHealthMonitor(services, interval)
+	monitor.Start()
+	defer monitor.Stop()
+
 	// Service routes
 	mux.HandleFunc("/api/v1/services", func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(services)
 	})
 
+	mux.HandleFunc("/api/v1/services/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(monitor.Snapshot())
+	})
+
 	// Proxy routes
+	defaultRegion := os.Getenv("GATEWAY_REGION")
 	for _, svc := range services {
-		proxy := createProxy(svc.Backend, svc.Prefix)
-		mux.Handle(svc.Prefix+"/", authMiddleware(rateLimitMiddleware(proxy)))
-		log.Printf("  → %s → %s", svc.Prefix, svc.Backend)
+		router := newRegionRouter(svc, defaultRegion, monitor)
+		proxy := requestIDMiddleware(accessLogMiddleware(svc.Name,
+			authMiddleware(validator, requireAuth, rateLimitMiddleware(
+				healthGateMiddleware(svc.Name, monitor, router, createProxy(svc, router, headerPolicy, transport))))))
+		mux.Handle(svc.Prefix, proxy)
+		mux.Handle(svc.Prefix+"/", proxy)
+		slog.Info("route", "prefix", svc.Prefix, "backend", svc.Backend)
 	}
 
 	port := getEnv("PORT", "8080")
-	log.Printf("🌐 Gateway listening on :%s", port)
+	slog.Info("🌐 Gateway listening", "port", port)
 	if err := http.ListenAndServe(":"+port, mux); err != nil {
-		log.Fatalf("Failed: %v", err)
+		logging.Fatal("Failed", "error", err)
 	}
 }
 
-func createProxy(backend, prefix string) http.Handler {
-	target, _ := url.Parse(backend)
+func createProxy(svc ServiceConfig, router *regionRouter, headers *HeaderPolicy, transport http.RoundTripper) http.Handler {
+	name, prefix := svc.Name, svc.Prefix
 
 	return &httputil.ReverseProxy{
-		Director: func(req *http.Request) {
-			req.URL.Scheme = target.Scheme
-			req.URL.Host = target.Host
-			req.URL.Path = strings.TrimPrefix(req.URL.Path, prefix)
-			req.Host = target.Host
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			out := pr.Out
+			target, region := router.target(pr.In)
+			out.URL.Scheme = target.Scheme
+			out.URL.Host = target.Host
+			out.URL.Path = stripPrefix(out.URL.Path, prefix)
+			if out.URL.RawPath != "" {
+				out.URL.RawPath = stripPrefix(out.URL.RawPath, prefix)
+			}
+			out.Host = target.Host
+			setServedBy(pr.In.Context(), target.String())
+
+			// Backends see the region the gateway routed to, never the
+			// client's unverified preference
+			headers.Apply(out.Header)
+			out.Header.Del(regionHeader)
+			if region != "" {
+				out.Header.Set(regionHeader, region)
+			}
+
+			// Extend any upstream X-Forwarded-For chain with the client IP
+			if prior := pr.In.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+				out.Header["X-Forwarded-For"] = prior
+			}
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Error("proxy error", "backend", name, "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "service unavailable",
+				"service": name,
+			})
 		},
 	}
 }
 
-func authMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for health checks
-		if strings.HasSuffix(r.URL.Path, "/health") {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			token = r.URL.Query().Get("token")
-		}
-
-		// TODO: Validate token properly
-		if token == "" && getEnv("REQUIRE_AUTH", "false") == "true" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+// stripPrefix removes a service prefix on a path-segment boundary and
+// always returns a rooted path, so "/api/v1/x" maps to "/" rather than "".
+func stripPrefix(path, prefix string) string {
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return path
+	}
+	stripped := strings.TrimPrefix(path, prefix)
+	if !strings.HasPrefix(stripped, "/") {
+		stripped = "/" + stripped
+	}
+	return stripped
 }
 
 func rateLimitMiddleware(next http.Handler) http.Handler {
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyStripsPrefixAndKeepsQuery(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	t.Cleanup(backend.Close)
+	svc := ServiceConfig{Name: "adapters", Prefix: "/api/v1/adapters", Backend: backend.URL}
+	proxy := createProxy(svc, newRegionRouter(svc, "", nil), NewHeaderPolicy("", ""), nil)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/adapters", "/"},
+		{"/api/v1/adapters/", "/"},
+		{"/api/v1/adapters/name/foo?x=1", "/name/foo?x=1"},
+		{"/api/v1/adapters?limit=5&status=active", "/?limit=5&status=active"},
+		{"/api/v1/adapters-legacy/list", "/api/v1/adapters-legacy/list"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := rec.Body.String(); rec.Code != http.StatusOK || got != tt.want {
+			t.Errorf("%s: backend saw %q (status %d), want %q", tt.path, got, rec.Code, tt.want)
+		}
+	}
+}
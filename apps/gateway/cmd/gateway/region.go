@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// regionHeader carries a client's preferred region. The gateway forwards
+// the region it routed to so backends can apply the same preference.
+const regionHeader = "X-OpenLoRA-Region"
+
+// regionRouter picks a service backend by preferred region, falling back to
+// the default backend for requests with no preference, an unknown region
+// or a region whose backend is marked down.
+type regionRouter struct {
+	fallback      *url.URL
+	byRegion      map[string]*url.URL
+	defaultRegion string
+	healthy       func(region string) bool
+}
+
+// newRegionRouter builds the router for svc. monitor may be nil, in which
+// case every regional backend is assumed healthy.
+func newRegionRouter(svc ServiceConfig, defaultRegion string, monitor *HealthMonitor) *regionRouter {
+	fallback, _ := url.Parse(svc.Backend)
+	rr := &regionRouter{
+		fallback:      fallback,
+		byRegion:      make(map[string]*url.URL),
+		defaultRegion: defaultRegion,
+		healthy:       func(string) bool { return true },
+	}
+	if monitor != nil {
+		rr.healthy = func(region string) bool { return monitor.IsHealthy(svc.Name, region) }
+	}
+	for region, backend := range svc.Regions {
+		u, _ := url.Parse(backend)
+		rr.byRegion[region] = u
+	}
+	return rr
+}
+
+// target returns the backend for r and the region it serves, which is
+// empty when the fallback was used.
+func (rr *regionRouter) target(r *http.Request) (*url.URL, string) {
+	region := r.Header.Get(regionHeader)
+	if region == "" {
+		region = rr.defaultRegion
+	}
+	if u, ok := rr.byRegion[region]; ok && rr.healthy(region) {
+		return u, region
+	}
+	return rr.fallback, ""
+}
+
+// validateRegions checks every regional backend is an absolute http(s) URL.
+func validateRegions(svc ServiceConfig) error {
+	regions := make([]string, 0, len(svc.Regions))
+	for region := range svc.Regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	for _, region := range regions {
+		backend := svc.Regions[region]
+		if region == "" {
+			return fmt.Errorf("service %q: region name is required", svc.Name)
+		}
+		u, err := url.Parse(backend)
+		if err != nil {
+			return fmt.Errorf("service %q: region %q backend %q: %w", svc.Name, region, backend, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("service %q: region %q backend %q must be an absolute http(s) URL", svc.Name, region, backend)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoRegion serves requests by echoing the region header it received.
+func echoRegion(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + ":" + r.Header.Get(regionHeader)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProxyOverwritesClientRegion(t *testing.T) {
+	def := echoRegion(t, "default")
+	eu := echoRegion(t, "eu")
+	svc := ServiceConfig{Name: "adapters", Prefix: "/api/v1/adapters", Backend: def.URL, Regions: map[string]string{"eu": eu.URL}}
+	router := newRegionRouter(svc, "", nil)
+	// Forwarding only X-Request-Id must not let the region through either
+	proxy := createProxy(svc, router, NewHeaderPolicy("X-Request-Id", ""), nil)
+
+	tests := []struct {
+		region string
+		want   string
+	}{
+		{"eu", "eu:eu"},
+		{"us-east-1", "default:"},
+		{"", "default:"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+		if tt.region != "" {
+			req.Header.Set(regionHeader, tt.region)
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("region %q: backend saw %q, want %q", tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestAccessLogNamesServingBackend(t *testing.T) {
+	def := echoRegion(t, "default")
+	eu := echoRegion(t, "eu")
+	svc := ServiceConfig{Name: "adapters", Prefix: "/api/v1/adapters", Backend: def.URL, Regions: map[string]string{"eu": eu.URL}}
+	handler := accessLogMiddleware(svc.Name, createProxy(svc, newRegionRouter(svc, "", nil), NewHeaderPolicy("", ""), nil))
+
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/adapters", nil)
+	req.Header.Set(regionHeader, "eu")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := buf.String()
+	if !strings.Contains(line, "backend="+eu.URL) || !strings.Contains(line, "service=adapters") {
+		t.Fatalf("access log %q does not name the eu backend", line)
+	}
+}
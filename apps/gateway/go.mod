@@ -1,3 +1,10 @@
 module openlora/gateway
 
 go 1.21
+
+require (
+	gopkg.in/yaml.v3 v3.0.1
+	openlora/core v0.0.0
+)
+
+replace openlora/core => ../../packages/core-go
@@ -3,19 +3,32 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
+	"openlora/core/logging"
+	"openlora/core/svcauth"
 	"openlora/marketplace/internal/api"
 	"openlora/marketplace/internal/search"
 )
 
 func main() {
-	log.Println("🛍️ OpenHub Marketplace Service starting...")
+	logging.Setup("marketplace")
+	slog.Info("🛍️ OpenHub Marketplace Service starting...")
 
 	// Initialize search engine
 	searchEngine := search.NewEngine()
+	if v := os.Getenv("TRENDING_HALF_LIFE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logging.Fatal("Invalid TRENDING_HALF_LIFE", "value", v)
+		}
+		searchEngine.SetHalfLife(d)
+	}
+	searchEngine.StartTrending(time.Minute)
+	defer searchEngine.StopTrending()
 	server := api.NewServer(searchEngine)
 
 	port := os.Getenv("PORT")
@@ -23,8 +36,8 @@ func main() {
 		port = "8087"
 	}
 
-	log.Printf("🌐 Listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	slog.Info("🌐 Listening", "port", port)
+	if err := http.ListenAndServe(":"+port, svcauth.Middleware(os.Getenv(svcauth.SecretEnv), server)); err != nil {
+		logging.Fatal("Server failed", "error", err)
 	}
 }
@@ -1,3 +1,7 @@
 module openlora/marketplace
 
 go 1.21
+
+require openlora/core v0.0.0
+
+replace openlora/core => ../../packages/core-go
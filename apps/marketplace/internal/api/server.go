@@ -3,8 +3,11 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"openlora/marketplace/internal/search"
 )
@@ -25,7 +28,12 @@ func NewServer(e *search.Engine) *Server {
 func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/health", s.handleHealth)
 	s.mux.HandleFunc("/search", s.handleSearch)
+	s.mux.HandleFunc("/search/facets", s.handleFacets)
 	s.mux.HandleFunc("/trending", s.handleTrending)
+	s.mux.HandleFunc("/similar", s.handleSimilar)
+	s.mux.HandleFunc("/events", s.handleEvent)
+	s.mux.HandleFunc("/index", s.handleIndex)
+	s.mux.HandleFunc("/adapters/", s.handleAdapter)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -36,15 +44,69 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// handleSearch serves /search?q=&task=&tags=&limit=&offset=. Repeated or
+// comma-separated tags must all be present. The total number of matches is
+// returned in X-Total-Count so clients can page.
 func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	task := r.URL.Query().Get("task")
-	results := s.engine.Search(query, task)
-	
+
+	limit, err := nonNegativeParam(r, "limit")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset, err := nonNegativeParam(r, "offset")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, total := s.engine.Search(query, task, tagsParam(r), limit, offset)
+	if results == nil {
+		results = []*search.SearchResult{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	json.NewEncoder(w).Encode(results)
 }
 
+func (s *Server) handleFacets(w http.ResponseWriter, r *http.Request) {
+	facets := s.engine.Facets(r.URL.Query().Get("q"), r.URL.Query().Get("task"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(facets)
+}
+
+// tagsParam collects the tags query parameter, which may be repeated or
+// comma-separated.
+func tagsParam(r *http.Request) []string {
+	var tags []string
+	for _, v := range r.URL.Query()["tags"] {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// nonNegativeParam parses an optional non-negative integer query
+// parameter, returning 0 when it is absent.
+func nonNegativeParam(r *http.Request, name string) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return n, nil
+}
+
 func (s *Server) handleTrending(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
 	limit := 10
@@ -54,7 +116,139 @@ func (s *Server) handleTrending(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	results := s.engine.GetTrending(limit)
+	results, err := s.engine.GetTrending(r.URL.Query().Get("algo"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
 }
+
+// handleSimilar serves /similar?id=&limit=, the adapters most like id.
+// The limit defaults to 10.
+func (s *Server) handleSimilar(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id required", http.StatusBadRequest)
+		return
+	}
+	limit, err := nonNegativeParam(r, "limit")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	results, err := s.engine.Similar(id, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleIndex upserts an adapter on POST and removes one on DELETE ?id=.
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var item search.SearchResult
+		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.engine.Upsert(&item); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		latest, _ := s.engine.Latest(item.ID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(latest)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		if err := s.engine.Delete(id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleEvent records a download, like or view of an adapter.
+func (s *Server) handleEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		AdapterID string `json:"adapter_id"`
+		Kind      string `json:"kind"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.engine.RecordEvent(req.AdapterID, req.Kind); err != nil {
+		if errors.Is(err, search.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleAdapter serves /adapters/{id} (latest version) and
+// /adapters/{id}/versions (full history, newest first).
+func (s *Server) handleAdapter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/adapters/"):], "/"), "/")
+	id := parts[0]
+	if id == "" {
+		http.Error(w, "ID required", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		item, ok := s.engine.Latest(id)
+		if !ok {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(item)
+
+	case len(parts) == 2 && parts[1] == "versions":
+		versions := s.engine.Versions(id)
+		if len(versions) == 0 {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(versions)
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"openlora/core/clock"
+)
+
+// Event kinds accepted by RecordEvent.
+const (
+	EventDownload = "download"
+	EventLike     = "like"
+	EventView     = "view"
+)
+
+// eventWeights is how much one event of each kind adds to the trending
+// score before decay.
+var eventWeights = map[string]float64{
+	EventDownload: 3,
+	EventLike:     5,
+	EventView:     1,
+}
+
+// DefaultHalfLife is how long it takes an event's contribution to the
+// trending score to halve.
+const DefaultHalfLife = 24 * time.Hour
+
+var (
+	// ErrUnknownEvent is returned by RecordEvent for an unsupported kind.
+	ErrUnknownEvent = errors.New("unknown event kind")
+	// ErrNotFound is returned for an adapter that is not indexed.
+	ErrNotFound = errors.New("adapter not found")
+)
+
+// activity is an adapter's exponentially decayed event score as of at.
+type activity struct {
+	score float64
+	at    time.Time
+}
+
+// decayed returns the score decayed from a.at to now.
+func (a *activity) decayed(now time.Time, halfLife time.Duration) float64 {
+	elapsed := now.Sub(a.at)
+	if elapsed <= 0 {
+		return a.score
+	}
+	return a.score * math.Exp2(-float64(elapsed)/float64(halfLife))
+}
+
+// SetClock replaces the clock used to time events and decay.
+func (e *Engine) SetClock(c clock.Clock) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.clock = c
+}
+
+// SetHalfLife sets how quickly trending scores decay.
+func (e *Engine) SetHalfLife(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.halfLife = d
+}
+
+// RecordEvent counts a download, like or view of an adapter and adds it to
+// the adapter's trending score, which decays with the configured half-life
+// so recent activity outweighs old popularity.
+func (e *Engine) RecordEvent(adapterID, kind string) error {
+	weight, ok := eventWeights[kind]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownEvent, kind)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.index[adapterID]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, adapterID)
+	}
+
+	now := e.clock.Now()
+	act := e.activity[adapterID]
+	act.score = act.decayed(now, e.halfLife) + weight
+	act.at = now
+
+	e.update(adapterID, func(item *SearchResult) {
+		switch kind {
+		case EventDownload:
+			item.Downloads++
+		case EventLike:
+			item.Likes++
+		case EventView:
+			item.Views++
+		}
+		item.TrendingScore = act.score
+	})
+	return nil
+}
+
+// RecomputeTrending decays every adapter's trending score to now.
+func (e *Engine) RecomputeTrending() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.clock.Now()
+	for id, act := range e.activity {
+		score := act.decayed(now, e.halfLife)
+		e.update(id, func(item *SearchResult) { item.TrendingScore = score })
+	}
+}
+
+// StartTrending recomputes trending scores every interval until
+// StopTrending is called.
+func (e *Engine) StartTrending(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stopCh:
+				return
+			case <-ticker.C:
+				e.RecomputeTrending()
+			}
+		}
+	}()
+}
+
+// StopTrending ends background recomputation.
+func (e *Engine) StopTrending() {
+	close(e.stopCh)
+}
+
+// update replaces the latest version of an adapter with a modified copy,
+// so results already handed to callers are never written to. Callers must
+// hold e.mu.
+func (e *Engine) update(id string, fn func(item *SearchResult)) {
+	item, ok := e.index[id]
+	if !ok {
+		return
+	}
+	c := *item
+	fn(&c)
+
+	history := e.versions[id]
+	history[len(history)-1] = &c
+	e.index[id] = &c
+}
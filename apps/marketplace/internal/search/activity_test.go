@@ -0,0 +1,90 @@
+package search
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecentBurstOutranksStalePopularity(t *testing.T) {
+	e, clk := newTestEngine(t)
+	now := clk.Now()
+	// stale was popular ten days ago; niche was quiet until now
+	e.Index(&SearchResult{ID: "stale", Name: "stale", Downloads: 9000, TrendingScore: 500, UpdatedAt: now.Add(-10 * 24 * time.Hour)})
+	e.Index(&SearchResult{ID: "niche", Name: "niche", Downloads: 10, TrendingScore: 5, UpdatedAt: now})
+
+	// Indexed scores as-is, stale leads
+	if got, _ := e.GetTrending("", 2); !reflect.DeepEqual(ids(got), []string{"stale", "niche"}) {
+		t.Fatalf("before = %v, want [stale niche]", ids(got))
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := e.RecordEvent("niche", EventDownload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.RecomputeTrending()
+
+	got, err := e.GetTrending("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(got), []string{"niche", "stale"}) {
+		t.Fatalf("after burst = %v, want [niche stale]", ids(got))
+	}
+	// 500 halved ten times; 5 plus twenty downloads at weight 3
+	if stale := got[1].TrendingScore; math.Abs(stale-500.0/1024) > 1e-9 {
+		t.Errorf("stale score = %v, want %v", stale, 500.0/1024)
+	}
+	if niche := got[0]; niche.TrendingScore != 65 || niche.Downloads != 30 {
+		t.Errorf("niche = score %v, downloads %d; want 65, 30", niche.TrendingScore, niche.Downloads)
+	}
+	// The raw-popularity ranking is unchanged
+	if got, _ := e.GetTrending("downloads", 2); !reflect.DeepEqual(ids(got), []string{"stale", "niche"}) {
+		t.Errorf("downloads = %v, want [stale niche]", ids(got))
+	}
+}
+
+func TestTrendingDecaysWithHalfLife(t *testing.T) {
+	e, clk := newTestEngine(t)
+	e.SetHalfLife(time.Hour)
+	e.Index(&SearchResult{ID: "a", Name: "a", UpdatedAt: clk.Now()})
+
+	for _, kind := range []string{EventLike, EventView, EventDownload} {
+		if err := e.RecordEvent("a", kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+	score := func() float64 {
+		item, _ := e.Latest("a")
+		return item.TrendingScore
+	}
+	if got := score(); got != 9 {
+		t.Fatalf("score = %v, want 5+1+3", got)
+	}
+
+	clk.Advance(2 * time.Hour)
+	e.RecomputeTrending()
+	if got := score(); got != 9.0/4 {
+		t.Fatalf("score two half-lives later = %v, want %v", got, 9.0/4)
+	}
+	// A new event adds to the decayed score, not the old one
+	if err := e.RecordEvent("a", EventView); err != nil {
+		t.Fatal(err)
+	}
+	if got := score(); got != 9.0/4+1 {
+		t.Fatalf("score after view = %v, want %v", got, 9.0/4+1)
+	}
+	if item, _ := e.Latest("a"); item.Likes != 1 || item.Views != 2 || item.Downloads != 1 {
+		t.Errorf("counters = %d likes, %d views, %d downloads", item.Likes, item.Views, item.Downloads)
+	}
+
+	if err := e.RecordEvent("a", "share"); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("unknown kind = %v, want ErrUnknownEvent", err)
+	}
+	if err := e.RecordEvent("missing", EventView); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown adapter = %v, want ErrNotFound", err)
+	}
+}
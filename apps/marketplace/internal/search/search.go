@@ -2,10 +2,14 @@
 package search
 
 import (
+	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"openlora/core/clock"
 )
 
 // SearchResult represents a discoverable adapter.
@@ -15,8 +19,11 @@ type SearchResult struct {
 	Description   string    `json:"description,omitempty"`
 	Author        string    `json:"author"`
 	Task          string    `json:"task"` // CAUSAL_LM, SEQ_CLS
+	BaseModel     string    `json:"base_model,omitempty"`
+	Version       int       `json:"version"`
 	Downloads     int       `json:"downloads"`
 	Likes         int       `json:"likes"`
+	Views         int       `json:"views"`
 	TrendingScore float64   `json:"trending_score"`
 	Tags          []string  `json:"tags"`
 	UpdatedAt     time.Time `json:"updated_at"`
@@ -24,85 +31,320 @@ type SearchResult struct {
 
 // Engine handles search queries and indexing.
 type Engine struct {
-	mu    sync.RWMutex
-	index map[string]*SearchResult
-	lists map[string][]*SearchResult // Cached lists (trending, new, etc.)
+	mu       sync.RWMutex
+	index    map[string]*SearchResult   // latest version of each adapter
+	versions map[string][]*SearchResult // full history, oldest first
+	lists    map[string][]*SearchResult // Cached lists (trending, new, etc.)
+
+	strategies map[string]TrendingStrategy
+
+	activity map[string]*activity // decayed event score per adapter ID
+	halfLife time.Duration
+	clock    clock.Clock
+	stopCh   chan struct{}
 }
 
 // NewEngine creates a new search engine.
 func NewEngine() *Engine {
 	e := &Engine{
-		index: make(map[string]*SearchResult),
-		lists: make(map[string][]*SearchResult),
+		index:    make(map[string]*SearchResult),
+		versions: make(map[string][]*SearchResult),
+		lists:    make(map[string][]*SearchResult),
+
+		strategies: defaultStrategies(),
+
+		activity: make(map[string]*activity),
+		halfLife: DefaultHalfLife,
+		clock:    clock.Real{},
+		stopCh:   make(chan struct{}),
 	}
 	e.seedMockData() // For demo purposes
 	return e
 }
 
-// Search performs a query against the index.
-func (e *Engine) Search(query string, task string) []*SearchResult {
+// Index adds a version of an adapter. A zero Version is assigned the next
+// number; re-indexing an existing version replaces it. Search always sees
+// the highest version.
+func (e *Engine) Index(item *SearchResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.put(item)
+}
+
+// ErrInvalidItem is returned by Upsert for an item missing its ID or name.
+var ErrInvalidItem = errors.New("invalid search item")
+
+// Upsert adds or updates an adapter. A zero Version updates the latest
+// indexed version in place (or creates version 1); an explicit Version is
+// indexed as with Index. The item is copied, so the caller may reuse it.
+func (e *Engine) Upsert(item *SearchResult) error {
+	if item.ID == "" || item.Name == "" {
+		return fmt.Errorf("%w: id and name are required", ErrInvalidItem)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c := *item
+	c.Tags = append([]string(nil), item.Tags...)
+	if c.Version == 0 {
+		if latest, ok := e.index[c.ID]; ok {
+			c.Version = latest.Version
+		}
+	}
+	if c.UpdatedAt.IsZero() {
+		c.UpdatedAt = e.clock.Now()
+	}
+	e.put(&c)
+	return nil
+}
+
+// Delete removes every version of an adapter along with its trending
+// activity.
+func (e *Engine) Delete(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.index[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(e.index, id)
+	delete(e.versions, id)
+	delete(e.activity, id)
+	return nil
+}
+
+// put stores item and moves the latest pointer. Callers must hold e.mu.
+func (e *Engine) put(item *SearchResult) {
+	history := e.versions[item.ID]
+	if item.Version == 0 {
+		item.Version = 1
+		if n := len(history); n > 0 {
+			item.Version = history[n-1].Version + 1
+		}
+	}
+
+	i := sort.Search(len(history), func(i int) bool { return history[i].Version >= item.Version })
+	if i < len(history) && history[i].Version == item.Version {
+		history[i] = item
+	} else {
+		history = append(history, nil)
+		copy(history[i+1:], history[i:])
+		history[i] = item
+	}
+	e.versions[item.ID] = history
+	e.index[item.ID] = history[len(history)-1]
+
+	// A newly seen adapter starts from its indexed score as of its last
+	// update, so stale popularity decays like any other activity
+	if _, ok := e.activity[item.ID]; !ok {
+		at := item.UpdatedAt
+		if at.IsZero() {
+			at = e.clock.Now()
+		}
+		e.activity[item.ID] = &activity{score: item.TrendingScore, at: at}
+	}
+}
+
+// Latest returns the newest indexed version of an adapter.
+func (e *Engine) Latest(id string) (*SearchResult, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
+	item, ok := e.index[id]
+	return item, ok
+}
+
+// Versions returns every indexed version of an adapter, newest first.
+func (e *Engine) Versions(id string) []*SearchResult {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	history := e.versions[id]
+	result := make([]*SearchResult, len(history))
+	for i, item := range history {
+		result[len(history)-1-i] = item
+	}
+	return result
+}
+
+// Relevance tiers for a text match, best first. An item scores the best
+// tier it reaches; a non-empty query that reaches none excludes the item.
+const (
+	relevanceExactName   = 5
+	relevanceNamePrefix  = 4
+	relevanceNameContain = 3
+	relevanceDescription = 2
+	relevanceTag         = 1
+)
+
+// relevance scores how well item matches a lower-cased query.
+func relevance(item *SearchResult, query string) int {
+	name := strings.ToLower(item.Name)
+	switch {
+	case name == query:
+		return relevanceExactName
+	case strings.HasPrefix(name, query):
+		return relevanceNamePrefix
+	case strings.Contains(name, query):
+		return relevanceNameContain
+	case strings.Contains(strings.ToLower(item.Description), query):
+		return relevanceDescription
+	}
+	for _, tag := range item.Tags {
+		if strings.Contains(strings.ToLower(tag), query) {
+			return relevanceTag
+		}
+	}
+	return 0
+}
+
+// hasTags reports whether item carries every tag, ignoring case.
+func hasTags(item *SearchResult, tags []string) bool {
+	for _, want := range tags {
+		found := false
+		for _, tag := range item.Tags {
+			if strings.EqualFold(tag, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns the items matching the query and filters, with their
+// relevance scores. Callers must hold e.mu.
+func (e *Engine) match(query, task string, tags []string) ([]*SearchResult, map[*SearchResult]int) {
 	var results []*SearchResult
-	query = strings.ToLower(query)
+	scores := make(map[*SearchResult]int)
+	query = strings.ToLower(strings.TrimSpace(query))
 
 	for _, item := range e.index {
-		// Filter by task
+		// Filter by task and tags
 		if task != "" && item.Task != task {
 			continue
 		}
+		if !hasTags(item, tags) {
+			continue
+		}
 
-		// Text match
-		if query == "" || strings.Contains(strings.ToLower(item.Name), query) ||
-			strings.Contains(strings.ToLower(item.Description), query) {
+		if query == "" {
+			results = append(results, item)
+			continue
+		}
+		if score := relevance(item, query); score > 0 {
+			scores[item] = score
 			results = append(results, item)
 		}
 	}
+	return results, scores
+}
 
-	// Simple ranking by trending score
+// Search performs a query against the index, keeping only items of task
+// (if set) that carry all of tags. Results are ranked by relevance to the
+// query, then by trending score, then by ID. It returns the page of at
+// most limit results starting at offset (a zero limit means no limit)
+// along with the total number of matches.
+func (e *Engine) Search(query, task string, tags []string, limit, offset int) ([]*SearchResult, int) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	results, scores := e.match(query, task, tags)
 	sort.Slice(results, func(i, j int) bool {
-		return results[i].TrendingScore > results[j].TrendingScore
+		a, b := results[i], results[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if a.TrendingScore != b.TrendingScore {
+			return a.TrendingScore > b.TrendingScore
+		}
+		return a.ID < b.ID
 	})
 
-	return results
+	total := len(results)
+	if offset > total {
+		offset = total
+	}
+	results = results[offset:]
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	return results, total
+}
+
+// Facets counts the items matching query and task per tag, so a client
+// can show how many results each tag filter would leave. Tags are counted
+// lower-cased.
+func (e *Engine) Facets(query, task string) map[string]int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	results, _ := e.match(query, task, nil)
+	counts := make(map[string]int)
+	for _, item := range results {
+		seen := make(map[string]bool, len(item.Tags))
+		for _, tag := range item.Tags {
+			tag = strings.ToLower(tag)
+			if !seen[tag] {
+				seen[tag] = true
+				counts[tag]++
+			}
+		}
+	}
+	return counts
 }
 
-// GetTrending returns top trending adapters.
-func (e *Engine) GetTrending(limit int) []*SearchResult {
+// GetTrending returns top trending adapters ranked by the named algorithm,
+// or DefaultTrending if algo is empty.
+func (e *Engine) GetTrending(algo string, limit int) ([]*SearchResult, error) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
+	score, err := e.strategy(algo)
+	if err != nil {
+		return nil, err
+	}
+
 	// In real impl, this would be cached
+	now := e.clock.Now()
 	var all []*SearchResult
+	scores := make(map[*SearchResult]float64, len(e.index))
 	for _, item := range e.index {
 		all = append(all, item)
+		scores[item] = score(item, now)
 	}
 
 	sort.Slice(all, func(i, j int) bool {
-		return all[i].TrendingScore > all[j].TrendingScore
+		if scores[all[i]] != scores[all[j]] {
+			return scores[all[i]] > scores[all[j]]
+		}
+		return all[i].ID < all[j].ID
 	})
 
 	if limit > len(all) {
 		limit = len(all)
 	}
-	return all[:limit]
+	return all[:limit], nil
 }
 
 func (e *Engine) seedMockData() {
-	e.index["1"] = &SearchResult{
+	e.put(&SearchResult{
 		ID: "1", Name: "llama-2-chat-medical", Description: "Fine-tuned for medical advice",
-		Author: "med_team", Task: "CAUSAL_LM", Downloads: 1500, Likes: 340, TrendingScore: 95.5,
-		Tags: []string{"medical", "llama2", "chat"}, UpdatedAt: time.Now(),
-	}
-	e.index["2"] = &SearchResult{
+		Author: "med_team", Task: "CAUSAL_LM", BaseModel: "meta-llama/Llama-2-7b-hf", Downloads: 1500, Likes: 340, TrendingScore: 95.5,
+		Tags: []string{"medical", "llama2", "chat"}, UpdatedAt: time.Now().Add(-48 * time.Hour),
+	})
+	e.put(&SearchResult{
 		ID: "2", Name: "mistral-code-helper", Description: "Better coding capabilities",
-		Author: "dev_corp", Task: "CAUSAL_LM", Downloads: 8900, Likes: 1200, TrendingScore: 98.2,
-		Tags: []string{"coding", "mistral", "python"}, UpdatedAt: time.Now(),
-	}
-	e.index["3"] = &SearchResult{
+		Author: "dev_corp", Task: "CAUSAL_LM", BaseModel: "mistralai/Mistral-7B-v0.1", Downloads: 8900, Likes: 1200, TrendingScore: 98.2,
+		Tags: []string{"coding", "mistral", "python"}, UpdatedAt: time.Now().Add(-30 * 24 * time.Hour),
+	})
+	e.put(&SearchResult{
 		ID: "3", Name: "bert-sentiment-finance", Description: "Sentiment analysis for financial news",
-		Author: "fin_data", Task: "SEQ_CLS", Downloads: 450, Likes: 89, TrendingScore: 75.0,
-		Tags: []string{"finance", "sentiment", "bert"}, UpdatedAt: time.Now(),
-	}
+		Author: "fin_data", Task: "SEQ_CLS", BaseModel: "bert-base-uncased", Downloads: 450, Likes: 89, TrendingScore: 75.0,
+		Tags: []string{"finance", "sentiment", "bert"}, UpdatedAt: time.Now().Add(-6 * time.Hour),
+	})
 }
@@ -0,0 +1,254 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+)
+
+// newTestEngine returns an engine on a fake clock with the demo data
+// removed.
+func newTestEngine(t *testing.T) (*Engine, *clock.Fake) {
+	t.Helper()
+	clk := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	e := NewEngine()
+	e.SetClock(clk)
+	for _, id := range []string{"1", "2", "3"} {
+		if err := e.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e, clk
+}
+
+// ids returns the IDs of results in order.
+func ids(results []*SearchResult) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.ID
+	}
+	return out
+}
+
+func TestIndexVersionsResolvesLatest(t *testing.T) {
+	e, _ := newTestEngine(t)
+
+	e.Index(&SearchResult{ID: "med", Name: "medical-v1", Description: "first cut"})
+	e.Index(&SearchResult{ID: "med", Name: "medical-v2", Description: "more data"})
+	e.Index(&SearchResult{ID: "med", Version: 5, Name: "medical-v5"})
+	// An older version indexed late doesn't move the latest pointer
+	e.Index(&SearchResult{ID: "med", Version: 3, Name: "medical-v3"})
+	// Re-indexing an existing version replaces it
+	e.Index(&SearchResult{ID: "med", Version: 2, Name: "medical-v2-fixed"})
+
+	latest, ok := e.Latest("med")
+	if !ok || latest.Version != 5 || latest.Name != "medical-v5" {
+		t.Fatalf("Latest = %+v, %v; want version 5", latest, ok)
+	}
+
+	var got []string
+	for _, v := range e.Versions("med") {
+		got = append(got, v.Name)
+	}
+	want := []string{"medical-v5", "medical-v3", "medical-v2-fixed", "medical-v1"}
+	if len(got) != len(want) {
+		t.Fatalf("Versions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Versions = %v, want %v", got, want)
+		}
+	}
+
+	// Search sees only the latest version, once
+	results, total := e.Search("medical", "", nil, 0, 0)
+	if total != 1 || results[0].Version != 5 {
+		t.Fatalf("Search = %+v (total %d), want only version 5", results, total)
+	}
+	if results, _ := e.Search("first cut", "", nil, 0, 0); len(results) != 0 {
+		t.Fatalf("Search matched an old version's description: %v", ids(results))
+	}
+
+	// A new version without a number goes after the highest
+	e.Index(&SearchResult{ID: "med", Name: "medical-next"})
+	if latest, _ := e.Latest("med"); latest.Version != 6 {
+		t.Fatalf("next version = %d, want 6", latest.Version)
+	}
+	if _, ok := e.Latest("missing"); ok {
+		t.Fatal("Latest found an adapter that was never indexed")
+	}
+}
+
+func TestSearchRanksByRelevance(t *testing.T) {
+	e, _ := newTestEngine(t)
+	// Trending runs opposite to relevance, so only relevance can produce
+	// the expected order
+	e.Index(&SearchResult{ID: "tag", Name: "clinical-notes", TrendingScore: 99, Tags: []string{"medical"}})
+	e.Index(&SearchResult{ID: "desc", Name: "radiology", Description: "Medical imaging reports", TrendingScore: 98})
+	e.Index(&SearchResult{ID: "substring", Name: "llama-2-chat-medical", TrendingScore: 97})
+	e.Index(&SearchResult{ID: "prefix", Name: "medical-qa", TrendingScore: 96})
+	e.Index(&SearchResult{ID: "exact", Name: "Medical", TrendingScore: 1})
+	e.Index(&SearchResult{ID: "other", Name: "mistral-code-helper", TrendingScore: 100})
+
+	results, total := e.Search("medical", "", nil, 0, 0)
+	want := []string{"exact", "prefix", "substring", "desc", "tag"}
+	if total != len(want) || !reflect.DeepEqual(ids(results), want) {
+		t.Fatalf("Search = %v (total %d), want %v", ids(results), total, want)
+	}
+
+	// Within a tier, trending breaks the tie
+	e.Index(&SearchResult{ID: "substring-hot", Name: "mistral-medical", TrendingScore: 150})
+	results, _ = e.Search("medical", "", nil, 0, 0)
+	want = []string{"exact", "prefix", "substring-hot", "substring", "desc", "tag"}
+	if !reflect.DeepEqual(ids(results), want) {
+		t.Fatalf("Search after tie = %v, want %v", ids(results), want)
+	}
+}
+
+func TestSearchPaginates(t *testing.T) {
+	e, _ := newTestEngine(t)
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		e.Index(&SearchResult{ID: id, Name: "adapter-" + id, TrendingScore: float64(10 - i)})
+	}
+
+	tests := []struct {
+		limit, offset int
+		want          []string
+	}{
+		{0, 0, []string{"a", "b", "c", "d", "e"}},
+		{2, 0, []string{"a", "b"}},
+		{2, 2, []string{"c", "d"}},
+		{2, 4, []string{"e"}},
+		{0, 3, []string{"d", "e"}},
+		{2, 10, []string{}},
+	}
+	for _, tt := range tests {
+		results, total := e.Search("adapter", "", nil, tt.limit, tt.offset)
+		if total != 5 {
+			t.Errorf("limit %d offset %d: total = %d, want 5", tt.limit, tt.offset, total)
+		}
+		if got := ids(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("limit %d offset %d = %v, want %v", tt.limit, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTagFiltersAndFacets(t *testing.T) {
+	e, _ := newTestEngine(t)
+	e.Index(&SearchResult{ID: "med-chat", Name: "med-chat", Task: "CAUSAL_LM", TrendingScore: 3, Tags: []string{"medical", "chat", "llama2"}})
+	e.Index(&SearchResult{ID: "med-qa", Name: "med-qa", Task: "CAUSAL_LM", TrendingScore: 2, Tags: []string{"Medical", "qa"}})
+	e.Index(&SearchResult{ID: "med-cls", Name: "med-cls", Task: "SEQ_CLS", TrendingScore: 1, Tags: []string{"medical", "chat"}})
+	e.Index(&SearchResult{ID: "code", Name: "code-chat", Task: "CAUSAL_LM", Tags: []string{"coding", "chat", "chat"}})
+
+	tests := []struct {
+		name string
+		task string
+		tags []string
+		want []string
+	}{
+		{"one tag, any case", "", []string{"MEDICAL"}, []string{"med-chat", "med-qa", "med-cls"}},
+		{"all tags required", "", []string{"medical", "chat"}, []string{"med-chat", "med-cls"}},
+		{"with task", "CAUSAL_LM", []string{"medical", "chat"}, []string{"med-chat"}},
+		{"no item has both", "", []string{"medical", "coding"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total := e.Search("", tt.task, tt.tags, 0, 0)
+			if got := ids(results); total != len(tt.want) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search = %v (total %d), want %v", got, total, tt.want)
+			}
+		})
+	}
+
+	// Counts follow the query and task but not the tag filter, and a tag
+	// repeated on one item counts it once
+	facets := []struct {
+		query, task string
+		want        map[string]int
+	}{
+		{"", "", map[string]int{"medical": 3, "chat": 3, "llama2": 1, "qa": 1, "coding": 1}},
+		{"med", "", map[string]int{"medical": 3, "chat": 2, "llama2": 1, "qa": 1}},
+		{"", "CAUSAL_LM", map[string]int{"medical": 2, "chat": 2, "llama2": 1, "qa": 1, "coding": 1}},
+		{"nothing", "", map[string]int{}},
+	}
+	for _, tt := range facets {
+		if got := e.Facets(tt.query, tt.task); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Facets(%q, %q) = %v, want %v", tt.query, tt.task, got, tt.want)
+		}
+	}
+}
+
+func TestUpsertThenSearch(t *testing.T) {
+	e, clk := newTestEngine(t)
+
+	item := &SearchResult{ID: "med", Name: "medical-chat", Task: "CAUSAL_LM", Tags: []string{"medical"}}
+	if err := e.Upsert(item); err != nil {
+		t.Fatal(err)
+	}
+	// The engine keeps its own copy
+	item.Name = "changed"
+	item.Tags[0] = "changed"
+
+	results, _ := e.Search("medical", "", nil, 0, 0)
+	if len(results) != 1 || results[0].Name != "medical-chat" || results[0].Tags[0] != "medical" {
+		t.Fatalf("Search = %+v, want the upserted item as given", results)
+	}
+	if got := results[0]; got.Version != 1 || !got.UpdatedAt.Equal(clk.Now()) {
+		t.Errorf("upserted = version %d at %v, want version 1 stamped now", got.Version, got.UpdatedAt)
+	}
+
+	// A zero version updates in place; the old name stops matching
+	if err := e.Upsert(&SearchResult{ID: "med", Name: "clinical-chat"}); err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := e.Search("medical", "", nil, 0, 0); len(results) != 0 {
+		t.Errorf("old name still matches: %v", ids(results))
+	}
+	if results, _ := e.Search("clinical", "", nil, 0, 0); len(results) != 1 || results[0].Version != 1 {
+		t.Errorf("Search(clinical) = %+v, want version 1 updated", results)
+	}
+	if got := len(e.Versions("med")); got != 1 {
+		t.Errorf("versions = %d, want 1", got)
+	}
+
+	for _, bad := range []*SearchResult{{Name: "no-id"}, {ID: "no-name"}} {
+		if err := e.Upsert(bad); !errors.Is(err, ErrInvalidItem) {
+			t.Errorf("Upsert(%+v) = %v, want ErrInvalidItem", bad, err)
+		}
+	}
+}
+
+func TestDeleteThenAbsent(t *testing.T) {
+	e, _ := newTestEngine(t)
+	for _, id := range []string{"keep", "drop"} {
+		if err := e.Upsert(&SearchResult{ID: id, Name: "adapter-" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Index(&SearchResult{ID: "drop", Name: "adapter-drop"}) // a second version
+
+	if err := e.Delete("drop"); err != nil {
+		t.Fatal(err)
+	}
+	if results, total := e.Search("adapter", "", nil, 0, 0); total != 1 || results[0].ID != "keep" {
+		t.Errorf("Search = %v, want only keep", ids(results))
+	}
+	if _, ok := e.Latest("drop"); ok {
+		t.Error("Latest still finds drop")
+	}
+	if got := e.Versions("drop"); len(got) != 0 {
+		t.Errorf("Versions = %d, want none", len(got))
+	}
+	if got, _ := e.GetTrending("", 10); !reflect.DeepEqual(ids(got), []string{"keep"}) {
+		t.Errorf("GetTrending = %v, want [keep]", ids(got))
+	}
+	if err := e.RecordEvent("drop", EventView); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RecordEvent after delete = %v, want ErrNotFound", err)
+	}
+	if err := e.Delete("drop"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete = %v, want ErrNotFound", err)
+	}
+}
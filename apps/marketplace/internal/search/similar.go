@@ -0,0 +1,81 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Similarity weights. Sharing a base model means the adapters can be
+// swapped on the same deployment, so it counts most; each shared tag adds
+// a little.
+const (
+	similarBaseModel = 3
+	similarTask      = 2
+	similarTag       = 1
+)
+
+// similarity scores how alike two adapters are. Zero means unrelated.
+func similarity(a, b *SearchResult) int {
+	score := 0
+	if a.BaseModel != "" && strings.EqualFold(a.BaseModel, b.BaseModel) {
+		score += similarBaseModel
+	}
+	if a.Task != "" && a.Task == b.Task {
+		score += similarTask
+	}
+	seen := make(map[string]bool, len(a.Tags))
+	for _, tag := range a.Tags {
+		seen[strings.ToLower(tag)] = true
+	}
+	for _, tag := range b.Tags {
+		tag = strings.ToLower(tag)
+		if seen[tag] {
+			score += similarTag
+			delete(seen, tag)
+		}
+	}
+	return score
+}
+
+// Similar returns up to limit other adapters ranked by how much they share
+// with adapterID: base model, task and tags. Ties go to the higher
+// trending score. Unrelated adapters are left out. A limit of 0 or less
+// returns every match.
+func (e *Engine) Similar(adapterID string, limit int) ([]*SearchResult, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	target, ok := e.index[adapterID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, adapterID)
+	}
+
+	results := make([]*SearchResult, 0)
+	scores := make(map[*SearchResult]int)
+	for id, item := range e.index {
+		if id == adapterID {
+			continue
+		}
+		if score := similarity(target, item); score > 0 {
+			results = append(results, item)
+			scores[item] = score
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if a.TrendingScore != b.TrendingScore {
+			return a.TrendingScore > b.TrendingScore
+		}
+		return a.ID < b.ID
+	})
+
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	return results, nil
+}
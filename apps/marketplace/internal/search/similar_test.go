@@ -0,0 +1,45 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSimilarRanksByOverlap(t *testing.T) {
+	e, _ := newTestEngine(t)
+	target := &SearchResult{ID: "target", Name: "target", Task: "CAUSAL_LM", BaseModel: "meta-llama/Llama-2-7b-hf",
+		Tags: []string{"medical", "chat", "llama2"}}
+	e.Index(target)
+	// Scores against target: base model 3, task 2, each shared tag 1
+	e.Index(&SearchResult{ID: "twin", Name: "twin", Task: "CAUSAL_LM", BaseModel: "META-LLAMA/Llama-2-7b-hf",
+		Tags: []string{"Medical", "chat"}}) // 3+2+2
+	e.Index(&SearchResult{ID: "same-base", Name: "same-base", Task: "SEQ_CLS", BaseModel: "meta-llama/Llama-2-7b-hf"}) // 3
+	e.Index(&SearchResult{ID: "tags-only", Name: "tags-only", Task: "SEQ_CLS",
+		Tags: []string{"medical", "chat", "llama2", "medical"}}) // 3, repeated tag counted once
+	e.Index(&SearchResult{ID: "task-tag", Name: "task-tag", Task: "CAUSAL_LM", TrendingScore: 50,
+		Tags: []string{"chat"}}) // 2+1
+	e.Index(&SearchResult{ID: "task-only", Name: "task-only", Task: "CAUSAL_LM", TrendingScore: 99}) // 2
+	e.Index(&SearchResult{ID: "unrelated", Name: "unrelated", Task: "TOKEN_CLS", BaseModel: "bert-base-uncased",
+		Tags: []string{"finance"}, TrendingScore: 100})
+
+	got, err := e.Similar("target", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// same-base, tags-only and task-tag tie at 3; trending then ID break it
+	want := []string{"twin", "task-tag", "same-base", "tags-only", "task-only"}
+	if !reflect.DeepEqual(ids(got), want) {
+		t.Fatalf("Similar = %v, want %v", ids(got), want)
+	}
+
+	if got, _ := e.Similar("target", 2); !reflect.DeepEqual(ids(got), want[:2]) {
+		t.Errorf("limit 2 = %v, want %v", ids(got), want[:2])
+	}
+	if got, _ := e.Similar("unrelated", 0); len(got) != 0 {
+		t.Errorf("Similar(unrelated) = %v, want none", ids(got))
+	}
+	if _, err := e.Similar("missing", 5); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown id = %v, want ErrNotFound", err)
+	}
+}
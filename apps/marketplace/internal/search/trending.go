@@ -0,0 +1,59 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnknownAlgorithm is returned for a trending algorithm that has not
+// been registered.
+var ErrUnknownAlgorithm = errors.New("unknown trending algorithm")
+
+// TrendingStrategy scores an adapter for trending; higher ranks first.
+type TrendingStrategy func(item *SearchResult, now time.Time) float64
+
+// DefaultTrending is used when no algorithm is requested.
+const DefaultTrending = "score"
+
+func defaultStrategies() map[string]TrendingStrategy {
+	return map[string]TrendingStrategy{
+		// Precomputed TrendingScore, as indexed
+		"score": func(item *SearchResult, _ time.Time) float64 {
+			return item.TrendingScore
+		},
+		"downloads": func(item *SearchResult, _ time.Time) float64 {
+			return float64(item.Downloads)
+		},
+		"likes": func(item *SearchResult, _ time.Time) float64 {
+			return float64(item.Likes)
+		},
+		// Downloads per day since the adapter was last updated, so a fresh
+		// release with modest totals can outrank an old favourite.
+		"velocity": func(item *SearchResult, now time.Time) float64 {
+			age := now.Sub(item.UpdatedAt)
+			if age < time.Hour {
+				age = time.Hour
+			}
+			return float64(item.Downloads) / age.Hours() * 24
+		},
+	}
+}
+
+// RegisterTrending adds or replaces a named trending algorithm.
+func (e *Engine) RegisterTrending(name string, strategy TrendingStrategy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.strategies[name] = strategy
+}
+
+func (e *Engine) strategy(name string) (TrendingStrategy, error) {
+	if name == "" {
+		name = DefaultTrending
+	}
+	s, ok := e.strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+	}
+	return s, nil
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrendingStrategiesOrderDifferently(t *testing.T) {
+	e, clk := newTestEngine(t)
+	now := clk.Now()
+	// Each strategy favours a different adapter:
+	//   old:    most downloads, few likes, stale
+	//   liked:  most likes
+	//   fresh:  modest totals but released two hours ago
+	e.Index(&SearchResult{ID: "old", Name: "old", Downloads: 1000, Likes: 10, TrendingScore: 50, UpdatedAt: now.Add(-100 * 24 * time.Hour)})
+	e.Index(&SearchResult{ID: "liked", Name: "liked", Downloads: 500, Likes: 300, TrendingScore: 10, UpdatedAt: now.Add(-24 * time.Hour)})
+	e.Index(&SearchResult{ID: "fresh", Name: "fresh", Downloads: 200, Likes: 100, TrendingScore: 90, UpdatedAt: now.Add(-2 * time.Hour)})
+
+	tests := []struct {
+		algo string
+		want []string
+	}{
+		{"", []string{"fresh", "old", "liked"}}, // DefaultTrending
+		{"score", []string{"fresh", "old", "liked"}},
+		{"downloads", []string{"old", "liked", "fresh"}},
+		{"likes", []string{"liked", "fresh", "old"}},
+		// 2400/day, 500/day, 10/day
+		{"velocity", []string{"fresh", "liked", "old"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algo, func(t *testing.T) {
+			got, err := e.GetTrending(tt.algo, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids(got), tt.want) {
+				t.Errorf("GetTrending(%q) = %v, want %v", tt.algo, ids(got), tt.want)
+			}
+		})
+	}
+
+	if got, _ := e.GetTrending("downloads", 1); !reflect.DeepEqual(ids(got), []string{"old"}) {
+		t.Errorf("limit 1 = %v, want [old]", ids(got))
+	}
+}
+
+func TestTrendingCustomAndUnknownStrategy(t *testing.T) {
+	e, _ := newTestEngine(t)
+	e.Index(&SearchResult{ID: "popular", Name: "popular", Downloads: 10})
+	e.Index(&SearchResult{ID: "niche", Name: "niche", Downloads: 5})
+
+	if _, err := e.GetTrending("least-downloaded", 10); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Fatalf("err = %v, want ErrUnknownAlgorithm", err)
+	}
+
+	e.RegisterTrending("least-downloaded", func(item *SearchResult, _ time.Time) float64 {
+		return -float64(item.Downloads)
+	})
+	got, err := e.GetTrending("least-downloaded", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(got), []string{"niche", "popular"}) {
+		t.Errorf("custom strategy order = %v, want [niche popular]", ids(got))
+	}
+}
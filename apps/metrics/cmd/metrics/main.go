@@ -3,27 +3,116 @@
 package main
 
 import (
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"openlora/core/logging"
+	"openlora/core/svcauth"
+	"openlora/core/svcauth/grpcauth"
 	"openlora/metrics/internal/api"
 	"openlora/metrics/internal/collector"
+	pb "openlora/metrics/proto"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
-	log.Println("📈 OpenLoRA Metrics Aggregator starting...")
+	logging.Setup("metrics")
+	slog.Info("📈 OpenLoRA Metrics Aggregator starting...")
 
 	coll := collector.NewCollector()
+
+	var sink *collector.FileSink
+	if path := os.Getenv("METRICS_STORE_PATH"); path != "" {
+		retention := collector.DefaultRetention
+		if v := os.Getenv("METRICS_RETENTION"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				logging.Fatal("Invalid METRICS_RETENTION", "value", v)
+			}
+			retention = d
+		}
+
+		var err error
+		sink, err = collector.NewFileSink(path)
+		if err != nil {
+			logging.Fatal("Failed to open metric store", "error", err)
+		}
+		if _, err := sink.Compact(time.Now().Add(-retention)); err != nil {
+			logging.Fatal("Failed to compact metric store", "error", err)
+		}
+		batches, err := collector.LoadBatches(path)
+		if err != nil {
+			logging.Fatal("Failed to load metric history", "error", err)
+		}
+		coll.Restore(batches)
+		slog.Info("📂 Restored metric history", "batches", len(batches), "path", path)
+		coll.SetSink(sink)
+
+		go func() {
+			ticker := time.NewTicker(collector.DefaultCompactInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				dropped, err := sink.Compact(time.Now().Add(-retention))
+				if err != nil {
+					slog.Warn("Metric store compaction failed", "error", err)
+					continue
+				}
+				slog.Debug("Compacted metric store", "dropped", dropped)
+			}
+		}()
+	}
+
 	server := api.NewServer(coll)
 
+	// gRPC ingestion for streaming training workers
+	grpcServer := grpc.NewServer(grpcauth.ServerOptions(os.Getenv(svcauth.SecretEnv))...)
+	pb.RegisterMetricsServer(grpcServer, api.NewGRPCServer(coll))
+
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "50052"
+	}
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		logging.Fatal("Failed to listen", "error", err)
+	}
+	go func() {
+		slog.Info("📡 gRPC server listening", "port", grpcPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			logging.Fatal("gRPC server failed", "error", err)
+		}
+	}()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8085"
 	}
 
-	log.Printf("🌐 Listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	go func() {
+		slog.Info("🌐 Listening", "port", port)
+		if err := http.ListenAndServe(":"+port, svcauth.Middleware(os.Getenv(svcauth.SecretEnv), server)); err != nil {
+			logging.Fatal("Server failed", "error", err)
+		}
+	}()
+
+	// Wait for shutdown signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("Shutting down...")
+	grpcServer.GracefulStop()
+	if sink != nil {
+		// Detach first so no push is mid-append when the file closes
+		coll.SetSink(nil)
+		if err := sink.Close(); err != nil {
+			slog.Error("Failed to close metric store", "error", err)
+		}
 	}
 }
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"openlora/metrics/internal/collector"
+	pb "openlora/metrics/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer implements the Metrics gRPC service.
+type GRPCServer struct {
+	pb.UnimplementedMetricsServer
+	collector *collector.Collector
+}
+
+// NewGRPCServer creates a gRPC server backed by the collector.
+func NewGRPCServer(c *collector.Collector) *GRPCServer {
+	return &GRPCServer{collector: c}
+}
+
+// PushMetrics feeds each streamed batch into the collector and acks the
+// totals once the client closes the stream.
+func (s *GRPCServer) PushMetrics(stream pb.Metrics_PushMetricsServer) error {
+	resp := &pb.PushMetricsResponse{}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+
+		batch := batchFromProto(msg)
+		if err := s.collector.Push(batch); err != nil {
+			if errors.Is(err, collector.ErrTimestampOutOfRange) {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			return err
+		}
+		resp.Batches++
+		resp.Metrics += int64(len(batch.Metrics))
+	}
+}
+
+func batchFromProto(msg *pb.MetricBatch) collector.MetricBatch {
+	batch := collector.MetricBatch{
+		Source:    msg.GetSource(),
+		JobID:     msg.GetJobId(),
+		AdapterID: msg.GetAdapterId(),
+		Metrics:   make([]collector.Metric, 0, len(msg.GetMetrics())),
+	}
+	for _, m := range msg.GetMetrics() {
+		metric := collector.Metric{
+			Name:   m.GetName(),
+			Type:   collector.MetricType(m.GetType()),
+			Value:  m.GetValue(),
+			Labels: m.GetLabels(),
+		}
+		if ts := m.GetTimestampUnixNano(); ts != 0 {
+			metric.Timestamp = time.Unix(0, ts)
+		}
+		batch.Metrics = append(batch.Metrics, metric)
+	}
+	return batch
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"openlora/metrics/internal/collector"
+	pb "openlora/metrics/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newBufconnClient(t *testing.T, c *collector.Collector) pb.MetricsClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterMetricsServer(srv, NewGRPCServer(c))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMetricsClient(conn)
+}
+
+func TestPushMetricsStream(t *testing.T) {
+	c := collector.NewCollector()
+	client := newBufconnClient(t, c)
+
+	stream, err := client.PushMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := []*pb.MetricBatch{
+		{Source: "trainer", JobId: "job-1", Metrics: []*pb.Metric{
+			{Name: "loss", Type: "gauge", Value: 2},
+			{Name: "lr", Type: "gauge", Value: 0.001},
+		}},
+		{Source: "trainer", JobId: "job-1", Metrics: []*pb.Metric{
+			{Name: "loss", Type: "gauge", Value: 1},
+		}},
+	}
+	for _, b := range batches {
+		if err := stream.Send(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetBatches() != 2 || resp.GetMetrics() != 3 {
+		t.Fatalf("ack = %d batches / %d metrics, want 2 / 3", resp.GetBatches(), resp.GetMetrics())
+	}
+
+	loss := c.GetJobMetrics("job-1")["loss"]
+	if loss == nil || loss.Count != 2 || loss.Last != 1 {
+		t.Fatalf("job-1 loss = %+v, want both streamed values", loss)
+	}
+}
+
+func TestPushMetricsStreamsManyPoints(t *testing.T) {
+	const batches, perBatch = 100, 10
+	c := collector.NewCollector()
+	client := newBufconnClient(t, c)
+
+	stream, err := client.PushMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	for b := 0; b < batches; b++ {
+		msg := &pb.MetricBatch{Source: "trainer", JobId: "job-1"}
+		for i := 0; i < perBatch; i++ {
+			step := b*perBatch + i
+			msg.Metrics = append(msg.Metrics, &pb.Metric{
+				Name:              "loss",
+				Type:              "gauge",
+				Value:             float64(step),
+				TimestampUnixNano: start.Add(time.Duration(step) * time.Millisecond).UnixNano(),
+			})
+		}
+		if err := stream.Send(msg); err != nil {
+			t.Fatalf("Send batch %d: %v", b, err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = batches * perBatch
+	if resp.GetBatches() != batches || resp.GetMetrics() != total {
+		t.Fatalf("ack = %d batches / %d metrics, want %d / %d", resp.GetBatches(), resp.GetMetrics(), batches, total)
+	}
+
+	loss := c.GetJobMetrics("job-1")["loss"]
+	if loss == nil {
+		t.Fatal("job-1 has no loss series")
+	}
+	if loss.Count != total || loss.Min != 0 || loss.Max != total-1 || loss.Last != total-1 || loss.Sum != total*(total-1)/2 {
+		t.Errorf("job-1 loss = count %d min %v max %v last %v sum %v, want %d points 0..%d",
+			loss.Count, loss.Min, loss.Max, loss.Last, loss.Sum, total, total-1)
+	}
+
+	points := c.Query("loss", time.Time{}, time.Time{}, 0)
+	if len(points) != total {
+		t.Fatalf("Query returned %d points, want %d", len(points), total)
+	}
+	for i, p := range points {
+		if p.Value != float64(i) {
+			t.Fatalf("point %d = %v, want %d (stream order lost)", i, p.Value, i)
+		}
+	}
+}
+
+func TestPushMetricsRejectsOutOfRangeTimestamp(t *testing.T) {
+	client := newBufconnClient(t, collector.NewCollector())
+
+	stream, err := client.PushMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour).UnixNano()
+	if err := stream.Send(&pb.MetricBatch{Metrics: []*pb.Metric{{Name: "loss", Value: 1, TimestampUnixNano: old}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+}
@@ -3,8 +3,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"openlora/core/negotiate"
 	"openlora/metrics/internal/collector"
 )
 
@@ -26,7 +31,13 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/metrics", s.handleMetrics)
 	s.mux.HandleFunc("/metrics/push", s.handlePush)
 	s.mux.HandleFunc("/metrics/prometheus", s.handlePrometheus)
+	s.mux.HandleFunc("/metrics/query", s.handleQuery)
+	s.mux.HandleFunc("/metrics/job", s.handleJobMetrics)
+	s.mux.HandleFunc("/metrics/anomalies", s.handleAnomalies)
 	s.mux.HandleFunc("/recent", s.handleRecent)
+	s.mux.HandleFunc("/alerts", s.handleAlerts)
+	s.mux.HandleFunc("/alerts/rules", s.handleAlertRules)
+	s.mux.HandleFunc("/alerts/events", s.handleAlertEvents)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -38,20 +49,65 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		s.handleDeleteMetrics(w, r)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	name := r.URL.Query().Get("name")
-	w.Header().Set("Content-Type", "application/json")
 
 	if name != "" {
+		if r.URL.Query().Get("series") == "true" {
+			negotiate.Write(w, r, s.collector.GetSeries(name))
+			return
+		}
 		m := s.collector.GetMetric(name)
 		if m == nil {
 			http.Error(w, "Not found", http.StatusNotFound)
 			return
 		}
-		json.NewEncoder(w).Encode(m)
+		negotiate.Write(w, r, m)
 		return
 	}
 
-	json.NewEncoder(w).Encode(s.collector.GetAllMetrics())
+	if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+		negotiate.Write(w, r, s.collector.GetMetricsByPrefix(prefix))
+		return
+	}
+
+	negotiate.Write(w, r, s.collector.GetAllMetrics())
+}
+
+// handleDeleteMetrics serves DELETE /metrics?label=k=v[&label=k=v...],
+// removing every series matching all of the given labels.
+func (s *Server) handleDeleteMetrics(w http.ResponseWriter, r *http.Request) {
+	selector := make(map[string]string)
+	for _, pair := range r.URL.Query()["label"] {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			http.Error(w, "invalid label "+strconv.Quote(pair)+", want key=value", http.StatusBadRequest)
+			return
+		}
+		selector[k] = v
+	}
+
+	res, err := s.collector.DeleteMetricsBySelector(selector)
+	if err != nil {
+		if errors.Is(err, collector.ErrEmptySelector) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
 }
 
 func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
@@ -66,18 +122,115 @@ func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.collector.Push(batch)
+	if err := s.collector.Push(batch); err != nil {
+		if errors.Is(err, collector.ErrTimestampOutOfRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
 func (s *Server) handlePrometheus(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
 	w.Write([]byte(s.collector.PrometheusExport()))
 }
 
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	name := q.Get("name")
+	if name == "" {
+		http.Error(w, "name required", http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTime(q.Get("from"))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTime(q.Get("to"))
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	points := 0
+	if p := q.Get("points"); p != "" {
+		points, err = strconv.Atoi(p)
+		if err != nil || points < 0 {
+			http.Error(w, "invalid points", http.StatusBadRequest)
+			return
+		}
+	}
+
+	negotiate.Write(w, r, s.collector.Query(name, from, to, points))
+}
+
+func (s *Server) handleJobMetrics(w http.ResponseWriter, r *http.Request) {
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		http.Error(w, "job_id required", http.StatusBadRequest)
+		return
+	}
+
+	metrics := s.collector.GetJobMetrics(jobID)
+	if metrics == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	negotiate.Write(w, r, metrics)
+}
+
+func (s *Server) handleAnomalies(w http.ResponseWriter, r *http.Request) {
+	negotiate.Write(w, r, s.collector.GetAnomalies())
+}
+
+// parseTime accepts RFC3339 timestamps or Unix seconds; empty means unbounded.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
 func (s *Server) handleRecent(w http.ResponseWriter, r *http.Request) {
+	negotiate.Write(w, r, s.collector.GetRecentBatches(100))
+}
+
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	negotiate.Write(w, r, s.collector.GetAlerts())
+}
+
+func (s *Server) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rule collector.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule, err := s.collector.AddAlertRule(rule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(s.collector.GetRecentBatches(100))
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (s *Server) handleAlertEvents(w http.ResponseWriter, r *http.Request) {
+	negotiate.Write(w, r, s.collector.GetAlertEvents())
 }
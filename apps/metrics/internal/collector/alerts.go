@@ -0,0 +1,234 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// AlertOp is the comparison an alert rule applies to a metric value.
+type AlertOp string
+
+const (
+	OpGreater      AlertOp = ">"
+	OpGreaterEqual AlertOp = ">="
+	OpLess         AlertOp = "<"
+	OpLessEqual    AlertOp = "<="
+)
+
+// AlertState is the evaluation state of a rule.
+type AlertState string
+
+const (
+	AlertOK      AlertState = "ok"
+	AlertPending AlertState = "pending" // condition true, waiting out ForDuration
+	AlertFiring  AlertState = "firing"
+)
+
+// ErrInvalidAlertRule is returned for a rule missing a metric or using an
+// unknown operator.
+var ErrInvalidAlertRule = errors.New("invalid alert rule")
+
+// AlertRule fires when Metric satisfies Op Threshold continuously for at
+// least ForDuration. ForDuration is given in JSON as a Go duration string
+// such as "5m".
+type AlertRule struct {
+	ID          string        `json:"id"`
+	Metric      string        `json:"metric"`
+	Op          AlertOp       `json:"op"`
+	Threshold   float64       `json:"threshold"`
+	ForDuration time.Duration `json:"for_duration"`
+}
+
+// MarshalJSON renders ForDuration as a duration string.
+func (r AlertRule) MarshalJSON() ([]byte, error) {
+	type alias AlertRule
+	return json.Marshal(struct {
+		alias
+		ForDuration string `json:"for_duration,omitempty"`
+	}{alias(r), durationString(r.ForDuration)})
+}
+
+// UnmarshalJSON accepts ForDuration as a duration string or as seconds.
+func (r *AlertRule) UnmarshalJSON(data []byte) error {
+	type alias AlertRule
+	aux := struct {
+		*alias
+		ForDuration json.RawMessage `json:"for_duration"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.ForDuration = 0
+	if len(aux.ForDuration) == 0 || string(aux.ForDuration) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(aux.ForDuration, &s); err == nil {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("for_duration: %w", err)
+		}
+		r.ForDuration = d
+		return nil
+	}
+	secs, err := strconv.ParseFloat(string(aux.ForDuration), 64)
+	if err != nil {
+		return fmt.Errorf("for_duration: %w", err)
+	}
+	r.ForDuration = time.Duration(secs * float64(time.Second))
+	return nil
+}
+
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+func (r AlertRule) matches(v float64) bool {
+	switch r.Op {
+	case OpGreater:
+		return v > r.Threshold
+	case OpGreaterEqual:
+		return v >= r.Threshold
+	case OpLess:
+		return v < r.Threshold
+	case OpLessEqual:
+		return v <= r.Threshold
+	}
+	return false
+}
+
+// AlertStatus is a rule together with its current evaluation state.
+type AlertStatus struct {
+	Rule      AlertRule  `json:"rule"`
+	State     AlertState `json:"state"`
+	Since     time.Time  `json:"since,omitempty"`
+	LastValue float64    `json:"last_value"`
+}
+
+// AlertEvent records a rule starting or stopping firing.
+type AlertEvent struct {
+	RuleID    string     `json:"rule_id"`
+	Metric    string     `json:"metric"`
+	State     AlertState `json:"state"`
+	Value     float64    `json:"value"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// AddAlertRule registers a rule, assigning an ID if none is given.
+func (c *Collector) AddAlertRule(rule AlertRule) (AlertRule, error) {
+	if rule.Metric == "" {
+		return AlertRule{}, fmt.Errorf("%w: metric required", ErrInvalidAlertRule)
+	}
+	switch rule.Op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual:
+	default:
+		return AlertRule{}, fmt.Errorf("%w: unknown op %q", ErrInvalidAlertRule, rule.Op)
+	}
+	if rule.ForDuration < 0 {
+		return AlertRule{}, fmt.Errorf("%w: negative for_duration", ErrInvalidAlertRule)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if rule.ID == "" {
+		c.nextRuleID++
+		rule.ID = "rule-" + strconv.Itoa(c.nextRuleID)
+	}
+	for _, a := range c.alerts {
+		if a.Rule.ID == rule.ID {
+			return AlertRule{}, fmt.Errorf("%w: duplicate id %q", ErrInvalidAlertRule, rule.ID)
+		}
+	}
+	c.alerts = append(c.alerts, &AlertStatus{Rule: rule, State: AlertOK})
+	return rule, nil
+}
+
+// GetAlerts returns every rule with its current state, in registration order.
+func (c *Collector) GetAlerts() []AlertStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]AlertStatus, len(c.alerts))
+	for i, a := range c.alerts {
+		result[i] = *a
+	}
+	return result
+}
+
+// GetAlertEvents returns the most recent firing and resolution events,
+// oldest first.
+func (c *Collector) GetAlertEvents() []AlertEvent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]AlertEvent, len(c.alertEvents))
+	copy(result, c.alertEvents)
+	return result
+}
+
+// evaluateAlerts advances every rule whose metric appears in the batch,
+// using the last value pushed for it. Callers must hold c.mu.
+func (c *Collector) evaluateAlerts(batch MetricBatch) {
+	if len(c.alerts) == 0 {
+		return
+	}
+
+	latest := make(map[string]float64, len(batch.Metrics))
+	for _, m := range batch.Metrics {
+		latest[m.Name] = m.Value
+	}
+
+	now := batch.Timestamp
+	for _, a := range c.alerts {
+		v, ok := latest[a.Rule.Metric]
+		if !ok {
+			continue
+		}
+		a.LastValue = v
+
+		if !a.Rule.matches(v) {
+			if a.State == AlertFiring {
+				c.recordAlertEvent(a, AlertOK, now)
+			}
+			if a.State != AlertOK {
+				a.State = AlertOK
+				a.Since = now
+			}
+			continue
+		}
+
+		switch a.State {
+		case AlertOK:
+			a.State = AlertPending
+			a.Since = now
+			fallthrough
+		case AlertPending:
+			if now.Sub(a.Since) >= a.Rule.ForDuration {
+				a.State = AlertFiring
+				a.Since = now
+				c.recordAlertEvent(a, AlertFiring, now)
+			}
+		}
+	}
+}
+
+func (c *Collector) recordAlertEvent(a *AlertStatus, state AlertState, at time.Time) {
+	c.alertEvents = append(c.alertEvents, AlertEvent{
+		RuleID:    a.Rule.ID,
+		Metric:    a.Rule.Metric,
+		State:     state,
+		Value:     a.LastValue,
+		Timestamp: at,
+	})
+	if len(c.alertEvents) > c.maxRecent {
+		c.alertEvents = c.alertEvents[1:]
+	}
+}
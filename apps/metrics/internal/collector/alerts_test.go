@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+)
+
+// newAlertCollector returns a collector on a fake clock with rule
+// registered.
+func newAlertCollector(t *testing.T, rule AlertRule) (*Collector, *clock.Fake) {
+	t.Helper()
+	clk := clock.NewFake(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	c := NewCollector()
+	c.SetClock(clk)
+	if _, err := c.AddAlertRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	return c, clk
+}
+
+// alertState returns the state of the only registered rule.
+func alertState(t *testing.T, c *Collector) AlertState {
+	t.Helper()
+	alerts := c.GetAlerts()
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	return alerts[0].State
+}
+
+func TestAlertFiresOnThresholdCrossing(t *testing.T) {
+	c, _ := newAlertCollector(t, AlertRule{Metric: "gpu_mem_util", Op: OpGreater, Threshold: 95})
+
+	push(t, c, Metric{Name: "gpu_mem_util", Value: 95})
+	if got := alertState(t, c); got != AlertOK {
+		t.Fatalf("at the threshold state = %s, want %s", got, AlertOK)
+	}
+
+	// Other metrics don't move the rule
+	push(t, c, Metric{Name: "loss", Value: 99})
+	if got := alertState(t, c); got != AlertOK {
+		t.Fatalf("after unrelated metric state = %s, want %s", got, AlertOK)
+	}
+
+	push(t, c, Metric{Name: "gpu_mem_util", Value: 96})
+	if got := alertState(t, c); got != AlertFiring {
+		t.Fatalf("over the threshold state = %s, want %s", got, AlertFiring)
+	}
+	events := c.GetAlertEvents()
+	if len(events) != 1 || events[0].State != AlertFiring || events[0].Value != 96 {
+		t.Fatalf("events = %+v, want one firing event at 96", events)
+	}
+}
+
+func TestAlertForDurationDebounces(t *testing.T) {
+	c, clk := newAlertCollector(t, AlertRule{Metric: "loss", Op: OpGreaterEqual, Threshold: 2, ForDuration: 5 * time.Minute})
+
+	push(t, c, Metric{Name: "loss", Value: 2.5})
+	if got := alertState(t, c); got != AlertPending {
+		t.Fatalf("first breach state = %s, want %s", got, AlertPending)
+	}
+
+	// A dip below the threshold resets the wait
+	clk.Advance(4 * time.Minute)
+	push(t, c, Metric{Name: "loss", Value: 1})
+	if got := alertState(t, c); got != AlertOK {
+		t.Fatalf("after dip state = %s, want %s", got, AlertOK)
+	}
+	clk.Advance(time.Minute)
+	push(t, c, Metric{Name: "loss", Value: 3})
+	clk.Advance(4 * time.Minute)
+	push(t, c, Metric{Name: "loss", Value: 3})
+	if got := alertState(t, c); got != AlertPending {
+		t.Fatalf("4m into second breach state = %s, want %s", got, AlertPending)
+	}
+	if events := c.GetAlertEvents(); len(events) != 0 {
+		t.Fatalf("events before ForDuration = %+v, want none", events)
+	}
+
+	clk.Advance(time.Minute)
+	push(t, c, Metric{Name: "loss", Value: 3})
+	if got := alertState(t, c); got != AlertFiring {
+		t.Fatalf("5m into second breach state = %s, want %s", got, AlertFiring)
+	}
+	events := c.GetAlertEvents()
+	if len(events) != 1 || events[0].State != AlertFiring || !events[0].Timestamp.Equal(clk.Now()) {
+		t.Fatalf("events = %+v, want one firing event at %v", events, clk.Now())
+	}
+}
+
+func TestAlertRecovers(t *testing.T) {
+	c, clk := newAlertCollector(t, AlertRule{ID: "mem", Metric: "gpu_mem_util", Op: OpGreater, Threshold: 95})
+
+	push(t, c, Metric{Name: "gpu_mem_util", Value: 99})
+	clk.Advance(time.Minute)
+	push(t, c, Metric{Name: "gpu_mem_util", Value: 80})
+
+	alerts := c.GetAlerts()
+	if alerts[0].State != AlertOK || !alerts[0].Since.Equal(clk.Now()) || alerts[0].LastValue != 80 {
+		t.Fatalf("after recovery alert = %+v, want ok since %v at 80", alerts[0], clk.Now())
+	}
+
+	want := []AlertState{AlertFiring, AlertOK}
+	events := c.GetAlertEvents()
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %v", events, want)
+	}
+	for i, e := range events {
+		if e.RuleID != "mem" || e.State != want[i] {
+			t.Errorf("event %d = %+v, want rule mem %s", i, e, want[i])
+		}
+	}
+
+	// Staying healthy records nothing further
+	push(t, c, Metric{Name: "gpu_mem_util", Value: 70})
+	if n := len(c.GetAlertEvents()); n != 2 {
+		t.Errorf("events after staying ok = %d, want 2", n)
+	}
+}
@@ -2,8 +2,17 @@
 package collector
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"openlora/core/clock"
 )
 
 // MetricType categorizes metrics.
@@ -33,66 +42,223 @@ type MetricBatch struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
-// AggregatedMetric holds aggregated statistics.
+// AggregatedMetric holds aggregated statistics for one series, identified
+// by the metric name and its label set.
 type AggregatedMetric struct {
-	Name   string    `json:"name"`
-	Count  int64     `json:"count"`
-	Sum    float64   `json:"sum"`
-	Min    float64   `json:"min"`
-	Max    float64   `json:"max"`
-	Avg    float64   `json:"avg"`
-	Last   float64   `json:"last"`
-	LastAt time.Time `json:"last_at"`
+	Name   string            `json:"name"`
+	Type   MetricType        `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Count  int64             `json:"count"`
+	Sum    float64           `json:"sum"`
+	Min    float64           `json:"min"`
+	Max    float64           `json:"max"`
+	Avg    float64           `json:"avg"`
+	Last   float64           `json:"last"`
+	LastAt time.Time         `json:"last_at"`
+	// AnomalyCount is the number of non-finite values rejected for this series.
+	AnomalyCount int64    `json:"anomaly_count"`
+	buckets      []uint64 // cumulative counts per histogramBuckets bound
 }
 
+// Anomaly records a non-finite (NaN or ±Inf) value that was excluded from
+// aggregation. Value is rendered as text since JSON cannot carry it.
+type Anomaly struct {
+	Name      string            `json:"name"`
+	JobID     string            `json:"job_id,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     string            `json:"value"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// histogramBuckets are the upper bounds used when exporting histograms.
+var histogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
 // Collector aggregates metrics from training jobs.
 type Collector struct {
 	mu        sync.RWMutex
-	metrics   map[string]*AggregatedMetric
+	metrics   map[string]*AggregatedMetric            // keyed by seriesKey
+	jobs      map[string]map[string]*AggregatedMetric // job ID -> seriesKey
 	recent    []MetricBatch
 	maxRecent int
+	anomalies []Anomaly
+
+	alerts      []*AlertStatus
+	alertEvents []AlertEvent
+	nextRuleID  int
+	sink        MetricSink
+	clock       clock.Clock
+	maxPast     time.Duration
+	maxFuture   time.Duration
 }
 
+// Default bounds on client-supplied timestamps, relative to the collector's
+// clock.
+const (
+	DefaultMaxPast   = 7 * 24 * time.Hour
+	DefaultMaxFuture = 5 * time.Minute
+)
+
+// ErrTimestampOutOfRange is returned by Push for a batch or metric
+// timestamp outside the accepted window.
+var ErrTimestampOutOfRange = errors.New("timestamp out of accepted range")
+
 // NewCollector creates a new collector.
 func NewCollector() *Collector {
 	return &Collector{
 		metrics:   make(map[string]*AggregatedMetric),
+		jobs:      make(map[string]map[string]*AggregatedMetric),
 		recent:    make([]MetricBatch, 0),
 		maxRecent: 1000,
+		clock:     clock.Real{},
+		maxPast:   DefaultMaxPast,
+		maxFuture: DefaultMaxFuture,
 	}
 }
 
-// Push adds a batch of metrics.
-func (c *Collector) Push(batch MetricBatch) {
+// SetTimestampWindow sets how far in the past or future a client-supplied
+// timestamp may be before Push rejects the batch.
+func (c *Collector) SetTimestampWindow(past, future time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxPast = past
+	c.maxFuture = future
+}
+
+// SetClock replaces the clock used to stamp pushed batches.
+func (c *Collector) SetClock(clk clock.Clock) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.clock = clk
+}
 
-	batch.Timestamp = time.Now()
+// SetSink persists every subsequently pushed batch to sink.
+func (c *Collector) SetSink(sink MetricSink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sink = sink
+}
+
+// Restore replays previously persisted batches, in order, into the
+// aggregates and the recent ring. Batches keep their original timestamps
+// and are not written back to the sink.
+func (c *Collector) Restore(batches []MetricBatch) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, batch := range batches {
+		c.ingest(batch)
+	}
+}
 
+// Push adds a batch of metrics. Timestamps supplied by the client are kept,
+// so historical and out-of-order data lands at the right time; the batch
+// defaults to now and metrics default to the batch timestamp. A timestamp
+// outside the window set by SetTimestampWindow rejects the whole batch with
+// ErrTimestampOutOfRange.
+func (c *Collector) Push(batch MetricBatch) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if batch.Timestamp.IsZero() {
+		batch.Timestamp = now
+	} else if err := c.checkTimestamp(batch.Timestamp, now); err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+
+	// Copy so filling in defaults doesn't write through to the caller
+	metrics := make([]Metric, len(batch.Metrics))
+	for i, m := range batch.Metrics {
+		if m.Timestamp.IsZero() {
+			m.Timestamp = batch.Timestamp
+		} else if err := c.checkTimestamp(m.Timestamp, now); err != nil {
+			return fmt.Errorf("metric %s: %w", m.Name, err)
+		}
+		metrics[i] = m
+	}
+	batch.Metrics = metrics
+
+	batch = c.rejectAnomalies(batch)
+	c.ingest(batch)
+	c.evaluateAlerts(batch)
+
+	if c.sink != nil {
+		if err := c.sink.Append(batch); err != nil {
+			slog.Warn("metric sink append failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// checkTimestamp validates ts against the accepted window around now.
+// Callers must hold c.mu.
+func (c *Collector) checkTimestamp(ts, now time.Time) error {
+	if ts.Before(now.Add(-c.maxPast)) || ts.After(now.Add(c.maxFuture)) {
+		return fmt.Errorf("%w: %s is not within %s before or %s after %s",
+			ErrTimestampOutOfRange, ts.Format(time.RFC3339), c.maxPast, c.maxFuture, now.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// rejectAnomalies removes non-finite values from the batch, recording each
+// one so it can be inspected later. Callers must hold c.mu.
+func (c *Collector) rejectAnomalies(batch MetricBatch) MetricBatch {
+	clean := make([]Metric, 0, len(batch.Metrics))
 	for _, m := range batch.Metrics {
-		key := m.Name
-		agg, ok := c.metrics[key]
-		if !ok {
-			agg = &AggregatedMetric{
-				Name: m.Name,
-				Min:  m.Value,
-				Max:  m.Value,
-			}
-			c.metrics[key] = agg
+		if !math.IsNaN(m.Value) && !math.IsInf(m.Value, 0) {
+			clean = append(clean, m)
+			continue
 		}
 
-		agg.Count++
-		agg.Sum += m.Value
-		agg.Last = m.Value
-		agg.LastAt = m.Timestamp
+		ts := m.Timestamp
+		if ts.IsZero() {
+			ts = batch.Timestamp
+		}
+		c.anomalies = append(c.anomalies, Anomaly{
+			Name:      m.Name,
+			JobID:     batch.JobID,
+			Labels:    copyLabels(m.Labels),
+			Value:     formatFloat(m.Value),
+			Timestamp: ts,
+		})
+		if len(c.anomalies) > c.maxRecent {
+			c.anomalies = c.anomalies[1:]
+		}
 
-		if m.Value < agg.Min {
-			agg.Min = m.Value
+		key := seriesKey(m.Name, m.Labels)
+		getSeries(c.metrics, key, m).AnomalyCount++
+		if batch.JobID != "" {
+			getSeries(c.jobAggregates(batch.JobID), key, m).AnomalyCount++
 		}
-		if m.Value > agg.Max {
-			agg.Max = m.Value
+	}
+	batch.Metrics = clean
+	return batch
+}
+
+// GetAnomalies returns the most recent rejected values, oldest first.
+func (c *Collector) GetAnomalies() []Anomaly {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]Anomaly, len(c.anomalies))
+	copy(result, c.anomalies)
+	return result
+}
+
+// ingest folds a batch into the aggregates and the recent ring. Callers
+// must hold c.mu.
+func (c *Collector) ingest(batch MetricBatch) {
+	var jobMetrics map[string]*AggregatedMetric
+	if batch.JobID != "" {
+		jobMetrics = c.jobAggregates(batch.JobID)
+	}
+
+	for _, m := range batch.Metrics {
+		key := seriesKey(m.Name, m.Labels)
+		observe(c.metrics, key, m)
+		if jobMetrics != nil {
+			observe(jobMetrics, key, m)
 		}
-		agg.Avg = agg.Sum / float64(agg.Count)
 	}
 
 	// Store recent
@@ -102,26 +268,179 @@ func (c *Collector) Push(batch MetricBatch) {
 	}
 }
 
-// GetMetric retrieves an aggregated metric.
+// jobAggregates returns the per-job series map, creating it if needed.
+// Callers must hold c.mu.
+func (c *Collector) jobAggregates(jobID string) map[string]*AggregatedMetric {
+	aggs := c.jobs[jobID]
+	if aggs == nil {
+		aggs = make(map[string]*AggregatedMetric)
+		c.jobs[jobID] = aggs
+	}
+	return aggs
+}
+
+// getSeries returns the aggregate stored under key, creating an empty one
+// shaped after m if needed.
+func getSeries(aggs map[string]*AggregatedMetric, key string, m Metric) *AggregatedMetric {
+	agg, ok := aggs[key]
+	if !ok {
+		typ := m.Type
+		if typ == "" {
+			typ = MetricGauge
+		}
+		agg = &AggregatedMetric{
+			Name:   m.Name,
+			Type:   typ,
+			Labels: copyLabels(m.Labels),
+		}
+		if typ == MetricHist {
+			agg.buckets = make([]uint64, len(histogramBuckets))
+		}
+		aggs[key] = agg
+	}
+	return agg
+}
+
+// observe folds m into the aggregate stored under key, creating it if needed.
+func observe(aggs map[string]*AggregatedMetric, key string, m Metric) {
+	agg := getSeries(aggs, key, m)
+
+	if agg.Count == 0 || m.Value < agg.Min {
+		agg.Min = m.Value
+	}
+	if agg.Count == 0 || m.Value > agg.Max {
+		agg.Max = m.Value
+	}
+
+	agg.Count++
+	agg.Sum += m.Value
+	// Late-arriving points still count towards the totals but don't
+	// replace a newer last value
+	if !m.Timestamp.Before(agg.LastAt) {
+		agg.Last = m.Value
+		agg.LastAt = m.Timestamp
+	}
+	agg.Avg = agg.Sum / float64(agg.Count)
+
+	if agg.buckets != nil {
+		for i, bound := range histogramBuckets {
+			if m.Value <= bound {
+				agg.buckets[i]++
+			}
+		}
+	}
+}
+
+// GetJobMetrics returns the aggregates recorded for a single job, keyed by
+// series (the metric name plus any labels, e.g. `loss{gpu="0"}`). It
+// returns nil for an unknown job.
+func (c *Collector) GetJobMetrics(jobID string) map[string]*AggregatedMetric {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	aggs, ok := c.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	result := make(map[string]*AggregatedMetric, len(aggs))
+	for key, m := range aggs {
+		result[key] = m.clone()
+	}
+	return result
+}
+
+// clone copies m so it can be handed out while ingestion keeps updating
+// the original. Labels are never modified after creation and are shared.
+func (m *AggregatedMetric) clone() *AggregatedMetric {
+	cp := *m
+	cp.buckets = append([]uint64(nil), m.buckets...)
+	return &cp
+}
+
+// GetMetric returns the rollup of every series with the given name, or nil
+// if none exist. Use GetSeries for the per-label breakdown.
 func (c *Collector) GetMetric(name string) *AggregatedMetric {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.metrics[name]
+
+	var rollup *AggregatedMetric
+	for _, m := range c.metrics {
+		if m.Name != name {
+			continue
+		}
+		if rollup == nil {
+			rollup = &AggregatedMetric{Name: name, Type: m.Type}
+		}
+		rollup.AnomalyCount += m.AnomalyCount
+		if m.Count == 0 {
+			continue
+		}
+		if rollup.Count == 0 || m.Min < rollup.Min {
+			rollup.Min = m.Min
+		}
+		if rollup.Count == 0 || m.Max > rollup.Max {
+			rollup.Max = m.Max
+		}
+		rollup.Count += m.Count
+		rollup.Sum += m.Sum
+		if !m.LastAt.Before(rollup.LastAt) {
+			rollup.Last = m.Last
+			rollup.LastAt = m.LastAt
+		}
+	}
+	if rollup != nil && rollup.Count > 0 {
+		rollup.Avg = rollup.Sum / float64(rollup.Count)
+	}
+	return rollup
 }
 
-// GetAllMetrics returns all aggregated metrics.
+// GetSeries returns copies of every label combination recorded for a
+// metric name, ordered by label set.
+func (c *Collector) GetSeries(name string) []*AggregatedMetric {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]*AggregatedMetric, 0)
+	for _, m := range c.metrics {
+		if m.Name == name {
+			result = append(result, m.clone())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return formatLabels(result[i].Labels, "", "") < formatLabels(result[j].Labels, "", "")
+	})
+	return result
+}
+
+// GetAllMetrics returns copies of all aggregated metrics.
 func (c *Collector) GetAllMetrics() []*AggregatedMetric {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	result := make([]*AggregatedMetric, 0, len(c.metrics))
 	for _, m := range c.metrics {
-		result = append(result, m)
+		result = append(result, m.clone())
 	}
 	return result
 }
 
-// GetRecentBatches returns recent metric batches.
+// GetMetricsByPrefix returns copies of all aggregated metrics whose name
+// starts with prefix.
+func (c *Collector) GetMetricsByPrefix(prefix string) []*AggregatedMetric {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]*AggregatedMetric, 0)
+	for _, m := range c.metrics {
+		if strings.HasPrefix(m.Name, prefix) {
+			result = append(result, m.clone())
+		}
+	}
+	return result
+}
+
+// GetRecentBatches returns up to limit of the most recent metric batches,
+// oldest first, in a slice the caller owns.
 func (c *Collector) GetRecentBatches(limit int) []MetricBatch {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -129,23 +448,280 @@ func (c *Collector) GetRecentBatches(limit int) []MetricBatch {
 	if limit > len(c.recent) {
 		limit = len(c.recent)
 	}
-	return c.recent[len(c.recent)-limit:]
+	return append([]MetricBatch(nil), c.recent[len(c.recent)-limit:]...)
 }
 
-// PrometheusExport returns metrics in Prometheus format.
-func (c *Collector) PrometheusExport() string {
+// MetricPoint is a single timestamped value of a metric.
+type MetricPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// Query returns the values recorded for a metric between from and to
+// (inclusive; zero times are unbounded), oldest first. When maxPoints > 0
+// the result is bucket-averaged over time to at most maxPoints points.
+func (c *Collector) Query(name string, from, to time.Time, maxPoints int) []MetricPoint {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
+	points := make([]MetricPoint, 0)
+	for _, batch := range c.recent {
+		for _, m := range batch.Metrics {
+			if m.Name != name {
+				continue
+			}
+			ts := m.Timestamp
+			if ts.IsZero() {
+				ts = batch.Timestamp
+			}
+			if (!from.IsZero() && ts.Before(from)) || (!to.IsZero() && ts.After(to)) {
+				continue
+			}
+			points = append(points, MetricPoint{Timestamp: ts, Value: m.Value})
+		}
+	}
+	c.mu.RUnlock()
 
-	var out string
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+
+	if maxPoints <= 0 || len(points) <= maxPoints {
+		return points
+	}
+	return downsample(points, maxPoints)
+}
+
+// downsample averages sorted points into at most n equal-width time buckets.
+func downsample(points []MetricPoint, n int) []MetricPoint {
+	start := points[0].Timestamp
+	span := points[len(points)-1].Timestamp.Sub(start)
+
+	type bucket struct {
+		sumValue float64
+		sumNanos float64
+		count    int
+	}
+	buckets := make([]bucket, n)
+
+	for _, p := range points {
+		idx := 0
+		if span > 0 {
+			idx = int(float64(p.Timestamp.Sub(start)) / float64(span) * float64(n))
+			if idx >= n {
+				idx = n - 1
+			}
+		}
+		buckets[idx].sumValue += p.Value
+		buckets[idx].sumNanos += float64(p.Timestamp.Sub(start))
+		buckets[idx].count++
+	}
+
+	result := make([]MetricPoint, 0, n)
+	for _, b := range buckets {
+		if b.count == 0 {
+			continue
+		}
+		result = append(result, MetricPoint{
+			Timestamp: start.Add(time.Duration(b.sumNanos / float64(b.count))),
+			Value:     b.sumValue / float64(b.count),
+		})
+	}
+	return result
+}
+
+// promUntyped is the Prometheus type of a family whose series disagree.
+const promUntyped MetricType = "untyped"
+
+// promFamily is the series exported under one sanitized metric name.
+type promFamily struct {
+	sources map[string]bool              // metric names before sanitizing
+	series  map[string]*AggregatedMetric // keyed by rendered labels
+}
+
+// PrometheusExport returns metrics in the Prometheus text exposition format.
+// Series are grouped into families by sanitized name with a single
+// HELP/TYPE header, and series that only become identical once sanitized
+// are merged. Counters export their running total, gauges their last
+// value. A family whose series disagree on type is exported as untyped
+// with one such sample per series. Series that only ever recorded
+// anomalies have nothing to export and are left out.
+func (c *Collector) PrometheusExport() string {
+	c.mu.RLock()
+	families := make(map[string]*promFamily)
 	for _, m := range c.metrics {
-		out += "# HELP " + m.Name + " Aggregated metric\n"
-		out += "# TYPE " + m.Name + " gauge\n"
-		out += m.Name + " " + formatFloat(m.Last) + "\n"
+		if m.Count == 0 {
+			continue
+		}
+		name := sanitizeName(m.Name)
+		f, ok := families[name]
+		if !ok {
+			f = &promFamily{sources: make(map[string]bool), series: make(map[string]*AggregatedMetric)}
+			families[name] = f
+		}
+		f.sources[m.Name] = true
+		labels := formatLabels(m.Labels, "", "")
+		if existing, ok := f.series[labels]; ok {
+			existing.merge(m)
+		} else {
+			f.series[labels] = m.clone()
+		}
+	}
+	c.mu.RUnlock()
+
+	var b strings.Builder
+	for _, name := range sortedKeys(families) {
+		f := families[name]
+		labelSets := sortedKeys(f.series)
+
+		typ := f.series[labelSets[0]].Type
+		for _, labels := range labelSets {
+			if f.series[labels].Type != typ {
+				typ = promUntyped
+				break
+			}
+		}
+
+		b.WriteString("# HELP " + name + " Aggregated metric " + escapeHelp(strings.Join(sortedKeys(f.sources), ", ")) + "\n")
+		b.WriteString("# TYPE " + name + " " + string(typ) + "\n")
+
+		for _, labels := range labelSets {
+			m := f.series[labels]
+			if typ != MetricHist {
+				b.WriteString(name + labels + " " + formatFloat(sampleValue(m)) + "\n")
+				continue
+			}
+			for i, bound := range histogramBuckets {
+				b.WriteString(name + "_bucket" + formatLabels(m.Labels, "le", formatFloat(bound)) +
+					" " + strconv.FormatUint(m.buckets[i], 10) + "\n")
+			}
+			b.WriteString(name + "_bucket" + formatLabels(m.Labels, "le", "+Inf") +
+				" " + strconv.FormatInt(m.Count, 10) + "\n")
+			b.WriteString(name + "_sum" + labels + " " + formatFloat(m.Sum) + "\n")
+			b.WriteString(name + "_count" + labels + " " + strconv.FormatInt(m.Count, 10) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// sampleValue is the single value exported for a non-histogram series:
+// the running total for counters and the last value otherwise.
+func sampleValue(m *AggregatedMetric) float64 {
+	if m.Type == MetricCounter {
+		return m.Sum
+	}
+	return m.Last
+}
+
+// merge folds src into m as if m had observed src's points too. Series of
+// different types merge into an untyped one without buckets.
+func (m *AggregatedMetric) merge(src *AggregatedMetric) {
+	if src.Min < m.Min {
+		m.Min = src.Min
+	}
+	if src.Max > m.Max {
+		m.Max = src.Max
+	}
+	m.Count += src.Count
+	m.Sum += src.Sum
+	m.Avg = m.Sum / float64(m.Count)
+	m.AnomalyCount += src.AnomalyCount
+	if !src.LastAt.Before(m.LastAt) {
+		m.Last = src.Last
+		m.LastAt = src.LastAt
+	}
+	if m.Type != src.Type {
+		m.Type = promUntyped
+		m.buckets = nil
+		return
+	}
+	for i := range m.buckets {
+		m.buckets[i] += src.buckets[i]
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeHelp(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+// seriesKey identifies a series by name and its sorted label pairs. The
+// canonical rendering is stable across pushes regardless of map order.
+func seriesKey(name string, labels map[string]string) string {
+	return name + formatLabels(labels, "", "")
+}
+
+// formatLabels renders labels as {k="v",...} sorted by key, optionally
+// appending an extra pair (used for histogram "le" bounds).
+func formatLabels(labels map[string]string, extraKey, extraValue string) string {
+	if len(labels) == 0 && extraKey == "" {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		pairs = append(pairs, sanitizeName(k)+`="`+escapeLabelValue(labels[k])+`"`)
+	}
+	if extraKey != "" {
+		pairs = append(pairs, extraKey+`="`+extraValue+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+// sanitizeName replaces characters not allowed in Prometheus names.
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
 	}
 	return out
 }
 
 func formatFloat(f float64) string {
-	return string(rune(int(f*100) / 100)) // Simplified
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
 }
@@ -0,0 +1,390 @@
+package collector
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+)
+
+// TestGettersDoNotRaceWithPush reads what the getters returned while a
+// push updates the same series; run with -race.
+func TestGettersDoNotRaceWithPush(t *testing.T) {
+	c := NewCollector()
+	pushJob(t, c, "job-1", 1)
+
+	var got []*AggregatedMetric
+	got = append(got, c.GetSeries("loss")...)
+	got = append(got, c.GetAllMetrics()...)
+	got = append(got, c.GetMetricsByPrefix("lo")...)
+	recent := c.GetRecentBatches(10)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			pushJob(t, c, "job-1", float64(i))
+		}
+	}()
+	// Deliberately unsynchronized with the pushes
+	time.Sleep(10 * time.Millisecond)
+	for _, m := range got {
+		if m.Count != 1 || m.Sum != 1 {
+			t.Errorf("returned series changed: %+v", m)
+		}
+	}
+	if len(recent) != 1 {
+		t.Errorf("recent batches = %d, want 1", len(recent))
+	}
+	<-done
+}
+
+func TestGetRecentBatchesReturnsCopy(t *testing.T) {
+	c := NewCollector()
+	pushJob(t, c, "job-1", 1)
+	pushJob(t, c, "job-2", 2)
+
+	batches := c.GetRecentBatches(2)
+	batches[0].JobID = "changed"
+	if got := c.GetRecentBatches(2)[0].JobID; got != "job-1" {
+		t.Fatalf("ring batch JobID = %q, want job-1", got)
+	}
+}
+
+func TestFakeClockStampsAndBoundsPushes(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.SetClock(clock.NewFake(now))
+	c.SetTimestampWindow(time.Hour, time.Minute)
+
+	if err := c.Push(MetricBatch{Metrics: []Metric{{Name: "loss", Value: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.GetRecentBatches(1)[0]; !got.Timestamp.Equal(now) || !got.Metrics[0].Timestamp.Equal(now) {
+		t.Fatalf("batch stamped %v / %v, want the fake clock's %v", got.Timestamp, got.Metrics[0].Timestamp, now)
+	}
+
+	err := c.Push(MetricBatch{Timestamp: now.Add(-2 * time.Hour), Metrics: []Metric{{Name: "loss", Value: 1}}})
+	if !errors.Is(err, ErrTimestampOutOfRange) {
+		t.Fatalf("err = %v, want ErrTimestampOutOfRange relative to the fake clock", err)
+	}
+}
+
+func TestPushKeepsClientTimestamps(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.SetClock(clock.NewFake(now))
+	c.SetTimestampWindow(24*time.Hour, time.Minute)
+
+	batchAt := now.Add(-3 * time.Hour)
+	metricAt := now.Add(-5 * time.Hour)
+	batch := MetricBatch{Timestamp: batchAt, Metrics: []Metric{
+		{Name: "loss", Value: 0.5},
+		{Name: "loss", Value: 0.9, Timestamp: metricAt},
+	}}
+	if err := c.Push(batch); err != nil {
+		t.Fatal(err)
+	}
+	if !batch.Metrics[0].Timestamp.IsZero() {
+		t.Fatal("Push filled in the caller's metric timestamp")
+	}
+
+	got := c.GetRecentBatches(1)[0]
+	if !got.Timestamp.Equal(batchAt) {
+		t.Errorf("batch timestamp = %v, want client's %v", got.Timestamp, batchAt)
+	}
+	if !got.Metrics[0].Timestamp.Equal(batchAt) || !got.Metrics[1].Timestamp.Equal(metricAt) {
+		t.Errorf("metric timestamps = %v, %v; want %v (from batch), %v (own)",
+			got.Metrics[0].Timestamp, got.Metrics[1].Timestamp, batchAt, metricAt)
+	}
+
+	// A late push of older data still lands in time order
+	if err := c.Push(MetricBatch{Timestamp: now.Add(-10 * time.Hour), Metrics: []Metric{{Name: "loss", Value: 1.2}}}); err != nil {
+		t.Fatal(err)
+	}
+	points := c.Query("loss", time.Time{}, time.Time{}, 0)
+	want := []MetricPoint{
+		{Timestamp: now.Add(-10 * time.Hour), Value: 1.2},
+		{Timestamp: metricAt, Value: 0.9},
+		{Timestamp: batchAt, Value: 0.5},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("points = %+v, want %+v", points, want)
+	}
+	for i := range want {
+		if !points[i].Timestamp.Equal(want[i].Timestamp) || points[i].Value != want[i].Value {
+			t.Errorf("point %d = %+v, want %+v", i, points[i], want[i])
+		}
+	}
+}
+
+func TestPushRejectsMetricTimestampOutsideWindow(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.SetClock(clock.NewFake(now))
+	c.SetTimestampWindow(time.Hour, time.Minute)
+
+	err := c.Push(MetricBatch{Metrics: []Metric{
+		{Name: "loss", Value: 1},
+		{Name: "loss", Value: 2, Timestamp: now.Add(10 * time.Minute)},
+	}})
+	if !errors.Is(err, ErrTimestampOutOfRange) {
+		t.Fatalf("err = %v, want ErrTimestampOutOfRange", err)
+	}
+	if n := len(c.GetRecentBatches(10)); n != 0 {
+		t.Fatalf("%d batches stored, want the whole batch rejected", n)
+	}
+}
+
+func TestPrometheusExportCounterAndGauge(t *testing.T) {
+	c := NewCollector()
+	push := func(metrics ...Metric) {
+		t.Helper()
+		if err := c.Push(MetricBatch{Metrics: metrics}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	push(
+		Metric{Name: "tokens_processed", Type: MetricCounter, Value: 5, Labels: map[string]string{"gpu": "0"}},
+		Metric{Name: "tokens_processed", Type: MetricCounter, Value: 3, Labels: map[string]string{"gpu": "1"}},
+		Metric{Name: "loss", Type: MetricGauge, Value: 0.5},
+	)
+	push(
+		Metric{Name: "tokens_processed", Type: MetricCounter, Value: 7, Labels: map[string]string{"gpu": "0"}},
+		Metric{Name: "loss", Type: MetricGauge, Value: 0.25},
+	)
+
+	want := `# HELP loss Aggregated metric loss
+# TYPE loss gauge
+loss 0.25
+# HELP tokens_processed Aggregated metric tokens_processed
+# TYPE tokens_processed counter
+tokens_processed{gpu="0"} 12
+tokens_processed{gpu="1"} 3
+`
+	if got := c.PrometheusExport(); got != want {
+		t.Fatalf("export:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrometheusExportFamilyEdgeCases(t *testing.T) {
+	c := NewCollector()
+	now := time.Now()
+	err := c.Push(MetricBatch{Metrics: []Metric{
+		// Mixed types under one name
+		{Name: "mixed", Type: MetricCounter, Value: 2, Labels: map[string]string{"k": "a"}},
+		{Name: "mixed", Type: MetricGauge, Value: 9, Labels: map[string]string{"k": "b"}},
+		// Distinct names that sanitize to the same family and series
+		{Name: "lr.value", Value: 0.1, Timestamp: now.Add(-time.Second)},
+		{Name: "lr-value", Value: 0.2, Timestamp: now},
+		// Only ever anomalous
+		{Name: "grad_norm", Value: math.NaN()},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP lr_value Aggregated metric lr-value, lr.value
+# TYPE lr_value gauge
+lr_value 0.2
+# HELP mixed Aggregated metric mixed
+# TYPE mixed untyped
+mixed{k="a"} 2
+mixed{k="b"} 9
+`
+	if got := c.PrometheusExport(); got != want {
+		t.Fatalf("export:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// push records one batch holding metrics.
+func push(t *testing.T, c *Collector, metrics ...Metric) {
+	t.Helper()
+	if err := c.Push(MetricBatch{Source: "trainer", JobID: "job-1", Metrics: metrics}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetMetricsByPrefix(t *testing.T) {
+	c := NewCollector()
+	push(t, c,
+		Metric{Name: "train_loss", Type: MetricGauge, Value: 0.5},
+		Metric{Name: "train_lr", Type: MetricGauge, Value: 1e-4},
+		Metric{Name: "eval_loss", Type: MetricGauge, Value: 0.7},
+		Metric{Name: "pretrain_steps", Type: MetricCounter, Value: 10},
+		Metric{Name: "train", Type: MetricGauge, Value: 1},
+	)
+
+	got := make(map[string]bool)
+	for _, m := range c.GetMetricsByPrefix("train_") {
+		got[m.Name] = true
+	}
+	if len(got) != 2 || !got["train_loss"] || !got["train_lr"] {
+		t.Fatalf("train_ metrics = %v, want train_loss and train_lr only", got)
+	}
+	if n := len(c.GetMetricsByPrefix("gpu_")); n != 0 {
+		t.Fatalf("gpu_ matched %d metrics, want none", n)
+	}
+	if n := len(c.GetMetricsByPrefix("")); n != 5 {
+		t.Fatalf("empty prefix matched %d metrics, want all 5", n)
+	}
+}
+
+func TestLabelSetsAggregateIndependently(t *testing.T) {
+	c := NewCollector()
+	for _, v := range []float64{1, 3} {
+		push(t, c, Metric{Name: "loss", Type: MetricGauge, Value: v, Labels: map[string]string{"run": "a"}})
+	}
+	for _, v := range []float64{10, 20, 30} {
+		push(t, c, Metric{Name: "loss", Type: MetricGauge, Value: v, Labels: map[string]string{"run": "b"}})
+	}
+
+	series := c.GetSeries("loss")
+	if len(series) != 2 {
+		t.Fatalf("series = %+v, want one per label set", series)
+	}
+	want := []AggregatedMetric{
+		{Count: 2, Sum: 4, Min: 1, Max: 3, Avg: 2, Last: 3},
+		{Count: 3, Sum: 60, Min: 10, Max: 30, Avg: 20, Last: 30},
+	}
+	for i, run := range []string{"a", "b"} {
+		got, w := series[i], want[i]
+		if got.Labels["run"] != run || got.Count != w.Count || got.Sum != w.Sum || got.Min != w.Min || got.Max != w.Max || got.Avg != w.Avg || got.Last != w.Last {
+			t.Fatalf("run %s = %+v, want %+v", run, got, w)
+		}
+	}
+
+	// The name-level view rolls both series up
+	rollup := c.GetMetric("loss")
+	if rollup.Count != 5 || rollup.Min != 1 || rollup.Max != 30 || rollup.Sum != 64 {
+		t.Fatalf("rollup = %+v, want both series combined", rollup)
+	}
+	if all := c.GetAllMetrics(); len(all) != 2 {
+		t.Fatalf("GetAllMetrics = %d entries, want one per series", len(all))
+	}
+}
+
+func TestQueryFiltersRangeAndDownsamples(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.SetClock(clock.NewFake(start.Add(time.Hour)))
+	// One loss point a second for 100 seconds, pushed newest first so
+	// Query has to sort, plus another metric that must be left out
+	for i := 99; i >= 0; i-- {
+		ts := start.Add(time.Duration(i) * time.Second)
+		push(t, c,
+			Metric{Name: "loss", Type: MetricGauge, Value: float64(i), Timestamp: ts},
+			Metric{Name: "lr", Type: MetricGauge, Value: -1, Timestamp: ts},
+		)
+	}
+
+	from, to := start.Add(10*time.Second), start.Add(19*time.Second)
+	points := c.Query("loss", from, to, 0)
+	if len(points) != 10 {
+		t.Fatalf("got %d points in [10s, 19s], want 10", len(points))
+	}
+	for i, p := range points {
+		if p.Value != float64(10+i) || !p.Timestamp.Equal(start.Add(time.Duration(10+i)*time.Second)) {
+			t.Fatalf("point %d = %+v, want value %d at its own timestamp, oldest first", i, p, 10+i)
+		}
+	}
+	if n := len(c.Query("loss", time.Time{}, time.Time{}, 0)); n != 100 {
+		t.Fatalf("unbounded query returned %d points, want 100", n)
+	}
+	if n := len(c.Query("loss", start.Add(time.Hour), time.Time{}, 0)); n != 0 {
+		t.Fatalf("query after the last point returned %d points", n)
+	}
+
+	var total float64
+	for _, p := range c.Query("loss", time.Time{}, time.Time{}, 0) {
+		total += p.Value
+	}
+	for _, maxPoints := range []int{1, 3, 7, 10, 33, 99, 100, 500} {
+		points := c.Query("loss", time.Time{}, time.Time{}, maxPoints)
+		if len(points) == 0 || len(points) > maxPoints {
+			t.Fatalf("maxPoints %d: got %d points", maxPoints, len(points))
+		}
+		for i := 1; i < len(points); i++ {
+			if !points[i].Timestamp.After(points[i-1].Timestamp) {
+				t.Fatalf("maxPoints %d: points out of order at %d", maxPoints, i)
+			}
+		}
+		if maxPoints == 1 && math.Abs(points[0].Value-total/100) > 1e-9 {
+			t.Fatalf("single bucket = %v, want the average %v", points[0].Value, total/100)
+		}
+	}
+}
+
+func TestGetJobMetricsIsolatesJobs(t *testing.T) {
+	c := NewCollector()
+	batch := func(jobID string, loss, acc float64) MetricBatch {
+		return MetricBatch{Source: "trainer", JobID: jobID, Metrics: []Metric{
+			{Name: "loss", Type: MetricGauge, Value: loss},
+			{Name: "accuracy", Type: MetricGauge, Value: acc},
+		}}
+	}
+	for _, b := range []MetricBatch{batch("job-a", 1, 0.5), batch("job-b", 10, 0.9), batch("job-a", 3, 0.7), batch("", 100, 0)} {
+		if err := c.Push(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, b := c.GetJobMetrics("job-a"), c.GetJobMetrics("job-b")
+	if len(a) != 2 || len(b) != 2 {
+		t.Fatalf("job-a has %d series, job-b %d; want 2 each", len(a), len(b))
+	}
+	if m := a["loss"]; m.Count != 2 || m.Min != 1 || m.Max != 3 {
+		t.Fatalf("job-a loss = %+v, want only job-a's two values", m)
+	}
+	if m := b["loss"]; m.Count != 1 || m.Last != 10 {
+		t.Fatalf("job-b loss = %+v, want only job-b's value", m)
+	}
+	if c.GetJobMetrics("job-c") != nil {
+		t.Fatal("unknown job returned metrics")
+	}
+
+	// The job-less batch still counts globally
+	if m := c.GetMetric("loss"); m.Count != 4 || m.Max != 100 {
+		t.Fatalf("global loss = %+v, want all four values", m)
+	}
+
+	a["loss"].Count = 99
+	if m := c.GetJobMetrics("job-a")["loss"]; m.Count != 2 {
+		t.Fatal("GetJobMetrics handed out the collector's aggregate")
+	}
+}
+
+func TestNaNIsFlaggedNotAggregated(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.SetClock(clock.NewFake(now))
+	labels := map[string]string{"run": "a"}
+
+	push(t, c, Metric{Name: "loss", Type: MetricGauge, Value: math.NaN(), Labels: labels})
+	push(t, c, Metric{Name: "loss", Type: MetricGauge, Value: math.Inf(1), Labels: labels})
+	for _, v := range []float64{0.5, 1.5} {
+		push(t, c, Metric{Name: "loss", Type: MetricGauge, Value: v, Labels: labels})
+	}
+
+	m := c.GetMetric("loss")
+	if m == nil || math.IsNaN(m.Avg) || math.IsInf(m.Avg, 0) || m.Avg != 1 || m.Min != 0.5 || m.Max != 1.5 || m.Count != 2 {
+		t.Fatalf("loss = %+v, want the two finite values only", m)
+	}
+	if m.AnomalyCount != 2 {
+		t.Fatalf("anomaly count = %d, want 2", m.AnomalyCount)
+	}
+
+	anomalies := c.GetAnomalies()
+	if len(anomalies) != 2 {
+		t.Fatalf("anomalies = %+v, want 2", anomalies)
+	}
+	first := anomalies[0]
+	if first.Name != "loss" || first.JobID != "job-1" || first.Value != "NaN" || first.Labels["run"] != "a" || !first.Timestamp.Equal(now) {
+		t.Fatalf("anomaly = %+v, want the NaN loss from job-1 at %v", first, now)
+	}
+	if anomalies[1].Value != "+Inf" {
+		t.Fatalf("second anomaly value = %q, want +Inf", anomalies[1].Value)
+	}
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+)
+
+// JobIDLabel is the selector key that matches a batch's JobID for metrics
+// that don't carry a job_id label of their own.
+const JobIDLabel = "job_id"
+
+// ErrEmptySelector is returned by DeleteMetricsBySelector when no labels
+// are given, which would otherwise delete everything.
+var ErrEmptySelector = errors.New("label selector must not be empty")
+
+// DeleteResult counts what DeleteMetricsBySelector removed.
+type DeleteResult struct {
+	Series    int `json:"series"`     // aggregated series
+	JobSeries int `json:"job_series"` // per-job aggregated series
+	Points    int `json:"points"`     // values in recent batches
+}
+
+// matchesSelector reports whether every selector label is present in
+// labels with the same value. A job_id selector also matches jobID when
+// labels has no job_id of its own.
+func matchesSelector(selector, labels map[string]string, jobID string) bool {
+	for k, v := range selector {
+		got, ok := labels[k]
+		if !ok && k == JobIDLabel {
+			got, ok = jobID, jobID != ""
+		}
+		if !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// dropMatching returns batch without the metrics matching selector, and
+// how many were dropped. The batch's metrics are copied, not edited.
+func dropMatching(batch MetricBatch, selector map[string]string) (MetricBatch, int) {
+	kept := make([]Metric, 0, len(batch.Metrics))
+	for _, m := range batch.Metrics {
+		if !matchesSelector(selector, m.Labels, batch.JobID) {
+			kept = append(kept, m)
+		}
+	}
+	dropped := len(batch.Metrics) - len(kept)
+	batch.Metrics = kept
+	return batch, dropped
+}
+
+// DeleteMetricsBySelector removes every aggregate, recent value and
+// anomaly whose labels match all of selector. Batches left empty are
+// dropped from the recent ring. With a sink set, the deletion is recorded
+// there first so it also applies to batches restored from the sink; if
+// that fails nothing is deleted.
+func (c *Collector) DeleteMetricsBySelector(selector map[string]string) (DeleteResult, error) {
+	var res DeleteResult
+	if len(selector) == 0 {
+		return res, ErrEmptySelector
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sink != nil {
+		if err := c.sink.Delete(selector); err != nil {
+			return res, fmt.Errorf("record deletion: %w", err)
+		}
+	}
+
+	for key, m := range c.metrics {
+		if matchesSelector(selector, m.Labels, "") {
+			delete(c.metrics, key)
+			res.Series++
+		}
+	}
+
+	for jobID, aggs := range c.jobs {
+		for key, m := range aggs {
+			if matchesSelector(selector, m.Labels, jobID) {
+				delete(aggs, key)
+				res.JobSeries++
+			}
+		}
+		if len(aggs) == 0 {
+			delete(c.jobs, jobID)
+		}
+	}
+
+	// Build new slices: batches handed out by GetRecentBatches share their
+	// metrics with the ring
+	recent := make([]MetricBatch, 0, len(c.recent))
+	for _, batch := range c.recent {
+		kept, dropped := dropMatching(batch, selector)
+		res.Points += dropped
+		if len(kept.Metrics) == 0 && dropped > 0 {
+			continue
+		}
+		recent = append(recent, kept)
+	}
+	c.recent = recent
+
+	anomalies := make([]Anomaly, 0, len(c.anomalies))
+	for _, a := range c.anomalies {
+		if !matchesSelector(selector, a.Labels, a.JobID) {
+			anomalies = append(anomalies, a)
+		}
+	}
+	c.anomalies = anomalies
+
+	return res, nil
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDeleteBySelectorLeavesOtherJobs(t *testing.T) {
+	c := NewCollector()
+	pushJob(t, c, "job-1", 1)
+	pushJob(t, c, "job-2", 2)
+	// gpu_util carries no job_id label, so the selector reaches it through
+	// the batch's JobID
+	err := c.Push(MetricBatch{Source: "trainer", JobID: "job-1", Metrics: []Metric{
+		{Name: "loss", Type: MetricGauge, Value: 0.5, Labels: map[string]string{"job_id": "job-1"}},
+		{Name: "gpu_util", Type: MetricGauge, Value: 80},
+		{Name: "loss", Type: MetricGauge, Value: math.NaN(), Labels: map[string]string{"job_id": "job-1"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushJob(t, c, "job-2", 3)
+
+	res, err := c.DeleteMetricsBySelector(map[string]string{"job_id": "job-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DeleteResult{Series: 1, JobSeries: 2, Points: 3}
+	if res != want {
+		t.Errorf("result = %+v, want %+v", res, want)
+	}
+
+	if got := c.GetJobMetrics("job-1"); got != nil {
+		t.Errorf("job-1 aggregates = %v, want none", got)
+	}
+	job2 := c.GetJobMetrics("job-2")
+	if m := job2[`loss{job_id="job-2"}`]; m == nil || m.Count != 2 || m.Last != 3 {
+		t.Errorf("job-2 loss = %+v, want both values kept", m)
+	}
+	series := c.GetSeries("loss")
+	if len(series) != 1 || series[0].Labels["job_id"] != "job-2" {
+		t.Errorf("loss series = %+v, want only job-2", series)
+	}
+	// The global gpu_util series has no job label, so it isn't selected
+	if m := c.GetMetric("gpu_util"); m == nil || m.Count != 1 {
+		t.Errorf("gpu_util = %+v, want it kept", m)
+	}
+
+	// Recent batches match on the batch JobID too, so both of job-1's go
+	for _, b := range c.GetRecentBatches(10) {
+		if b.JobID != "job-2" {
+			t.Errorf("recent batch from %s survived: %+v", b.JobID, b)
+		}
+	}
+	if got := len(c.GetRecentBatches(10)); got != 2 {
+		t.Errorf("recent batches = %d, want job-2's 2", got)
+	}
+	if got := c.GetAnomalies(); len(got) != 0 {
+		t.Errorf("anomalies = %+v, want job-1's removed", got)
+	}
+}
+
+func TestDeleteBySelectorMatchesAllLabels(t *testing.T) {
+	c := NewCollector()
+	err := c.Push(MetricBatch{Source: "trainer", JobID: "job-1", Metrics: []Metric{
+		{Name: "gpu_util", Type: MetricGauge, Value: 70, Labels: map[string]string{"gpu": "0"}},
+		{Name: "gpu_util", Type: MetricGauge, Value: 90, Labels: map[string]string{"gpu": "1"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushJob(t, c, "job-2", 1)
+
+	if _, err := c.DeleteMetricsBySelector(map[string]string{"job_id": "job-2", "gpu": "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(c.GetSeries("gpu_util")); got != 2 {
+		t.Fatalf("gpu_util series = %d, want 2: job-2 has no gpu label", got)
+	}
+
+	res, err := c.DeleteMetricsBySelector(map[string]string{"job_id": "job-1", "gpu": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.JobSeries != 1 || res.Points != 1 {
+		t.Errorf("result = %+v, want one job series and one point", res)
+	}
+	if _, ok := c.GetJobMetrics("job-1")[`gpu_util{gpu="1"}`]; !ok {
+		t.Error("gpu 1 was deleted with gpu 0")
+	}
+
+	if _, err := c.DeleteMetricsBySelector(nil); !errors.Is(err, ErrEmptySelector) {
+		t.Fatalf("empty selector = %v, want ErrEmptySelector", err)
+	}
+}
@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MetricSink persists metric batches beyond the in-memory ring. Delete
+// records that metrics matching selector in earlier batches were removed.
+type MetricSink interface {
+	Append(batch MetricBatch) error
+	Delete(selector map[string]string) error
+	Close() error
+}
+
+// sinkRecord is one line of a FileSink: a batch, or a tombstone naming
+// the selector of a deletion.
+type sinkRecord struct {
+	MetricBatch
+	Delete map[string]string `json:"delete,omitempty"`
+}
+
+// Defaults for keeping a FileSink bounded: batches older than
+// DefaultRetention are dropped by a compaction every
+// DefaultCompactInterval.
+const (
+	DefaultRetention       = 7 * 24 * time.Hour
+	DefaultCompactInterval = time.Hour
+)
+
+// FileSink appends batches and deletions to a file as JSON lines.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileSink opens (or creates) path for appending.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{path: path, file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Compact rewrites the file keeping only batches stamped at or after
+// cutoff. Tombstones are applied and dropped along the way. The new file
+// replaces the old one atomically, so a crash leaves one or the other.
+// It returns how many batches had expired.
+func (s *FileSink) Compact(cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batches, err := LoadBatches(s.path)
+	if err != nil {
+		return 0, err
+	}
+	kept := make([]MetricBatch, 0, len(batches))
+	for _, batch := range batches {
+		if !batch.Timestamp.Before(cutoff) {
+			kept = append(kept, batch)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := json.NewEncoder(tmp)
+	for _, batch := range kept {
+		if err := enc.Encode(sinkRecord{MetricBatch: batch}); err != nil {
+			tmp.Close()
+			return 0, err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	s.file.Close()
+	s.file = f
+	s.enc = json.NewEncoder(f)
+	return len(batches) - len(kept), nil
+}
+
+// Append writes one batch as a single line.
+func (s *FileSink) Append(batch MetricBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(sinkRecord{MetricBatch: batch})
+}
+
+// Delete writes a tombstone for selector and syncs it, so the deletion is
+// not lost to a crash.
+func (s *FileSink) Delete(selector map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.enc.Encode(sinkRecord{Delete: selector}); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close flushes and closes the underlying file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// LoadBatches reads every batch from a JSON-lines file in write order,
+// removing metrics deleted by later tombstones; batches a deletion
+// empties are dropped. A missing file yields no batches. A truncated
+// final line, as left by a crash mid-write, is ignored.
+func LoadBatches(path string) ([]MetricBatch, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var batches []MetricBatch
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var pending error
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if pending != nil {
+			return nil, pending
+		}
+		var rec sinkRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			pending = fmt.Errorf("line %d: %w", line, err)
+			continue
+		}
+		if rec.Delete == nil {
+			batches = append(batches, rec.MetricBatch)
+			continue
+		}
+		kept := batches[:0]
+		for _, batch := range batches {
+			batch, dropped := dropMatching(batch, rec.Delete)
+			if len(batch.Metrics) == 0 && dropped > 0 {
+				continue
+			}
+			kept = append(kept, batch)
+		}
+		batches = kept
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
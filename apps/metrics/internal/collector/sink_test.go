@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pushJob(t *testing.T, c *Collector, jobID string, value float64) {
+	t.Helper()
+	err := c.Push(MetricBatch{
+		Source:  "trainer",
+		JobID:   jobID,
+		Metrics: []Metric{{Name: "loss", Type: MetricGauge, Value: value, Labels: map[string]string{"job_id": jobID}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeletedMetricsStayDeletedAfterRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollector()
+	c.SetSink(sink)
+
+	pushJob(t, c, "job-1", 1)
+	pushJob(t, c, "job-2", 2)
+	if _, err := c.DeleteMetricsBySelector(map[string]string{"job_id": "job-1"}); err != nil {
+		t.Fatal(err)
+	}
+	pushJob(t, c, "job-1", 3) // after the deletion, so it stays
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	batches, err := LoadBatches(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 {
+		t.Fatalf("restored %d batches, want 2", len(batches))
+	}
+	restored := NewCollector()
+	restored.Restore(batches)
+
+	series := restored.GetSeries("loss")
+	if len(series) != 2 {
+		t.Fatalf("series = %d, want 2", len(series))
+	}
+	for _, m := range series {
+		if m.Labels["job_id"] == "job-1" && (m.Count != 1 || m.Last != 3) {
+			t.Fatalf("job-1 series = %+v, want only the value pushed after the delete", m)
+		}
+	}
+}
+
+type failingSink struct{ err error }
+
+func (s failingSink) Append(MetricBatch) error       { return nil }
+func (s failingSink) Delete(map[string]string) error { return s.err }
+func (s failingSink) Close() error                   { return nil }
+
+func TestDeleteKeepsMetricsWhenTombstoneFails(t *testing.T) {
+	c := NewCollector()
+	c.SetSink(failingSink{err: errors.New("disk full")})
+	pushJob(t, c, "job-1", 1)
+
+	if _, err := c.DeleteMetricsBySelector(map[string]string{"job_id": "job-1"}); err == nil {
+		t.Fatal("delete succeeded without a tombstone")
+	}
+	if got := len(c.GetSeries("loss")); got != 1 {
+		t.Fatalf("series = %d, want the metric kept", got)
+	}
+}
+
+func TestCompactDropsExpiredBatchesAndTombstones(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	batch := func(jobID string, age time.Duration) MetricBatch {
+		return MetricBatch{JobID: jobID, Timestamp: now.Add(-age), Metrics: []Metric{{Name: "loss", Value: 1}}}
+	}
+	for _, b := range []MetricBatch{batch("old", 48*time.Hour), batch("gone", time.Hour), batch("kept", time.Hour)} {
+		if err := sink.Append(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Delete(map[string]string{"job_id": "gone"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dropped, err := sink.Compact(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 1 {
+		t.Fatalf("dropped = %d, want 1 expired batch", dropped)
+	}
+	if err := sink.Append(batch("after", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Fatalf("file has %d lines after compaction, want 2:\n%s", lines, data)
+	}
+	batches, err := LoadBatches(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 || batches[0].JobID != "kept" || batches[1].JobID != "after" {
+		t.Fatalf("batches = %+v, want kept then after", batches)
+	}
+}
+
+func TestFileSinkPreservesOrderAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var written []MetricBatch
+	// Two sessions, with timestamps deliberately out of write order
+	for session := 0; session < 2; session++ {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 25; i++ {
+			n := session*25 + i
+			b := MetricBatch{
+				Source:    "trainer",
+				JobID:     "job-1",
+				Timestamp: base.Add(time.Duration((n*7)%50) * time.Second),
+				Metrics:   []Metric{{Name: "step", Type: MetricCounter, Value: float64(n), Labels: map[string]string{"job_id": "job-1"}}},
+			}
+			if err := sink.Append(b); err != nil {
+				t.Fatal(err)
+			}
+			written = append(written, b)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := LoadBatches(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(written) {
+		t.Fatalf("loaded %d batches, want %d", len(loaded), len(written))
+	}
+	for i := range written {
+		got, want := loaded[i], written[i]
+		if got.JobID != want.JobID || !got.Timestamp.Equal(want.Timestamp) || len(got.Metrics) != 1 || got.Metrics[0].Value != want.Metrics[0].Value {
+			t.Fatalf("batch %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Restoring replays them in the same order
+	c := NewCollector()
+	c.Restore(loaded)
+	recent := c.GetRecentBatches(len(written))
+	for i := range recent {
+		if recent[i].Metrics[0].Value != float64(i) {
+			t.Fatalf("restored batch %d has step %v", i, recent[i].Metrics[0].Value)
+		}
+	}
+	if m := c.GetMetric("step"); m == nil || m.Count != int64(len(written)) {
+		t.Fatalf("restored step = %+v, want %d values", m, len(written))
+	}
+}
@@ -3,15 +3,21 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"openlora/core/clock"
+	"openlora/core/logging"
+	"openlora/core/svcauth"
+	"openlora/core/svcauth/grpcauth"
 	"openlora/orchestrator/internal/allocator"
 	"openlora/orchestrator/internal/api"
+	"openlora/orchestrator/internal/notifier"
 	"openlora/orchestrator/internal/scheduler"
 	pb "openlora/orchestrator/proto"
 
@@ -19,12 +25,33 @@ import (
 )
 
 func main() {
-	log.Println("🚀 OpenLoRA Resource Orchestrator starting...")
+	logging.Setup("orchestrator")
+	slog.Info("🚀 OpenLoRA Resource Orchestrator starting...")
 
-	// Initialize components
+	// Initialize components. Everything shares one clock so timestamps
+	// agree and are UTC.
+	clk := clock.Real{}
 	alloc := allocator.NewGPUAllocator()
+	alloc.SetClock(clk)
+	if path := os.Getenv("QUOTAS_FILE"); path != "" {
+		quotas, err := allocator.LoadQuotas(path)
+		if err != nil {
+			logging.Fatal("Failed to load quotas", "path", path, "error", err)
+		}
+		for _, q := range quotas {
+			alloc.SetQuota(q)
+		}
+		slog.Info("Loaded quotas", "users", len(quotas))
+	}
 	sched := scheduler.NewScheduler(alloc)
-	grpcServer := grpc.NewServer()
+	sched.SetClock(clk)
+	sched.RejectOverCeiling(os.Getenv("PRIORITY_CEILING_MODE") == "reject")
+
+	// Notify job owners when their jobs are preempted
+	if webhookURL := os.Getenv("NOTIFY_WEBHOOK_URL"); webhookURL != "" {
+		sched.OnPreempt(preemptNotifier(notifier.NewWebhook(webhookURL), clk))
+	}
+	grpcServer := grpc.NewServer(grpcauth.ServerOptions(os.Getenv(svcauth.SecretEnv))...)
 
 	// Register gRPC service
 	pb.RegisterOrchestratorServer(grpcServer, api.NewGRPCServer(sched, alloc))
@@ -33,13 +60,13 @@ func main() {
 	grpcPort := getEnv("GRPC_PORT", "50051")
 	lis, err := net.Listen("tcp", ":"+grpcPort)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		logging.Fatal("Failed to listen", "error", err)
 	}
 
 	go func() {
-		log.Printf("📡 gRPC server listening on :%s", grpcPort)
+		slog.Info("📡 gRPC server listening", "port", grpcPort)
 		if err := grpcServer.Serve(lis); err != nil {
-			log.Fatalf("gRPC server failed: %v", err)
+			logging.Fatal("gRPC server failed", "error", err)
 		}
 	}()
 
@@ -47,10 +74,28 @@ func main() {
 	httpPort := getEnv("HTTP_PORT", "8081")
 	httpServer := api.NewHTTPServer(sched, alloc)
 
+	logRetention := api.DefaultLogRetention
+	if v := os.Getenv("JOB_LOG_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logging.Fatal("Invalid JOB_LOG_RETENTION", "value", v)
+		}
+		logRetention = d
+	}
+	go func() {
+		ticker := time.NewTicker(logRetention / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			if n := httpServer.PruneLogs(logRetention); n > 0 {
+				slog.Debug("Pruned job logs", "jobs", n)
+			}
+		}
+	}()
+
 	go func() {
-		log.Printf("🌐 HTTP server listening on :%s", httpPort)
-		if err := http.ListenAndServe(":"+httpPort, httpServer); err != nil {
-			log.Fatalf("HTTP server failed: %v", err)
+		slog.Info("🌐 HTTP server listening", "port", httpPort)
+		if err := http.ListenAndServe(":"+httpPort, svcauth.Middleware(os.Getenv(svcauth.SecretEnv), httpServer)); err != nil {
+			logging.Fatal("HTTP server failed", "error", err)
 		}
 	}()
 
@@ -59,10 +104,24 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down...")
+	slog.Info("Shutting down...")
 	grpcServer.GracefulStop()
 }
 
+// preemptNotifier returns a preemption hook that tells the job's owner
+// through webhook.
+func preemptNotifier(webhook *notifier.Webhook, clk clock.Clock) scheduler.PreemptHook {
+	return func(job scheduler.Job, reason string) {
+		webhook.SendAsync(notifier.Notification{
+			Event:     "job.preempted",
+			JobID:     job.ID,
+			UserID:    job.UserID,
+			Reason:    reason,
+			Timestamp: clk.Now(),
+		})
+	}
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+	"openlora/orchestrator/internal/allocator"
+	"openlora/orchestrator/internal/notifier"
+	"openlora/orchestrator/internal/scheduler"
+)
+
+func TestPreemptionSendsWebhook(t *testing.T) {
+	received := make(chan notifier.Notification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notifier.Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Error(err)
+		}
+		received <- n
+	}))
+	defer srv.Close()
+
+	alloc := allocator.NewGPUAllocator()
+	alloc.RegisterNode(&allocator.Node{
+		ID:        "node-1",
+		TotalMem:  1024,
+		TotalCPUs: 64,
+		GPUs:      []*allocator.GPU{{ID: "gpu-0", NodeID: "node-1", Type: allocator.GPUA100, MemoryGB: 80}},
+	})
+	sched := scheduler.NewScheduler(alloc)
+	defer sched.Stop()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	sched.OnPreempt(preemptNotifier(notifier.NewWebhook(srv.URL), clock.NewFake(now)))
+
+	if err := sched.Submit(&scheduler.Job{ID: "job-1", UserID: "alice", Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if job, _ := sched.GetJob("job-1"); job.State == scheduler.JobRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sched.Preempt("job-1", "maintenance"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case n := <-received:
+		want := notifier.Notification{Event: "job.preempted", JobID: "job-1", UserID: "alice", Reason: "maintenance", Timestamp: now}
+		if n != want {
+			t.Fatalf("webhook got %+v, want %+v", n, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook never called")
+	}
+}
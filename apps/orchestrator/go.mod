@@ -11,4 +11,7 @@ require (
 	golang.org/x/text v0.13.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20231002182017-d307bd883b97 // indirect
 	google.golang.org/protobuf v1.32.0 // indirect
+	openlora/core v0.0.0
 )
+
+replace openlora/core => ../../packages/core-go
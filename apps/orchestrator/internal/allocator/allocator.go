@@ -2,9 +2,15 @@
 package allocator
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"sort"
 	"sync"
 	"time"
+
+	"openlora/core/clock"
 )
 
 // GPUType represents GPU hardware type.
@@ -17,6 +23,15 @@ const (
 	GPUTPU  GPUType = "TPU-v4"
 )
 
+// Known reports whether t is one of the supported GPU types.
+func (t GPUType) Known() bool {
+	switch t {
+	case GPUA100, GPUH100, GPUL40S, GPUTPU:
+		return true
+	}
+	return false
+}
+
 // GPU represents a single GPU resource.
 type GPU struct {
 	ID        string    `json:"id"`
@@ -32,6 +47,7 @@ type GPU struct {
 type Node struct {
 	ID        string    `json:"id"`
 	Address   string    `json:"address"`
+	Region    string    `json:"region,omitempty"`
 	GPUs      []*GPU    `json:"gpus"`
 	TotalMem  int       `json:"total_memory_gb"`
 	UsedMem   int       `json:"used_memory_gb"`
@@ -45,6 +61,7 @@ type Node struct {
 type Allocation struct {
 	ID        string    `json:"id"`
 	JobID     string    `json:"job_id"`
+	UserID    string    `json:"user_id,omitempty"`
 	NodeID    string    `json:"node_id"`
 	GPUIDs    []string  `json:"gpu_ids"`
 	MemoryGB  int       `json:"memory_gb"`
@@ -59,6 +76,56 @@ type ResourceRequest struct {
 	MemoryGB    int     `json:"memory_gb"`
 	CPUs        int     `json:"cpus"`
 	MaxWaitSecs int     `json:"max_wait_secs,omitempty"`
+	// Region is the preferred region. Nodes there are tried first and
+	// others only if none fit, unless RequireRegion pins the request.
+	Region        string `json:"region,omitempty"`
+	RequireRegion bool   `json:"require_region,omitempty"`
+}
+
+// ErrInvalidRequest is returned by Validate for a malformed resource request.
+var ErrInvalidRequest = errors.New("invalid resource request")
+
+// Validate checks that counts are non-negative, that a GPU request also
+// asks for memory, and that the GPU type, if set, is known.
+func (r ResourceRequest) Validate() error {
+	switch {
+	case r.GPUs < 0:
+		return fmt.Errorf("%w: gpus must not be negative, got %d", ErrInvalidRequest, r.GPUs)
+	case r.CPUs < 0:
+		return fmt.Errorf("%w: cpus must not be negative, got %d", ErrInvalidRequest, r.CPUs)
+	case r.MemoryGB < 0:
+		return fmt.Errorf("%w: memory_gb must not be negative, got %d", ErrInvalidRequest, r.MemoryGB)
+	case r.GPUs > 0 && r.MemoryGB == 0:
+		return fmt.Errorf("%w: memory_gb must be positive when requesting GPUs", ErrInvalidRequest)
+	case r.MaxWaitSecs < 0:
+		return fmt.Errorf("%w: max_wait_secs must not be negative, got %d", ErrInvalidRequest, r.MaxWaitSecs)
+	case r.GPUType != "" && !r.GPUType.Known():
+		return fmt.Errorf("%w: unknown gpu_type %q", ErrInvalidRequest, r.GPUType)
+	case r.RequireRegion && r.Region == "":
+		return fmt.Errorf("%w: require_region needs a region", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// FailureReason classifies why an allocation could not be made.
+type FailureReason string
+
+const (
+	ReasonQuotaExceeded      FailureReason = "quota_exceeded"
+	ReasonNoMatchingType     FailureReason = "no_matching_type"
+	ReasonInsufficientMemory FailureReason = "insufficient_memory"
+	ReasonNoCapacity         FailureReason = "no_capacity"
+)
+
+// AllocationError is returned by Allocate when a request cannot be placed.
+// Use errors.As to inspect the Reason.
+type AllocationError struct {
+	Reason  FailureReason `json:"reason"`
+	Message string        `json:"message"`
+}
+
+func (e *AllocationError) Error() string {
+	return string(e.Reason) + ": " + e.Message
 }
 
 // GPUAllocator manages GPU allocation across the cluster.
@@ -67,15 +134,32 @@ type GPUAllocator struct {
 	nodes       map[string]*Node
 	allocations map[string]*Allocation
 	quotas      map[string]*Quota
+	clock       clock.Clock
 }
 
-// Quota defines resource limits per user/team.
+// Quota defines resource limits per user/team. A zero MaxGPUs or
+// MaxMemoryGB leaves that resource unlimited, so a quota can carry just a
+// priority ceiling.
 type Quota struct {
 	UserID       string `json:"user_id"`
 	MaxGPUs      int    `json:"max_gpus"`
 	MaxMemoryGB  int    `json:"max_memory_gb"`
 	UsedGPUs     int    `json:"used_gpus"`
 	UsedMemoryGB int    `json:"used_memory_gb"`
+	MaxPriority  *int   `json:"max_priority,omitempty"` // nil means no ceiling
+}
+
+// LoadQuotas reads a JSON array of quotas from path.
+func LoadQuotas(path string) ([]*Quota, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var quotas []*Quota
+	if err := json.Unmarshal(data, &quotas); err != nil {
+		return nil, fmt.Errorf("parse quotas: %w", err)
+	}
+	return quotas, nil
 }
 
 // NewGPUAllocator creates a new allocator.
@@ -84,73 +168,257 @@ func NewGPUAllocator() *GPUAllocator {
 		nodes:       make(map[string]*Node),
 		allocations: make(map[string]*Allocation),
 		quotas:      make(map[string]*Quota),
+		clock:       clock.Real{},
 	}
 }
 
+// SetClock replaces the clock used to stamp pings and allocations.
+func (a *GPUAllocator) SetClock(c clock.Clock) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.clock = c
+}
+
 // RegisterNode adds a compute node to the cluster.
 func (a *GPUAllocator) RegisterNode(node *Node) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	node.Healthy = true
-	node.LastPing = time.Now()
+	node.LastPing = a.clock.Now()
 	a.nodes[node.ID] = node
 }
 
+// SetQuota installs limits for q.UserID, keeping any usage already tracked.
+func (a *GPUAllocator) SetQuota(q *Quota) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if existing, ok := a.quotas[q.UserID]; ok {
+		q.UsedGPUs = existing.UsedGPUs
+		q.UsedMemoryGB = existing.UsedMemoryGB
+	}
+	a.quotas[q.UserID] = q
+}
+
+// PriorityCeiling returns the highest priority userID may submit at.
+func (a *GPUAllocator) PriorityCeiling(userID string) (int, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	quota, ok := a.quotas[userID]
+	if !ok || quota.MaxPriority == nil {
+		return 0, false
+	}
+	return *quota.MaxPriority, true
+}
+
 // Allocate reserves resources for a job.
 func (a *GPUAllocator) Allocate(jobID, userID string, req ResourceRequest) (*Allocation, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	node, gpus, err := a.place(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	alloc := &Allocation{
+		ID:        generateID(),
+		JobID:     jobID,
+		UserID:    userID,
+		NodeID:    node.ID,
+		GPUIDs:    make([]string, req.GPUs),
+		MemoryGB:  req.MemoryGB,
+		CPUs:      req.CPUs,
+		CreatedAt: a.clock.Now(),
+	}
+
+	for i := 0; i < req.GPUs; i++ {
+		gpus[i].Allocated = true
+		gpus[i].JobID = jobID
+		gpus[i].AllocAt = alloc.CreatedAt
+		alloc.GPUIDs[i] = gpus[i].ID
+	}
+
+	node.UsedMem += req.MemoryGB
+	node.UsedCPUs += req.CPUs
+
+	a.allocations[alloc.ID] = alloc
+
+	// Update quota
+	if quota, ok := a.quotas[userID]; ok {
+		quota.UsedGPUs += req.GPUs
+		quota.UsedMemoryGB += req.MemoryGB
+	}
+
+	return alloc, nil
+}
+
+// Placement is where a request would be allocated right now.
+type Placement struct {
+	NodeID string   `json:"node_id"`
+	GPUIDs []string `json:"gpu_ids"`
+}
+
+// CanAllocate reports where Allocate would place req for userID without
+// reserving anything. If the request can't be placed it returns the same
+// AllocationError Allocate would. An empty userID skips the quota check.
+func (a *GPUAllocator) CanAllocate(userID string, req ResourceRequest) (*Placement, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	node, gpus, err := a.place(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Placement{NodeID: node.ID, GPUIDs: make([]string, req.GPUs)}
+	for i := 0; i < req.GPUs; i++ {
+		p.GPUIDs[i] = gpus[i].ID
+	}
+	return p, nil
+}
+
+// place picks the node and GPUs for req, trying nodes in the preferred
+// region first and otherwise in ID order so the choice is predictable.
+// Callers must hold a.mu.
+func (a *GPUAllocator) place(userID string, req ResourceRequest) (*Node, []*GPU, error) {
 	// Check quota
 	if quota, ok := a.quotas[userID]; ok {
-		if quota.UsedGPUs+req.GPUs > quota.MaxGPUs {
-			return nil, errors.New("quota exceeded: GPU limit")
+		if quota.MaxGPUs > 0 && quota.UsedGPUs+req.GPUs > quota.MaxGPUs {
+			return nil, nil, &AllocationError{
+				Reason:  ReasonQuotaExceeded,
+				Message: fmt.Sprintf("GPU limit %d reached (%d in use, %d requested)", quota.MaxGPUs, quota.UsedGPUs, req.GPUs),
+			}
+		}
+		if quota.MaxMemoryGB > 0 && quota.UsedMemoryGB+req.MemoryGB > quota.MaxMemoryGB {
+			return nil, nil, &AllocationError{
+				Reason:  ReasonQuotaExceeded,
+				Message: fmt.Sprintf("memory limit %dGB reached (%dGB in use, %dGB requested)", quota.MaxMemoryGB, quota.UsedMemoryGB, req.MemoryGB),
+			}
+		}
+	}
+
+	// Track the closest miss so the error explains what blocked placement
+	typeMatched, gpusFree := false, false
+
+	ids := make([]string, 0, len(a.nodes))
+	for id, node := range a.nodes {
+		if req.RequireRegion && node.Region != req.Region {
+			continue
 		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if req.Region != "" {
+			inI, inJ := a.nodes[ids[i]].Region == req.Region, a.nodes[ids[j]].Region == req.Region
+			if inI != inJ {
+				return inI
+			}
+		}
+		return ids[i] < ids[j]
+	})
+
+	where := ""
+	if req.RequireRegion {
+		where = " in region " + req.Region
 	}
 
 	// Find suitable node
-	for _, node := range a.nodes {
+	for _, id := range ids {
+		node := a.nodes[id]
 		if !node.Healthy {
 			continue
 		}
 
+		if countGPUs(node, req.GPUType) >= req.GPUs {
+			typeMatched = true
+		}
+
 		gpus := a.findAvailableGPUs(node, req)
-		if len(gpus) >= req.GPUs {
-			// Allocate
-			alloc := &Allocation{
-				ID:        generateID(),
-				JobID:     jobID,
-				NodeID:    node.ID,
-				GPUIDs:    make([]string, req.GPUs),
-				MemoryGB:  req.MemoryGB,
-				CPUs:      req.CPUs,
-				CreatedAt: time.Now(),
-			}
+		if len(gpus) < req.GPUs {
+			continue
+		}
+		gpusFree = true
 
-			for i := 0; i < req.GPUs; i++ {
-				gpus[i].Allocated = true
-				gpus[i].JobID = jobID
-				gpus[i].AllocAt = time.Now()
-				alloc.GPUIDs[i] = gpus[i].ID
-			}
+		// Nodes that don't report memory are not memory-constrained
+		if node.TotalMem > 0 && node.TotalMem-node.UsedMem < req.MemoryGB {
+			continue
+		}
 
-			node.UsedMem += req.MemoryGB
-			node.UsedCPUs += req.CPUs
+		return node, gpus, nil
+	}
 
-			a.allocations[alloc.ID] = alloc
+	switch {
+	case !typeMatched:
+		gpuType := string(req.GPUType)
+		if gpuType == "" {
+			gpuType = "any"
+		}
+		return nil, nil, &AllocationError{
+			Reason:  ReasonNoMatchingType,
+			Message: fmt.Sprintf("no healthy node%s has %d GPU(s) of type %s", where, req.GPUs, gpuType),
+		}
+	case gpusFree:
+		return nil, nil, &AllocationError{
+			Reason:  ReasonInsufficientMemory,
+			Message: fmt.Sprintf("no node%s with free GPUs has %dGB memory available", where, req.MemoryGB),
+		}
+	default:
+		return nil, nil, &AllocationError{
+			Reason:  ReasonNoCapacity,
+			Message: fmt.Sprintf("all matching GPUs%s are allocated (%d requested)", where, req.GPUs),
+		}
+	}
+}
 
-			// Update quota
-			if quota, ok := a.quotas[userID]; ok {
-				quota.UsedGPUs += req.GPUs
-				quota.UsedMemoryGB += req.MemoryGB
-			}
+// CheckFeasible reports whether req could ever be placed on the registered
+// nodes if they were idle. Memory is split evenly across the requested GPUs,
+// so a request that needs more per card than any matching GPU has returns
+// an AllocationError with ReasonInsufficientMemory. GPUs that don't report
+// memory are not memory-constrained, and an empty cluster is not judged.
+func (a *GPUAllocator) CheckFeasible(req ResourceRequest) error {
+	if req.GPUs <= 0 || req.MemoryGB <= 0 {
+		return nil
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if len(a.nodes) == 0 {
+		return nil
+	}
 
-			return alloc, nil
+	perGPU := (req.MemoryGB + req.GPUs - 1) / req.GPUs
+	largest := 0
+	for _, node := range a.nodes {
+		fits := 0
+		for _, gpu := range node.GPUs {
+			if req.GPUType != "" && gpu.Type != req.GPUType {
+				continue
+			}
+			if gpu.MemoryGB > largest {
+				largest = gpu.MemoryGB
+			}
+			if gpu.MemoryGB == 0 || gpu.MemoryGB >= perGPU {
+				fits++
+			}
+		}
+		if fits >= req.GPUs {
+			return nil
 		}
 	}
 
-	return nil, errors.New("no suitable node found")
+	if largest == 0 || largest >= perGPU {
+		// Memory is not the blocker; leave it to the scheduler
+		return nil
+	}
+	return &AllocationError{
+		Reason: ReasonInsufficientMemory,
+		Message: fmt.Sprintf("%dGB over %d GPU(s) needs %dGB per GPU, but the largest matching GPU has %dGB",
+			req.MemoryGB, req.GPUs, perGPU, largest),
+	}
 }
 
 // Release frees resources from an allocation.
@@ -181,6 +449,11 @@ func (a *GPUAllocator) Release(allocID string) error {
 	node.UsedMem -= alloc.MemoryGB
 	node.UsedCPUs -= alloc.CPUs
 
+	if quota, ok := a.quotas[alloc.UserID]; ok {
+		quota.UsedGPUs = max(quota.UsedGPUs-len(alloc.GPUIDs), 0)
+		quota.UsedMemoryGB = max(quota.UsedMemoryGB-alloc.MemoryGB, 0)
+	}
+
 	delete(a.allocations, allocID)
 	return nil
 }
@@ -220,6 +493,87 @@ func (a *GPUAllocator) GetClusterStatus() map[string]interface{} {
 	}
 }
 
+// NodeDetail is a per-node breakdown of GPU inventory and health.
+type NodeDetail struct {
+	ID            string          `json:"id"`
+	Address       string          `json:"address"`
+	Healthy       bool            `json:"healthy"`
+	LastHeartbeat time.Time       `json:"last_heartbeat"`
+	TotalGPUs     int             `json:"total_gpus"`
+	AllocatedGPUs int             `json:"allocated_gpus"`
+	FreeGPUs      int             `json:"free_gpus"`
+	GPUsByType    map[GPUType]int `json:"gpus_by_type"`
+	TotalMemoryGB int             `json:"total_memory_gb"`
+	UsedMemoryGB  int             `json:"used_memory_gb"`
+	TotalCPUs     int             `json:"total_cpus"`
+	UsedCPUs      int             `json:"used_cpus"`
+	GPUs          []GPU           `json:"gpus"`
+}
+
+// NodeDetails returns a breakdown for every node, sorted by ID. The counts
+// sum to the totals reported by GetClusterStatus.
+func (a *GPUAllocator) NodeDetails() []NodeDetail {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	details := make([]NodeDetail, 0, len(a.nodes))
+	for _, node := range a.nodes {
+		d := NodeDetail{
+			ID:            node.ID,
+			Address:       node.Address,
+			Healthy:       node.Healthy,
+			LastHeartbeat: node.LastPing,
+			TotalGPUs:     len(node.GPUs),
+			GPUsByType:    make(map[GPUType]int),
+			TotalMemoryGB: node.TotalMem,
+			UsedMemoryGB:  node.UsedMem,
+			TotalCPUs:     node.TotalCPUs,
+			UsedCPUs:      node.UsedCPUs,
+			GPUs:          make([]GPU, 0, len(node.GPUs)),
+		}
+		for _, gpu := range node.GPUs {
+			if gpu.Allocated {
+				d.AllocatedGPUs++
+			}
+			d.GPUsByType[gpu.Type]++
+			d.GPUs = append(d.GPUs, *gpu)
+		}
+		d.FreeGPUs = d.TotalGPUs - d.AllocatedGPUs
+		details = append(details, d)
+	}
+	sort.Slice(details, func(i, j int) bool { return details[i].ID < details[j].ID })
+	return details
+}
+
+// Clone returns a deep copy of the allocator's state, suitable for
+// dry-run placement without affecting the live cluster.
+func (a *GPUAllocator) Clone() *GPUAllocator {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	c := NewGPUAllocator()
+	c.clock = a.clock
+	for id, node := range a.nodes {
+		n := *node
+		n.GPUs = make([]*GPU, len(node.GPUs))
+		for i, gpu := range node.GPUs {
+			g := *gpu
+			n.GPUs[i] = &g
+		}
+		c.nodes[id] = &n
+	}
+	for id, alloc := range a.allocations {
+		al := *alloc
+		al.GPUIDs = append([]string(nil), alloc.GPUIDs...)
+		c.allocations[id] = &al
+	}
+	for id, quota := range a.quotas {
+		q := *quota
+		c.quotas[id] = &q
+	}
+	return c
+}
+
 func (a *GPUAllocator) findAvailableGPUs(node *Node, req ResourceRequest) []*GPU {
 	var available []*GPU
 	for _, gpu := range node.GPUs {
@@ -232,6 +586,17 @@ func (a *GPUAllocator) findAvailableGPUs(node *Node, req ResourceRequest) []*GPU
 	return available
 }
 
+// countGPUs returns how many GPUs on the node match gpuType, allocated or not.
+func countGPUs(node *Node, gpuType GPUType) int {
+	n := 0
+	for _, gpu := range node.GPUs {
+		if gpuType == "" || gpu.Type == gpuType {
+			n++
+		}
+	}
+	return n
+}
+
 func generateID() string {
 	return time.Now().Format("20060102150405") + "-" + randomString(8)
 }
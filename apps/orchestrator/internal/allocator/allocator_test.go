@@ -0,0 +1,182 @@
+package allocator
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestAllocator(gpus int) *GPUAllocator {
+	a := NewGPUAllocator()
+	node := &Node{ID: "node-1", TotalMem: 1024, TotalCPUs: 64}
+	for i := 0; i < gpus; i++ {
+		node.GPUs = append(node.GPUs, &GPU{ID: "gpu-" + string(rune('a'+i)), NodeID: node.ID, Type: GPUA100, MemoryGB: 80})
+	}
+	a.RegisterNode(node)
+	return a
+}
+
+func TestQuotaWithOnlyPriorityCeilingAllowsGPUs(t *testing.T) {
+	a := newTestAllocator(2)
+	ceiling := 1
+	a.SetQuota(&Quota{UserID: "alice", MaxPriority: &ceiling})
+
+	if _, err := a.Allocate("job-1", "alice", ResourceRequest{GPUs: 2, MemoryGB: 40}); err != nil {
+		t.Fatalf("Allocate with priority-only quota: %v", err)
+	}
+}
+
+func TestQuotaLimitsGPUs(t *testing.T) {
+	a := newTestAllocator(4)
+	a.SetQuota(&Quota{UserID: "alice", MaxGPUs: 2})
+
+	if _, err := a.Allocate("job-1", "alice", ResourceRequest{GPUs: 2, MemoryGB: 40}); err != nil {
+		t.Fatalf("first Allocate: %v", err)
+	}
+	_, err := a.Allocate("job-2", "alice", ResourceRequest{GPUs: 1, MemoryGB: 20})
+	var allocErr *AllocationError
+	if !errors.As(err, &allocErr) || allocErr.Reason != ReasonQuotaExceeded {
+		t.Fatalf("second Allocate error = %v, want %s", err, ReasonQuotaExceeded)
+	}
+}
+
+func TestReleaseReturnsQuota(t *testing.T) {
+	a := newTestAllocator(2)
+	a.SetQuota(&Quota{UserID: "alice", MaxGPUs: 2, MaxMemoryGB: 100})
+
+	for i := 0; i < 5; i++ {
+		alloc, err := a.Allocate("job", "alice", ResourceRequest{GPUs: 2, MemoryGB: 100})
+		if err != nil {
+			t.Fatalf("Allocate #%d: %v", i, err)
+		}
+		if err := a.Release(alloc.ID); err != nil {
+			t.Fatalf("Release #%d: %v", i, err)
+		}
+	}
+
+	q := a.quotas["alice"]
+	if q.UsedGPUs != 0 || q.UsedMemoryGB != 0 {
+		t.Errorf("usage after release = %d GPUs, %dGB; want 0, 0", q.UsedGPUs, q.UsedMemoryGB)
+	}
+}
+
+func TestAllocateFailureReasons(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(a *GPUAllocator)
+		req   ResourceRequest
+		want  FailureReason
+	}{
+		{
+			name:  "quota exceeded",
+			setup: func(a *GPUAllocator) { a.SetQuota(&Quota{UserID: "alice", MaxGPUs: 1}) },
+			req:   ResourceRequest{GPUs: 2, MemoryGB: 40},
+			want:  ReasonQuotaExceeded,
+		},
+		{
+			name: "no matching type",
+			req:  ResourceRequest{GPUs: 1, GPUType: GPUH100, MemoryGB: 40},
+			want: ReasonNoMatchingType,
+		},
+		{
+			name: "too many GPUs of any type",
+			req:  ResourceRequest{GPUs: 3, MemoryGB: 40},
+			want: ReasonNoMatchingType,
+		},
+		{
+			name: "insufficient memory",
+			req:  ResourceRequest{GPUs: 1, MemoryGB: 2048},
+			want: ReasonInsufficientMemory,
+		},
+		{
+			name: "no capacity",
+			setup: func(a *GPUAllocator) {
+				if _, err := a.Allocate("job-0", "bob", ResourceRequest{GPUs: 2, MemoryGB: 40}); err != nil {
+					t.Fatalf("seed Allocate: %v", err)
+				}
+			},
+			req:  ResourceRequest{GPUs: 1, MemoryGB: 40},
+			want: ReasonNoCapacity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAllocator(2)
+			if tt.setup != nil {
+				tt.setup(a)
+			}
+
+			_, err := a.Allocate("job-1", "alice", tt.req)
+			var allocErr *AllocationError
+			if !errors.As(err, &allocErr) || allocErr.Reason != tt.want {
+				t.Fatalf("Allocate error = %v, want %s", err, tt.want)
+			}
+			if _, err := a.CanAllocate("alice", tt.req); !errors.As(err, &allocErr) || allocErr.Reason != tt.want {
+				t.Errorf("CanAllocate error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFeasible(t *testing.T) {
+	tests := []struct {
+		name string
+		req  ResourceRequest
+		want FailureReason // empty means feasible
+	}{
+		{name: "fits one GPU", req: ResourceRequest{GPUs: 1, MemoryGB: 80}},
+		{name: "split across GPUs", req: ResourceRequest{GPUs: 2, MemoryGB: 160}},
+		{name: "over one GPU", req: ResourceRequest{GPUs: 1, MemoryGB: 81}, want: ReasonInsufficientMemory},
+		{name: "rounds up per GPU", req: ResourceRequest{GPUs: 2, MemoryGB: 161}, want: ReasonInsufficientMemory},
+		{name: "no memory requested", req: ResourceRequest{GPUs: 1}},
+		{name: "type filter", req: ResourceRequest{GPUs: 1, GPUType: GPUA100, MemoryGB: 100}, want: ReasonInsufficientMemory},
+	}
+
+	a := newTestAllocator(2)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.CheckFeasible(tt.req)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("CheckFeasible(%+v) = %v, want nil", tt.req, err)
+				}
+				return
+			}
+			var allocErr *AllocationError
+			if !errors.As(err, &allocErr) || allocErr.Reason != tt.want {
+				t.Fatalf("CheckFeasible(%+v) = %v, want %s", tt.req, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceRequestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   ResourceRequest
+		valid bool
+	}{
+		{"GPU job", ResourceRequest{GPUs: 2, GPUType: GPUH100, MemoryGB: 80, CPUs: 8}, true},
+		{"CPU only", ResourceRequest{CPUs: 4}, true},
+		{"any GPU type", ResourceRequest{GPUs: 1, MemoryGB: 16}, true},
+		{"pinned region", ResourceRequest{GPUs: 1, MemoryGB: 16, Region: "eu-west", RequireRegion: true}, true},
+		{"negative GPUs", ResourceRequest{GPUs: -1, MemoryGB: 16}, false},
+		{"negative CPUs", ResourceRequest{GPUs: 1, MemoryGB: 16, CPUs: -2}, false},
+		{"negative memory", ResourceRequest{MemoryGB: -8}, false},
+		{"GPUs without memory", ResourceRequest{GPUs: 1}, false},
+		{"negative max wait", ResourceRequest{GPUs: 1, MemoryGB: 16, MaxWaitSecs: -1}, false},
+		{"unknown GPU type", ResourceRequest{GPUs: 1, GPUType: "V100", MemoryGB: 16}, false},
+		{"required region missing", ResourceRequest{GPUs: 1, MemoryGB: 16, RequireRegion: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.valid && err != nil {
+				t.Fatalf("Validate() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidRequest) {
+				t.Fatalf("Validate() = %v, want ErrInvalidRequest", err)
+			}
+		})
+	}
+}
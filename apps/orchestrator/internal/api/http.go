@@ -3,9 +3,16 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"openlora/orchestrator/internal/allocator"
+	"openlora/orchestrator/internal/logs"
 	"openlora/orchestrator/internal/scheduler"
 )
 
@@ -13,6 +20,7 @@ import (
 type HTTPServer struct {
 	scheduler *scheduler.Scheduler
 	allocator *allocator.GPUAllocator
+	logs      *logs.Relay
 	mux       *http.ServeMux
 }
 
@@ -21,6 +29,7 @@ func NewHTTPServer(sched *scheduler.Scheduler, alloc *allocator.GPUAllocator) *H
 	s := &HTTPServer{
 		scheduler: sched,
 		allocator: alloc,
+		logs:      logs.NewRelay(logs.DefaultCapacity),
 		mux:       http.NewServeMux(),
 	}
 	s.setupRoutes()
@@ -31,10 +40,18 @@ func (s *HTTPServer) setupRoutes() {
 	s.mux.HandleFunc("/", s.handleRoot)
 	s.mux.HandleFunc("/health", s.handleHealth)
 	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
 	s.mux.HandleFunc("/jobs", s.handleJobs)
+	s.mux.HandleFunc("/jobs/", s.handleJobByID)
 	s.mux.HandleFunc("/jobs/submit", s.handleSubmitJob)
+	s.mux.HandleFunc("/jobs/preempt", s.handlePreemptJob)
+	s.mux.HandleFunc("/jobs/events", s.handleJobEvents)
+	s.mux.HandleFunc("/scheduler/simulate", s.handleSimulate)
+	s.mux.HandleFunc("/usage", s.handleUsage)
 	s.mux.HandleFunc("/nodes", s.handleNodes)
 	s.mux.HandleFunc("/nodes/register", s.handleRegisterNode)
+	s.mux.HandleFunc("/nodes/detail", s.handleNodeDetail)
+	s.mux.HandleFunc("/allocations/check", s.handleCheckAllocation)
 }
 
 func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -46,6 +63,11 @@ func (s *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+func (s *HTTPServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(s.scheduler.PrometheusExport()))
+}
+
 func (s *HTTPServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	status := s.allocator.GetClusterStatus()
@@ -56,6 +78,10 @@ func (s *HTTPServer) handleJobs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method == http.MethodGet {
+		if nodeID := r.URL.Query().Get("node_id"); nodeID != "" {
+			json.NewEncoder(w).Encode(s.scheduler.ListJobsByNode(nodeID))
+			return
+		}
 		state := scheduler.JobState(r.URL.Query().Get("state"))
 		jobs := s.scheduler.ListJobs(state)
 		json.NewEncoder(w).Encode(jobs)
@@ -78,6 +104,18 @@ func (s *HTTPServer) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.scheduler.Submit(&job); err != nil {
+		if errors.Is(err, allocator.ErrInvalidRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, scheduler.ErrPriorityAboveCeiling) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, scheduler.ErrUnsatisfiable) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -86,12 +124,272 @@ func (s *HTTPServer) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"job_id": job.ID})
 }
 
+func (s *HTTPServer) handlePreemptJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		JobID  string `json:"job_id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.scheduler.Preempt(req.JobID, req.Reason); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "preempted", "job_id": req.JobID})
+}
+
+func (s *HTTPServer) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		http.Error(w, "job_id required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduler.JobEvents(jobID))
+}
+
+func (s *HTTPServer) handleJobByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/jobs/"):], "/"), "/")
+	if len(parts) != 2 || parts[1] != "logs" {
+		http.NotFound(w, r)
+		return
+	}
+
+	jobID := parts[0]
+	if _, err := s.scheduler.GetJob(jobID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handleAppendLogs(w, r, jobID)
+	case http.MethodGet:
+		s.handleTailLogs(w, r, jobID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// maxLogChunkBytes bounds a single posted log chunk.
+const maxLogChunkBytes = 1 << 20
+
+func (s *HTTPServer) handleAppendLogs(w http.ResponseWriter, r *http.Request, jobID string) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxLogChunkBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "empty log chunk", http.StatusBadRequest)
+		return
+	}
+
+	chunk := s.logs.Append(jobID, string(data))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "seq": chunk.Seq})
+}
+
+// DefaultLogRetention is how long a finished job's logs stay available
+// after its last output.
+const DefaultLogRetention = 10 * time.Minute
+
+// PruneLogs drops the buffered logs of jobs that have finished, or are
+// unknown to the scheduler, once they have been quiet for retention.
+func (s *HTTPServer) PruneLogs(retention time.Duration) int {
+	return s.logs.Prune(retention, func(jobID string) bool {
+		job, err := s.scheduler.GetJob(jobID)
+		if err != nil {
+			return true
+		}
+		switch job.State {
+		case scheduler.JobCompleted, scheduler.JobFailed, scheduler.JobCancelled:
+			return true
+		}
+		return false
+	})
+}
+
+// logHeartbeat keeps idle SSE connections open through proxies.
+const logHeartbeat = 15 * time.Second
+
+func (s *HTTPServer) handleTailLogs(w http.ResponseWriter, r *http.Request, jobID string) {
+	var after uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		after, _ = strconv.ParseUint(v, 10, 64)
+	}
+	if v := r.URL.Query().Get("after"); v != "" {
+		after, _ = strconv.ParseUint(v, 10, 64)
+	}
+
+	if r.URL.Query().Get("follow") != "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.logs.Since(jobID, after))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before the first read so no chunk slips between them
+	notify, cancel := s.logs.Subscribe(jobID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(logHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		for _, chunk := range s.logs.Since(jobID, after) {
+			writeLogEvent(w, chunk)
+			after = chunk.Seq
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-notify:
+		case <-heartbeat.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		}
+	}
+}
+
+// writeLogEvent writes a chunk as one SSE event, one data line per log line.
+func writeLogEvent(w io.Writer, chunk logs.Chunk) {
+	fmt.Fprintf(w, "id: %d\n", chunk.Seq)
+	for _, line := range strings.Split(strings.TrimSuffix(chunk.Data, "\n"), "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	io.WriteString(w, "\n")
+}
+
+func (s *HTTPServer) handleSimulate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduler.Simulate())
+}
+
+func (s *HTTPServer) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	from, err := parseTime(q.Get("from"))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTime(q.Get("to"))
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduler.Usage(q.Get("user_id"), from, to))
+}
+
+// parseTime accepts RFC3339 timestamps or Unix seconds and returns UTC;
+// empty means unbounded.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t.UTC(), err
+}
+
 func (s *HTTPServer) handleNodes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	status := s.allocator.GetClusterStatus()
 	json.NewEncoder(w).Encode(status)
 }
 
+func (s *HTTPServer) handleNodeDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cluster": s.allocator.GetClusterStatus(),
+		"nodes":   s.allocator.NodeDetails(),
+	})
+}
+
+// handleCheckAllocation is a dry run of allocation: it reports the node a
+// request would land on, or why it can't be placed, without reserving.
+func (s *HTTPServer) handleCheckAllocation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+		allocator.ResourceRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := req.ResourceRequest.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]interface{}{"satisfiable": true}
+	placement, err := s.allocator.CanAllocate(req.UserID, req.ResourceRequest)
+	var allocErr *allocator.AllocationError
+	switch {
+	case errors.As(err, &allocErr):
+		resp["satisfiable"] = false
+		resp["reason"] = allocErr.Reason
+		resp["message"] = allocErr.Message
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	default:
+		resp["node_id"] = placement.NodeID
+		resp["gpu_ids"] = placement.GPUIDs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
 func (s *HTTPServer) handleRegisterNode(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+	"openlora/orchestrator/internal/allocator"
+	"openlora/orchestrator/internal/logs"
+	"openlora/orchestrator/internal/scheduler"
+)
+
+func TestParseTimeReturnsUTC(t *testing.T) {
+	want := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	for _, v := range []string{"1700000000", "2023-11-14T22:13:20Z", "2023-11-15T00:13:20+02:00"} {
+		got, err := parseTime(v)
+		if err != nil {
+			t.Fatalf("parseTime(%q): %v", v, err)
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Fatalf("parseTime(%q) = %v, want %v", v, got, want)
+		}
+	}
+	if got, err := parseTime(""); err != nil || !got.IsZero() {
+		t.Fatalf("parseTime(\"\") = %v, %v; want zero", got, err)
+	}
+}
+
+// newLogServer starts an HTTP server with one submitted job, job-1.
+func newLogServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	alloc := allocator.NewGPUAllocator()
+	sched := scheduler.NewScheduler(alloc)
+	t.Cleanup(sched.Stop)
+	if err := sched.Submit(&scheduler.Job{ID: "job-1", UserID: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewHTTPServer(sched, alloc))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// postLog posts one log chunk for job-1 and returns its sequence number.
+func postLog(t *testing.T, srv *httptest.Server, data string) uint64 {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/jobs/job-1/logs", "text/plain", strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST logs status = %d", resp.StatusCode)
+	}
+	var body struct {
+		Seq uint64 `json:"seq"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Seq
+}
+
+// readEvent reads one SSE event, skipping keep-alive comments, and returns
+// its id and data lines.
+func readEvent(t *testing.T, r *bufio.Reader) (string, []string) {
+	t.Helper()
+	var id string
+	var data []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && id != "":
+			return id, data
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+func TestJobLogsPostAndFollow(t *testing.T) {
+	srv := newLogServer(t)
+
+	if seq := postLog(t, srv, "step 1\nstep 2\n"); seq != 1 {
+		t.Fatalf("first chunk seq = %d, want 1", seq)
+	}
+	postLog(t, srv, "step 3\n")
+
+	resp, err := http.Get(srv.URL + "/jobs/job-1/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []logs.Chunk
+	err = json.NewDecoder(resp.Body).Decode(&chunks)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || chunks[0].Data != "step 1\nstep 2\n" || chunks[1].Seq != 2 {
+		t.Fatalf("buffered chunks = %+v, want the two posted", chunks)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/jobs/job-1/logs?follow=true&after=1", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+
+	// Backlog after the requested chunk, then live output
+	if id, data := readEvent(t, events); id != "2" || len(data) != 1 || data[0] != "step 3" {
+		t.Fatalf("backlog event = %s %q, want 2 [step 3]", id, data)
+	}
+	postLog(t, srv, "step 4\nstep 5\n")
+	if id, data := readEvent(t, events); id != "3" || len(data) != 2 || data[0] != "step 4" || data[1] != "step 5" {
+		t.Fatalf("live event = %s %q, want 3 [step 4 step 5]", id, data)
+	}
+}
+
+func TestJobLogsUnknownJob(t *testing.T) {
+	srv := newLogServer(t)
+
+	resp, err := http.Post(srv.URL+"/jobs/missing/logs", "text/plain", strings.NewReader("hi\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestJobsFilteredByNode(t *testing.T) {
+	alloc := allocator.NewGPUAllocator()
+	for _, id := range []string{"node-a", "node-b"} {
+		alloc.RegisterNode(&allocator.Node{ID: id, GPUs: []*allocator.GPU{
+			{ID: id + "-gpu-0", NodeID: id, Type: allocator.GPUA100, MemoryGB: 80},
+		}})
+	}
+	sched := scheduler.NewScheduler(alloc)
+	t.Cleanup(sched.Stop)
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := sched.Submit(&scheduler.Job{ID: id, Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(NewHTTPServer(sched, alloc))
+	t.Cleanup(srv.Close)
+
+	jobsOn := func(nodeID string) []scheduler.Job {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/jobs?node_id=" + nodeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var jobs []scheduler.Job
+		if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
+			t.Fatal(err)
+		}
+		return jobs
+	}
+
+	// The scheduler loop places the jobs within a tick
+	deadline := time.Now().Add(5 * time.Second)
+	for len(jobsOn("node-b")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("jobs were never scheduled")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	for nodeID, want := range map[string]string{"node-a": "job-1", "node-b": "job-2"} {
+		jobs := jobsOn(nodeID)
+		if len(jobs) != 1 || jobs[0].ID != want || jobs[0].Allocation.NodeID != nodeID {
+			t.Errorf("jobs on %s = %+v, want only %s", nodeID, jobs, want)
+		}
+	}
+	if jobs := jobsOn("node-c"); len(jobs) != 0 {
+		t.Errorf("jobs on unknown node = %+v, want none", jobs)
+	}
+}
+
+func TestNodeDetailAddsUpToClusterTotals(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	alloc := allocator.NewGPUAllocator()
+	alloc.SetClock(clk)
+
+	nodes := map[string][]allocator.GPUType{
+		"node-a": {allocator.GPUA100, allocator.GPUA100, allocator.GPUA100, allocator.GPUA100},
+		"node-b": {allocator.GPUH100, allocator.GPUH100},
+		"node-c": {allocator.GPUL40S, allocator.GPUA100},
+	}
+	for _, id := range []string{"node-a", "node-b", "node-c"} {
+		node := &allocator.Node{ID: id, TotalMem: 512, TotalCPUs: 32}
+		for i, typ := range nodes[id] {
+			node.GPUs = append(node.GPUs, &allocator.GPU{ID: fmt.Sprintf("%s-gpu-%d", id, i), NodeID: id, Type: typ, MemoryGB: 80})
+		}
+		alloc.RegisterNode(node)
+		clk.Advance(time.Minute)
+		if id == "node-c" {
+			node.Healthy = false
+		}
+	}
+	for _, req := range []allocator.ResourceRequest{
+		{GPUs: 3, GPUType: allocator.GPUA100, MemoryGB: 100, CPUs: 8},
+		{GPUs: 1, GPUType: allocator.GPUH100, MemoryGB: 40, CPUs: 4},
+	} {
+		if _, err := alloc.Allocate("job", "alice", req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sched := scheduler.NewScheduler(alloc)
+	t.Cleanup(sched.Stop)
+	srv := httptest.NewServer(NewHTTPServer(sched, alloc))
+	t.Cleanup(srv.Close)
+	resp, err := http.Get(srv.URL + "/nodes/detail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Cluster struct {
+			TotalNodes   int `json:"total_nodes"`
+			HealthyNodes int `json:"healthy_nodes"`
+			TotalGPUs    int `json:"total_gpus"`
+			UsedGPUs     int `json:"used_gpus"`
+		} `json:"cluster"`
+		Nodes []allocator.NodeDetail `json:"nodes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	healthy, total, allocated, free := 0, 0, 0, 0
+	for _, n := range body.Nodes {
+		if n.Healthy {
+			healthy++
+		}
+		byType := 0
+		for _, c := range n.GPUsByType {
+			byType += c
+		}
+		if byType != n.TotalGPUs || len(n.GPUs) != n.TotalGPUs || n.AllocatedGPUs+n.FreeGPUs != n.TotalGPUs {
+			t.Errorf("%s counts disagree: %+v", n.ID, n)
+		}
+		total += n.TotalGPUs
+		allocated += n.AllocatedGPUs
+		free += n.FreeGPUs
+	}
+	c := body.Cluster
+	if len(body.Nodes) != c.TotalNodes || healthy != c.HealthyNodes || total != c.TotalGPUs || allocated != c.UsedGPUs || free != c.TotalGPUs-c.UsedGPUs {
+		t.Fatalf("per-node sums (%d nodes, %d healthy, %d GPUs, %d allocated, %d free) don't match cluster %+v",
+			len(body.Nodes), healthy, total, allocated, free, c)
+	}
+	if c.TotalNodes != 3 || c.HealthyNodes != 2 || c.TotalGPUs != 8 || c.UsedGPUs != 4 {
+		t.Fatalf("cluster = %+v, want 3 nodes, 2 healthy, 8 GPUs, 4 used", c)
+	}
+
+	// Spot-check one node: sorted first, most of its A100s taken
+	a := body.Nodes[0]
+	if a.ID != "node-a" || a.AllocatedGPUs != 3 || a.FreeGPUs != 1 || a.GPUsByType[allocator.GPUA100] != 4 ||
+		a.UsedMemoryGB != 100 || a.UsedCPUs != 8 || !a.LastHeartbeat.Equal(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("node-a detail = %+v", a)
+	}
+	if n := body.Nodes[2]; n.ID != "node-c" || n.Healthy {
+		t.Errorf("node-c detail = %+v, want unhealthy", n)
+	}
+}
+
+func TestCheckAllocation(t *testing.T) {
+	alloc := allocator.NewGPUAllocator()
+	alloc.RegisterNode(&allocator.Node{ID: "node-a", TotalMem: 256, TotalCPUs: 32, GPUs: []*allocator.GPU{
+		{ID: "a-gpu-0", NodeID: "node-a", Type: allocator.GPUA100, MemoryGB: 80},
+		{ID: "a-gpu-1", NodeID: "node-a", Type: allocator.GPUA100, MemoryGB: 80},
+	}})
+	alloc.RegisterNode(&allocator.Node{ID: "node-b", TotalMem: 512, TotalCPUs: 64, GPUs: []*allocator.GPU{
+		{ID: "b-gpu-0", NodeID: "node-b", Type: allocator.GPUH100, MemoryGB: 80},
+		{ID: "b-gpu-1", NodeID: "node-b", Type: allocator.GPUH100, MemoryGB: 80},
+		{ID: "b-gpu-2", NodeID: "node-b", Type: allocator.GPUH100, MemoryGB: 80},
+		{ID: "b-gpu-3", NodeID: "node-b", Type: allocator.GPUH100, MemoryGB: 80},
+	}})
+	alloc.SetQuota(&allocator.Quota{UserID: "bob", MaxGPUs: 1})
+	sched := scheduler.NewScheduler(alloc)
+	t.Cleanup(sched.Stop)
+	srv := httptest.NewServer(NewHTTPServer(sched, alloc))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		node   string
+		reason allocator.FailureReason
+	}{
+		{"fits A100 node", `{"user_id": "alice", "gpus": 2, "gpu_type": "A100", "memory_gb": 100}`, http.StatusOK, "node-a", ""},
+		{"fits H100 node", `{"user_id": "alice", "gpus": 4, "gpu_type": "H100", "memory_gb": 200}`, http.StatusOK, "node-b", ""},
+		{"more GPUs than any node", `{"user_id": "alice", "gpus": 8, "memory_gb": 100}`, http.StatusOK, "", allocator.ReasonNoMatchingType},
+		{"missing GPU type", `{"user_id": "alice", "gpus": 1, "gpu_type": "L40S", "memory_gb": 40}`, http.StatusOK, "", allocator.ReasonNoMatchingType},
+		{"over quota", `{"user_id": "bob", "gpus": 2, "memory_gb": 40}`, http.StatusOK, "", allocator.ReasonQuotaExceeded},
+		{"invalid request", `{"user_id": "alice", "gpus": -1}`, http.StatusBadRequest, "", ""},
+	}
+	check := func(t *testing.T, body string) *http.Response {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/allocations/check", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := check(t, tt.body)
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var got struct {
+				Satisfiable bool                    `json:"satisfiable"`
+				NodeID      string                  `json:"node_id"`
+				GPUIDs      []string                `json:"gpu_ids"`
+				Reason      allocator.FailureReason `json:"reason"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Satisfiable != (tt.node != "") || got.NodeID != tt.node || got.Reason != tt.reason {
+				t.Fatalf("check = %+v, want node %q reason %q", got, tt.node, tt.reason)
+			}
+			for _, id := range got.GPUIDs {
+				if !strings.HasPrefix(id, strings.TrimPrefix(tt.node, "node-")+"-gpu-") {
+					t.Errorf("GPU %s is not on %s", id, tt.node)
+				}
+			}
+		})
+	}
+	// A dry run reserves nothing
+	if used := alloc.GetClusterStatus()["used_gpus"]; used != 0 {
+		t.Fatalf("used GPUs after checks = %v, want 0", used)
+	}
+
+	// Once node-a is busy the same A100 request is blocked on capacity
+	if _, err := alloc.Allocate("job-1", "alice", allocator.ResourceRequest{GPUs: 2, GPUType: allocator.GPUA100, MemoryGB: 100}); err != nil {
+		t.Fatal(err)
+	}
+	resp := check(t, `{"user_id": "alice", "gpus": 2, "gpu_type": "A100", "memory_gb": 100}`)
+	defer resp.Body.Close()
+	var busy map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&busy); err != nil {
+		t.Fatal(err)
+	}
+	if busy["satisfiable"] != false || busy["reason"] != string(allocator.ReasonNoCapacity) {
+		t.Fatalf("check on busy cluster = %v, want no_capacity", busy)
+	}
+	get, err := http.Get(srv.URL + "/allocations/check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get.Body.Close()
+	if get.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET = %d, want 405", get.StatusCode)
+	}
+}
+
+func TestSubmitJobRejectsInvalidResources(t *testing.T) {
+	alloc := allocator.NewGPUAllocator()
+	alloc.RegisterNode(&allocator.Node{ID: "node-a", TotalMem: 256, TotalCPUs: 32, GPUs: []*allocator.GPU{
+		{ID: "a-gpu-0", NodeID: "node-a", Type: allocator.GPUA100, MemoryGB: 80},
+	}})
+	sched := scheduler.NewScheduler(alloc)
+	t.Cleanup(sched.Stop)
+	srv := httptest.NewServer(NewHTTPServer(sched, alloc))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name      string
+		resources string
+		status    int
+	}{
+		{"valid", `{"gpus": 1, "gpu_type": "A100", "memory_gb": 40, "cpus": 4}`, http.StatusOK},
+		{"negative gpus", `{"gpus": -1, "memory_gb": 40}`, http.StatusBadRequest},
+		{"negative cpus", `{"gpus": 1, "memory_gb": 40, "cpus": -4}`, http.StatusBadRequest},
+		{"negative memory", `{"memory_gb": -1}`, http.StatusBadRequest},
+		{"zero memory with gpus", `{"gpus": 1, "memory_gb": 0}`, http.StatusBadRequest},
+		{"unknown gpu type", `{"gpus": 1, "gpu_type": "RTX4090", "memory_gb": 40}`, http.StatusBadRequest},
+		{"negative max wait", `{"gpus": 1, "memory_gb": 40, "max_wait_secs": -5}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"name": "train", "user_id": "alice", "resources": ` + tt.resources + `}`
+			resp, err := http.Post(srv.URL+"/jobs/submit", "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+
+	// Only the valid submission was queued
+	if jobs := sched.ListJobs(""); len(jobs) != 1 {
+		t.Fatalf("%d jobs accepted, want 1", len(jobs))
+	}
+}
@@ -0,0 +1,167 @@
+// Package logs buffers job log output so clients can tail running jobs.
+package logs
+
+import (
+	"sync"
+	"time"
+
+	"openlora/core/clock"
+)
+
+// DefaultCapacity is the number of chunks kept per job.
+const DefaultCapacity = 1000
+
+// Chunk is a piece of log output. Seq increases by one per chunk within a
+// job and is never reused, so clients can resume after a given chunk.
+type Chunk struct {
+	Seq       uint64    `json:"seq"`
+	Data      string    `json:"data"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type buffer struct {
+	chunks    []Chunk
+	nextSeq   uint64
+	subs      map[chan struct{}]struct{}
+	lastWrite time.Time
+}
+
+// Relay holds a bounded ring of chunks per job; the oldest chunks are
+// dropped once a job exceeds its capacity. Whole buffers are only dropped
+// by Prune.
+type Relay struct {
+	mu       sync.Mutex
+	buffers  map[string]*buffer
+	capacity int
+	clock    clock.Clock
+}
+
+// NewRelay creates a relay keeping up to capacity chunks per job.
+func NewRelay(capacity int) *Relay {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Relay{
+		buffers:  make(map[string]*buffer),
+		capacity: capacity,
+		clock:    clock.Real{},
+	}
+}
+
+// SetClock replaces the clock used to stamp chunks and age buffers.
+func (r *Relay) SetClock(c clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = c
+}
+
+func (r *Relay) buffer(jobID string) *buffer {
+	b, ok := r.buffers[jobID]
+	if !ok {
+		b = &buffer{nextSeq: 1, subs: make(map[chan struct{}]struct{}), lastWrite: r.clock.Now()}
+		r.buffers[jobID] = b
+	}
+	return b
+}
+
+// Prune drops the buffer of every job that finished reports done, that
+// has had no output for at least idle and that nobody is following. It
+// returns how many buffers were dropped. finished is called without the
+// relay's lock held.
+func (r *Relay) Prune(idle time.Duration, finished func(jobID string) bool) int {
+	r.mu.Lock()
+	var candidates []string
+	for jobID, b := range r.buffers {
+		if r.prunable(b, idle) {
+			candidates = append(candidates, jobID)
+		}
+	}
+	r.mu.Unlock()
+
+	var done []string
+	for _, jobID := range candidates {
+		if finished(jobID) {
+			done = append(done, jobID)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dropped := 0
+	for _, jobID := range done {
+		// Output or a follower may have arrived meanwhile
+		if b, ok := r.buffers[jobID]; ok && r.prunable(b, idle) {
+			delete(r.buffers, jobID)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// prunable reports whether b is idle and unfollowed. Callers must hold
+// r.mu.
+func (r *Relay) prunable(b *buffer, idle time.Duration) bool {
+	return len(b.subs) == 0 && r.clock.Now().Sub(b.lastWrite) >= idle
+}
+
+// Append stores a chunk for the job and wakes any followers.
+func (r *Relay) Append(jobID, data string) Chunk {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.buffer(jobID)
+	now := r.clock.Now()
+	chunk := Chunk{Seq: b.nextSeq, Data: data, Timestamp: now}
+	b.nextSeq++
+	b.lastWrite = now
+
+	b.chunks = append(b.chunks, chunk)
+	if len(b.chunks) > r.capacity {
+		b.chunks = b.chunks[len(b.chunks)-r.capacity:]
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return chunk
+}
+
+// Since returns the buffered chunks with Seq greater than after.
+func (r *Relay) Since(jobID string, after uint64) []Chunk {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buffers[jobID]
+	if !ok {
+		return []Chunk{}
+	}
+	result := make([]Chunk, 0, len(b.chunks))
+	for _, c := range b.chunks {
+		if c.Seq > after {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// Subscribe returns a channel that receives a signal whenever a chunk is
+// appended for the job. Call cancel to stop receiving.
+func (r *Relay) Subscribe(jobID string) (<-chan struct{}, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	r.buffer(jobID).subs[ch] = struct{}{}
+
+	cancel := func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if b, ok := r.buffers[jobID]; ok {
+			delete(b.subs, ch)
+		}
+	}
+	return ch, cancel
+}
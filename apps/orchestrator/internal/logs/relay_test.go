@@ -0,0 +1,78 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+)
+
+func TestPruneDropsFinishedIdleJobs(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	r := NewRelay(10)
+	r.SetClock(clk)
+
+	r.Append("done", "bye\n")
+	r.Append("running", "step 1\n")
+	r.Append("followed", "hi\n")
+	_, cancel := r.Subscribe("followed")
+
+	finished := func(jobID string) bool { return jobID != "running" }
+
+	clk.Advance(time.Minute)
+	if n := r.Prune(5*time.Minute, finished); n != 0 {
+		t.Fatalf("pruned %d buffers within the grace period", n)
+	}
+
+	clk.Advance(5 * time.Minute)
+	if n := r.Prune(5*time.Minute, finished); n != 1 {
+		t.Fatalf("pruned %d buffers, want only the finished, unfollowed one", n)
+	}
+	if got := r.Since("done", 0); len(got) != 0 {
+		t.Fatalf("finished job still has %d chunks", len(got))
+	}
+	if got := r.Since("running", 0); len(got) != 1 {
+		t.Fatalf("running job has %d chunks, want 1", len(got))
+	}
+
+	cancel()
+	if n := r.Prune(5*time.Minute, finished); n != 1 {
+		t.Fatalf("pruned %d buffers after the follower left, want 1", n)
+	}
+}
+
+func TestPruneKeepsJobsWithRecentOutput(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	r := NewRelay(10)
+	r.SetClock(clk)
+
+	r.Append("job-1", "a\n")
+	clk.Advance(10 * time.Minute)
+	r.Append("job-1", "b\n")
+	if n := r.Prune(5*time.Minute, func(string) bool { return true }); n != 0 {
+		t.Fatalf("pruned %d buffers with fresh output", n)
+	}
+}
+
+func TestAppendDropsOldestOnOverflow(t *testing.T) {
+	r := NewRelay(3)
+	for _, line := range []string{"a", "b", "c", "d", "e"} {
+		r.Append("job-1", line)
+	}
+
+	got := r.Since("job-1", 0)
+	if len(got) != 3 {
+		t.Fatalf("kept %d chunks, want 3", len(got))
+	}
+	for i, want := range []struct {
+		seq  uint64
+		data string
+	}{{3, "c"}, {4, "d"}, {5, "e"}} {
+		if got[i].Seq != want.seq || got[i].Data != want.data {
+			t.Errorf("chunk %d = %d %q, want %d %q", i, got[i].Seq, got[i].Data, want.seq, want.data)
+		}
+	}
+	if got := r.Since("job-1", 4); len(got) != 1 || got[0].Data != "e" {
+		t.Errorf("Since(4) = %+v, want only e", got)
+	}
+}
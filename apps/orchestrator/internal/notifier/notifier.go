@@ -0,0 +1,62 @@
+// Package notifier delivers job lifecycle notifications to external systems.
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Notification is the payload sent to webhook subscribers.
+type Notification struct {
+	Event     string    `json:"event"`
+	JobID     string    `json:"job_id"`
+	UserID    string    `json:"user_id,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Webhook posts notifications as JSON to a fixed URL.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a webhook notifier.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Send delivers a notification synchronously.
+func (w *Webhook) Send(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SendAsync delivers a notification in the background, logging failures.
+func (w *Webhook) SendAsync(n Notification) {
+	go func() {
+		if err := w.Send(n); err != nil {
+			slog.Warn("webhook failed", "event", n.Event, "error", err)
+		}
+	}()
+}
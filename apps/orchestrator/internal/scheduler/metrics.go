@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// waitBuckets are the upper bounds, in seconds, of the queue-wait histograms.
+var waitBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 21600, 86400}
+
+// waitHistogram accumulates durations into cumulative waitBuckets counts.
+type waitHistogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func newWaitHistogram() *waitHistogram {
+	return &waitHistogram{buckets: make([]uint64, len(waitBuckets))}
+}
+
+func (h *waitHistogram) observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	secs := d.Seconds()
+	for i, bound := range waitBuckets {
+		if secs <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+func (h *waitHistogram) write(b *strings.Builder, name, help string) {
+	b.WriteString("# HELP " + name + " " + help + "\n")
+	b.WriteString("# TYPE " + name + " histogram\n")
+	for i, bound := range waitBuckets {
+		b.WriteString(name + `_bucket{le="` + strconv.FormatFloat(bound, 'g', -1, 64) + `"} ` +
+			strconv.FormatUint(h.buckets[i], 10) + "\n")
+	}
+	b.WriteString(name + `_bucket{le="+Inf"} ` + strconv.FormatUint(h.count, 10) + "\n")
+	b.WriteString(name + "_sum " + strconv.FormatFloat(h.sum, 'g', -1, 64) + "\n")
+	b.WriteString(name + "_count " + strconv.FormatUint(h.count, 10) + "\n")
+}
+
+// PrometheusExport returns the scheduler's queue-wait histograms in the
+// Prometheus text exposition format. Time in queue is observed on every
+// allocation, measured from the job's latest enqueue; time to first
+// allocation is observed once per job, measured from submission.
+func (s *Scheduler) PrometheusExport() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var b strings.Builder
+	s.queueWait.write(&b, "openlora_scheduler_queue_wait_seconds",
+		"Time jobs spent queued before being allocated.")
+	s.firstAlloc.write(&b, "openlora_scheduler_time_to_first_allocation_seconds",
+		"Time from job submission to its first allocation.")
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+	"openlora/orchestrator/internal/allocator"
+)
+
+func TestWaitHistogramsFillAsJobsMove(t *testing.T) {
+	s := newTestScheduler(t)
+	clk := clock.NewFake(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	s.SetClock(clk)
+
+	// Both jobs need the whole node, so job-2 waits for job-1
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := s.Submit(&Job{ID: id, Resources: allocator.ResourceRequest{GPUs: 2, MemoryGB: 80}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out := s.PrometheusExport(); !strings.Contains(out, "openlora_scheduler_queue_wait_seconds_count 0\n") {
+		t.Fatalf("histogram not empty before scheduling:\n%s", out)
+	}
+
+	clk.Advance(10 * time.Second)
+	s.trySchedule() // job-1 after 10s
+
+	clk.Advance(100 * time.Second)
+	if err := s.CompleteJob("job-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	s.trySchedule() // job-2 after 110s
+
+	// A preempted job is requeued: its second wait counts towards time in
+	// queue but not towards time to first allocation
+	clk.Advance(time.Minute)
+	if err := s.Preempt("job-2", "maintenance"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(20 * time.Second)
+	s.trySchedule() // job-2 again after 20s
+
+	out := s.PrometheusExport()
+	for _, line := range []string{
+		"# TYPE openlora_scheduler_queue_wait_seconds histogram",
+		`openlora_scheduler_queue_wait_seconds_bucket{le="5"} 0`,
+		`openlora_scheduler_queue_wait_seconds_bucket{le="15"} 1`,
+		`openlora_scheduler_queue_wait_seconds_bucket{le="30"} 2`,
+		`openlora_scheduler_queue_wait_seconds_bucket{le="60"} 2`,
+		`openlora_scheduler_queue_wait_seconds_bucket{le="300"} 3`,
+		`openlora_scheduler_queue_wait_seconds_bucket{le="+Inf"} 3`,
+		"openlora_scheduler_queue_wait_seconds_sum 140",
+		"openlora_scheduler_queue_wait_seconds_count 3",
+		"# TYPE openlora_scheduler_time_to_first_allocation_seconds histogram",
+		`openlora_scheduler_time_to_first_allocation_seconds_bucket{le="15"} 1`,
+		`openlora_scheduler_time_to_first_allocation_seconds_bucket{le="60"} 1`,
+		`openlora_scheduler_time_to_first_allocation_seconds_bucket{le="300"} 2`,
+		"openlora_scheduler_time_to_first_allocation_seconds_sum 120",
+		"openlora_scheduler_time_to_first_allocation_seconds_count 2",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("export missing %q", line)
+		}
+	}
+	if t.Failed() {
+		t.Logf("export:\n%s", out)
+	}
+
+	job, _ := s.GetJob("job-2")
+	wantScheduled := time.Date(2024, 3, 1, 9, 1, 50, 0, time.UTC)
+	if job.ScheduledAt == nil || !job.ScheduledAt.Equal(wantScheduled) {
+		t.Errorf("ScheduledAt = %v, want first allocation at %v", job.ScheduledAt, wantScheduled)
+	}
+	if !job.QueuedAt.Equal(wantScheduled.Add(time.Minute)) {
+		t.Errorf("QueuedAt = %v, want the requeue time", job.QueuedAt)
+	}
+}
+
+func TestWaitHistogramClampsNegative(t *testing.T) {
+	h := newWaitHistogram()
+	h.observe(-time.Second)
+	if h.sum != 0 || h.count != 1 || h.buckets[0] != 1 {
+		t.Fatalf("histogram after negative duration = %+v", h)
+	}
+}
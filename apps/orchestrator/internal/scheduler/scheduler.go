@@ -3,10 +3,15 @@ package scheduler
 
 import (
 	"container/heap"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"openlora/core/clock"
 	"openlora/orchestrator/internal/allocator"
 )
 
@@ -48,10 +53,67 @@ type Job struct {
 	RetryCount  int                       `json:"retry_count"`
 	MaxRetries  int                       `json:"max_retries"`
 	CreatedAt   time.Time                 `json:"created_at"`
+	QueuedAt    time.Time                 `json:"queued_at"`              // latest time the job entered the queue
+	ScheduledAt *time.Time                `json:"scheduled_at,omitempty"` // first allocation
 	StartedAt   *time.Time                `json:"started_at,omitempty"`
 	CompletedAt *time.Time                `json:"completed_at,omitempty"`
 	Error       string                    `json:"error,omitempty"`
+	GPUHours    float64                   `json:"gpu_hours,omitempty"` // consumed across all allocations
 	index       int                       // heap index
+	seq         uint64                    // submission order, breaks priority ties
+}
+
+// snapshot returns a deep copy of the job that is safe to read after s.mu
+// is released. Heap bookkeeping is reset so the copy is never mistaken for
+// a queued entry.
+func (j *Job) snapshot() *Job {
+	c := *j
+	c.index = -1
+	c.Config = copyConfig(j.Config)
+	if j.Allocation != nil {
+		alloc := *j.Allocation
+		alloc.GPUIDs = append([]string(nil), j.Allocation.GPUIDs...)
+		c.Allocation = &alloc
+	}
+	c.StartedAt = copyTime(j.StartedAt)
+	c.CompletedAt = copyTime(j.CompletedAt)
+	c.ScheduledAt = copyTime(j.ScheduledAt)
+	return &c
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// copyConfig deep-copies the JSON-shaped values a job config can hold.
+func copyConfig(cfg map[string]interface{}) map[string]interface{} {
+	if cfg == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		c[k] = copyConfigValue(v)
+	}
+	return c
+}
+
+func copyConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyConfig(v)
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, elem := range v {
+			c[i] = copyConfigValue(elem)
+		}
+		return c
+	default:
+		return v
+	}
 }
 
 // JobQueue is a priority queue for jobs.
@@ -60,7 +122,10 @@ type JobQueue []*Job
 func (pq JobQueue) Len() int { return len(pq) }
 
 func (pq JobQueue) Less(i, j int) bool {
-	return pq[i].Priority > pq[j].Priority
+	if pq[i].Priority != pq[j].Priority {
+		return pq[i].Priority > pq[j].Priority
+	}
+	return pq[i].seq < pq[j].seq
 }
 
 func (pq JobQueue) Swap(i, j int) {
@@ -86,41 +151,142 @@ func (pq *JobQueue) Pop() interface{} {
 	return job
 }
 
+// Peek returns the highest-priority job without removing it.
+func (pq JobQueue) Peek() *Job {
+	if len(pq) == 0 {
+		return nil
+	}
+	return pq[0]
+}
+
+// JobEvent records a notable transition in a job's lifecycle.
+type JobEvent struct {
+	JobID     string    `json:"job_id"`
+	Type      string    `json:"type"`
+	State     JobState  `json:"state"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UsageRecord is the GPU time consumed by one allocation of a job, from
+// allocation until release.
+type UsageRecord struct {
+	JobID    string    `json:"job_id"`
+	UserID   string    `json:"user_id"`
+	GPUs     int       `json:"gpus"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	GPUHours float64   `json:"gpu_hours"`
+}
+
+// UsageSummary totals a user's GPU usage over a time range.
+type UsageSummary struct {
+	UserID   string        `json:"user_id"`
+	Jobs     int           `json:"jobs"`
+	GPUHours float64       `json:"gpu_hours"`
+	Records  []UsageRecord `json:"records"`
+}
+
+// PreemptHook is invoked with a snapshot of a job after it has been preempted.
+type PreemptHook func(job Job, reason string)
+
 // Scheduler manages job scheduling and execution.
 type Scheduler struct {
-	mu        sync.RWMutex
-	queue     JobQueue
-	jobs      map[string]*Job
-	allocator *allocator.GPUAllocator
-	stopCh    chan struct{}
+	mu           sync.RWMutex
+	queue        JobQueue
+	jobs         map[string]*Job
+	events       map[string][]JobEvent
+	preemptHooks []PreemptHook
+	usage        []UsageRecord
+	rejectAbove  bool // reject rather than clamp over-ceiling priorities
+	allocator    *allocator.GPUAllocator
+	clock        clock.Clock
+	stopCh       chan struct{}
+	seq          uint64
+	queueWait    *waitHistogram
+	firstAlloc   *waitHistogram
 }
 
 // NewScheduler creates a new scheduler.
 func NewScheduler(alloc *allocator.GPUAllocator) *Scheduler {
 	s := &Scheduler{
-		queue:     make(JobQueue, 0),
-		jobs:      make(map[string]*Job),
-		allocator: alloc,
-		stopCh:    make(chan struct{}),
+		queue:      make(JobQueue, 0),
+		jobs:       make(map[string]*Job),
+		events:     make(map[string][]JobEvent),
+		allocator:  alloc,
+		clock:      clock.Real{},
+		stopCh:     make(chan struct{}),
+		queueWait:  newWaitHistogram(),
+		firstAlloc: newWaitHistogram(),
 	}
 	heap.Init(&s.queue)
 	go s.runLoop()
 	return s
 }
 
-// Submit adds a job to the queue.
+// SetClock replaces the clock used for job and usage timestamps.
+func (s *Scheduler) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = c
+}
+
+// ErrPriorityAboveCeiling is returned by Submit when a job asks for more
+// priority than its owner's quota allows and rejection is enabled.
+var ErrPriorityAboveCeiling = errors.New("priority above user ceiling")
+
+// ErrUnsatisfiable is returned by Submit for jobs whose resource request
+// no node in the cluster could ever satisfy.
+var ErrUnsatisfiable = errors.New("resource request can never be satisfied")
+
+// RejectOverCeiling makes Submit fail for jobs above their owner's priority
+// ceiling instead of clamping them to it.
+func (s *Scheduler) RejectOverCeiling(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectAbove = reject
+}
+
+// Submit adds a job to the queue. Malformed resource requests fail with
+// allocator.ErrInvalidRequest.
 func (s *Scheduler) Submit(job *Job) error {
+	if err := job.Resources.Validate(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.allocator.CheckFeasible(job.Resources); err != nil {
+		var allocErr *allocator.AllocationError
+		if errors.As(err, &allocErr) {
+			return fmt.Errorf("%w: %s", ErrUnsatisfiable, allocErr.Message)
+		}
+		return err
+	}
+
+	requested := job.Priority
+	if ceiling, ok := s.allocator.PriorityCeiling(job.UserID); ok && job.Priority > ceiling {
+		if s.rejectAbove {
+			return fmt.Errorf("%w: requested %d, max %d", ErrPriorityAboveCeiling, job.Priority, ceiling)
+		}
+		job.Priority = ceiling
+	}
+
 	if job.ID == "" {
-		job.ID = generateJobID()
+		job.ID = s.generateJobID()
 	}
 	job.State = JobQueued
-	job.CreatedAt = time.Now()
+	job.CreatedAt = s.clock.Now()
 
 	s.jobs[job.ID] = job
-	heap.Push(&s.queue, job)
+	if job.Priority != requested {
+		s.recordEvent(job, "priority_clamped", fmt.Sprintf("priority %d lowered to user ceiling %d", requested, job.Priority))
+	}
+	s.seq++
+	job.seq = s.seq
+	job.index = -1
+	s.requeue(job)
 
 	return nil
 }
@@ -136,17 +302,14 @@ func (s *Scheduler) Cancel(jobID string) error {
 	}
 
 	if job.State == JobRunning {
-		// Release resources
-		if job.Allocation != nil {
-			s.allocator.Release(job.Allocation.ID)
-		}
+		s.releaseAllocation(job)
 	}
 
 	job.State = JobCancelled
 	return nil
 }
 
-// GetJob retrieves a job by ID.
+// GetJob retrieves a snapshot of a job by ID.
 func (s *Scheduler) GetJob(jobID string) (*Job, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -155,10 +318,12 @@ func (s *Scheduler) GetJob(jobID string) (*Job, error) {
 	if !ok {
 		return nil, errors.New("job not found")
 	}
-	return job, nil
+	return job.snapshot(), nil
 }
 
-// ListJobs returns all jobs matching a filter.
+// ListJobs returns snapshots of all jobs matching a filter. The snapshots
+// are taken under one lock, so they reflect a single consistent state and
+// are unaffected by later scheduling.
 func (s *Scheduler) ListJobs(state JobState) []*Job {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -166,9 +331,25 @@ func (s *Scheduler) ListJobs(state JobState) []*Job {
 	var result []*Job
 	for _, job := range s.jobs {
 		if state == "" || job.State == state {
-			result = append(result, job)
+			result = append(result, job.snapshot())
+		}
+	}
+	return result
+}
+
+// ListJobsByNode returns snapshots of the jobs currently holding an
+// allocation on nodeID, oldest first.
+func (s *Scheduler) ListJobsByNode(nodeID string) []*Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Job
+	for _, job := range s.jobs {
+		if job.Allocation != nil && job.Allocation.NodeID == nodeID {
+			result = append(result, job.snapshot())
 		}
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].seq < result[j].seq })
 	return result
 }
 
@@ -182,14 +363,17 @@ func (s *Scheduler) CompleteJob(jobID string, err error) error {
 		return errors.New("job not found")
 	}
 
-	now := time.Now()
+	now := s.clock.Now()
 	job.CompletedAt = &now
 
+	// Release resources
+	s.releaseAllocation(job)
+
 	if err != nil {
 		if job.RetryCount < job.MaxRetries {
 			job.RetryCount++
 			job.State = JobRetrying
-			heap.Push(&s.queue, job)
+			s.requeue(job)
 			return nil
 		}
 		job.State = JobFailed
@@ -198,14 +382,208 @@ func (s *Scheduler) CompleteJob(jobID string, err error) error {
 		job.State = JobCompleted
 	}
 
-	// Release resources
-	if job.Allocation != nil {
-		s.allocator.Release(job.Allocation.ID)
+	return nil
+}
+
+// OnPreempt registers a hook called whenever a job is preempted.
+func (s *Scheduler) OnPreempt(hook PreemptHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preemptHooks = append(s.preemptHooks, hook)
+}
+
+// Preempt evicts a running job, releases its resources and puts it back
+// in the queue. Registered preemption hooks are notified afterwards.
+func (s *Scheduler) Preempt(jobID, reason string) error {
+	s.mu.Lock()
+
+	job, ok := s.jobs[jobID]
+	if !ok {
+		s.mu.Unlock()
+		return errors.New("job not found")
+	}
+	if job.State != JobRunning && job.State != JobAllocated {
+		s.mu.Unlock()
+		return errors.New("job is not running")
 	}
 
+	s.releaseAllocation(job)
+	job.StartedAt = nil
+	job.State = JobQueued
+	s.requeue(job)
+	s.recordEvent(job, "preempted", reason)
+
+	snapshot := *job.snapshot()
+	hooks := make([]PreemptHook, len(s.preemptHooks))
+	copy(hooks, s.preemptHooks)
+	s.mu.Unlock()
+
+	// Hooks run outside the lock so they may call back into the scheduler
+	for _, hook := range hooks {
+		hook(snapshot, reason)
+	}
 	return nil
 }
 
+// releaseAllocation frees the job's resources and records the GPU time it
+// held. Callers must hold s.mu.
+func (s *Scheduler) releaseAllocation(job *Job) {
+	alloc := job.Allocation
+	if alloc == nil {
+		return
+	}
+	s.allocator.Release(alloc.ID)
+	job.Allocation = nil
+
+	end := s.clock.Now()
+	rec := UsageRecord{
+		JobID:    job.ID,
+		UserID:   job.UserID,
+		GPUs:     len(alloc.GPUIDs),
+		Start:    alloc.CreatedAt,
+		End:      end,
+		GPUHours: end.Sub(alloc.CreatedAt).Hours() * float64(len(alloc.GPUIDs)),
+	}
+	job.GPUHours += rec.GPUHours
+	s.usage = append(s.usage, rec)
+}
+
+// Usage summarizes GPU usage per user for allocations released within
+// [from, to]. Zero times leave that end unbounded and an empty userID
+// includes every user. Summaries are ordered by user ID.
+func (s *Scheduler) Usage(userID string, from, to time.Time) []UsageSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byUser := make(map[string]*UsageSummary)
+	jobs := make(map[string]map[string]bool)
+	for _, rec := range s.usage {
+		if userID != "" && rec.UserID != userID {
+			continue
+		}
+		if (!from.IsZero() && rec.End.Before(from)) || (!to.IsZero() && rec.End.After(to)) {
+			continue
+		}
+
+		sum, ok := byUser[rec.UserID]
+		if !ok {
+			sum = &UsageSummary{UserID: rec.UserID, Records: []UsageRecord{}}
+			byUser[rec.UserID] = sum
+			jobs[rec.UserID] = make(map[string]bool)
+		}
+		sum.GPUHours += rec.GPUHours
+		sum.Records = append(sum.Records, rec)
+		if !jobs[rec.UserID][rec.JobID] {
+			jobs[rec.UserID][rec.JobID] = true
+			sum.Jobs++
+		}
+	}
+
+	result := make([]UsageSummary, 0, len(byUser))
+	for _, sum := range byUser {
+		result = append(result, *sum)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
+}
+
+// JobEvents returns the recorded events for a job, oldest first.
+func (s *Scheduler) JobEvents(jobID string) []JobEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]JobEvent, len(s.events[jobID]))
+	copy(events, s.events[jobID])
+	return events
+}
+
+// recordEvent appends to the job's event log. Callers must hold s.mu.
+func (s *Scheduler) recordEvent(job *Job, eventType, message string) {
+	s.events[job.ID] = append(s.events[job.ID], JobEvent{
+		JobID:     job.ID,
+		Type:      eventType,
+		State:     job.State,
+		Message:   message,
+		Timestamp: s.clock.Now(),
+	})
+}
+
+// PlannedPlacement is a placement the scheduler would make.
+type PlannedPlacement struct {
+	JobID  string   `json:"job_id"`
+	NodeID string   `json:"node_id"`
+	GPUIDs []string `json:"gpu_ids"`
+}
+
+// UnplacedJob is a job that would remain queued, with the reason why.
+type UnplacedJob struct {
+	JobID  string                  `json:"job_id"`
+	Reason string                  `json:"reason"`
+	Code   allocator.FailureReason `json:"code,omitempty"`
+}
+
+// SimulationResult is the outcome of a scheduling dry run.
+type SimulationResult struct {
+	Placements []PlannedPlacement `json:"placements"`
+	Queued     []UnplacedJob      `json:"queued"`
+}
+
+// Simulate runs one scheduling pass against a copy of the cluster and
+// queue, reporting what would be placed without allocating anything.
+func (s *Scheduler) Simulate() *SimulationResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Order a copy of the queue; popping the live heap would touch job indices
+	pending := make([]*Job, len(s.queue))
+	copy(pending, s.queue)
+	sort.Slice(pending, func(i, j int) bool {
+		return JobQueue(pending).Less(i, j)
+	})
+
+	cluster := s.allocator.Clone()
+	result := &SimulationResult{
+		Placements: make([]PlannedPlacement, 0),
+		Queued:     make([]UnplacedJob, 0),
+	}
+
+	var blockedBy string
+	for _, job := range pending {
+		if job.State != JobQueued && job.State != JobRetrying {
+			continue
+		}
+
+		// Mirror trySchedule: nothing is placed behind an unschedulable head
+		if blockedBy != "" {
+			result.Queued = append(result.Queued, UnplacedJob{
+				JobID:  job.ID,
+				Reason: "waiting behind job " + blockedBy,
+			})
+			continue
+		}
+
+		alloc, err := cluster.Allocate(job.ID, job.UserID, job.Resources)
+		if err != nil {
+			blockedBy = job.ID
+			unplaced := UnplacedJob{JobID: job.ID, Reason: err.Error()}
+			var allocErr *allocator.AllocationError
+			if errors.As(err, &allocErr) {
+				unplaced.Code = allocErr.Reason
+			}
+			result.Queued = append(result.Queued, unplaced)
+			continue
+		}
+
+		result.Placements = append(result.Placements, PlannedPlacement{
+			JobID:  job.ID,
+			NodeID: alloc.NodeID,
+			GPUIDs: alloc.GPUIDs,
+		})
+	}
+
+	return result
+}
+
 func (s *Scheduler) runLoop() {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
@@ -230,26 +608,57 @@ func (s *Scheduler) trySchedule() {
 
 	// Try to allocate resources for queued jobs
 	for s.queue.Len() > 0 {
-		job := heap.Pop(&s.queue).(*Job)
+		job := s.queue.Peek()
+
+		// Drop jobs cancelled while waiting in the queue
+		if job.State != JobQueued && job.State != JobRetrying {
+			heap.Pop(&s.queue)
+			continue
+		}
 
+		// Leave an unschedulable head in place so ordering is untouched
 		alloc, err := s.allocator.Allocate(job.ID, job.UserID, job.Resources)
 		if err != nil {
-			// Re-queue if no resources
-			heap.Push(&s.queue, job)
 			break
 		}
 
+		heap.Pop(&s.queue)
 		job.Allocation = alloc
 		job.State = JobRunning
-		now := time.Now()
+		now := s.clock.Now()
 		job.StartedAt = &now
+		s.queueWait.observe(now.Sub(job.QueuedAt))
+		if job.ScheduledAt == nil {
+			job.ScheduledAt = &now
+			s.firstAlloc.observe(now.Sub(job.CreatedAt))
+		}
 	}
 }
 
+// requeue inserts a job into the queue, or restores heap order if it is
+// already queued. Callers must hold s.mu.
+func (s *Scheduler) requeue(job *Job) {
+	if job.index >= 0 && job.index < s.queue.Len() && s.queue[job.index] == job {
+		heap.Fix(&s.queue, job.index)
+		return
+	}
+	job.QueuedAt = s.clock.Now()
+	heap.Push(&s.queue, job)
+}
+
 func (s *Scheduler) Stop() {
 	close(s.stopCh)
 }
 
-func generateJobID() string {
-	return time.Now().Format("20060102150405") + "-job"
+// generateJobID returns an unused ID made of the submission time and a
+// random suffix. Callers must hold s.mu.
+func (s *Scheduler) generateJobID() string {
+	suffix := make([]byte, 4)
+	for {
+		rand.Read(suffix)
+		id := s.clock.Now().UTC().Format("20060102150405") + "-job-" + hex.EncodeToString(suffix)
+		if _, taken := s.jobs[id]; !taken {
+			return id
+		}
+	}
 }
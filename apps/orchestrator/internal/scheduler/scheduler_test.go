@@ -0,0 +1,510 @@
+package scheduler
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+	"openlora/orchestrator/internal/allocator"
+)
+
+func newTestScheduler(t *testing.T) *Scheduler {
+	t.Helper()
+	alloc := allocator.NewGPUAllocator()
+	alloc.RegisterNode(&allocator.Node{
+		ID:        "node-1",
+		TotalMem:  1024,
+		TotalCPUs: 64,
+		GPUs: []*allocator.GPU{
+			{ID: "gpu-0", NodeID: "node-1", Type: allocator.GPUA100, MemoryGB: 80},
+			{ID: "gpu-1", NodeID: "node-1", Type: allocator.GPUA100, MemoryGB: 80},
+		},
+	})
+	s := NewScheduler(alloc)
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func TestPreemptHookGetsDeepCopy(t *testing.T) {
+	s := newTestScheduler(t)
+	job := &Job{
+		ID:        "job-1",
+		Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40},
+		Config:    map[string]interface{}{"lora": map[string]interface{}{"rank": 8}},
+	}
+	if err := s.Submit(job); err != nil {
+		t.Fatal(err)
+	}
+	s.trySchedule()
+
+	s.OnPreempt(func(j Job, reason string) {
+		j.Config["lora"].(map[string]interface{})["rank"] = 64
+		j.Config["added"] = true
+	})
+	if err := s.Preempt("job-1", "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetJob("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rank := got.Config["lora"].(map[string]interface{})["rank"]; rank != 8 {
+		t.Fatalf("rank = %v, want 8: hook wrote through to the scheduler's job", rank)
+	}
+	if _, ok := got.Config["added"]; ok {
+		t.Fatal("hook added a config key to the scheduler's job")
+	}
+}
+
+func TestFakeClockDrivesRetriesAndUsage(t *testing.T) {
+	s := newTestScheduler(t)
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	clk := clock.NewFake(start)
+	s.SetClock(clk)
+	s.allocator.SetClock(clk)
+
+	job := &Job{ID: "job-1", UserID: "alice", MaxRetries: 1, Resources: allocator.ResourceRequest{GPUs: 2, MemoryGB: 40}}
+	if err := s.Submit(job); err != nil {
+		t.Fatal(err)
+	}
+	s.trySchedule()
+
+	got, _ := s.GetJob("job-1")
+	if got.StartedAt == nil || !got.StartedAt.Equal(start) || got.StartedAt.Location() != time.UTC {
+		t.Fatalf("StartedAt = %v, want %v in UTC", got.StartedAt, start)
+	}
+
+	clk.Advance(2 * time.Hour)
+	if err := s.CompleteJob("job-1", errors.New("oom")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetJob("job-1"); got.State != JobRetrying || got.RetryCount != 1 {
+		t.Fatalf("after first failure: state %s, retries %d; want retrying, 1", got.State, got.RetryCount)
+	}
+
+	s.trySchedule()
+	clk.Advance(time.Hour)
+	if err := s.CompleteJob("job-1", errors.New("oom")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetJob("job-1"); got.State != JobFailed {
+		t.Fatalf("after retries exhausted: state %s, want failed", got.State)
+	}
+
+	usage := s.Usage("alice", time.Time{}, time.Time{})
+	if len(usage) != 1 || usage[0].GPUHours != 6 {
+		t.Fatalf("usage = %+v, want 6 GPU-hours (2 GPUs for 2h, then 1h)", usage)
+	}
+	for _, rec := range usage[0].Records {
+		if rec.Start.Location() != time.UTC || rec.End.Location() != time.UTC {
+			t.Fatalf("usage record %+v is not UTC", rec)
+		}
+	}
+}
+
+// TestListJobsSnapshotsAreIndependent reads ListJobs results while the
+// scheduler keeps changing the same jobs; run with -race.
+func TestListJobsSnapshotsAreIndependent(t *testing.T) {
+	s := newTestScheduler(t)
+	for _, id := range []string{"job-1", "job-2"} {
+		job := &Job{ID: id, Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}, Config: map[string]interface{}{"epochs": 1}}
+		if err := s.Submit(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.trySchedule()
+	jobs := s.ListJobs(JobRunning)
+	if len(jobs) != 2 {
+		t.Fatalf("running jobs = %d, want 2", len(jobs))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Preempt("job-1", "test")
+		s.CompleteJob("job-2", nil)
+		s.trySchedule()
+	}()
+	// Deliberately unsynchronized with the changes above
+	time.Sleep(10 * time.Millisecond)
+	for _, job := range jobs {
+		if job.State != JobRunning || job.StartedAt == nil || job.Allocation == nil || len(job.Allocation.GPUIDs) != 1 || job.Config["epochs"] != 1 {
+			t.Errorf("snapshot of %s changed: %+v", job.ID, job)
+		}
+	}
+	<-done
+}
+
+func TestSubmitClampsPriorityToUserCeiling(t *testing.T) {
+	s := newTestScheduler(t)
+	ceiling := 5
+	s.allocator.SetQuota(&allocator.Quota{UserID: "alice", MaxPriority: &ceiling})
+
+	job := &Job{ID: "job-1", UserID: "alice", Priority: 100, Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}}
+	if err := s.Submit(job); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.GetJob("job-1")
+	if got.Priority != ceiling {
+		t.Fatalf("priority = %d, want clamped to %d", got.Priority, ceiling)
+	}
+	events := s.JobEvents("job-1")
+	if len(events) == 0 || events[0].Type != "priority_clamped" {
+		t.Fatalf("events = %+v, want a priority_clamped event first", events)
+	}
+
+	// Users without a ceiling keep what they asked for
+	if err := s.Submit(&Job{ID: "job-2", UserID: "bob", Priority: 100, Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetJob("job-2"); got.Priority != 100 {
+		t.Fatalf("uncapped priority = %d, want 100", got.Priority)
+	}
+
+	s.RejectOverCeiling(true)
+	err := s.Submit(&Job{ID: "job-3", UserID: "alice", Priority: 100, Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}})
+	if !errors.Is(err, ErrPriorityAboveCeiling) {
+		t.Fatalf("err = %v, want ErrPriorityAboveCeiling", err)
+	}
+	if _, err := s.GetJob("job-3"); err == nil {
+		t.Fatal("rejected job was queued")
+	}
+}
+
+func TestGeneratedJobIDsUseClockAndAreUnique(t *testing.T) {
+	s := newTestScheduler(t)
+	s.SetClock(clock.NewFake(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)))
+
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		job := &Job{UserID: "alice", Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}}
+		if err := s.Submit(job); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(job.ID, "20240301090000-job-") {
+			t.Fatalf("ID %q does not carry the fake clock's time", job.ID)
+		}
+		if seen[job.ID] {
+			t.Fatalf("duplicate ID %q within one second", job.ID)
+		}
+		seen[job.ID] = true
+	}
+}
+
+// queueOrder returns the IDs of queued jobs in scheduling order and checks
+// each job's heap index.
+func queueOrder(t *testing.T, s *Scheduler) []string {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, job := range s.queue {
+		if job.index != i {
+			t.Fatalf("job %s has index %d at position %d", job.ID, job.index, i)
+		}
+	}
+	q := append(JobQueue(nil), s.queue...)
+	sort.Slice(q, func(i, j int) bool { return q.Less(i, j) })
+	ids := make([]string, len(q))
+	for i, job := range q {
+		ids[i] = job.ID
+	}
+	return ids
+}
+
+func TestFailedSchedulingKeepsQueueOrder(t *testing.T) {
+	s := newTestScheduler(t)
+	fake := clock.NewFake(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	s.SetClock(fake)
+	if err := s.Submit(&Job{ID: "blocker", Resources: allocator.ResourceRequest{GPUs: 2, MemoryGB: 40}}); err != nil {
+		t.Fatal(err)
+	}
+	s.trySchedule()
+
+	// Equal priority, so only submission order separates them; the head
+	// needs both GPUs and can't be placed while the blocker runs
+	for _, id := range []string{"head", "second", "third"} {
+		gpus := 1
+		if id == "head" {
+			gpus = 2
+		}
+		fake.Advance(time.Second)
+		if err := s.Submit(&Job{ID: id, Resources: allocator.ResourceRequest{GPUs: gpus, MemoryGB: 40}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	head, _ := s.GetJob("head")
+	queuedAt := head.QueuedAt
+
+	want := []string{"head", "second", "third"}
+	for i := 0; i < 3; i++ {
+		s.trySchedule()
+		if got := queueOrder(t, s); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("attempt %d: queue = %v, want %v", i+1, got, want)
+		}
+	}
+	if head, _ := s.GetJob("head"); head.State != JobQueued || !head.QueuedAt.Equal(queuedAt) {
+		t.Fatalf("head = %s queued at %v, want still queued at %v", head.State, head.QueuedAt, queuedAt)
+	}
+
+	if err := s.CompleteJob("blocker", nil); err != nil {
+		t.Fatal(err)
+	}
+	s.trySchedule()
+	if head, _ := s.GetJob("head"); head.State != JobRunning {
+		t.Fatalf("head = %s after the blocker finished, want running", head.State)
+	}
+	if got := queueOrder(t, s); strings.Join(got, ",") != "second,third" {
+		t.Fatalf("queue = %v, want [second third]", got)
+	}
+}
+
+func TestPreemptHookGetsJobAndReason(t *testing.T) {
+	s := newTestScheduler(t)
+	for _, id := range []string{"victim", "bystander"} {
+		if err := s.Submit(&Job{ID: id, UserID: "alice", Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.trySchedule()
+
+	type call struct {
+		job    Job
+		reason string
+	}
+	var calls []call
+	s.OnPreempt(func(j Job, reason string) { calls = append(calls, call{j, reason}) })
+
+	if err := s.Preempt("victim", "higher priority job"); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("hook fired %d times, want once", len(calls))
+	}
+	got := calls[0]
+	if got.job.ID != "victim" || got.job.UserID != "alice" || got.job.State != JobQueued || got.reason != "higher priority job" {
+		t.Fatalf("hook got %s/%s in state %s with reason %q", got.job.ID, got.job.UserID, got.job.State, got.reason)
+	}
+
+	events := s.JobEvents("victim")
+	last := events[len(events)-1]
+	if last.Type != "preempted" || last.Message != "higher priority job" {
+		t.Fatalf("last event = %+v, want the preemption and its reason", last)
+	}
+
+	if err := s.Preempt("victim", "again"); err == nil || len(calls) != 1 {
+		t.Fatalf("preempting a queued job = %v with %d hook calls, want an error and no call", err, len(calls))
+	}
+}
+
+func TestSimulateMatchesScheduling(t *testing.T) {
+	s := newTestScheduler(t)
+	jobs := []*Job{
+		{ID: "small", Priority: 3, Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}},
+		{ID: "large", Priority: 2, Resources: allocator.ResourceRequest{GPUs: 2, MemoryGB: 40}},
+		{ID: "behind", Priority: 1, Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}},
+	}
+	for _, job := range jobs {
+		if err := s.Submit(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sim := s.Simulate()
+	for _, job := range jobs {
+		if got, _ := s.GetJob(job.ID); got.State != JobQueued || got.Allocation != nil {
+			t.Fatalf("Simulate changed %s: state %s, allocation %+v", job.ID, got.State, got.Allocation)
+		}
+	}
+
+	s.trySchedule()
+
+	placed := make(map[string]PlannedPlacement)
+	for _, p := range sim.Placements {
+		placed[p.JobID] = p
+	}
+	for _, job := range jobs {
+		got, _ := s.GetJob(job.ID)
+		plan, planned := placed[job.ID]
+		if planned != (got.State == JobRunning) {
+			t.Fatalf("%s: simulated placed=%v, actual state %s", job.ID, planned, got.State)
+		}
+		if planned && (got.Allocation.NodeID != plan.NodeID || strings.Join(got.Allocation.GPUIDs, ",") != strings.Join(plan.GPUIDs, ",")) {
+			t.Fatalf("%s: simulated %s %v, scheduled on %s %v", job.ID, plan.NodeID, plan.GPUIDs, got.Allocation.NodeID, got.Allocation.GPUIDs)
+		}
+	}
+
+	if len(sim.Queued) != 2 || sim.Queued[0].JobID != "large" || sim.Queued[0].Code == "" || sim.Queued[1].Reason != "waiting behind job large" {
+		t.Fatalf("queued = %+v, want large blocked on capacity and behind waiting for it", sim.Queued)
+	}
+	if got := queueOrder(t, s); strings.Join(got, ",") != "large,behind" {
+		t.Fatalf("queue = %v, want what Simulate reported as queued", got)
+	}
+}
+
+func TestUsageAcrossCompletedJobs(t *testing.T) {
+	s := newTestScheduler(t)
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	s.SetClock(clk)
+	s.allocator.SetClock(clk)
+
+	submit := func(id, user string, gpus int) {
+		t.Helper()
+		if err := s.Submit(&Job{ID: id, UserID: user, Resources: allocator.ResourceRequest{GPUs: gpus, MemoryGB: 40}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	complete := func(id string) {
+		t.Helper()
+		if err := s.CompleteJob(id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// alice-1 and bob-1 share the node, then alice-2 takes both GPUs
+	submit("alice-1", "alice", 1)
+	submit("bob-1", "bob", 1)
+	s.trySchedule()
+	clk.Advance(time.Hour)
+	complete("alice-1")
+	clk.Advance(30 * time.Minute)
+	complete("bob-1")
+
+	submit("alice-2", "alice", 2)
+	s.trySchedule()
+	clk.Advance(2 * time.Hour)
+	complete("alice-2")
+
+	if got, _ := s.GetJob("alice-2"); got.GPUHours != 4 {
+		t.Errorf("alice-2 GPUHours = %v, want 4", got.GPUHours)
+	}
+
+	tests := []struct {
+		name     string
+		userID   string
+		from, to time.Time
+		want     []UsageSummary // Records not compared
+	}{
+		{
+			name: "all users",
+			want: []UsageSummary{{UserID: "alice", Jobs: 2, GPUHours: 5}, {UserID: "bob", Jobs: 1, GPUHours: 1.5}},
+		},
+		{
+			name:   "one user",
+			userID: "bob",
+			want:   []UsageSummary{{UserID: "bob", Jobs: 1, GPUHours: 1.5}},
+		},
+		{
+			name: "from excludes earlier releases",
+			from: start.Add(2 * time.Hour),
+			want: []UsageSummary{{UserID: "alice", Jobs: 1, GPUHours: 4}},
+		},
+		{
+			name: "to excludes later releases",
+			to:   start.Add(time.Hour),
+			want: []UsageSummary{{UserID: "alice", Jobs: 1, GPUHours: 1}},
+		},
+		{
+			name:   "no matching user",
+			userID: "carol",
+			want:   []UsageSummary{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Usage(tt.userID, tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Usage = %+v, want %+v", got, tt.want)
+			}
+			for i, want := range tt.want {
+				if got[i].UserID != want.UserID || got[i].Jobs != want.Jobs || got[i].GPUHours != want.GPUHours {
+					t.Errorf("summary %d = %s %d jobs %v GPU-h, want %s %d jobs %v GPU-h",
+						i, got[i].UserID, got[i].Jobs, got[i].GPUHours, want.UserID, want.Jobs, want.GPUHours)
+				}
+				if len(got[i].Records) != want.Jobs {
+					t.Errorf("summary %d has %d records, want %d", i, len(got[i].Records), want.Jobs)
+				}
+			}
+		})
+	}
+}
+
+func TestSubmitRejectsOverCapacityMemory(t *testing.T) {
+	s := newTestScheduler(t)
+
+	// 100GB on one 80GB card can never be placed
+	err := s.Submit(&Job{ID: "too-big", Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 100}})
+	if !errors.Is(err, ErrUnsatisfiable) || !strings.Contains(err.Error(), "100GB over 1 GPU(s) needs 100GB per GPU") {
+		t.Fatalf("err = %v, want ErrUnsatisfiable explaining the per-GPU shortfall", err)
+	}
+	if _, err := s.GetJob("too-big"); err == nil {
+		t.Fatal("rejected job was stored")
+	}
+	if queued := s.ListJobs(JobQueued); len(queued) != 0 {
+		t.Fatalf("queue holds %d jobs after rejection, want 0", len(queued))
+	}
+
+	// Split across both cards it fits
+	if err := s.Submit(&Job{ID: "split", Resources: allocator.ResourceRequest{GPUs: 2, MemoryGB: 100}}); err != nil {
+		t.Fatalf("Submit of a 2-GPU 100GB job: %v", err)
+	}
+}
+
+func TestListJobsByNode(t *testing.T) {
+	alloc := allocator.NewGPUAllocator()
+	for _, id := range []string{"node-a", "node-b"} {
+		alloc.RegisterNode(&allocator.Node{ID: id, GPUs: []*allocator.GPU{
+			{ID: id + "-gpu-0", NodeID: id, Type: allocator.GPUA100, MemoryGB: 80},
+			{ID: id + "-gpu-1", NodeID: id, Type: allocator.GPUA100, MemoryGB: 80},
+		}})
+	}
+	s := NewScheduler(alloc)
+	t.Cleanup(s.Stop)
+
+	// Nodes fill in ID order: job-1 and job-2 on node-a, job-3 on node-b,
+	// job-4 waits for capacity
+	for _, job := range []*Job{
+		{ID: "job-1", Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}},
+		{ID: "job-2", Resources: allocator.ResourceRequest{GPUs: 1, MemoryGB: 40}},
+		{ID: "job-3", Resources: allocator.ResourceRequest{GPUs: 2, MemoryGB: 40}},
+		{ID: "job-4", Resources: allocator.ResourceRequest{GPUs: 2, MemoryGB: 40}},
+	} {
+		if err := s.Submit(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.trySchedule()
+
+	jobIDs := func(nodeID string) []string {
+		var ids []string
+		for _, job := range s.ListJobsByNode(nodeID) {
+			if job.Allocation.NodeID != nodeID {
+				t.Errorf("job %s on %s listed for %s", job.ID, job.Allocation.NodeID, nodeID)
+			}
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+	if got := jobIDs("node-a"); strings.Join(got, ",") != "job-1,job-2" {
+		t.Errorf("node-a jobs = %v, want [job-1 job-2]", got)
+	}
+	if got := jobIDs("node-b"); strings.Join(got, ",") != "job-3" {
+		t.Errorf("node-b jobs = %v, want [job-3]", got)
+	}
+	if got := jobIDs("node-c"); len(got) != 0 {
+		t.Errorf("unknown node jobs = %v, want none", got)
+	}
+
+	// A finished job no longer holds the node
+	if err := s.CompleteJob("job-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := jobIDs("node-a"); strings.Join(got, ",") != "job-2" {
+		t.Errorf("node-a jobs after job-1 completed = %v, want [job-2]", got)
+	}
+}
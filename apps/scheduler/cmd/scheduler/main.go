@@ -3,20 +3,56 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"openlora/core/logging"
 	"openlora/scheduler/internal/api"
 	"openlora/scheduler/internal/queue"
 	"openlora/scheduler/internal/resources"
 )
 
 func main() {
-	log.Println("🚀 OpenLoRA Scheduler starting...")
+	logging.Setup("scheduler")
+	slog.Info("🚀 OpenLoRA Scheduler starting...")
 
 	// Initialize components
 	jobQueue := queue.NewJobQueue()
+	if dir := os.Getenv("SCHEDULER_STATE_DIR"); dir != "" {
+		interval := queue.DefaultFlushInterval
+		if v := os.Getenv("SCHEDULER_FLUSH_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				logging.Fatal("Invalid SCHEDULER_FLUSH_INTERVAL", "value", v)
+			}
+			interval = d
+		}
+		store := queue.NewFileStore(filepath.Join(dir, "jobs.json"))
+		if err := jobQueue.EnablePersistence(store, filepath.Join(dir, "jobs.wal"), interval); err != nil {
+			logging.Fatal("Failed to restore job queue", "error", err)
+		}
+	}
 	resourceMgr := resources.NewResourceManager()
+	heartbeatTimeout := resources.DefaultHeartbeatTimeout
+	if v := os.Getenv("WORKER_HEARTBEAT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logging.Fatal("Invalid WORKER_HEARTBEAT_TIMEOUT", "value", v)
+		}
+		heartbeatTimeout = d
+	}
+	resourceMgr.SetHeartbeatTimeout(heartbeatTimeout)
+	resourceMgr.StartReaper(heartbeatTimeout/2, func(workerIDs []string) {
+		for _, workerID := range workerIDs {
+			for _, id := range jobQueue.RequeueWorker(workerID) {
+				slog.Info("Requeued job from evicted worker", "job_id", id, "worker_id", workerID)
+			}
+		}
+	})
 	server := api.NewServer(jobQueue, resourceMgr)
 
 	// Get port from env or default
@@ -25,8 +61,21 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("📡 Listening on :%s", port)
-	if err := server.Start(":" + port); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	go func() {
+		slog.Info("📡 Listening", "port", port)
+		if err := server.Start(":" + port); err != nil {
+			logging.Fatal("Server failed", "error", err)
+		}
+	}()
+
+	// Wait for shutdown signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("Shutting down...")
+	resourceMgr.StopReaper()
+	if err := jobQueue.Close(); err != nil {
+		slog.Error("Failed to flush job queue", "error", err)
 	}
 }
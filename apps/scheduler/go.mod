@@ -2,4 +2,14 @@ module openlora/scheduler
 
 go 1.21
 
-require github.com/google/uuid v1.5.0
+require (
+	github.com/google/uuid v1.5.0
+	openlora/core v0.0.0
+)
+
+require (
+	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+)
+
+replace openlora/core => ../../packages/core-go
@@ -3,8 +3,11 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 
+	"openlora/core/negotiate"
 	"openlora/scheduler/internal/queue"
 	"openlora/scheduler/internal/resources"
 )
@@ -33,8 +36,10 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/jobs", s.handleJobs)
 	s.mux.HandleFunc("/jobs/submit", s.handleSubmit)
 	s.mux.HandleFunc("/jobs/dequeue", s.handleDequeue)
+	s.mux.HandleFunc("/jobs/complete", s.handleComplete)
 	s.mux.HandleFunc("/workers", s.handleWorkers)
 	s.mux.HandleFunc("/workers/register", s.handleRegisterWorker)
+	s.mux.HandleFunc("/workers/", s.handleWorkerByID)
 	s.mux.HandleFunc("/stats", s.handleStats)
 }
 
@@ -47,6 +52,15 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// jobResponse is a job as returned by GET /jobs?id=. Pending jobs also
+// carry how many jobs are ahead of them and, once some run has finished,
+// a rough estimate of the wait.
+type jobResponse struct {
+	*queue.Job
+	QueuePosition *int     `json:"queue_position,omitempty"`
+	ETASeconds    *float64 `json:"eta_seconds,omitempty"`
+}
+
 func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -60,11 +74,19 @@ func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Job not found", http.StatusNotFound)
 			return
 		}
-		json.NewEncoder(w).Encode(job)
+		resp := jobResponse{Job: job}
+		if pos, err := s.queue.Position(jobID); err == nil {
+			resp.QueuePosition = &pos
+			if eta, err := s.queue.ETA(jobID); err == nil && eta > 0 {
+				secs := eta.Seconds()
+				resp.ETASeconds = &secs
+			}
+		}
+		negotiate.Write(w, r, resp)
 		return
 	}
 
-	json.NewEncoder(w).Encode(s.queue.Stats())
+	negotiate.Write(w, r, s.queue.Stats())
 }
 
 func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
@@ -83,6 +105,9 @@ func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"job_id": jobID})
 }
 
+// handleDequeue hands the next fitting job to a worker. For a registered
+// worker the GPUs it may use are capped at its free healthy GPUs, and the
+// job's GPUs are reserved so GPU health reports and eviction can find it.
 func (s *Server) handleDequeue(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -98,7 +123,19 @@ func (s *Server) handleDequeue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	free, registered := s.resources.GetAvailableResources()[req.WorkerID]
+	if registered && req.Available.GPUs > free.GPUs {
+		req.Available.GPUs = free.GPUs
+	}
+
 	job := s.queue.Dequeue(req.WorkerID, req.Available)
+	if job != nil && registered && job.Resources.GPUs > 0 {
+		if _, ok := s.resources.AllocateGPUs(req.WorkerID, job.Resources.GPUs, job.ID); !ok {
+			// Lost a race for the GPUs; leave the job for the next poll
+			s.queue.Requeue(job.ID)
+			job = nil
+		}
+	}
 	if job == nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{"job": nil})
 		return
@@ -107,6 +144,36 @@ func (s *Server) handleDequeue(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{"job": job})
 }
 
+// handleComplete records a job's outcome and frees the GPUs it held.
+func (s *Server) handleComplete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		JobID string `json:"job_id"`
+		Error string `json:"error,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var jobErr error
+	if req.Error != "" {
+		jobErr = errors.New(req.Error)
+	}
+	workerID, ok := s.queue.Complete(req.JobID, jobErr)
+	if !ok {
+		http.Error(w, "Job not running", http.StatusNotFound)
+		return
+	}
+	s.resources.ReleaseJob(workerID, req.JobID)
+
+	negotiate.Write(w, r, s.queue.GetJob(req.JobID))
+}
+
 func (s *Server) handleWorkers(w http.ResponseWriter, r *http.Request) {
 	available := s.resources.GetAvailableResources()
 	json.NewEncoder(w).Encode(available)
@@ -128,10 +195,75 @@ func (s *Server) handleRegisterWorker(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "registered"})
 }
 
+func (s *Server) handleWorkerByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/workers/"):], "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	switch parts[1] {
+	case "gpu-health":
+		s.handleGPUHealth(w, r, parts[0])
+	case "heartbeat":
+		s.handleHeartbeat(w, r, parts[0])
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleHeartbeat records that a worker is still alive.
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request, workerID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.resources.Heartbeat(workerID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleGPUHealth accepts a batch of GPU health reports from a worker and
+// requeues jobs that were running on GPUs reported unhealthy. A batch
+// naming an unknown GPU is rejected whole.
+func (s *Server) handleGPUHealth(w http.ResponseWriter, r *http.Request, workerID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var reports []resources.GPUHealthReport
+	if err := json.NewDecoder(r.Body).Decode(&reports); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jobIDs, err := s.resources.ReportGPUHealth(workerID, reports)
+	if errors.Is(err, resources.ErrWorkerNotFound) || errors.Is(err, resources.ErrGPUNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	requeued := []string{}
+	for _, jobID := range jobIDs {
+		if s.queue.Requeue(jobID) {
+			requeued = append(requeued, jobID)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"requeued": requeued})
+}
+
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	stats := map[string]interface{}{
 		"jobs":    s.queue.Stats(),
 		"cluster": s.resources.ClusterStats(),
 	}
-	json.NewEncoder(w).Encode(stats)
+	negotiate.Write(w, r, stats)
 }
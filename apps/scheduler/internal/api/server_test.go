@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"openlora/scheduler/internal/queue"
+	"openlora/scheduler/internal/resources"
+)
+
+func newTestServer(t *testing.T) (*Server, *queue.JobQueue, *resources.ResourceManager) {
+	t.Helper()
+	q := queue.NewJobQueue()
+	rm := resources.NewResourceManager()
+	rm.RegisterWorker(&resources.Worker{
+		ID:        "worker-1",
+		GPUs:      []resources.GPU{{ID: "gpu-0", Type: "A100"}, {ID: "gpu-1", Type: "A100"}},
+		TotalCPUs: 16,
+		MemoryGB:  128,
+	})
+	return NewServer(q, rm), q, rm
+}
+
+func post(t *testing.T, s *Server, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b)))
+	return rec
+}
+
+func dequeue(t *testing.T, s *Server, gpus int) *queue.Job {
+	t.Helper()
+	rec := post(t, s, "/jobs/dequeue", map[string]interface{}{
+		"worker_id": "worker-1",
+		"available": queue.ResourceRequirements{GPUs: gpus, CPUs: 16, MemoryGB: 128},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("dequeue: status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Job *queue.Job `json:"job"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Job
+}
+
+func TestDequeueReservesGPUs(t *testing.T) {
+	s, q, rm := newTestServer(t)
+	id := q.Submit(&queue.Job{Resources: queue.ResourceRequirements{GPUs: 2}})
+	q.Submit(&queue.Job{Resources: queue.ResourceRequirements{GPUs: 1}})
+
+	if job := dequeue(t, s, 2); job == nil || job.ID != id {
+		t.Fatalf("dequeued %+v, want %s", job, id)
+	}
+	if free := rm.GetAvailableResources()["worker-1"].GPUs; free != 0 {
+		t.Fatalf("free GPUs after dequeue = %d, want 0", free)
+	}
+	// The worker over-reports; the reservation keeps it from taking more
+	if job := dequeue(t, s, 2); job != nil {
+		t.Fatalf("dequeued %s with no free GPUs", job.ID)
+	}
+}
+
+func TestUnhealthyGPURequeuesDequeuedJob(t *testing.T) {
+	s, q, rm := newTestServer(t)
+	id := q.Submit(&queue.Job{Resources: queue.ResourceRequirements{GPUs: 2}})
+	dequeue(t, s, 2)
+
+	rec := post(t, s, "/workers/worker-1/gpu-health", []map[string]interface{}{
+		{"gpu_id": "gpu-1", "healthy": false},
+	})
+	var resp struct {
+		Requeued []string `json:"requeued"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Requeued) != 1 || resp.Requeued[0] != id {
+		t.Fatalf("requeued = %v, want [%s]", resp.Requeued, id)
+	}
+	if job := q.GetJob(id); job.Status != queue.JobPending {
+		t.Fatalf("status = %s, want pending", job.Status)
+	}
+	if free := rm.GetAvailableResources()["worker-1"].GPUs; free != 1 {
+		t.Fatalf("free GPUs = %d, want 1 (gpu-1 is unhealthy)", free)
+	}
+}
+
+func TestGPUHealthBatchWithUnknownGPUChangesNothing(t *testing.T) {
+	s, q, rm := newTestServer(t)
+	id := q.Submit(&queue.Job{Resources: queue.ResourceRequirements{GPUs: 2}})
+	dequeue(t, s, 2)
+
+	rec := post(t, s, "/workers/worker-1/gpu-health", []map[string]interface{}{
+		{"gpu_id": "gpu-1", "healthy": false},
+		{"gpu_id": "gpu-9", "healthy": false},
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if job := q.GetJob(id); job.Status != queue.JobRunning {
+		t.Fatalf("status = %s, want running: the rejected batch was partly applied", job.Status)
+	}
+	if free := rm.GetAvailableResources()["worker-1"].GPUs; free != 0 {
+		t.Fatalf("free GPUs = %d, want 0", free)
+	}
+}
+
+func TestCompleteReleasesGPUs(t *testing.T) {
+	s, q, rm := newTestServer(t)
+	id := q.Submit(&queue.Job{Resources: queue.ResourceRequirements{GPUs: 2}})
+	dequeue(t, s, 2)
+
+	if rec := post(t, s, "/jobs/complete", map[string]string{"job_id": id}); rec.Code != http.StatusOK {
+		t.Fatalf("complete: status %d: %s", rec.Code, rec.Body)
+	}
+	if job := q.GetJob(id); job.Status != queue.JobCompleted {
+		t.Fatalf("status = %s, want completed", job.Status)
+	}
+	if free := rm.GetAvailableResources()["worker-1"].GPUs; free != 2 {
+		t.Fatalf("free GPUs after complete = %d, want 2", free)
+	}
+	if rec := post(t, s, "/jobs/complete", map[string]string{"job_id": id}); rec.Code != http.StatusNotFound {
+		t.Fatalf("second complete: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,394 @@
+package queue
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Store holds the durable copy of every job. SaveJobs upserts by job ID.
+type Store interface {
+	LoadJobs() ([]*Job, error)
+	SaveJobs(jobs []*Job) error
+}
+
+// DefaultFlushInterval is how often state changes are batched to the store.
+const DefaultFlushInterval = time.Second
+
+// FileStore is a Store that keeps every job in one JSON file, rewritten
+// atomically on each save.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a store backed by path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// LoadJobs implements Store. A missing file holds no jobs.
+func (s *FileStore) LoadJobs() ([]*Job, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", s.path, err)
+	}
+	return jobs, nil
+}
+
+// SaveJobs implements Store.
+func (s *FileStore) SaveJobs(jobs []*Job) error {
+	existing, err := s.LoadJobs()
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*Job, len(existing)+len(jobs))
+	for _, job := range existing {
+		byID[job.ID] = job
+	}
+	for _, job := range jobs {
+		byID[job.ID] = job
+	}
+	all := make([]*Job, 0, len(byID))
+	for _, job := range byID {
+		all = append(all, job)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces path with data via a synced temp file and rename.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// wal is an append-only log of job snapshots, one JSON line per state
+// change, covering changes not yet flushed to the store.
+type wal struct {
+	path string
+	file *os.File
+	enc  *json.Encoder
+	size int64
+}
+
+func openWAL(path string) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w := &wal{path: path, file: f, size: info.Size()}
+	w.enc = json.NewEncoder(countingWriter{w})
+	return w, nil
+}
+
+type countingWriter struct{ w *wal }
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.file.Write(p)
+	c.w.size += int64(n)
+	return n, err
+}
+
+// append logs job and syncs the log, so the change survives a crash once
+// append returns.
+func (w *wal) append(job Job) error {
+	if err := w.enc.Encode(job); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// errCorruptWAL reports an unreadable entry in the middle of the log.
+var errCorruptWAL = errors.New("corrupt wal entry")
+
+// entries reads every snapshot in the log in write order. A final line
+// that is unterminated or does not decode, as left by a crash mid-write,
+// is cut off so later appends start on a clean line; any other bad line
+// is an error.
+func (w *wal) entries() ([]Job, error) {
+	f, err := os.Open(w.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var jobs []Job
+	var good int64
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(b) == 0 {
+			break
+		}
+
+		var job Job
+		if b[len(b)-1] != '\n' || json.Unmarshal(b, &job) != nil {
+			if _, peekErr := r.Peek(1); peekErr != io.EOF {
+				return nil, fmt.Errorf("%w at line %d", errCorruptWAL, line)
+			}
+			slog.Warn("Dropping torn final wal entry", "path", w.path, "line", line)
+			if err := w.file.Truncate(good); err != nil {
+				return nil, err
+			}
+			w.size = good
+			break
+		}
+		jobs = append(jobs, job)
+		good += int64(len(b))
+	}
+	return jobs, nil
+}
+
+// discardBefore drops the first offset bytes, keeping whatever was
+// appended after them, by atomically replacing the file.
+func (w *wal) discardBefore(offset int64) error {
+	if _, err := w.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	tail, err := io.ReadAll(w.file)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(w.path, tail); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = f
+	w.size = int64(len(tail))
+	return nil
+}
+
+func (w *wal) close() error {
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// EnablePersistence recovers the queue from store plus any changes left in
+// the write-ahead log at walPath, then logs every later state change there
+// and flushes the batch to store every interval. Each log append is
+// synced, so a crash between flushes loses nothing; replaying
+// changes the store already has is harmless since each entry is a full
+// job snapshot.
+func (q *JobQueue) EnablePersistence(store Store, walPath string, interval time.Duration) error {
+	jobs, err := store.LoadJobs()
+	if err != nil {
+		return fmt.Errorf("load jobs: %w", err)
+	}
+	w, err := openWAL(walPath)
+	if err != nil {
+		return fmt.Errorf("open wal: %w", err)
+	}
+	replay, err := w.entries()
+	if err != nil {
+		w.close()
+		return fmt.Errorf("read wal: %w", err)
+	}
+
+	q.mu.Lock()
+	byID := make(map[string]*Job, len(jobs))
+	for _, job := range jobs {
+		byID[job.ID] = job
+	}
+	q.dirty = make(map[string]Job)
+	for _, job := range replay {
+		job := job
+		byID[job.ID] = &job
+		q.dirty[job.ID] = job
+	}
+	q.store = store
+	q.wal = w
+	q.restore(byID)
+	q.stopCh = make(chan struct{})
+	q.flushDone = make(chan struct{})
+	q.mu.Unlock()
+
+	go q.flushLoop(interval)
+	return nil
+}
+
+// restore rebuilds the pending and completed sets from jobs. Jobs that
+// were running belong to workers that no longer report to this process,
+// so they go back to pending as if requeued. Pending jobs keep their saved
+// QueueSeq, so restarts don't reorder the queue, and numbering continues
+// after the highest one; jobs saved without one queue behind the rest by
+// creation time. Callers must hold q.mu.
+func (q *JobQueue) restore(jobs map[string]*Job) {
+	q.pending = make(pendingHeap, 0)
+	q.running = make(map[string]*Job)
+	q.completed = make(map[string]*Job)
+
+	var unsequenced []*Job
+	for _, job := range jobs {
+		if job.QueueSeq > q.seq {
+			q.seq = job.QueueSeq
+		}
+		switch job.Status {
+		case JobPending:
+		case JobRunning:
+			job.Attempts--
+			job.Status = JobPending
+			job.StartedAt = nil
+			job.WorkerID = ""
+			q.record(job)
+		default:
+			q.completed[job.ID] = job
+			continue
+		}
+		if job.QueueSeq == 0 {
+			unsequenced = append(unsequenced, job)
+			continue
+		}
+		heap.Push(&q.pending, job)
+	}
+
+	sort.Slice(unsequenced, func(i, j int) bool { return unsequenced[i].CreatedAt.Before(unsequenced[j].CreatedAt) })
+	for _, job := range unsequenced {
+		q.insertPending(job)
+		q.record(job)
+	}
+}
+
+// record logs a snapshot of job after a state change. Callers must hold
+// q.mu.
+func (q *JobQueue) record(job *Job) {
+	if q.wal == nil {
+		return
+	}
+	if err := q.wal.append(*job); err != nil {
+		slog.Warn("job wal append failed", "job_id", job.ID, "error", err)
+	}
+	q.dirty[job.ID] = *job
+}
+
+func (q *JobQueue) flushLoop(interval time.Duration) {
+	defer close(q.flushDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case <-ticker.C:
+			if err := q.Flush(); err != nil {
+				slog.Warn("job store flush failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush writes the changes logged since the last flush to the store and
+// trims them from the write-ahead log. On failure they stay in both the
+// log and the next batch.
+func (q *JobQueue) Flush() error {
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+
+	q.mu.Lock()
+	if q.wal == nil || len(q.dirty) == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	batch := q.dirty
+	q.dirty = make(map[string]Job)
+	mark := q.wal.size
+	q.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(batch))
+	for _, job := range batch {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	err := q.store.SaveJobs(jobs)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err != nil {
+		for id, job := range batch {
+			if _, newer := q.dirty[id]; !newer {
+				q.dirty[id] = job
+			}
+		}
+		return fmt.Errorf("save jobs: %w", err)
+	}
+	if err := q.wal.discardBefore(mark); err != nil {
+		return fmt.Errorf("trim wal: %w", err)
+	}
+	return nil
+}
+
+// Close stops the flush loop, flushes outstanding changes and closes the
+// write-ahead log. It is a no-op without persistence.
+func (q *JobQueue) Close() error {
+	q.mu.Lock()
+	if q.wal == nil {
+		q.mu.Unlock()
+		return nil
+	}
+	q.mu.Unlock()
+
+	close(q.stopCh)
+	<-q.flushDone
+	flushErr := q.Flush()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err := q.wal.close()
+	q.wal = nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return err
+}
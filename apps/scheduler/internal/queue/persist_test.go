@@ -0,0 +1,245 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openPersistent returns a queue recovered from the store and log in dir.
+// It is never closed, so each call after the first sees the state a crash
+// would have left behind.
+func openPersistent(t *testing.T, dir string) (*JobQueue, error) {
+	t.Helper()
+	q := NewJobQueue()
+	err := q.EnablePersistence(NewFileStore(filepath.Join(dir, "jobs.json")), filepath.Join(dir, "jobs.wal"), time.Hour)
+	return q, err
+}
+
+func TestCrashRecoveryReplaysWAL(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := q.Submit(&Job{Name: "first"})
+	second := q.Submit(&Job{Name: "second"})
+	done := q.Submit(&Job{Name: "done"})
+	q.Dequeue("worker-1", ResourceRequirements{}) // first
+	q.Dequeue("worker-1", ResourceRequirements{}) // second
+	q.Complete(second, nil)
+	q.Dequeue("worker-1", ResourceRequirements{}) // done
+	q.Complete(done, nil)
+
+	recovered, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := recovered.GetJob(first)
+	if job == nil || job.Status != JobPending || job.WorkerID != "" || job.Attempts != 0 {
+		t.Fatalf("interrupted job = %+v, want pending with no attempts", job)
+	}
+	if job := recovered.GetJob(done); job == nil || job.Status != JobCompleted {
+		t.Fatalf("completed job = %+v", job)
+	}
+	if stats := recovered.Stats(); stats["running"] != 0 {
+		t.Fatalf("stats = %v, want nothing running", stats)
+	}
+	if next := recovered.Dequeue("worker-2", ResourceRequirements{}); next == nil || next.ID != first {
+		t.Fatalf("next = %+v, want %s", next, first)
+	}
+}
+
+func TestCrashRecoveryAfterFlush(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flushed := q.Submit(&Job{Name: "flushed"})
+	if err := q.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	logged := q.Submit(&Job{Name: "logged"})
+
+	recovered, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{flushed, logged} {
+		if job := recovered.GetJob(id); job == nil || job.Status != JobPending {
+			t.Fatalf("job %s = %+v, want pending", id, job)
+		}
+	}
+}
+
+func TestRecoveryDropsTornFinalLine(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := q.Submit(&Job{Name: "before"})
+	appendFile(t, filepath.Join(dir, "jobs.wal"), `{"id":"torn","sta`)
+
+	recovered, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered.GetJob("torn") != nil {
+		t.Fatal("torn entry was replayed")
+	}
+	after := recovered.Submit(&Job{Name: "after"})
+
+	// The torn bytes were cut off, so the next append did not join them
+	again, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{before, after} {
+		if again.GetJob(id) == nil {
+			t.Fatalf("job %s lost", id)
+		}
+	}
+}
+
+func TestRecoveryRejectsMidLogCorruption(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Submit(&Job{Name: "before"})
+	appendFile(t, filepath.Join(dir, "jobs.wal"), "not json\n")
+	q.Submit(&Job{Name: "after"})
+
+	if _, err := openPersistent(t, dir); !errors.Is(err, errCorruptWAL) {
+		t.Fatalf("err = %v, want errCorruptWAL", err)
+	}
+}
+
+// memStore is an in-memory Store. With crashAfter set, SaveJobs stores
+// only that many jobs of the next batch and then fails, like a process
+// dying mid-flush.
+type memStore struct {
+	mu         sync.Mutex
+	jobs       map[string]Job
+	crashAfter int // 0 means never
+}
+
+func newMemStore() *memStore {
+	return &memStore{jobs: make(map[string]Job)}
+}
+
+func (s *memStore) LoadJobs() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+func (s *memStore) SaveJobs(jobs []*Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, job := range jobs {
+		if s.crashAfter > 0 && i == s.crashAfter {
+			s.crashAfter = 0
+			return errors.New("crashed mid-flush")
+		}
+		s.jobs[job.ID] = *job
+	}
+	return nil
+}
+
+func TestCrashMidFlushLosesNothing(t *testing.T) {
+	store := newMemStore()
+	walPath := filepath.Join(t.TempDir(), "jobs.wal")
+	q := NewJobQueue()
+	if err := q.EnablePersistence(store, walPath, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	done := q.Submit(&Job{Name: "done"})
+	running := q.Submit(&Job{Name: "running"})
+	pending := q.Submit(&Job{Name: "pending"})
+	q.Dequeue("worker-1", ResourceRequirements{})
+	q.Complete(done, nil)
+	q.Dequeue("worker-1", ResourceRequirements{})
+
+	store.crashAfter = 1
+	if err := q.Flush(); err == nil {
+		t.Fatal("Flush succeeded, want the simulated crash")
+	}
+	if n := len(store.jobs); n != 1 {
+		t.Fatalf("store holds %d jobs, want 1 from the partial flush", n)
+	}
+
+	// The crashed queue is abandoned; recover from the half-written store
+	// and the log
+	recovered := NewJobQueue()
+	if err := recovered.EnablePersistence(store, walPath, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]JobStatus{done: JobCompleted, running: JobPending, pending: JobPending}
+	for id, status := range want {
+		if job := recovered.GetJob(id); job == nil || job.Status != status {
+			t.Fatalf("job %s = %+v, want %s", id, job, status)
+		}
+	}
+
+	if err := recovered.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for id, status := range want {
+		if job, ok := store.jobs[id]; !ok || job.Status != status {
+			t.Fatalf("stored job %s = %+v, want %s", id, job, status)
+		}
+	}
+}
+
+func TestRestartKeepsQueueOrder(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried := q.Submit(&Job{Name: "retried", MaxRetries: 1})
+	q.Dequeue("worker-1", ResourceRequirements{})
+	waiting := q.Submit(&Job{Name: "waiting"})
+	// The retry queues behind the job submitted before it failed
+	q.Complete(retried, errors.New("oom"))
+
+	q, err = openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := q.Submit(&Job{Name: "later"})
+
+	q, err = openPersistent(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{waiting, retried, later} {
+		if next := q.Dequeue("worker-1", ResourceRequirements{}); next == nil || next.ID != want {
+			t.Fatalf("next = %+v, want %s", next, want)
+		}
+	}
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"errors"
+	"time"
+)
+
+// runTimeWindow is how many recent runs the ETA average covers.
+const runTimeWindow = 20
+
+// ErrNotPending is returned by Position and ETA for a job that is not
+// waiting in the queue.
+var ErrNotPending = errors.New("job is not pending")
+
+// Position returns how many pending jobs will be dequeued before jobID,
+// so 0 means it is next. Jobs ahead of it in priority are counted even
+// if they might be skipped for lack of resources.
+func (q *JobQueue) Position(jobID string) (int, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.position(jobID)
+}
+
+// ETA estimates how long jobID will wait before starting: the jobs ahead
+// of it, times the average recent run time, spread over the jobs running
+// now. It is 0 until some run has finished.
+func (q *JobQueue) ETA(jobID string) (time.Duration, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	ahead, err := q.position(jobID)
+	if err != nil {
+		return 0, err
+	}
+	if len(q.runTimes) == 0 {
+		return 0, nil
+	}
+
+	var total time.Duration
+	for _, d := range q.runTimes {
+		total += d
+	}
+	avg := total / time.Duration(len(q.runTimes))
+
+	workers := len(q.running)
+	if workers == 0 {
+		workers = 1
+	}
+	return avg * time.Duration(ahead) / time.Duration(workers), nil
+}
+
+// position is Position without locking. Callers must hold q.mu.
+func (q *JobQueue) position(jobID string) (int, error) {
+	var job *Job
+	for _, j := range q.pending {
+		if j.ID == jobID {
+			job = j
+			break
+		}
+	}
+	if job == nil {
+		return 0, ErrNotPending
+	}
+
+	ahead := 0
+	for _, j := range q.pending {
+		if j.Priority > job.Priority || (j.Priority == job.Priority && j.QueueSeq < job.QueueSeq) {
+			ahead++
+		}
+	}
+	return ahead, nil
+}
+
+// observeRunTime records how long a finished run took. Callers must hold
+// q.mu.
+func (q *JobQueue) observeRunTime(d time.Duration) {
+	q.runTimes = append(q.runTimes, d)
+	if len(q.runTimes) > runTimeWindow {
+		q.runTimes = q.runTimes[len(q.runTimes)-runTimeWindow:]
+	}
+}
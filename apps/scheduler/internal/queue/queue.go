@@ -2,6 +2,8 @@
 package queue
 
 import (
+	"container/heap"
+	"strings"
 	"sync"
 	"time"
 
@@ -43,6 +45,58 @@ type Job struct {
 	CompletedAt *time.Time             `json:"completed_at,omitempty"`
 	Error       string                 `json:"error,omitempty"`
 	WorkerID    string                 `json:"worker_id,omitempty"`
+	MaxRetries  int                    `json:"max_retries,omitempty"` // failed runs to retry
+	Attempts    int                    `json:"attempts"`              // runs started
+	QueueSeq    uint64                 `json:"queue_seq,omitempty"`   // queue order, breaks priority ties
+
+	index int // heap index while pending
+}
+
+// snapshot returns a deep copy of the job that is safe to read after q.mu
+// is released. Heap bookkeeping is reset so the copy is never mistaken for
+// a queued entry.
+func (j *Job) snapshot() *Job {
+	c := *j
+	c.index = -1
+	c.Config = copyConfig(j.Config)
+	c.StartedAt = copyTime(j.StartedAt)
+	c.CompletedAt = copyTime(j.CompletedAt)
+	return &c
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// copyConfig deep-copies the JSON-shaped values a job config can hold.
+func copyConfig(cfg map[string]interface{}) map[string]interface{} {
+	if cfg == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		c[k] = copyConfigValue(v)
+	}
+	return c
+}
+
+func copyConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyConfig(v)
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, elem := range v {
+			c[i] = copyConfigValue(elem)
+		}
+		return c
+	default:
+		return v
+	}
 }
 
 // ResourceRequirements specifies resource needs.
@@ -53,18 +107,38 @@ type ResourceRequirements struct {
 	CPUs     int    `json:"cpus"`
 }
 
+// FitsIn reports whether a job needing r can run on a worker with
+// available resources free. A job that names a GPU type only fits a
+// worker offering that type.
+func (r ResourceRequirements) FitsIn(available ResourceRequirements) bool {
+	if r.GPUs > available.GPUs || r.MemoryGB > available.MemoryGB || r.CPUs > available.CPUs {
+		return false
+	}
+	return r.GPUType == "" || strings.EqualFold(r.GPUType, available.GPUType)
+}
+
 // JobQueue manages pending and running jobs.
 type JobQueue struct {
 	mu        sync.RWMutex
-	pending   []*Job
+	pending   pendingHeap
 	running   map[string]*Job
 	completed map[string]*Job
+	seq       uint64
+	runTimes  []time.Duration // most recent last, at most runTimeWindow
+
+	// Persistence, set up by EnablePersistence
+	store     Store
+	wal       *wal
+	dirty     map[string]Job // snapshots logged since the last flush
+	flushMu   sync.Mutex
+	stopCh    chan struct{}
+	flushDone chan struct{}
 }
 
 // NewJobQueue creates a new job queue.
 func NewJobQueue() *JobQueue {
 	return &JobQueue{
-		pending:   make([]*Job, 0),
+		pending:   make(pendingHeap, 0),
 		running:   make(map[string]*Job),
 		completed: make(map[string]*Job),
 	}
@@ -78,60 +152,165 @@ func (q *JobQueue) Submit(job *Job) string {
 	job.ID = uuid.New().String()
 	job.Status = JobPending
 	job.CreatedAt = time.Now()
+	job.Attempts = 0
+	q.insertPending(job)
+	q.record(job)
 
-	// Insert by priority
-	inserted := false
-	for i, existing := range q.pending {
-		if job.Priority > existing.Priority {
-			q.pending = append(q.pending[:i], append([]*Job{job}, q.pending[i:]...)...)
-			inserted = true
-			break
-		}
+	return job.ID
+}
+
+// pendingHeap orders pending jobs by priority, highest first, then by the
+// order they were queued in.
+type pendingHeap []*Job
+
+func (h pendingHeap) Len() int { return len(h) }
+
+func (h pendingHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].QueueSeq < h[j].QueueSeq
+}
+
+func (h pendingHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *pendingHeap) Push(x interface{}) {
+	job := x.(*Job)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+
+func (h *pendingHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	job := old[n-1]
+	old[n-1] = nil
+	job.index = -1
+	*h = old[:n-1]
+	return job
+}
+
+// insertPending queues job behind every pending job of equal or higher
+// priority. Callers must hold q.mu.
+func (q *JobQueue) insertPending(job *Job) {
+	q.seq++
+	job.QueueSeq = q.seq
+	heap.Push(&q.pending, job)
+}
+
+// Requeue moves a running job back to pending, e.g. after its hardware
+// failed. The interrupted run does not count against the job's retries.
+// It returns false if the job is not running.
+func (q *JobQueue) Requeue(jobID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.running[jobID]
+	if !ok {
+		return false
 	}
-	if !inserted {
-		q.pending = append(q.pending, job)
+	q.requeue(job)
+	return true
+}
+
+// RequeueWorker moves every job running on workerID back to pending, as
+// Requeue does, and returns their IDs.
+func (q *JobQueue) RequeueWorker(workerID string) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var jobIDs []string
+	for id, job := range q.running {
+		if job.WorkerID == workerID {
+			q.requeue(job)
+			jobIDs = append(jobIDs, id)
+		}
 	}
+	return jobIDs
+}
 
-	return job.ID
+// requeue moves a running job back to pending without charging the run
+// as an attempt. The job keeps its QueueSeq, so it regains its original place
+// ahead of anything submitted after it. Callers must hold q.mu.
+func (q *JobQueue) requeue(job *Job) {
+	delete(q.running, job.ID)
+	job.Attempts--
+	job.Status = JobPending
+	job.StartedAt = nil
+	job.WorkerID = ""
+	heap.Push(&q.pending, job)
+	q.record(job)
 }
 
-// Dequeue gets the next job for a worker.
+// Dequeue gets a snapshot of the next job for a worker.
 func (q *JobQueue) Dequeue(workerID string, available ResourceRequirements) *Job {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	for i, job := range q.pending {
+	// Pop in priority order until a job fits, then put back the ones
+	// skipped; their QueueSeq is unchanged so they keep their places.
+	var skipped []*Job
+	defer func() {
+		for _, job := range skipped {
+			heap.Push(&q.pending, job)
+		}
+	}()
+
+	for q.pending.Len() > 0 {
+		job := heap.Pop(&q.pending).(*Job)
 		// Check if worker can handle this job
-		if job.Resources.GPUs <= available.GPUs &&
-			job.Resources.MemoryGB <= available.MemoryGB {
-			// Remove from pending
-			q.pending = append(q.pending[:i], q.pending[i+1:]...)
-
-			// Mark as running
-			job.Status = JobRunning
-			now := time.Now()
-			job.StartedAt = &now
-			job.WorkerID = workerID
-
-			q.running[job.ID] = job
-			return job
+		if !job.Resources.FitsIn(available) {
+			skipped = append(skipped, job)
+			continue
 		}
+
+		// Mark as running
+		job.Status = JobRunning
+		now := time.Now()
+		job.StartedAt = &now
+		job.WorkerID = workerID
+		job.Attempts++
+
+		q.running[job.ID] = job
+		q.record(job)
+		return job.snapshot()
 	}
 
 	return nil
 }
 
-// Complete marks a job as completed.
-func (q *JobQueue) Complete(jobID string, err error) {
+// Complete marks a job as completed. A failed job with retries left goes
+// back to pending, keeping the error until a later run succeeds. It
+// returns the worker the job ran on, or false if the job was not running.
+func (q *JobQueue) Complete(jobID string, err error) (string, bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	job, ok := q.running[jobID]
 	if !ok {
-		return
+		return "", false
 	}
 
+	workerID := job.WorkerID
 	delete(q.running, jobID)
+	if job.StartedAt != nil {
+		q.observeRunTime(time.Since(*job.StartedAt))
+	}
+
+	if err != nil && job.Attempts <= job.MaxRetries {
+		job.Status = JobPending
+		job.Error = err.Error()
+		job.StartedAt = nil
+		job.WorkerID = ""
+		q.insertPending(job)
+		q.record(job)
+		return workerID, true
+	}
+
 	now := time.Now()
 	job.CompletedAt = &now
 
@@ -140,9 +319,12 @@ func (q *JobQueue) Complete(jobID string, err error) {
 		job.Error = err.Error()
 	} else {
 		job.Status = JobCompleted
+		job.Error = ""
 	}
 
 	q.completed[jobID] = job
+	q.record(job)
+	return workerID, true
 }
 
 // Cancel cancels a pending job.
@@ -151,11 +333,12 @@ func (q *JobQueue) Cancel(jobID string) bool {
 	defer q.mu.Unlock()
 
 	// Check pending
-	for i, job := range q.pending {
+	for _, job := range q.pending {
 		if job.ID == jobID {
-			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			heap.Remove(&q.pending, job.index)
 			job.Status = JobCancelled
 			q.completed[jobID] = job
+			q.record(job)
 			return true
 		}
 	}
@@ -163,20 +346,20 @@ func (q *JobQueue) Cancel(jobID string) bool {
 	return false
 }
 
-// GetJob retrieves a job by ID.
+// GetJob retrieves a snapshot of a job by ID.
 func (q *JobQueue) GetJob(jobID string) *Job {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 
 	if job, ok := q.running[jobID]; ok {
-		return job
+		return job.snapshot()
 	}
 	if job, ok := q.completed[jobID]; ok {
-		return job
+		return job.snapshot()
 	}
 	for _, job := range q.pending {
 		if job.ID == jobID {
-			return job
+			return job.snapshot()
 		}
 	}
 
@@ -0,0 +1,287 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequeueWorker(t *testing.T) {
+	q := NewJobQueue()
+	a := q.Submit(&Job{})
+	b := q.Submit(&Job{})
+	c := q.Submit(&Job{})
+	q.Dequeue("worker-1", ResourceRequirements{})
+	q.Dequeue("worker-2", ResourceRequirements{})
+	q.Dequeue("worker-1", ResourceRequirements{})
+	d := q.Submit(&Job{})
+
+	requeued := q.RequeueWorker("worker-1")
+	if len(requeued) != 2 {
+		t.Fatalf("requeued = %v, want 2 jobs", requeued)
+	}
+	for _, id := range []string{a, c} {
+		job := q.GetJob(id)
+		if job.Status != JobPending || job.WorkerID != "" || job.Attempts != 0 {
+			t.Fatalf("job %s = %+v, want pending with no attempts", id, job)
+		}
+	}
+	if job := q.GetJob(b); job.Status != JobRunning {
+		t.Fatalf("job on worker-2 status = %s, want running", job.Status)
+	}
+	// Requeued jobs keep their place ahead of later submissions
+	for _, want := range []string{a, c, d} {
+		if next := q.Dequeue("worker-3", ResourceRequirements{}); next.ID != want {
+			t.Fatalf("next = %s, want %s", next.ID, want)
+		}
+	}
+}
+
+func TestPositionTracksHigherPriorityArrivals(t *testing.T) {
+	q := NewJobQueue()
+	first := q.Submit(&Job{Priority: PriorityNormal})
+	mine := q.Submit(&Job{Priority: PriorityNormal})
+
+	assertPosition := func(want int) {
+		t.Helper()
+		pos, err := q.Position(mine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos != want {
+			t.Fatalf("position = %d, want %d", pos, want)
+		}
+	}
+
+	assertPosition(1)
+	q.Submit(&Job{Priority: PriorityLow})
+	assertPosition(1)
+	q.Submit(&Job{Priority: PriorityHigh})
+	assertPosition(2)
+	q.Submit(&Job{Priority: PriorityCritical})
+	assertPosition(3)
+	q.Submit(&Job{Priority: PriorityNormal})
+	assertPosition(3)
+
+	// Dequeuing the jobs ahead moves it up
+	q.Dequeue("worker-1", ResourceRequirements{})
+	q.Dequeue("worker-1", ResourceRequirements{})
+	assertPosition(1)
+	if job := q.Dequeue("worker-1", ResourceRequirements{}); job.ID != first {
+		t.Fatalf("dequeued %s, want %s", job.ID, first)
+	}
+	assertPosition(0)
+
+	q.Dequeue("worker-1", ResourceRequirements{})
+	if _, err := q.Position(mine); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("Position of a running job: err = %v, want ErrNotPending", err)
+	}
+}
+
+// TestGetJobReturnsSnapshot reads jobs returned by GetJob and Dequeue
+// while the queue keeps changing them; run with -race.
+func TestGetJobReturnsSnapshot(t *testing.T) {
+	q := NewJobQueue()
+	id := q.Submit(&Job{MaxRetries: 1, Config: map[string]interface{}{"lora": map[string]interface{}{"rank": 8}}})
+	pending := q.GetJob(id)
+	running := q.Dequeue("worker-1", ResourceRequirements{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Complete(id, errors.New("oom"))
+		q.Dequeue("worker-2", ResourceRequirements{})
+		q.Complete(id, nil)
+	}()
+	// Deliberately unsynchronized with the changes above
+	time.Sleep(10 * time.Millisecond)
+	if pending.Status != JobPending || pending.WorkerID != "" {
+		t.Errorf("pending snapshot changed: %+v", pending)
+	}
+	if running.Status != JobRunning || running.WorkerID != "worker-1" || running.Attempts != 1 || running.Error != "" {
+		t.Errorf("running snapshot changed: %+v", running)
+	}
+	<-done
+
+	pending.Config["lora"].(map[string]interface{})["rank"] = 64
+	if rank := q.GetJob(id).Config["lora"].(map[string]interface{})["rank"]; rank != 8 {
+		t.Fatalf("rank = %v, want 8: snapshot shares config with the queue", rank)
+	}
+}
+
+func TestFitsIn(t *testing.T) {
+	worker := ResourceRequirements{GPUs: 4, GPUType: "A100", MemoryGB: 64, CPUs: 8}
+
+	tests := []struct {
+		name string
+		need ResourceRequirements
+		want bool
+	}{
+		{"nothing", ResourceRequirements{}, true},
+		{"exactly everything", ResourceRequirements{GPUs: 4, MemoryGB: 64, CPUs: 8}, true},
+		{"too many CPUs", ResourceRequirements{GPUs: 1, MemoryGB: 16, CPUs: 32}, false},
+		{"too many GPUs", ResourceRequirements{GPUs: 8}, false},
+		{"too much memory", ResourceRequirements{MemoryGB: 128}, false},
+		{"matching GPU type", ResourceRequirements{GPUs: 1, GPUType: "A100"}, true},
+		{"GPU type ignores case", ResourceRequirements{GPUs: 1, GPUType: "a100"}, true},
+		{"other GPU type", ResourceRequirements{GPUs: 1, GPUType: "H100"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.need.FitsIn(worker); got != tt.want {
+			t.Errorf("%s: FitsIn = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	// A worker that doesn't report a type only takes untyped jobs
+	if (ResourceRequirements{GPUType: "A100"}).FitsIn(ResourceRequirements{GPUs: 1}) {
+		t.Error("typed job fit a worker of unknown GPU type")
+	}
+}
+
+func TestDequeueSkipsJobsThatDoNotFit(t *testing.T) {
+	q := NewJobQueue()
+	cpuHeavy := q.Submit(&Job{Priority: PriorityHigh, Resources: ResourceRequirements{GPUs: 1, MemoryGB: 16, CPUs: 32}})
+	h100 := q.Submit(&Job{Priority: PriorityHigh, Resources: ResourceRequirements{GPUs: 1, GPUType: "H100", CPUs: 1}})
+	small := q.Submit(&Job{Priority: PriorityLow, Resources: ResourceRequirements{GPUs: 1, MemoryGB: 16, CPUs: 2}})
+
+	// Enough GPUs and memory for every job, but only 2 CPUs and A100s
+	worker := ResourceRequirements{GPUs: 2, GPUType: "A100", MemoryGB: 80, CPUs: 2}
+	job := q.Dequeue("worker-1", worker)
+	if job == nil || job.ID != small {
+		t.Fatalf("dequeued %+v, want the low-priority job that fits", job)
+	}
+	if next := q.Dequeue("worker-1", worker); next != nil {
+		t.Fatalf("dequeued %s, want nothing else to fit", next.ID)
+	}
+	for _, id := range []string{cpuHeavy, h100} {
+		if job := q.GetJob(id); job.Status != JobPending || job.Attempts != 0 {
+			t.Fatalf("skipped job %s = %+v, want still pending", id, job)
+		}
+	}
+
+	// Skipped jobs keep their places for a worker they do fit
+	big := ResourceRequirements{GPUs: 8, GPUType: "H100", MemoryGB: 640, CPUs: 64}
+	for _, want := range []string{cpuHeavy, h100} {
+		if job := q.Dequeue("worker-2", big); job == nil || job.ID != want {
+			t.Fatalf("dequeued %+v, want %s", job, want)
+		}
+	}
+}
+
+func TestPendingOrderByPriorityThenArrival(t *testing.T) {
+	q := NewJobQueue()
+	priorities := []JobPriority{PriorityNormal, PriorityLow, PriorityCritical, PriorityNormal, PriorityHigh,
+		PriorityLow, PriorityNormal, PriorityCritical, PriorityHigh, PriorityNormal, PriorityLow, PriorityNormal}
+
+	// Expected order: each level from critical down, in arrival order
+	byLevel := make(map[JobPriority][]string)
+	for i, p := range priorities {
+		id := q.Submit(&Job{Name: string(rune('a' + i)), Priority: p})
+		byLevel[p] = append(byLevel[p], id)
+	}
+	cancelled := byLevel[PriorityNormal][2]
+	if !q.Cancel(cancelled) {
+		t.Fatal("cancel failed")
+	}
+
+	var want []string
+	for p := PriorityCritical; p >= PriorityLow; p-- {
+		for _, id := range byLevel[p] {
+			if id != cancelled {
+				want = append(want, id)
+			}
+		}
+	}
+	for i, id := range want {
+		job := q.Dequeue("worker-1", ResourceRequirements{})
+		if job == nil || job.ID != id {
+			t.Fatalf("dequeue %d = %+v, want %s", i, job, q.GetJob(id).Name)
+		}
+	}
+	if job := q.Dequeue("worker-1", ResourceRequirements{}); job != nil {
+		t.Fatalf("dequeued %s from an empty queue", job.Name)
+	}
+}
+
+func TestEqualPriorityIsFIFOAcrossDequeues(t *testing.T) {
+	q := NewJobQueue()
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, q.Submit(&Job{Priority: PriorityNormal}))
+	}
+	// Interleave submissions with dequeues; later arrivals stay behind
+	if job := q.Dequeue("worker-1", ResourceRequirements{}); job.ID != ids[0] {
+		t.Fatalf("first = %s, want %s", job.ID, ids[0])
+	}
+	ids = append(ids, q.Submit(&Job{Priority: PriorityNormal}))
+	urgent := q.Submit(&Job{Priority: PriorityHigh})
+
+	want := append([]string{urgent}, ids[1:]...)
+	for _, id := range want {
+		if job := q.Dequeue("worker-1", ResourceRequirements{}); job.ID != id {
+			t.Fatalf("dequeued %s, want %s", job.ID, id)
+		}
+	}
+}
+
+func TestCompleteRetriesFailedJobs(t *testing.T) {
+	q := NewJobQueue()
+	id := q.Submit(&Job{MaxRetries: 2})
+	other := q.Submit(&Job{})
+
+	// Two failures go back to pending, behind the job queued meanwhile
+	for attempt := 1; attempt <= 2; attempt++ {
+		job := q.Dequeue("worker-1", ResourceRequirements{})
+		if job.ID != id || job.Attempts != attempt {
+			t.Fatalf("attempt %d: dequeued %s with %d attempts", attempt, job.ID, job.Attempts)
+		}
+		if worker, ok := q.Complete(id, errors.New("oom")); !ok || worker != "worker-1" {
+			t.Fatalf("Complete = %q, %v", worker, ok)
+		}
+		job = q.GetJob(id)
+		if job.Status != JobPending || job.WorkerID != "" || job.StartedAt != nil || job.Error != "oom" {
+			t.Fatalf("after failure %d = %+v, want pending with the error kept", attempt, job)
+		}
+		if attempt == 1 {
+			if next := q.Dequeue("worker-2", ResourceRequirements{}); next.ID != other {
+				t.Fatalf("dequeued %s, want the job submitted before the retry", next.ID)
+			}
+		}
+	}
+
+	if job := q.Dequeue("worker-1", ResourceRequirements{}); job.ID != id || job.Attempts != 3 {
+		t.Fatalf("third run = %+v", job)
+	}
+	q.Complete(id, nil)
+	job := q.GetJob(id)
+	if job.Status != JobCompleted || job.Error != "" || job.Attempts != 3 || job.CompletedAt == nil {
+		t.Fatalf("after success = %+v, want completed on attempt 3 with the error cleared", job)
+	}
+}
+
+func TestCompleteFailsAfterRetriesExhausted(t *testing.T) {
+	q := NewJobQueue()
+	id := q.Submit(&Job{MaxRetries: 1})
+
+	for _, msg := range []string{"oom", "nccl timeout"} {
+		q.Dequeue("worker-1", ResourceRequirements{})
+		q.Complete(id, errors.New(msg))
+	}
+	job := q.GetJob(id)
+	if job.Status != JobFailed || job.Error != "nccl timeout" || job.Attempts != 2 || job.CompletedAt == nil {
+		t.Fatalf("job = %+v, want failed after 2 attempts with the last error", job)
+	}
+	if next := q.Dequeue("worker-1", ResourceRequirements{}); next != nil {
+		t.Fatalf("failed job dequeued again: %+v", next)
+	}
+	if _, ok := q.Complete(id, nil); ok {
+		t.Fatal("Complete succeeded for a job that is not running")
+	}
+
+	// Without retries the first failure is final
+	once := q.Submit(&Job{})
+	q.Dequeue("worker-1", ResourceRequirements{})
+	q.Complete(once, errors.New("oom"))
+	if job := q.GetJob(once); job.Status != JobFailed {
+		t.Fatalf("job without retries = %s, want failed", job.Status)
+	}
+}
@@ -2,52 +2,157 @@
 package resources
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
+	"time"
+
+	"openlora/core/clock"
 )
 
 // GPU represents a GPU resource.
 type GPU struct {
-	ID       string `json:"id"`
-	Type     string `json:"type"` // "A100", "H100", etc.
-	MemoryGB int    `json:"memory_gb"`
-	InUse    bool   `json:"in_use"`
-	JobID    string `json:"job_id,omitempty"`
+	ID        string `json:"id"`
+	Type      string `json:"type"` // "A100", "H100", etc.
+	MemoryGB  int    `json:"memory_gb"`
+	InUse     bool   `json:"in_use"`
+	JobID     string `json:"job_id,omitempty"`
+	Unhealthy bool   `json:"unhealthy,omitempty"` // reported bad; never allocated
 }
 
 // Worker represents a training worker node.
 type Worker struct {
-	ID        string `json:"id"`
-	Address   string `json:"address"`
-	GPUs      []GPU  `json:"gpus"`
-	TotalCPUs int    `json:"total_cpus"`
-	UsedCPUs  int    `json:"used_cpus"`
-	MemoryGB  int    `json:"memory_gb"`
-	UsedMemGB int    `json:"used_memory_gb"`
-	Healthy   bool   `json:"healthy"`
+	ID        string    `json:"id"`
+	Address   string    `json:"address"`
+	GPUs      []GPU     `json:"gpus"`
+	TotalCPUs int       `json:"total_cpus"`
+	UsedCPUs  int       `json:"used_cpus"`
+	MemoryGB  int       `json:"memory_gb"`
+	UsedMemGB int       `json:"used_memory_gb"`
+	Healthy   bool      `json:"healthy"`
+	LastSeen  time.Time `json:"last_seen"`
 }
 
+// DefaultHeartbeatTimeout is how long a worker may go without a heartbeat
+// before it is considered dead.
+const DefaultHeartbeatTimeout = 30 * time.Second
+
 // ResourceManager tracks cluster resources.
 type ResourceManager struct {
-	mu      sync.RWMutex
-	workers map[string]*Worker
+	mu               sync.RWMutex
+	workers          map[string]*Worker
+	clock            clock.Clock
+	heartbeatTimeout time.Duration
+	stopCh           chan struct{}
+	stopOnce         sync.Once
 }
 
 // NewResourceManager creates a resource manager.
 func NewResourceManager() *ResourceManager {
 	return &ResourceManager{
-		workers: make(map[string]*Worker),
+		workers:          make(map[string]*Worker),
+		clock:            clock.Real{},
+		heartbeatTimeout: DefaultHeartbeatTimeout,
+		stopCh:           make(chan struct{}),
 	}
 }
 
+// SetClock replaces the clock used for heartbeat timing.
+func (rm *ResourceManager) SetClock(c clock.Clock) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.clock = c
+}
+
+// SetHeartbeatTimeout sets how long a worker may stay silent before
+// ReapStaleWorkers evicts it.
+func (rm *ResourceManager) SetHeartbeatTimeout(d time.Duration) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.heartbeatTimeout = d
+}
+
 // RegisterWorker adds a worker to the cluster.
 func (rm *ResourceManager) RegisterWorker(worker *Worker) {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
 
 	worker.Healthy = true
+	worker.LastSeen = rm.clock.Now()
 	rm.workers[worker.ID] = worker
 }
 
+// Heartbeat records that a worker is alive. A worker evicted for missing
+// heartbeats becomes healthy again.
+func (rm *ResourceManager) Heartbeat(workerID string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	worker, ok := rm.workers[workerID]
+	if !ok {
+		return ErrWorkerNotFound
+	}
+	worker.LastSeen = rm.clock.Now()
+	worker.Healthy = true
+	return nil
+}
+
+// ReapStaleWorkers marks every healthy worker that has not sent a
+// heartbeat within the timeout unhealthy and frees its GPUs. It returns
+// the IDs of the evicted workers so the caller can requeue their jobs.
+func (rm *ResourceManager) ReapStaleWorkers() []string {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	now := rm.clock.Now()
+	var evicted []string
+	for _, worker := range rm.workers {
+		if !worker.Healthy || now.Sub(worker.LastSeen) < rm.heartbeatTimeout {
+			continue
+		}
+		worker.Healthy = false
+		for i := range worker.GPUs {
+			worker.GPUs[i].InUse = false
+			worker.GPUs[i].JobID = ""
+		}
+		evicted = append(evicted, worker.ID)
+		slog.Warn("Worker missed heartbeats; evicted", "worker_id", worker.ID, "last_seen", worker.LastSeen)
+	}
+	return evicted
+}
+
+// StartReaper runs ReapStaleWorkers every interval until StopReaper is
+// called, passing the IDs of evicted workers to onEvict.
+func (rm *ResourceManager) StartReaper(interval time.Duration, onEvict func(workerIDs []string)) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		rm.reapOnTicks(ticker.C, onEvict)
+	}()
+}
+
+// reapOnTicks runs ReapStaleWorkers on every tick until StopReaper is
+// called. Staleness is judged by rm's clock, not the tick times, so tests
+// can drive it with a fake clock and hand-made ticks.
+func (rm *ResourceManager) reapOnTicks(ticks <-chan time.Time, onEvict func(workerIDs []string)) {
+	for {
+		select {
+		case <-rm.stopCh:
+			return
+		case <-ticks:
+			if workerIDs := rm.ReapStaleWorkers(); len(workerIDs) > 0 {
+				onEvict(workerIDs)
+			}
+		}
+	}
+}
+
+// StopReaper ends background eviction. It is safe to call more than once.
+func (rm *ResourceManager) StopReaper() {
+	rm.stopOnce.Do(func() { close(rm.stopCh) })
+}
+
 // DeregisterWorker removes a worker.
 func (rm *ResourceManager) DeregisterWorker(workerID string) {
 	rm.mu.Lock()
@@ -68,7 +173,7 @@ func (rm *ResourceManager) AllocateGPUs(workerID string, numGPUs int, jobID stri
 
 	allocated := make([]string, 0, numGPUs)
 	for i := range worker.GPUs {
-		if !worker.GPUs[i].InUse {
+		if !worker.GPUs[i].InUse && !worker.GPUs[i].Unhealthy {
 			worker.GPUs[i].InUse = true
 			worker.GPUs[i].JobID = jobID
 			allocated = append(allocated, worker.GPUs[i].ID)
@@ -114,6 +219,78 @@ func (rm *ResourceManager) ReleaseGPUs(workerID string, gpuIDs []string) {
 	}
 }
 
+// ReleaseJob frees every GPU on workerID held by jobID.
+func (rm *ResourceManager) ReleaseJob(workerID, jobID string) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	worker, ok := rm.workers[workerID]
+	if !ok {
+		return
+	}
+	for i := range worker.GPUs {
+		if worker.GPUs[i].InUse && worker.GPUs[i].JobID == jobID {
+			worker.GPUs[i].InUse = false
+			worker.GPUs[i].JobID = ""
+		}
+	}
+}
+
+var (
+	ErrWorkerNotFound = errors.New("worker not found")
+	ErrGPUNotFound    = errors.New("gpu not found")
+)
+
+// GPUHealthReport is a worker's view of one of its GPUs.
+type GPUHealthReport struct {
+	GPUID   string `json:"gpu_id"`
+	Healthy bool   `json:"healthy"`
+}
+
+// ReportGPUHealth marks a batch of a worker's GPUs healthy or unhealthy.
+// Unhealthy GPUs are no longer allocated. If a GPU was running a job,
+// every GPU held by that job on the worker is released and the job ID is
+// returned so the caller can requeue it. The batch is applied only if
+// every GPU in it is known; otherwise nothing changes.
+func (rm *ResourceManager) ReportGPUHealth(workerID string, reports []GPUHealthReport) ([]string, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	worker, ok := rm.workers[workerID]
+	if !ok {
+		return nil, ErrWorkerNotFound
+	}
+
+	gpus := make(map[string]*GPU, len(worker.GPUs))
+	for i := range worker.GPUs {
+		gpus[worker.GPUs[i].ID] = &worker.GPUs[i]
+	}
+	for _, rep := range reports {
+		if _, ok := gpus[rep.GPUID]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrGPUNotFound, rep.GPUID)
+		}
+	}
+
+	var jobIDs []string
+	for _, rep := range reports {
+		gpu := gpus[rep.GPUID]
+		gpu.Unhealthy = !rep.Healthy
+		if rep.Healthy || !gpu.InUse {
+			continue
+		}
+
+		jobID := gpu.JobID
+		for j := range worker.GPUs {
+			if worker.GPUs[j].JobID == jobID {
+				worker.GPUs[j].InUse = false
+				worker.GPUs[j].JobID = ""
+			}
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+	return jobIDs, nil
+}
+
 // GetAvailableResources returns available resources per worker.
 func (rm *ResourceManager) GetAvailableResources() map[string]AvailableResources {
 	rm.mu.RLock()
@@ -127,7 +304,7 @@ func (rm *ResourceManager) GetAvailableResources() map[string]AvailableResources
 
 		freeGPUs := 0
 		for _, gpu := range worker.GPUs {
-			if !gpu.InUse {
+			if !gpu.InUse && !gpu.Unhealthy {
 				freeGPUs++
 			}
 		}
@@ -156,6 +333,7 @@ func (rm *ResourceManager) ClusterStats() map[string]interface{} {
 
 	totalGPUs := 0
 	usedGPUs := 0
+	unhealthyGPUs := 0
 	healthyWorkers := 0
 
 	for _, worker := range rm.workers {
@@ -167,6 +345,9 @@ func (rm *ResourceManager) ClusterStats() map[string]interface{} {
 			if gpu.InUse {
 				usedGPUs++
 			}
+			if gpu.Unhealthy {
+				unhealthyGPUs++
+			}
 		}
 	}
 
@@ -175,6 +356,7 @@ func (rm *ResourceManager) ClusterStats() map[string]interface{} {
 		"healthy_workers": healthyWorkers,
 		"total_gpus":      totalGPUs,
 		"used_gpus":       usedGPUs,
+		"unhealthy_gpus":  unhealthyGPUs,
 		"gpu_utilization": float64(usedGPUs) / float64(totalGPUs+1) * 100,
 	}
 }
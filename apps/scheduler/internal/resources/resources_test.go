@@ -0,0 +1,108 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+)
+
+func newTestManager(t *testing.T) (*ResourceManager, *clock.Fake) {
+	t.Helper()
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	rm := NewResourceManager()
+	rm.SetClock(clk)
+	rm.SetHeartbeatTimeout(30 * time.Second)
+	rm.RegisterWorker(&Worker{ID: "worker-1", GPUs: []GPU{{ID: "gpu-0"}, {ID: "gpu-1"}}})
+	rm.RegisterWorker(&Worker{ID: "worker-2", GPUs: []GPU{{ID: "gpu-0"}}})
+	return rm, clk
+}
+
+func TestReapStaleWorkersEvictsSilentWorkers(t *testing.T) {
+	rm, clk := newTestManager(t)
+	if _, ok := rm.AllocateGPUs("worker-1", 2, "job-1"); !ok {
+		t.Fatal("allocate failed")
+	}
+
+	clk.Advance(20 * time.Second)
+	if err := rm.Heartbeat("worker-2"); err != nil {
+		t.Fatal(err)
+	}
+	if evicted := rm.ReapStaleWorkers(); len(evicted) != 0 {
+		t.Fatalf("evicted %v before the timeout", evicted)
+	}
+
+	clk.Advance(15 * time.Second)
+	evicted := rm.ReapStaleWorkers()
+	if len(evicted) != 1 || evicted[0] != "worker-1" {
+		t.Fatalf("evicted = %v, want [worker-1]", evicted)
+	}
+	if _, ok := rm.GetAvailableResources()["worker-1"]; ok {
+		t.Fatal("evicted worker still offers resources")
+	}
+	if evicted := rm.ReapStaleWorkers(); len(evicted) != 0 {
+		t.Fatalf("evicted %v twice", evicted)
+	}
+
+	if err := rm.Heartbeat("worker-1"); err != nil {
+		t.Fatal(err)
+	}
+	if free := rm.GetAvailableResources()["worker-1"].GPUs; free != 2 {
+		t.Fatalf("free GPUs after rejoining = %d, want 2", free)
+	}
+}
+
+func TestHeartbeatUnknownWorker(t *testing.T) {
+	rm, _ := newTestManager(t)
+	if err := rm.Heartbeat("nope"); err != ErrWorkerNotFound {
+		t.Fatalf("err = %v, want ErrWorkerNotFound", err)
+	}
+}
+
+func TestStopReaperTwice(t *testing.T) {
+	rm, _ := newTestManager(t)
+	rm.StartReaper(time.Hour, func([]string) {})
+	rm.StopReaper()
+	rm.StopReaper()
+}
+
+func TestReaperEvictsOnTick(t *testing.T) {
+	rm, clk := newTestManager(t)
+	ticks := make(chan time.Time)
+	evictions := make(chan []string, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rm.reapOnTicks(ticks, func(workerIDs []string) { evictions <- workerIDs })
+	}()
+
+	// Ticks are unbuffered, so each send returns only once the previous
+	// tick has been handled
+	clk.Advance(20 * time.Second)
+	if err := rm.Heartbeat("worker-2"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(15 * time.Second)
+	ticks <- clk.Now()
+	ticks <- clk.Now()
+	select {
+	case got := <-evictions:
+		if len(got) != 1 || got[0] != "worker-1" {
+			t.Fatalf("evicted = %v, want [worker-1]", got)
+		}
+	default:
+		t.Fatal("silent worker was not evicted")
+	}
+
+	// Nothing newly stale, so onEvict isn't called
+	ticks <- clk.Now()
+	ticks <- clk.Now()
+	select {
+	case got := <-evictions:
+		t.Fatalf("evicted %v with no stale workers", got)
+	default:
+	}
+
+	rm.StopReaper()
+	<-done
+}
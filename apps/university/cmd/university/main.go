@@ -3,28 +3,37 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
+	"openlora/core/logging"
+	"openlora/core/svcauth"
 	"openlora/university/internal/api"
 	"openlora/university/internal/courses"
 )
 
 func main() {
-	log.Println("🎓 OpenUniversity Service starting...")
+	logging.Setup("university")
+	slog.Info("🎓 OpenUniversity Service starting...")
 
 	// Initialize course manager
 	courseMgr := courses.NewManager()
+	if secret := os.Getenv("CERTIFICATE_SECRET"); secret != "" {
+		courseMgr.SetCertificateKey([]byte(secret))
+	} else {
+		slog.Warn("CERTIFICATE_SECRET not set; certificates will not verify after a restart")
+	}
 	server := api.NewServer(courseMgr)
+	server.SetAdminToken(os.Getenv("UNIVERSITY_ADMIN_TOKEN"))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8088"
 	}
 
-	log.Printf("🌐 Listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	slog.Info("🌐 Listening", "port", port)
+	if err := http.ListenAndServe(":"+port, svcauth.Middleware(os.Getenv(svcauth.SecretEnv), server)); err != nil {
+		logging.Fatal("Server failed", "error", err)
 	}
 }
@@ -0,0 +1,130 @@
+package courses
+
+import (
+	"testing"
+	"time"
+)
+
+// completeLoRA101 walks userID through every module of the seeded
+// lora-101 course: a reading, a quiz and a lab.
+func completeLoRA101(t *testing.T, m *Manager, userID string) {
+	t.Helper()
+	if err := m.UpdateProgress(userID, "lora-101", "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, passed, err := m.SubmitQuiz(userID, "lora-101", "m2", []int{1, 1}); err != nil || !passed {
+		t.Fatalf("quiz: passed %v, %v", passed, err)
+	}
+	if err := m.UpdateProgress(userID, "lora-101", "m3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SubmitLab(userID, "lora-101", "lab-BasicTune", LabPassed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertificateIssuedOnceAtCompletion(t *testing.T) {
+	m := NewManager()
+	if err := m.Enroll("alice", "lora-101"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing until the last module completes
+	if err := m.UpdateProgress("alice", "lora-101", "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.SubmitQuiz("alice", "lora-101", "m2", []int{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateProgress("alice", "lora-101", "m3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetCertificate("alice", "lora-101"); err == nil {
+		t.Fatal("certificate issued before the lab passed")
+	}
+	if err := m.SubmitLab("alice", "lora-101", "lab-BasicTune", LabPassed); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := m.GetCertificate("alice", "lora-101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.UserID != "alice" || cert.CourseID != "lora-101" || cert.ID == "" || cert.IssuedAt.IsZero() {
+		t.Fatalf("certificate = %+v", cert)
+	}
+	if !m.VerifyCertificate(cert) {
+		t.Fatal("issued certificate does not verify")
+	}
+
+	// Progress that stays at or returns to 100% doesn't reissue
+	if err := m.UpdateProgress("alice", "lora-101", "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SubmitLab("alice", "lora-101", "lab-BasicTune", LabFailed); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SubmitLab("alice", "lora-101", "lab-BasicTune", LabPassed); err != nil {
+		t.Fatal(err)
+	}
+	again, err := m.GetCertificate("alice", "lora-101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *again != *cert {
+		t.Fatalf("certificate reissued: %+v, was %+v", again, cert)
+	}
+
+	if _, err := m.GetCertificate("bob", "lora-101"); err == nil {
+		t.Error("certificate found for a user who never enrolled")
+	}
+}
+
+func TestVerifyCertificateRejectsTampering(t *testing.T) {
+	m := NewManager()
+	m.SetCertificateKey([]byte("test-key"))
+	if err := m.Enroll("alice", "lora-101"); err != nil {
+		t.Fatal(err)
+	}
+	completeLoRA101(t, m, "alice")
+	cert, err := m.GetCertificate("alice", "lora-101")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(c *Certificate)
+	}{
+		{"user", func(c *Certificate) { c.UserID = "mallory" }},
+		{"course", func(c *Certificate) { c.CourseID = "ops-201" }},
+		{"issued at", func(c *Certificate) { c.IssuedAt = c.IssuedAt.Add(-time.Hour) }},
+		{"hash", func(c *Certificate) {
+			flipped := "0"
+			if c.Hash[len(c.Hash)-1] == '0' {
+				flipped = "1"
+			}
+			c.Hash = c.Hash[:len(c.Hash)-1] + flipped
+		}},
+		{"not hex", func(c *Certificate) { c.Hash = "not-a-hash" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *cert
+			tt.tamper(&c)
+			if m.VerifyCertificate(&c) {
+				t.Fatalf("tampered certificate verified: %+v", c)
+			}
+		})
+	}
+
+	// Only a manager holding the same key can verify it
+	other := NewManager()
+	if other.VerifyCertificate(cert) {
+		t.Error("certificate verified under a different key")
+	}
+	other.SetCertificateKey([]byte("test-key"))
+	if !other.VerifyCertificate(cert) {
+		t.Error("certificate did not verify under the same key")
+	}
+}
@@ -3,19 +3,23 @@ package courses
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 // Course represents an educational course.
 type Course struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Level       string    `json:"level"` // Beginner, Intermediate, Advanced
-	Modules     []Module  `json:"modules"`
-	Tags        []string  `json:"tags"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Level         string    `json:"level"` // Beginner, Intermediate, Advanced
+	Modules       []Module  `json:"modules"`
+	Tags          []string  `json:"tags"`
+	Prerequisites []string  `json:"prerequisites,omitempty"` // Course IDs to complete first
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 // Module represents a section of a course.
@@ -24,32 +28,49 @@ type Module struct {
 	Title    string `json:"title"`
 	Content  string `json:"content_url"`      // Link to markdown/video
 	LabID    string `json:"lab_id,omitempty"` // Link to interactive lab
+	Quiz     *Quiz  `json:"quiz,omitempty"`
 	Duration int    `json:"duration_min"`
 }
 
-// Enrollment tracks a user's progress in a course.
+// Lab statuses accepted by SubmitLab. Only a passed lab lets its module
+// count toward progress.
+const (
+	LabInProgress = "in_progress"
+	LabFailed     = "failed"
+	LabPassed     = "passed"
+)
+
+// Enrollment tracks a user's progress in a course. CompletedMods lists the
+// modules the user has finished reading; a module with a lab only counts
+// toward Progress once its lab has passed.
 type Enrollment struct {
-	UserID        string            `json:"user_id"`
-	CourseID      string            `json:"course_id"`
-	Progress      float64           `json:"progress"` // 0-100
-	CompletedMods []string          `json:"completed_modules"`
-	LabStatus     map[string]string `json:"lab_status"` // lab_id -> status
-	StartedAt     time.Time         `json:"started_at"`
-	LastActiveAt  time.Time         `json:"last_active_at"`
+	UserID        string             `json:"user_id"`
+	CourseID      string             `json:"course_id"`
+	Progress      float64            `json:"progress"` // 0-100
+	CompletedMods []string           `json:"completed_modules"`
+	LabStatus     map[string]string  `json:"lab_status"`  // lab_id -> status
+	QuizScores    map[string]float64 `json:"quiz_scores"` // module_id -> best score
+	StartedAt     time.Time          `json:"started_at"`
+	LastActiveAt  time.Time          `json:"last_active_at"`
+	CompletedAt   *time.Time         `json:"completed_at,omitempty"` // first reached 100%
 }
 
 // Manager handles course logic.
 type Manager struct {
-	mu          sync.RWMutex
-	courses     map[string]*Course
-	enrollments map[string]*Enrollment // Key: userID:courseID
+	mu           sync.RWMutex
+	courses      map[string]*Course
+	enrollments  map[string]*Enrollment  // Key: userID:courseID
+	certificates map[string]*Certificate // Key: userID:courseID
+	certKey      []byte
 }
 
 // NewManager creates a new course manager.
 func NewManager() *Manager {
 	m := &Manager{
-		courses:     make(map[string]*Course),
-		enrollments: make(map[string]*Enrollment),
+		courses:      make(map[string]*Course),
+		enrollments:  make(map[string]*Enrollment),
+		certificates: make(map[string]*Certificate),
+		certKey:      randomKey(),
 	}
 	m.seedCourses()
 	return m
@@ -78,12 +99,36 @@ func (m *Manager) GetCourse(id string) (*Course, error) {
 	return nil, errors.New("course not found")
 }
 
-// Enroll signs a user up for a course.
+// PrerequisiteError is returned by Enroll when the user has not completed
+// every prerequisite of the course.
+type PrerequisiteError struct {
+	CourseID string
+	Missing  []string
+}
+
+func (e *PrerequisiteError) Error() string {
+	return fmt.Sprintf("course %s requires completing %s first", e.CourseID, strings.Join(e.Missing, ", "))
+}
+
+// EnrollOptions modifies how Enroll validates an enrollment.
+type EnrollOptions struct {
+	// IgnorePrerequisites enrolls the user even if prerequisites are unmet.
+	IgnorePrerequisites bool
+}
+
+// Enroll signs a user up for a course. A course is completed once its
+// certificate has been issued, and every prerequisite must be.
 func (m *Manager) Enroll(userID, courseID string) error {
+	return m.EnrollWith(userID, courseID, EnrollOptions{})
+}
+
+// EnrollWith signs a user up for a course with the given options.
+func (m *Manager) EnrollWith(userID, courseID string, opts EnrollOptions) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, ok := m.courses[courseID]; !ok {
+	course, ok := m.courses[courseID]
+	if !ok {
 		return errors.New("course not found")
 	}
 
@@ -92,6 +137,18 @@ func (m *Manager) Enroll(userID, courseID string) error {
 		return errors.New("already enrolled")
 	}
 
+	if !opts.IgnorePrerequisites {
+		var missing []string
+		for _, prereq := range course.Prerequisites {
+			if _, done := m.certificates[userID+":"+prereq]; !done {
+				missing = append(missing, prereq)
+			}
+		}
+		if len(missing) > 0 {
+			return &PrerequisiteError{CourseID: courseID, Missing: missing}
+		}
+	}
+
 	m.enrollments[key] = &Enrollment{
 		UserID:       userID,
 		CourseID:     courseID,
@@ -99,12 +156,14 @@ func (m *Manager) Enroll(userID, courseID string) error {
 		StartedAt:    time.Now(),
 		LastActiveAt: time.Now(),
 		LabStatus:    make(map[string]string),
+		QuizScores:   make(map[string]float64),
 	}
 
 	return nil
 }
 
-// UpdateProgress updates module completion status.
+// UpdateProgress updates module completion status. Completing the last
+// module issues the course certificate.
 func (m *Manager) UpdateProgress(userID, courseID, moduleID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -139,13 +198,85 @@ func (m *Manager) UpdateProgress(userID, courseID, moduleID string) error {
 
 	if !alreadyCompleted {
 		enrollment.CompletedMods = append(enrollment.CompletedMods, moduleID)
-		enrollment.Progress = float64(len(enrollment.CompletedMods)) / float64(len(course.Modules)) * 100.0
 	}
 	enrollment.LastActiveAt = time.Now()
+	m.recomputeProgress(course, enrollment)
+
+	return nil
+}
+
+// SubmitLab records the outcome of a lab attempt.
+func (m *Manager) SubmitLab(userID, courseID, labID, status string) error {
+	switch status {
+	case LabInProgress, LabFailed, LabPassed:
+	default:
+		return fmt.Errorf("invalid lab status %q", status)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := userID + ":" + courseID
+	enrollment, ok := m.enrollments[key]
+	if !ok {
+		return errors.New("not enrolled")
+	}
+
+	course := m.courses[courseID]
+	validLab := false
+	for _, mod := range course.Modules {
+		if mod.LabID != "" && mod.LabID == labID {
+			validLab = true
+			break
+		}
+	}
+	if !validLab {
+		return errors.New("lab not found")
+	}
+
+	enrollment.LabStatus[labID] = status
+	enrollment.LastActiveAt = time.Now()
+	m.recomputeProgress(course, enrollment)
 
 	return nil
 }
 
+// recomputeProgress sets Progress from the modules that are complete,
+// counting a module with a lab or quiz only once it has been passed, and
+// issues the certificate when every module is. Callers must hold m.mu.
+func (m *Manager) recomputeProgress(course *Course, enrollment *Enrollment) {
+	completed := make(map[string]bool, len(enrollment.CompletedMods))
+	for _, id := range enrollment.CompletedMods {
+		completed[id] = true
+	}
+
+	done := 0
+	for _, mod := range course.Modules {
+		if !completed[mod.ID] {
+			continue
+		}
+		if mod.LabID != "" && enrollment.LabStatus[mod.LabID] != LabPassed {
+			continue
+		}
+		if mod.Quiz != nil && enrollment.QuizScores[mod.ID] < mod.Quiz.threshold() {
+			continue
+		}
+		done++
+	}
+	if len(course.Modules) == 0 {
+		return
+	}
+	enrollment.Progress = float64(done) / float64(len(course.Modules)) * 100.0
+
+	if done == len(course.Modules) {
+		if enrollment.CompletedAt == nil {
+			now := time.Now()
+			enrollment.CompletedAt = &now
+		}
+		m.issueCertificate(enrollment)
+	}
+}
+
 // GetEnrollment retrieves user progress.
 func (m *Manager) GetEnrollment(userID, courseID string) (*Enrollment, error) {
 	m.mu.RLock()
@@ -158,19 +289,80 @@ func (m *Manager) GetEnrollment(userID, courseID string) (*Enrollment, error) {
 	return nil, errors.New("enrollment not found")
 }
 
+// GetUserProgress lists every enrollment of a user, ordered by course ID.
+func (m *Manager) GetUserProgress(userID string) []*Enrollment {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]*Enrollment, 0)
+	for _, e := range m.enrollments {
+		if e.UserID == userID {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CourseID < list[j].CourseID })
+	return list
+}
+
+// Leaderboard ranks a course's enrollments by progress, highest first.
+// Among users at the same progress, whoever completed the course earlier
+// ranks higher, then ties fall back to user ID. A limit of 0 or less
+// returns every enrollment.
+func (m *Manager) Leaderboard(courseID string, limit int) ([]*Enrollment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.courses[courseID]; !ok {
+		return nil, errors.New("course not found")
+	}
+
+	list := make([]*Enrollment, 0)
+	for _, e := range m.enrollments {
+		if e.CourseID == courseID {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Progress != b.Progress {
+			return a.Progress > b.Progress
+		}
+		if (a.CompletedAt == nil) != (b.CompletedAt == nil) {
+			return a.CompletedAt != nil
+		}
+		if a.CompletedAt != nil && !a.CompletedAt.Equal(*b.CompletedAt) {
+			return a.CompletedAt.Before(*b.CompletedAt)
+		}
+		return a.UserID < b.UserID
+	})
+
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
 func (m *Manager) seedCourses() {
 	m.courses["lora-101"] = &Course{
 		ID: "lora-101", Title: "LoRA Fundamentals", Description: "Introduction to Low-Rank Adaptation.",
 		Level: "Beginner", Tags: []string{"theory", "basics"}, CreatedAt: time.Now(),
 		Modules: []Module{
 			{ID: "m1", Title: "What is LoRA?", Duration: 15},
-			{ID: "m2", Title: "Matrix Decomposition", Duration: 30},
+			{ID: "m2", Title: "Matrix Decomposition", Duration: 30, Quiz: &Quiz{
+				Questions: []Question{
+					{Prompt: "What does the rank r of a LoRA update control?",
+						Options: []string{"Learning rate", "Size of the low-rank matrices", "Number of layers"}, Answer: 1},
+					{Prompt: "Which weights are trained during LoRA fine-tuning?",
+						Options: []string{"All base weights", "Only the adapter matrices", "Only the embeddings"}, Answer: 1},
+				},
+			}},
 			{ID: "m3", Title: "First Fine-tune", LabID: "lab-BasicTune", Duration: 60},
 		},
 	}
 	m.courses["ops-201"] = &Course{
 		ID: "ops-201", Title: "Operational AI", Description: "Managing LoRA at scale.",
-		Level: "Intermediate", Tags: []string{"devops", "production"}, CreatedAt: time.Now(),
+		Level: "Intermediate", Tags: []string{"devops", "production"}, Prerequisites: []string{"lora-101"},
+		CreatedAt: time.Now(),
 		Modules: []Module{
 			{ID: "m1", Title: "Adapter Registries", Duration: 20},
 			{ID: "m2", Title: "Canary Deployments", LabID: "lab-Canary", Duration: 45},
@@ -0,0 +1,241 @@
+package courses
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// progress returns the user's progress in a course.
+func progress(t *testing.T, m *Manager, userID, courseID string) float64 {
+	t.Helper()
+	e, err := m.GetEnrollment(userID, courseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.Progress
+}
+
+func TestLabModuleCompletesOnlyWhenLabPasses(t *testing.T) {
+	m := NewManager()
+	if err := m.EnrollWith("alice", "ops-201", EnrollOptions{IgnorePrerequisites: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// m1 has no lab; m2 needs lab-Canary
+	if err := m.UpdateProgress("alice", "ops-201", "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateProgress("alice", "ops-201", "m2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := progress(t, m, "alice", "ops-201"); got != 50 {
+		t.Fatalf("progress with lab not started = %v, want 50", got)
+	}
+
+	for _, status := range []string{LabInProgress, LabFailed} {
+		if err := m.SubmitLab("alice", "ops-201", "lab-Canary", status); err != nil {
+			t.Fatal(err)
+		}
+		if got := progress(t, m, "alice", "ops-201"); got != 50 {
+			t.Fatalf("progress with lab %s = %v, want 50", status, got)
+		}
+	}
+	if e, _ := m.GetEnrollment("alice", "ops-201"); e.CompletedAt != nil {
+		t.Fatal("course completed before the lab passed")
+	}
+
+	if err := m.SubmitLab("alice", "ops-201", "lab-Canary", LabPassed); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := m.GetEnrollment("alice", "ops-201")
+	if e.Progress != 100 || e.CompletedAt == nil || e.LabStatus["lab-Canary"] != LabPassed {
+		t.Fatalf("after lab passed = %+v, want complete", e)
+	}
+}
+
+func TestLabPassedBeforeReadingStillNeedsModule(t *testing.T) {
+	m := NewManager()
+	if err := m.EnrollWith("alice", "ops-201", EnrollOptions{IgnorePrerequisites: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SubmitLab("alice", "ops-201", "lab-Canary", LabPassed); err != nil {
+		t.Fatal(err)
+	}
+	if got := progress(t, m, "alice", "ops-201"); got != 0 {
+		t.Fatalf("progress = %v, want 0 until m2 is read", got)
+	}
+	if err := m.UpdateProgress("alice", "ops-201", "m2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := progress(t, m, "alice", "ops-201"); got != 50 {
+		t.Fatalf("progress = %v, want 50", got)
+	}
+}
+
+func TestSubmitLabRejectsInvalid(t *testing.T) {
+	m := NewManager()
+	if err := m.EnrollWith("alice", "ops-201", EnrollOptions{IgnorePrerequisites: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, user, lab, status string
+	}{
+		{"unknown status", "alice", "lab-Canary", "done"},
+		{"unknown lab", "alice", "lab-BasicTune", LabPassed},
+		{"not enrolled", "bob", "lab-Canary", LabPassed},
+	}
+	for _, tt := range tests {
+		if err := m.SubmitLab(tt.user, "ops-201", tt.lab, tt.status); err == nil {
+			t.Errorf("%s: SubmitLab succeeded", tt.name)
+		}
+	}
+	if e, _ := m.GetEnrollment("alice", "ops-201"); len(e.LabStatus) != 0 {
+		t.Errorf("lab status = %v, want nothing recorded", e.LabStatus)
+	}
+}
+
+func TestEnrollRequiresPrerequisites(t *testing.T) {
+	m := NewManager()
+
+	err := m.Enroll("alice", "ops-201")
+	var prereq *PrerequisiteError
+	if !errors.As(err, &prereq) {
+		t.Fatalf("Enroll = %v, want a PrerequisiteError", err)
+	}
+	if prereq.CourseID != "ops-201" || !reflect.DeepEqual(prereq.Missing, []string{"lora-101"}) {
+		t.Fatalf("error = %+v, want lora-101 missing for ops-201", prereq)
+	}
+	if _, err := m.GetEnrollment("alice", "ops-201"); err == nil {
+		t.Fatal("blocked enrollment was recorded")
+	}
+
+	// Being enrolled, or partway through, isn't enough
+	if err := m.Enroll("alice", "lora-101"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateProgress("alice", "lora-101", "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Enroll("alice", "ops-201"); !errors.As(err, &prereq) {
+		t.Fatalf("Enroll with lora-101 in progress = %v, want a PrerequisiteError", err)
+	}
+
+	completeLoRA101(t, m, "alice")
+	if err := m.Enroll("alice", "ops-201"); err != nil {
+		t.Fatalf("Enroll after completing lora-101: %v", err)
+	}
+	// Another user's completion doesn't count
+	if err := m.Enroll("bob", "ops-201"); !errors.As(err, &prereq) {
+		t.Fatalf("Enroll for bob = %v, want a PrerequisiteError", err)
+	}
+}
+
+func TestEnrollAdminOverride(t *testing.T) {
+	m := NewManager()
+	if err := m.EnrollWith("alice", "ops-201", EnrollOptions{IgnorePrerequisites: true}); err != nil {
+		t.Fatalf("EnrollWith override: %v", err)
+	}
+	if _, err := m.GetEnrollment("alice", "ops-201"); err != nil {
+		t.Fatal(err)
+	}
+	// The override skips prerequisites only
+	if err := m.EnrollWith("alice", "ops-201", EnrollOptions{IgnorePrerequisites: true}); err == nil {
+		t.Error("duplicate enrollment succeeded with the override")
+	}
+	if err := m.EnrollWith("alice", "missing", EnrollOptions{IgnorePrerequisites: true}); err == nil {
+		t.Error("enrollment in an unknown course succeeded with the override")
+	}
+}
+
+// enrollmentUsers returns the user IDs of list in order.
+func enrollmentUsers(list []*Enrollment) []string {
+	out := make([]string, len(list))
+	for i, e := range list {
+		out[i] = e.UserID
+	}
+	return out
+}
+
+func TestLeaderboardOrdering(t *testing.T) {
+	m := NewManager()
+	for _, user := range []string{"frank", "erin", "dave", "carol", "bob", "alice", "zed", "gus"} {
+		if err := m.Enroll(user, "lora-101"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, user := range []string{"alice", "carol", "dave", "zed"} {
+		completeLoRA101(t, m, user)
+	}
+	for _, user := range []string{"bob", "erin", "gus"} {
+		if err := m.UpdateProgress(user, "lora-101", "m1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// zed drops back to two of three modules but keeps the completion;
+	// gus reaches two of three without ever completing
+	if err := m.SubmitLab("zed", "lora-101", "lab-BasicTune", LabFailed); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.SubmitQuiz("gus", "lora-101", "m2", []int{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pin completion times so the tie-breaks don't depend on the wall clock
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for user, offset := range map[string]time.Duration{"alice": time.Hour, "dave": time.Hour, "carol": 0, "zed": 0} {
+		at := base.Add(offset)
+		m.enrollments[user+":lora-101"].CompletedAt = &at
+	}
+
+	board, err := m.Leaderboard("lora-101", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100%: carol finished first, then alice and dave at the same time by
+	// ID. Two thirds: zed completed once, gus never did. One third: bob
+	// and erin by ID.
+	want := []string{"carol", "alice", "dave", "zed", "gus", "bob", "erin", "frank"}
+	if got := enrollmentUsers(board); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Leaderboard = %v, want %v", got, want)
+	}
+
+	if board, _ := m.Leaderboard("lora-101", 3); !reflect.DeepEqual(enrollmentUsers(board), want[:3]) {
+		t.Errorf("limit 3 = %v, want %v", enrollmentUsers(board), want[:3])
+	}
+	if board, _ := m.Leaderboard("ops-201", 0); len(board) != 0 {
+		t.Errorf("ops-201 = %v, want empty", enrollmentUsers(board))
+	}
+	if _, err := m.Leaderboard("missing", 0); err == nil {
+		t.Error("Leaderboard of an unknown course succeeded")
+	}
+}
+
+func TestGetUserProgress(t *testing.T) {
+	m := NewManager()
+	if err := m.Enroll("alice", "lora-101"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EnrollWith("alice", "ops-201", EnrollOptions{IgnorePrerequisites: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Enroll("bob", "lora-101"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateProgress("alice", "ops-201", "m1"); err != nil {
+		t.Fatal(err)
+	}
+
+	list := m.GetUserProgress("alice")
+	if len(list) != 2 || list[0].CourseID != "lora-101" || list[1].CourseID != "ops-201" {
+		t.Fatalf("GetUserProgress = %+v, want lora-101 then ops-201", list)
+	}
+	if list[0].Progress != 0 || list[1].Progress != 50 {
+		t.Errorf("progress = %v, %v; want 0, 50", list[0].Progress, list[1].Progress)
+	}
+	if list := m.GetUserProgress("nobody"); list == nil || len(list) != 0 {
+		t.Errorf("unknown user = %#v, want an empty list", list)
+	}
+}
@@ -0,0 +1,96 @@
+package courses
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultPassThreshold is the score a quiz needs when it sets none.
+const DefaultPassThreshold = 70.0
+
+// Quiz assesses a module. Completing the module requires a score of at
+// least PassThreshold percent.
+type Quiz struct {
+	Questions     []Question `json:"questions"`
+	PassThreshold float64    `json:"pass_threshold,omitempty"` // 0-100
+}
+
+// Question is a multiple-choice question. Answer is the index of the
+// correct option and is never sent to clients.
+type Question struct {
+	Prompt  string   `json:"prompt"`
+	Options []string `json:"options"`
+	Answer  int      `json:"-"`
+}
+
+func (q *Quiz) threshold() float64 {
+	if q.PassThreshold <= 0 {
+		return DefaultPassThreshold
+	}
+	return q.PassThreshold
+}
+
+// SubmitQuiz grades answers, one option index per question, against a
+// module's quiz and returns the score as a percentage. The user's best
+// score is kept, and a passing submission completes the module.
+func (m *Manager) SubmitQuiz(userID, courseID, moduleID string, answers []int) (score float64, passed bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := userID + ":" + courseID
+	enrollment, ok := m.enrollments[key]
+	if !ok {
+		return 0, false, errors.New("not enrolled")
+	}
+
+	var quiz *Quiz
+	for _, mod := range m.courses[courseID].Modules {
+		if mod.ID == moduleID {
+			quiz = mod.Quiz
+			if quiz == nil {
+				return 0, false, errors.New("module has no quiz")
+			}
+			break
+		}
+	}
+	if quiz == nil {
+		return 0, false, errors.New("module not found")
+	}
+	if len(answers) != len(quiz.Questions) {
+		return 0, false, fmt.Errorf("expected %d answers, got %d", len(quiz.Questions), len(answers))
+	}
+
+	correct := 0
+	for i, q := range quiz.Questions {
+		if answers[i] == q.Answer {
+			correct++
+		}
+	}
+	if len(quiz.Questions) > 0 {
+		score = float64(correct) / float64(len(quiz.Questions)) * 100.0
+	} else {
+		score = 100.0
+	}
+	passed = score >= quiz.threshold()
+
+	if best, ok := enrollment.QuizScores[moduleID]; !ok || score > best {
+		enrollment.QuizScores[moduleID] = score
+	}
+	if passed {
+		completed := false
+		for _, id := range enrollment.CompletedMods {
+			if id == moduleID {
+				completed = true
+				break
+			}
+		}
+		if !completed {
+			enrollment.CompletedMods = append(enrollment.CompletedMods, moduleID)
+		}
+	}
+	enrollment.LastActiveAt = time.Now()
+	m.recomputeProgress(m.courses[courseID], enrollment)
+
+	return score, passed, nil
+}
@@ -0,0 +1,105 @@
+package courses
+
+import (
+	"math"
+	"testing"
+)
+
+// newQuizManager returns a manager with alice enrolled in a course whose
+// only module is a four-question quiz passing at 75%.
+func newQuizManager(t *testing.T) *Manager {
+	t.Helper()
+	m := NewManager()
+	m.courses["quiz-101"] = &Course{ID: "quiz-101", Modules: []Module{
+		{ID: "q1", Quiz: &Quiz{PassThreshold: 75, Questions: []Question{
+			{Answer: 0}, {Answer: 1}, {Answer: 2}, {Answer: 3},
+		}}},
+	}}
+	if err := m.Enroll("alice", "quiz-101"); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSubmitQuizGradesAndKeepsBestScore(t *testing.T) {
+	m := newQuizManager(t)
+
+	tests := []struct {
+		name     string
+		answers  []int
+		score    float64
+		passed   bool
+		best     float64
+		progress float64
+	}{
+		{"fail", []int{0, 1, 0, 0}, 50, false, 50, 0},
+		{"at threshold", []int{0, 1, 2, 0}, 75, true, 75, 100},
+		{"worse retry", []int{0, 0, 0, 0}, 25, false, 75, 100},
+		{"perfect", []int{0, 1, 2, 3}, 100, true, 100, 100},
+		{"after perfect", []int{0, 1, 2, 0}, 75, true, 100, 100},
+	}
+	for _, tt := range tests {
+		score, passed, err := m.SubmitQuiz("alice", "quiz-101", "q1", tt.answers)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if score != tt.score || passed != tt.passed {
+			t.Errorf("%s: score %v passed %v, want %v %v", tt.name, score, passed, tt.score, tt.passed)
+		}
+		e, _ := m.GetEnrollment("alice", "quiz-101")
+		if e.QuizScores["q1"] != tt.best || e.Progress != tt.progress {
+			t.Errorf("%s: best %v progress %v, want %v %v", tt.name, e.QuizScores["q1"], e.Progress, tt.best, tt.progress)
+		}
+	}
+	e, _ := m.GetEnrollment("alice", "quiz-101")
+	if len(e.CompletedMods) != 1 {
+		t.Errorf("completed modules = %v, want q1 once", e.CompletedMods)
+	}
+}
+
+func TestQuizModuleNeedsPassingScore(t *testing.T) {
+	m := NewManager()
+	if err := m.Enroll("alice", "lora-101"); err != nil {
+		t.Fatal(err)
+	}
+	// Marking a quiz module read doesn't complete it; the seeded quiz
+	// uses the default 70% threshold, so one of two right fails
+	if err := m.UpdateProgress("alice", "lora-101", "m2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, passed, err := m.SubmitQuiz("alice", "lora-101", "m2", []int{1, 0}); err != nil || passed {
+		t.Fatalf("half right: passed %v, %v; want a fail", passed, err)
+	}
+	if got := progress(t, m, "alice", "lora-101"); got != 0 {
+		t.Fatalf("progress after failing = %v, want 0", got)
+	}
+	if _, passed, err := m.SubmitQuiz("alice", "lora-101", "m2", []int{1, 1}); err != nil || !passed {
+		t.Fatalf("all right: passed %v, %v; want a pass", passed, err)
+	}
+	if got := progress(t, m, "alice", "lora-101"); math.Abs(got-100.0/3) > 1e-9 {
+		t.Fatalf("progress after passing = %v, want one module of three", got)
+	}
+}
+
+func TestSubmitQuizRejectsInvalid(t *testing.T) {
+	m := newQuizManager(t)
+	m.courses["quiz-101"].Modules = append(m.courses["quiz-101"].Modules, Module{ID: "reading"})
+
+	tests := []struct {
+		name, user, module string
+		answers            []int
+	}{
+		{"not enrolled", "bob", "q1", []int{0, 1, 2, 3}},
+		{"unknown module", "alice", "q9", []int{0, 1, 2, 3}},
+		{"no quiz", "alice", "reading", []int{0}},
+		{"too few answers", "alice", "q1", []int{0, 1}},
+	}
+	for _, tt := range tests {
+		if _, _, err := m.SubmitQuiz(tt.user, "quiz-101", tt.module, tt.answers); err == nil {
+			t.Errorf("%s: SubmitQuiz succeeded", tt.name)
+		}
+	}
+	if e, _ := m.GetEnrollment("alice", "quiz-101"); len(e.QuizScores) != 0 {
+		t.Errorf("quiz scores = %v, want nothing recorded", e.QuizScores)
+	}
+}
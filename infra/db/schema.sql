@@ -46,9 +46,43 @@ CREATE TABLE adapters (
     parent_adapter_id UUID REFERENCES adapters(id),
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    downloads BIGINT NOT NULL DEFAULT 0,
+    deleted_at TIMESTAMPTZ,
     UNIQUE (name, version)
 );
 
+CREATE TABLE adapter_dependencies (
+    adapter_id UUID NOT NULL REFERENCES adapters(id),
+    depends_on_id UUID NOT NULL REFERENCES adapters(id),
+    dependency_type VARCHAR(20) NOT NULL CHECK (dependency_type IN ('requires', 'extends', 'conflicts')),
+    PRIMARY KEY (adapter_id, depends_on_id)
+);
+
+CREATE TABLE signing_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    public_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE adapter_signatures (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    adapter_id UUID NOT NULL REFERENCES adapters(id),
+    public_key_id VARCHAR(64) NOT NULL REFERENCES signing_keys(id),
+    signature BYTEA NOT NULL,
+    algo VARCHAR(20) NOT NULL,
+    signed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE compatibility_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    base_model_family VARCHAR(255) NOT NULL,
+    min_rank INTEGER NOT NULL DEFAULT 0,
+    max_rank INTEGER NOT NULL DEFAULT 0,
+    allowed_tasks JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 CREATE TABLE datasets (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     name VARCHAR(255) NOT NULL,
@@ -72,6 +106,48 @@ CREATE TABLE dataset_versions (
     UNIQUE (dataset_id, version)
 );
 
+CREATE TABLE dataset_schemas (
+    dataset_id UUID PRIMARY KEY REFERENCES datasets(id),
+    columns JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE dataset_version_tags (
+    dataset_id UUID NOT NULL REFERENCES datasets(id),
+    tag VARCHAR(100) NOT NULL,
+    version_id UUID NOT NULL REFERENCES dataset_versions(id),
+    version INTEGER NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (dataset_id, tag)
+);
+
+CREATE TABLE experiments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    owner_id VARCHAR(100) NOT NULL,
+    tags JSONB NOT NULL DEFAULT '[]',
+    config JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    experiment_id UUID NOT NULL REFERENCES experiments(id),
+    name VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    hyperparams JSONB NOT NULL DEFAULT '{}',
+    metrics JSONB NOT NULL DEFAULT '{}',
+    dataset_id VARCHAR(100) NOT NULL DEFAULT '',
+    adapter_id VARCHAR(100) NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempt INTEGER NOT NULL DEFAULT 1,
+    history JSONB NOT NULL DEFAULT '[]'
+);
+
 CREATE TABLE experiment_runs (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     adapter_id UUID REFERENCES adapters(id),
@@ -85,6 +161,49 @@ CREATE TABLE experiment_runs (
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- =============================================================================
+-- Deployments
+-- =============================================================================
+
+CREATE TABLE deployments (
+    id UUID PRIMARY KEY,
+    adapter_id VARCHAR(255) NOT NULL,
+    version INTEGER NOT NULL,
+    environment VARCHAR(20) NOT NULL,
+    region VARCHAR(50) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    replicas INTEGER NOT NULL DEFAULT 1,
+    traffic_percentage INTEGER NOT NULL DEFAULT 0,
+    max_in_flight INTEGER NOT NULL DEFAULT 0,
+    requests_per_second DOUBLE PRECISION NOT NULL DEFAULT 0,
+    config JSONB DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE deployment_revisions (
+    adapter_id VARCHAR(255) NOT NULL,
+    environment VARCHAR(20) NOT NULL,
+    revision INTEGER NOT NULL,
+    deployment_id UUID NOT NULL REFERENCES deployments(id),
+    version INTEGER NOT NULL,
+    replicas INTEGER NOT NULL,
+    traffic_percentage INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    rolled_back_to INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (adapter_id, environment, revision)
+);
+
+CREATE TABLE traffic_plans (
+    adapter_id VARCHAR(255) NOT NULL,
+    environment VARCHAR(20) NOT NULL,
+    weights JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (adapter_id, environment)
+);
+
 -- =============================================================================
 -- Audit Log (Immutable)
 -- =============================================================================
@@ -112,7 +231,11 @@ CREATE RULE audit_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
 CREATE INDEX idx_adapters_status ON adapters(status);
 CREATE INDEX idx_adapters_owner ON adapters(owner_id);
 CREATE INDEX idx_adapters_base_model ON adapters(base_model_id);
+CREATE INDEX idx_adapter_signatures_adapter ON adapter_signatures(adapter_id, signed_at DESC);
+CREATE INDEX idx_experiments_owner ON experiments(owner_id);
+CREATE INDEX idx_runs_experiment ON runs(experiment_id, name);
 CREATE INDEX idx_experiments_status ON experiment_runs(status);
 CREATE INDEX idx_experiments_adapter ON experiment_runs(adapter_id);
+CREATE INDEX idx_deployments_adapter ON deployments(adapter_id, environment);
 CREATE INDEX idx_audit_event_type ON audit_log(event_type);
 CREATE INDEX idx_audit_created_at ON audit_log(created_at);
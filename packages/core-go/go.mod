@@ -0,0 +1,18 @@
+module openlora/core
+
+go 1.21
+
+require (
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	google.golang.org/grpc v1.60.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/net v0.16.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20231002182017-d307bd883b97 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
@@ -0,0 +1,68 @@
+// Package jsonbody decodes JSON request bodies strictly: the body is size
+// capped, unknown fields and trailing data are rejected, and errors read
+// well enough to return to the client as a 400.
+package jsonbody
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBytes is the largest body Decode accepts.
+const DefaultMaxBytes = 1 << 20
+
+// Decode reads r's body into v, allowing at most DefaultMaxBytes. An empty
+// body returns io.EOF unchanged so callers can treat the body as optional.
+func Decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return DecodeLimit(w, r, v, DefaultMaxBytes)
+}
+
+// DecodeLimit is Decode with a caller-chosen size limit.
+func DecodeLimit(w http.ResponseWriter, r *http.Request, v interface{}, maxBytes int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return describe(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return describe(err)
+		}
+		return errors.New("request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// describe turns a decoding error into a message fit for the client.
+func describe(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+
+	switch {
+	case err == io.EOF:
+		return io.EOF
+	case errors.As(err, &maxErr):
+		return fmt.Errorf("request body exceeds %d bytes", maxErr.Limit)
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("malformed JSON: unexpected end of body")
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return fmt.Errorf("field %q has the wrong type (got %s)", typeErr.Field, typeErr.Value)
+		}
+		return fmt.Errorf("request body has the wrong type (got %s)", typeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return err
+}
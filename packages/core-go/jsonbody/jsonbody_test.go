@@ -0,0 +1,69 @@
+package jsonbody
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type job struct {
+	Name     string `json:"name"`
+	Replicas int    `json:"replicas"`
+}
+
+func decode(body string, maxBytes int64) (job, error) {
+	var v job
+	r := httptest.NewRequest("POST", "/jobs", strings.NewReader(body))
+	err := DecodeLimit(httptest.NewRecorder(), r, &v, maxBytes)
+	return v, err
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		maxBytes int64
+		want     string
+	}{
+		{"oversized", `{"name": "` + strings.Repeat("x", 64) + `"}`, 32, "request body exceeds 32 bytes"},
+		{"oversized after the first value", `{"name": "a"} ` + strings.Repeat(" ", 64), 32, "request body exceeds 32 bytes"},
+		{"unknown field", `{"name": "a", "replcias": 3}`, DefaultMaxBytes, `unknown field "replcias"`},
+		{"wrong type", `{"replicas": "three"}`, DefaultMaxBytes, `field "replicas" has the wrong type (got string)`},
+		{"not an object", `[1, 2]`, DefaultMaxBytes, "request body has the wrong type (got array)"},
+		{"malformed", `{"name": }`, DefaultMaxBytes, "malformed JSON at offset 10"},
+		{"truncated", `{"name": "a"`, DefaultMaxBytes, "malformed JSON: unexpected end of body"},
+		{"trailing value", `{"name": "a"} {"name": "b"}`, DefaultMaxBytes, "request body must contain a single JSON value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decode(tt.body, tt.maxBytes); err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeAccepts(t *testing.T) {
+	got, err := decode(`{"name": "a", "replicas": 3}`+"\n", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != (job{Name: "a", Replicas: 3}) {
+		t.Fatalf("decoded %+v", got)
+	}
+
+	// Decode applies the default cap
+	var v job
+	big := `{"name": "` + strings.Repeat("x", DefaultMaxBytes) + `"}`
+	r := httptest.NewRequest("POST", "/jobs", strings.NewReader(big))
+	if err := Decode(httptest.NewRecorder(), r, &v); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("body over DefaultMaxBytes = %v, want a size error", err)
+	}
+
+	// An empty body is left to the caller
+	if _, err := decode("", DefaultMaxBytes); !errors.Is(err, io.EOF) {
+		t.Fatalf("empty body = %v, want io.EOF", err)
+	}
+}
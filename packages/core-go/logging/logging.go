@@ -0,0 +1,53 @@
+// Package logging configures structured logging for OpenLoRA services.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// ParseLevel maps a LOG_LEVEL value to a slog level. Unknown values fall
+// back to info.
+func ParseLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// New returns a logger writing to w at the given level, as JSON when format
+// is "json" and as key=value text otherwise. Every record carries the
+// service name.
+func New(w io.Writer, service, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: ParseLevel(level)}
+
+	var h slog.Handler
+	if strings.EqualFold(format, "json") {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(h).With("service", service)
+}
+
+// Setup configures the default logger from LOG_LEVEL and LOG_FORMAT and
+// returns it. Output from the standard log package is routed through it.
+func Setup(service string) *slog.Logger {
+	logger := New(os.Stderr, service, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Fatal logs at error level and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestInfoLevelSuppressesDebug(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "metrics", "info", "json")
+	logger.Debug("noisy detail")
+	logger.Info("started", "port", 8085)
+	logger.Warn("slow")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want info and warn only:\n%s", len(lines), buf.String())
+	}
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["msg"] != "started" || rec["level"] != "INFO" || rec["service"] != "metrics" {
+		t.Fatalf("first record = %v, want the info line tagged with the service", rec)
+	}
+}
+
+func TestLevelsAndFormats(t *testing.T) {
+	tests := []struct {
+		level string
+		debug bool
+		warn  bool
+	}{
+		{"debug", true, true},
+		{"DEBUG", true, true},
+		{"", false, true},
+		{"bogus", false, true},
+		{"error", false, false},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := New(&buf, "svc", tt.level, "text")
+		logger.Debug("d")
+		logger.Warn("w")
+		out := buf.String()
+		if strings.Contains(out, "msg=d") != tt.debug || strings.Contains(out, "msg=w") != tt.warn {
+			t.Errorf("level %q logged %q", tt.level, out)
+		}
+		if out != "" && !strings.Contains(out, "service=svc") {
+			t.Errorf("level %q: text output %q lacks the service", tt.level, out)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package negotiate writes HTTP responses as msgpack or JSON depending on
+// the client's Accept header. Both encodings use the structs' json tags
+// for field names, so one set of types serves either format.
+package negotiate
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ContentTypeMsgpack is the media type msgpack responses are sent with.
+const ContentTypeMsgpack = "application/msgpack"
+
+// AcceptsMsgpack reports whether the client asked for a msgpack response.
+func AcceptsMsgpack(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == ContentTypeMsgpack || mediaType == "application/x-msgpack" {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes v as msgpack when negotiated via Accept, and as JSON
+// otherwise. The status line has usually gone out by the time encoding
+// fails, so errors are logged rather than returned.
+func Write(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Add("Vary", "Accept")
+
+	var err error
+	if AcceptsMsgpack(r) {
+		w.Header().Set("Content-Type", ContentTypeMsgpack)
+		enc := msgpack.NewEncoder(w)
+		enc.SetCustomStructTag("json")
+		err = enc.Encode(v)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(v)
+	}
+	if err != nil {
+		slog.Warn("Failed to encode response", "path", r.URL.Path, "error", err)
+	}
+}
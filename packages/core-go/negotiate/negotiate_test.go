@@ -0,0 +1,108 @@
+package negotiate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type payload struct {
+	JobID string `json:"job_id"`
+}
+
+func TestWriteNegotiates(t *testing.T) {
+	for _, tc := range []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "application/json"},
+		{"application/json", "application/json"},
+		{"application/msgpack", ContentTypeMsgpack},
+		{"text/html, application/x-msgpack;q=0.9", ContentTypeMsgpack},
+	} {
+		req := httptest.NewRequest("GET", "/jobs", nil)
+		req.Header.Set("Accept", tc.accept)
+		rec := httptest.NewRecorder()
+		Write(rec, req, payload{JobID: "job-1"})
+
+		if got := rec.Header().Get("Content-Type"); got != tc.contentType {
+			t.Fatalf("Accept %q: Content-Type = %q, want %q", tc.accept, got, tc.contentType)
+		}
+		var out map[string]interface{}
+		var err error
+		if tc.contentType == ContentTypeMsgpack {
+			err = msgpack.Unmarshal(rec.Body.Bytes(), &out)
+		} else {
+			err = json.Unmarshal(rec.Body.Bytes(), &out)
+		}
+		if err != nil || out["job_id"] != "job-1" {
+			t.Fatalf("Accept %q: body = %v, %v; want job_id field from the json tag", tc.accept, out, err)
+		}
+	}
+}
+
+type stats struct {
+	Workers   int                `json:"workers"`
+	Load      float64            `json:"load"`
+	Queues    map[string]int     `json:"queues"`
+	Jobs      []payload          `json:"jobs"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Nested    *struct{ OK bool } `json:"nested"`
+}
+
+// fetch GETs url with the given Accept header and decodes the response
+// according to its Content-Type.
+func fetch(t *testing.T, url, accept string) stats {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept", accept)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var out stats
+	if resp.Header.Get("Content-Type") == ContentTypeMsgpack {
+		dec := msgpack.NewDecoder(resp.Body)
+		dec.SetCustomStructTag("json")
+		err = dec.Decode(&out)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&out)
+	}
+	if err != nil {
+		t.Fatalf("Accept %q: decode: %v", accept, err)
+	}
+	out.UpdatedAt = out.UpdatedAt.UTC()
+	return out
+}
+
+func TestMsgpackMatchesJSON(t *testing.T) {
+	want := stats{
+		Workers:   3,
+		Load:      0.75,
+		Queues:    map[string]int{"high": 2, "low": 7},
+		Jobs:      []payload{{JobID: "job-1"}, {JobID: "job-2"}},
+		UpdatedAt: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		Nested:    &struct{ OK bool }{OK: true},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, want)
+	}))
+	defer srv.Close()
+
+	fromJSON := fetch(t, srv.URL, "application/json")
+	fromMsgpack := fetch(t, srv.URL, ContentTypeMsgpack)
+	if !reflect.DeepEqual(fromMsgpack, fromJSON) {
+		t.Fatalf("msgpack decoded to %+v\nJSON decoded to %+v", fromMsgpack, fromJSON)
+	}
+	if !reflect.DeepEqual(fromJSON, want) {
+		t.Fatalf("decoded %+v, want %+v", fromJSON, want)
+	}
+}
@@ -0,0 +1,84 @@
+package grpcauth
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"openlora/core/svcauth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial serves the standard health service behind ServerOptions(secret)
+// and connects to it with extra dial options.
+func dial(t *testing.T, secret string, opts ...grpc.DialOption) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(ServerOptions(secret)...)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	opts = append(opts,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+	)
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestSignedCallsAccepted(t *testing.T) {
+	client := dial(t, "s3cret", DialOptions(svcauth.NewSigner("s3cret"))...)
+	ctx := context.Background()
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("unary: %v", err)
+	}
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+}
+
+func TestUnsignedOrMissignedCallsRejected(t *testing.T) {
+	for name, opts := range map[string][]grpc.DialOption{
+		"unsigned":     nil,
+		"wrong secret": DialOptions(svcauth.NewSigner("other")),
+	} {
+		client := dial(t, "s3cret", opts...)
+		ctx := context.Background()
+
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("%s unary: err = %v, want Unauthenticated", name, err)
+		}
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("%s stream: err = %v, want Unauthenticated", name, err)
+		}
+	}
+}
+
+func TestEmptySecretDisablesVerification(t *testing.T) {
+	client := dial(t, "")
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package svcauth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"openlora/core/clock"
+)
+
+var epoch = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newVerifiedHandler wraps an OK handler in a verifier for secret whose
+// clock is fixed at epoch.
+func newVerifiedHandler(secret string) http.Handler {
+	v := NewVerifier(secret)
+	v.SetClock(clock.NewFake(epoch))
+	return v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+}
+
+// signedAt returns a request signed with secret at time at.
+func signedAt(secret string, at time.Time, method, target string) *http.Request {
+	signer := NewSigner(secret)
+	signer.SetClock(clock.NewFake(at))
+	r := httptest.NewRequest(method, target, nil)
+	signer.Sign(r)
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	unsigned := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+	noSignature := signedAt("s3cret", epoch, http.MethodGet, "/jobs")
+	noSignature.Header.Del(SignatureHeader)
+	otherPath := signedAt("s3cret", epoch, http.MethodGet, "/jobs")
+	otherPath.URL.Path = "/admin"
+	otherMethod := signedAt("s3cret", epoch, http.MethodGet, "/jobs")
+	otherMethod.Method = http.MethodDelete
+	garbled := signedAt("s3cret", epoch, http.MethodGet, "/jobs")
+	garbled.Header.Set(SignatureHeader, "not-hex")
+
+	tests := []struct {
+		name string
+		r    *http.Request
+		want int
+	}{
+		{"valid", signedAt("s3cret", epoch, http.MethodPost, "/jobs?x=1"), http.StatusOK},
+		{"within skew", signedAt("s3cret", epoch.Add(-4*time.Minute), http.MethodGet, "/jobs"), http.StatusOK},
+		{"missing", unsigned, http.StatusUnauthorized},
+		{"missing signature header", noSignature, http.StatusUnauthorized},
+		{"wrong secret", signedAt("other", epoch, http.MethodGet, "/jobs"), http.StatusUnauthorized},
+		{"path changed", otherPath, http.StatusUnauthorized},
+		{"method changed", otherMethod, http.StatusUnauthorized},
+		{"malformed signature", garbled, http.StatusUnauthorized},
+		{"replayed stale", signedAt("s3cret", epoch.Add(-10*time.Minute), http.MethodGet, "/jobs"), http.StatusUnauthorized},
+		{"from the future", signedAt("s3cret", epoch.Add(10*time.Minute), http.MethodGet, "/jobs"), http.StatusUnauthorized},
+		{"health unsigned", httptest.NewRequest(http.MethodGet, "/health", nil), http.StatusOK},
+	}
+
+	h := newVerifiedHandler("s3cret")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, tt.r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	v := NewVerifier("s3cret")
+	v.SetClock(clock.NewFake(epoch))
+	v.SetMaxSkew(time.Minute)
+
+	if err := v.Verify(httptest.NewRequest(http.MethodGet, "/jobs", nil)); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("unsigned: %v, want ErrMissingSignature", err)
+	}
+	if err := v.Verify(signedAt("other", epoch, http.MethodGet, "/jobs")); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("wrong secret: %v, want ErrBadSignature", err)
+	}
+	if err := v.Verify(signedAt("s3cret", epoch.Add(-2*time.Minute), http.MethodGet, "/jobs")); !errors.Is(err, ErrStaleRequest) {
+		t.Errorf("stale: %v, want ErrStaleRequest", err)
+	}
+	if err := v.Verify(signedAt("s3cret", epoch.Add(-30*time.Second), http.MethodGet, "/jobs")); err != nil {
+		t.Errorf("fresh: %v", err)
+	}
+}
+
+func TestTransportSignsRequests(t *testing.T) {
+	srv := httptest.NewServer(Middleware("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name   string
+		client *http.Client
+		want   int
+	}{
+		{"signed", &http.Client{Transport: NewSigner("s3cret").Transport(nil)}, http.StatusOK},
+		{"plain", http.DefaultClient, http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/adapters/a%2Fb", nil)
+			resp, err := tt.client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if req.Header.Get(SignatureHeader) != "" {
+				t.Fatal("transport modified the caller's request")
+			}
+		})
+	}
+}
+
+func TestMiddlewareDisabledWithoutSecret(t *testing.T) {
+	w := httptest.NewRecorder()
+	Middleware("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 with verification disabled", w.Code)
+	}
+}